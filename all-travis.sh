@@ -131,12 +131,19 @@ checkout snappy-go    $branch $default
 checkout websocket    $branch $default
 checkout walletapp    $branch $default
 
+# dependencies outside FactomProject, fetched by go get
+go get -u github.com/golang/protobuf/proto
+go get -u google.golang.org/grpc
+go get -u golang.org/x/crypto/pbkdf2
+
 echo "
 ******************************************************** 
 *     Compiling fctwallet, the cli, and factomd
 ******************************************************** 
 "
 compile FactomCode/factomd   || exit 1
+compile FactomCode/factomsign || exit 1
+compile FactomCode/factomload || exit 1
 compile fctwallet            || exit 1
 compile factom-cli           || exit 1
 compile walletapp            || exit 1
@@ -0,0 +1,89 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package anchor
+
+import (
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/util"
+)
+
+// With AnchorEvery set over 1, a bitcoin anchor is sent only for the dir
+// block ending a range of AnchorEvery dir blocks. It writes the merkle root
+// of the KeyMRs of the dir blocks of the range, which are confirmed
+// together with the dir block ending it.
+
+// anchorEvery returns the number of dir blocks of a bitcoin anchor
+func anchorEvery() uint32 {
+	n := util.ReadConfig().Btc.AnchorEvery
+	if n < 1 {
+		return 1
+	}
+	if n > common.MaxAnchorRange {
+		return common.MaxAnchorRange
+	}
+	return uint32(n)
+}
+
+// isRangeEnd tells if the dir block at height ends an anchor range
+func isRangeEnd(height uint32) bool {
+	return (height+1)%anchorEvery() == 0
+}
+
+// rangeEnd returns the height of the dir block ending the range of the dir
+// block at height
+func rangeEnd(height uint32) uint32 {
+	n := anchorEvery()
+	return height - height%n + n - 1
+}
+
+// rangeMembers returns the dir blocks before the one at height in its anchor
+// range, oldest first. The range stops after a dir block already confirmed
+// or sent in its own anchor, so no dir block is in two anchors.
+func rangeMembers(height uint32) ([]*common.DirBlockInfo, error) {
+	members := make([]*common.DirBlockInfo, 0)
+	for h := height; h > 0 && uint32(len(members))+1 < anchorEvery(); h-- {
+		info, err := dirBlockInfoByHeight(h - 1)
+		if err != nil {
+			return nil, err
+		}
+		if info.BTCConfirmed || isSent(info) {
+			break
+		}
+		members = append([]*common.DirBlockInfo{info}, members...)
+	}
+	return members, nil
+}
+
+// anchorPayload returns the OP_RETURN data of the bitcoin anchor of the dir
+// block, with the merkle root of its range
+func anchorPayload(keyMR *common.Hash, height uint32) ([]byte, error) {
+	members, err := rangeMembers(height)
+	if err != nil {
+		return nil, err
+	}
+	keyMRs := make([]*common.Hash, 0, len(members)+1)
+	for _, m := range members {
+		keyMRs = append(keyMRs, m.DBMerkleRoot)
+	}
+	keyMRs = append(keyMRs, keyMR)
+	return common.AnchorPayload(height-uint32(len(members)), height, common.AnchorMerkleRoot(keyMRs)), nil
+}
+
+func dirBlockInfoByHeight(height uint32) (*common.DirBlockInfo, error) {
+	dbHash, err := db.FetchDBHashByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	info, err := db.FetchDirBlockInfoByHash(dbHash)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, fmt.Errorf("no dir block info at height %d", height)
+	}
+	return info, nil
+}
@@ -16,6 +16,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/FactomProject/go-spew/spew"
@@ -28,12 +30,14 @@ import (
 
 	"github.com/FactomProject/FactomCode/common"
 	"github.com/FactomProject/FactomCode/database"
+	"github.com/FactomProject/FactomCode/p2p"
 	"github.com/FactomProject/FactomCode/util"
 	factomwire "github.com/FactomProject/btcd/wire"
 )
 
 var (
 	balances            []balance // unspent balance & address & its WIF
+	walletMutex         sync.Mutex
 	cfg                 *util.FactomdConfig
 	dclient, wclient    *btcrpcclient.Client
 	fee                 btcutil.Amount                  // tx fee for written into btc
@@ -41,10 +45,12 @@ var (
 	db                  database.Db
 	walletLocked        bool
 	reAnchorAfter       = 10 // hours. For anchors that do not get bitcoin callback info for over 10 hours, then re-anchor them.
-	reAnchorCheckEvery  = 1  // hour. do re-anchor check every 1 hour.
 	defaultAddress      btcutil.Address
 	confirmationsNeeded int
 
+	// lastAnchoredHeight is the highest dir block confirmed in btc, -1 if none
+	lastAnchoredHeight int64 = -1
+
 	//Server Private key for milestone 1
 	serverPrivKey common.PrivateKey
 
@@ -67,8 +73,14 @@ type AnchorRecord struct {
 	AnchorRecordVer int
 	DBHeight        uint32
 	KeyMR           string
+	DBHash          string `json:",omitempty"`
 	RecordHeight    uint32
 
+	// An aggregated anchor covers the dir blocks from FirstDBHeight to
+	// DBHeight, and writes MerkleRoot, the merkle root of their KeyMRs
+	FirstDBHeight uint32 `json:",omitempty"`
+	MerkleRoot    string `json:",omitempty"`
+
 	Bitcoin struct {
 		Address     string //"1HLoD9E4SDFFPDiYfNYnkBLQ85Y51J3Zb1",
 		TXID        string //"9b0fc92260312ce44e74ef369f5c66bbb85848f2eddd5a7a1cde251e54ccfdd5", BTC Hash - in reverse byte order
@@ -76,12 +88,39 @@ type AnchorRecord struct {
 		BlockHash   string //"00000000000000000cc14eacfc7057300aea87bed6fee904fd8e1c1f3dc008d4", BTC Hash - in reverse byte order
 		Offset      int32  //87
 	}
+
+	// Ethereum is set in the records of the ethereum anchors
+	Ethereum *EthereumAnchor `json:",omitempty"`
+}
+
+// EthereumAnchor is the ethereum transaction of an anchor
+type EthereumAnchor struct {
+	Account     string
+	Contract    string `json:",omitempty"`
+	TXID        string
+	BlockHeight int64
+	BlockHash   string
+	Offset      int64
+}
+
+// PlaceAnchor anchors the dir block into the blockchains enabled in the
+// config. Bitcoin anchors are sent only at the end of an anchor range.
+func PlaceAnchor(hash *common.Hash, blockHeight uint32) {
+	if cfg.Btc.Enabled && isRangeEnd(blockHeight) {
+		SendRawTransactionToBTC(hash, blockHeight)
+	}
+	if cfg.Eth.Enabled {
+		SendTransactionToETH(hash, blockHeight)
+	}
 }
 
 // SendRawTransactionToBTC is the main function used to anchor factom
 // dir block hash to bitcoin blockchain
 func SendRawTransactionToBTC(hash *common.Hash, blockHeight uint32) (*wire.ShaHash, error) {
 	anchorLog.Debug("SendRawTransactionToBTC: hash=", hash.String(), ", dir block height=", blockHeight) //strconv.FormatUint(blockHeight, 10))
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
 	dirBlockInfo, err := sanityCheck(hash)
 	if err != nil {
 		return nil, err
@@ -90,12 +129,22 @@ func SendRawTransactionToBTC(hash *common.Hash, blockHeight uint32) (*wire.ShaHa
 }
 
 func doTransaction(hash *common.Hash, blockHeight uint32, dirBlockInfo *common.DirBlockInfo) (*wire.ShaHash, error) {
-	b := balances[0]
-	balances = balances[1:]
+	if err := updateFee(); err != nil {
+		return nil, err
+	}
+	payload, err := anchorPayload(hash, blockHeight)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get anchor range: %s", err)
+	}
+	b, err := selectBalance()
+	if err != nil {
+		return nil, err
+	}
 	anchorLog.Info("new balances.len=", len(balances))
 
-	msgtx, err := createRawTransaction(b, hash.Bytes(), blockHeight)
+	msgtx, err := createRawTransaction(b, payload)
 	if err != nil {
+		balances = append(balances, b)
 		return nil, fmt.Errorf("cannot create Raw Transaction: %s", err)
 	}
 
@@ -103,16 +152,25 @@ func doTransaction(hash *common.Hash, blockHeight uint32, dirBlockInfo *common.D
 	if err != nil {
 		return nil, fmt.Errorf("cannot send Raw Transaction: %s", err)
 	}
+	addOutputs(msgtx, shaHash, b)
+	checkFunds()
 
 	if dirBlockInfo != nil {
 		dirBlockInfo.BTCTxHash = toHash(shaHash)
+		// the tx hash is kept to find the anchor after a restart
+		if err := db.InsertDirBlockInfo(dirBlockInfo); err != nil {
+			anchorLog.Error("cannot save btc tx hash of dir block ", blockHeight, ": ", err)
+		}
+		anchorTxs[hash.String()] = &sentAnchor{msgtx: msgtx, sent: time.Now()}
 	}
 
 	return shaHash, nil
 }
 
 func sanityCheck(hash *common.Hash) (*common.DirBlockInfo, error) {
+	dirBlockInfoMutex.Lock()
 	dirBlockInfo := dirBlockInfoMap[hash.String()]
+	dirBlockInfoMutex.Unlock()
 	if dirBlockInfo == nil {
 		s := fmt.Sprintf("Anchor Error: hash %s does not exist in dirBlockInfoMap.\n", hash.String())
 		anchorLog.Error(s)
@@ -140,16 +198,16 @@ func sanityCheck(hash *common.Hash) (*common.DirBlockInfo, error) {
 	}
 	if len(balances) == 0 {
 		s := fmt.Sprintf("\n\n$$$ WARNING: No balance in your wallet. No anchoring for now.\n")
-		anchorLog.Warning(s)
+		anchorLog.Alert(s)
 		return nil, errors.New(s)
 	}
 	return dirBlockInfo, nil
 }
 
-func createRawTransaction(b balance, hash []byte, blockHeight uint32) (*wire.MsgTx, error) {
+func createRawTransaction(b balance, payload []byte) (*wire.MsgTx, error) {
 	msgtx := wire.NewMsgTx()
 
-	if err := addTxOuts(msgtx, b, hash, blockHeight); err != nil {
+	if err := addTxOuts(msgtx, b, payload); err != nil {
 		return nil, fmt.Errorf("cannot addTxOuts: %s", err)
 	}
 
@@ -207,15 +265,10 @@ func addTxIn(msgtx *wire.MsgTx, b balance) error {
 	return nil
 }
 
-func addTxOuts(msgtx *wire.MsgTx, b balance, hash []byte, blockHeight uint32) error {
-	anchorHash, err := prependBlockHeight(blockHeight, hash)
-	if err != nil {
-		anchorLog.Errorf("ScriptBuilder error: %v\n", err)
-	}
-
+func addTxOuts(msgtx *wire.MsgTx, b balance, payload []byte) error {
 	builder := txscript.NewScriptBuilder()
 	builder.AddOp(txscript.OP_RETURN)
-	builder.AddData(anchorHash)
+	builder.AddData(payload)
 
 	// latest routine from Conformal btcsuite returns 2 parameters, not 1... not sure what to do for people with the old conformal libraries :(
 	opReturn, err := builder.Script()
@@ -345,6 +398,7 @@ func InitAnchor(ldb database.Db, q chan factomwire.FtmInternalMsg, serverKey com
 	db = ldb
 	inMsgQ = q
 	serverPrivKey = serverKey
+	cfg = util.ReadConfig()
 
 	var err error
 	dirBlockInfoMap, err = db.FetchAllUnconfirmedDirBlockInfo()
@@ -354,6 +408,26 @@ func InitAnchor(ldb database.Db, q chan factomwire.FtmInternalMsg, serverKey com
 	}
 	anchorLog.Debug("init dirBlockInfoMap.len=", len(dirBlockInfoMap))
 
+	if infos, err := db.FetchAllDirBlockInfo(); err == nil {
+		for _, dirBlockInfo := range infos {
+			if dirBlockInfo.BTCConfirmed {
+				setLastAnchoredHeight(dirBlockInfo.DBHeight)
+			}
+		}
+	}
+
+	readAnchorConfig()
+	util.OnReload(reloadFeeSettings, "Btc.AnchorEvery", "Btc.BtcTransFee", "Btc.MaxBtcTransFee", "Btc.FeeTargetBlocks",
+		"Btc.MinUTXOs", "Btc.LowBalanceAlert", "Eth.MaxGasPriceGwei", "Eth.GasLimit")
+	if cfg.Eth.Enabled {
+		if err = initEthereum(); err != nil {
+			anchorLog.Error(err.Error())
+		}
+	}
+	if !cfg.Btc.Enabled {
+		return
+	}
+
 	if err = InitRPCClient(); err != nil {
 		anchorLog.Error(err.Error())
 		return
@@ -364,7 +438,10 @@ func InitAnchor(ldb database.Db, q chan factomwire.FtmInternalMsg, serverKey com
 		return
 	}
 
-	ticker := time.NewTicker(time.Hour * time.Duration(reAnchorCheckEvery))
+	// the anchors sent before a restart are checked now
+	go checkForReAnchor()
+
+	ticker := time.NewTicker(pendingCheckEvery)
 	go func() {
 		for _ = range ticker.C {
 			// check init rpc client
@@ -374,6 +451,10 @@ func InitAnchor(ldb database.Db, q chan factomwire.FtmInternalMsg, serverKey com
 				}
 			}
 			checkForReAnchor()
+
+			walletMutex.Lock()
+			manageUTXO()
+			walletMutex.Unlock()
 		}
 	}()
 	return
@@ -393,18 +474,7 @@ func InitRPCClient() error {
 	certHomePathBtcd := cfg.Btc.CertHomePathBtcd
 	rpcBtcdHost := cfg.Btc.RpcBtcdHost
 	confirmationsNeeded = cfg.Anchor.ConfirmationsNeeded
-
-	//Added anchor parameters
-	var err error
-	serverECKey, err = common.NewPrivateKeyFromHex(cfg.Anchor.ServerECKey)
-	if err != nil {
-		panic("Cannot parse Server EC Key from configuration file: " + err.Error())
-	}
-	anchorChainID, err = common.HexToHash(cfg.Anchor.AnchorChainID)
-	anchorLog.Debug("anchorChainID: ", anchorChainID)
-	if err != nil || anchorChainID == nil {
-		panic("Cannot parse Server AnchorChainID from configuration file: " + err.Error())
-	}
+	readAnchorConfig()
 
 	// Connect to local btcwallet RPC server using websockets.
 	ntfnHandlers := createBtcwalletNotificationHandlers()
@@ -421,6 +491,7 @@ func InitRPCClient() error {
 		Pass:         rpcClientPass,
 		Certificates: certs,
 	}
+	setProxy(connCfg)
 	wclient, err = btcrpcclient.New(connCfg, &ntfnHandlers)
 	if err != nil {
 		return fmt.Errorf("cannot create rpc client for btcwallet: %s\n", err)
@@ -442,6 +513,7 @@ func InitRPCClient() error {
 		Pass:         rpcClientPass,
 		Certificates: certs,
 	}
+	setProxy(dconnCfg)
 	dclient, err = btcrpcclient.New(dconnCfg, &dntfnHandlers)
 	if err != nil {
 		return fmt.Errorf("cannot create rpc client for btcd: %s\n", err)
@@ -451,6 +523,44 @@ func InitRPCClient() error {
 	return nil
 }
 
+// setProxy makes the rpc client connect through the proxy of the config
+func setProxy(connCfg *btcrpcclient.ConnConfig) {
+	if p2p.Proxied(connCfg.Host) {
+		connCfg.Proxy = cfg.P2p.Proxy
+		connCfg.ProxyUser = cfg.P2p.ProxyUser
+		connCfg.ProxyPass = cfg.P2p.ProxyPass
+	}
+}
+
+// readAnchorConfig reads the keys and chain used to record the anchors
+func readAnchorConfig() {
+	var err error
+	serverECKey, err = common.NewPrivateKeyFromHex(cfg.Anchor.ServerECKey)
+	if err != nil {
+		panic("Cannot parse Server EC Key from configuration file: " + err.Error())
+	}
+	anchorChainID, err = common.HexToHash(cfg.Anchor.AnchorChainID)
+	anchorLog.Debug("anchorChainID: ", anchorChainID)
+	if err != nil || anchorChainID == nil {
+		panic("Cannot parse Server AnchorChainID from configuration file: " + err.Error())
+	}
+}
+
+// reloadFeeSettings updates the fee after the fee settings are reloaded. The
+// other settings which can be reloaded are read from util.ReadConfig each
+// time they are used, as cfg keeps the values of the start.
+func reloadFeeSettings(c *util.FactomdConfig) error {
+	if !c.Btc.Enabled || dclient == nil {
+		return nil
+	}
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+	if err := updateFee(); err != nil {
+		anchorLog.Warning(err.Error())
+	}
+	return nil
+}
+
 func unlockWallet(timeoutSecs int64) error {
 	err := wclient.WalletPassphrase(cfg.Btc.WalletPassphrase, int64(timeoutSecs))
 	if err != nil {
@@ -467,12 +577,21 @@ func initWallet() error {
 	err := updateUTXO()
 	if err == nil && len(balances) > 0 {
 		defaultAddress = balances[0].address
+		updateFee()
+		manageUTXO()
 	}
 	return err
 }
 
 func updateUTXO() error {
 	anchorLog.Info("updateUTXO: walletLocked=", walletLocked)
+	// the change of our transactions is kept until the wallet lists it
+	unconfirmed := make([]balance, 0)
+	for _, b := range balances {
+		if b.unspentResult.Confirmations == 0 {
+			unconfirmed = append(unconfirmed, b)
+		}
+	}
 	balances = make([]balance, 0, 200)
 	//if walletLocked {
 	err := unlockWallet(int64(6)) //600
@@ -487,14 +606,11 @@ func updateUTXO() error {
 	}
 	anchorLog.Info("updateUTXO: unspentResults.len=", len(unspentResults))
 
-	if len(unspentResults) > 0 {
-		var i int
-		for _, b := range unspentResults {
-			if b.Amount > fee.ToBTC() {
-				balances = append(balances, balance{unspentResult: b})
-				i++
-			}
-		}
+	// the outputs too small for an anchor are kept to be consolidated
+	listed := make(map[string]bool, len(unspentResults))
+	for _, b := range unspentResults {
+		balances = append(balances, balance{unspentResult: b})
+		listed[fmt.Sprintf("%s:%d", b.TxID, b.Vout)] = true
 	}
 	anchorLog.Info("updateUTXO: balances.len=", len(balances))
 
@@ -513,6 +629,13 @@ func updateUTXO() error {
 		//anchorLog.Infof("balance[%d]=%s \n", i, spew.Sdump(balances[i]))
 	}
 
+	for _, b := range unconfirmed {
+		if !listed[fmt.Sprintf("%s:%d", b.unspentResult.TxID, b.unspentResult.Vout)] {
+			balances = append(balances, b)
+		}
+	}
+	checkFunds()
+
 	//time.Sleep(1 * time.Second)
 	return nil
 }
@@ -537,38 +660,19 @@ func prependBlockHeight(height uint32, hash []byte) ([]byte, error) {
 func saveDirBlockInfo(transaction *btcutil.Tx, details *btcjson.BlockDetails) {
 	anchorLog.Debug("in saveDirBlockInfo")
 	var saved = false
+	var confirmed *common.DirBlockInfo
+	dirBlockInfoMutex.Lock()
 	for _, dirBlockInfo := range dirBlockInfoMap {
 		if bytes.Compare(dirBlockInfo.BTCTxHash.Bytes(), transaction.Sha().Bytes()) == 0 {
-			dirBlockInfo.BTCTxOffset = int32(details.Index)
-			dirBlockInfo.BTCBlockHeight = details.Height
-			btcBlockHash, _ := wire.NewShaHashFromStr(details.Hash)
-			dirBlockInfo.BTCBlockHash = toHash(btcBlockHash)
-			dirBlockInfo.BTCConfirmed = true
-			db.InsertDirBlockInfo(dirBlockInfo)
-			delete(dirBlockInfoMap, dirBlockInfo.DBMerkleRoot.String())
-			anchorLog.Infof("In saveDirBlockInfo, dirBlockInfo:%s saved to db\n", spew.Sdump(dirBlockInfo))
-			saved = true
-
-			anchorRec := new(AnchorRecord)
-			anchorRec.AnchorRecordVer = 1
-			anchorRec.DBHeight = dirBlockInfo.DBHeight
-			anchorRec.KeyMR = dirBlockInfo.DBMerkleRoot.String()
-			_, recordHeight, _ := db.FetchBlockHeightCache()
-			anchorRec.RecordHeight = uint32(recordHeight)
-			anchorRec.Bitcoin.Address = defaultAddress.String()
-			anchorRec.Bitcoin.TXID = transaction.Sha().String()
-			anchorRec.Bitcoin.BlockHeight = details.Height
-			anchorRec.Bitcoin.BlockHash = details.Hash
-			anchorRec.Bitcoin.Offset = int32(details.Index)
-			anchorLog.Info("anchor.record saved: " + spew.Sdump(anchorRec))
-
-			err := submitEntryToAnchorChain(anchorRec)
-			if err != nil {
-				anchorLog.Error("Error in writing anchor into anchor chain: ", err.Error())
-			}
+			confirmed = dirBlockInfo
 			break
 		}
 	}
+	dirBlockInfoMutex.Unlock()
+	if confirmed != nil {
+		confirmAnchor(confirmed, transaction.Sha().String(), details.Hash, details.Height, int32(details.Index))
+		saved = true
+	}
 	// This happends when there's a double spending (for dir block 122 and its btc tx)
 	// (see https://www.blocktrail.com/BTC/tx/ac82f4173259494b22f4987f1e18608f38f1ff756fb4a3c637dfb5565aa5e6cf)
 	// or tx mutation / malleated
@@ -578,6 +682,81 @@ func saveDirBlockInfo(transaction *btcutil.Tx, details *btcjson.BlockDetails) {
 	}
 }
 
+// confirmAnchor saves the btc block of the anchor of the dir block and
+// records the anchor in the anchor chain
+func confirmAnchor(dirBlockInfo *common.DirBlockInfo, txid, blockHash string, blockHeight, offset int32) {
+	dirBlockInfo.BTCTxOffset = offset
+	dirBlockInfo.BTCBlockHeight = blockHeight
+	btcBlockHash, _ := wire.NewShaHashFromStr(blockHash)
+	dirBlockInfo.BTCBlockHash = toHash(btcBlockHash)
+	dirBlockInfo.BTCConfirmed = true
+	db.InsertDirBlockInfo(dirBlockInfo)
+
+	// the dir blocks of the range are confirmed with the one ending it
+	members, err := rangeMembers(dirBlockInfo.DBHeight)
+	if err != nil {
+		anchorLog.Error("cannot get the anchor range of dir block ", dirBlockInfo.DBHeight, ": ", err)
+	}
+	keyMRs := make([]*common.Hash, 0, len(members)+1)
+	for _, m := range members {
+		m.BTCTxHash = dirBlockInfo.BTCTxHash
+		m.BTCTxOffset = offset
+		m.BTCBlockHeight = blockHeight
+		m.BTCBlockHash = dirBlockInfo.BTCBlockHash
+		m.BTCConfirmed = true
+		db.InsertDirBlockInfo(m)
+		keyMRs = append(keyMRs, m.DBMerkleRoot)
+	}
+	keyMRs = append(keyMRs, dirBlockInfo.DBMerkleRoot)
+	setLastAnchoredHeight(dirBlockInfo.DBHeight)
+
+	dirBlockInfoMutex.Lock()
+	for _, m := range members {
+		delete(dirBlockInfoMap, m.DBMerkleRoot.String())
+	}
+	delete(dirBlockInfoMap, dirBlockInfo.DBMerkleRoot.String())
+	dirBlockInfoMutex.Unlock()
+	walletMutex.Lock()
+	delete(anchorTxs, dirBlockInfo.DBMerkleRoot.String())
+	walletMutex.Unlock()
+	anchorLog.Infof("In saveDirBlockInfo, dirBlockInfo:%s saved to db\n", spew.Sdump(dirBlockInfo))
+
+	anchorRec := new(AnchorRecord)
+	anchorRec.AnchorRecordVer = 1
+	anchorRec.DBHeight = dirBlockInfo.DBHeight
+	anchorRec.KeyMR = dirBlockInfo.DBMerkleRoot.String()
+	anchorRec.DBHash = dirBlockInfo.DBHash.String()
+	_, recordHeight, _ := db.FetchBlockHeightCache()
+	anchorRec.RecordHeight = uint32(recordHeight)
+	if len(members) > 0 {
+		anchorRec.FirstDBHeight = members[0].DBHeight
+		anchorRec.MerkleRoot = common.AnchorMerkleRoot(keyMRs).String()
+	}
+	anchorRec.Bitcoin.Address = defaultAddress.String()
+	anchorRec.Bitcoin.TXID = txid
+	anchorRec.Bitcoin.BlockHeight = blockHeight
+	anchorRec.Bitcoin.BlockHash = blockHash
+	anchorRec.Bitcoin.Offset = offset
+	anchorLog.Info("anchor.record saved: " + spew.Sdump(anchorRec))
+
+	err = submitEntryToAnchorChain(anchorRec)
+	if err != nil {
+		anchorLog.Error("Error in writing anchor into anchor chain: ", err.Error())
+	}
+}
+
+func setLastAnchoredHeight(height uint32) {
+	if int64(height) > atomic.LoadInt64(&lastAnchoredHeight) {
+		atomic.StoreInt64(&lastAnchoredHeight, int64(height))
+	}
+}
+
+// LastAnchoredHeight returns the height of the highest dir block confirmed
+// in btc, or -1 if none is
+func LastAnchoredHeight() int64 {
+	return atomic.LoadInt64(&lastAnchoredHeight)
+}
+
 func toHash(txHash *wire.ShaHash) *common.Hash {
 	h := new(common.Hash)
 	h.SetBytes(txHash.Bytes())
@@ -588,16 +767,7 @@ func toHash(txHash *wire.ShaHash) *common.Hash {
 // when a new Directory Block is saved to db
 func UpdateDirBlockInfoMap(dirBlockInfo *common.DirBlockInfo) {
 	anchorLog.Debug("UpdateDirBlockInfoMap: ", spew.Sdump(dirBlockInfo))
+	dirBlockInfoMutex.Lock()
 	dirBlockInfoMap[dirBlockInfo.DBMerkleRoot.String()] = dirBlockInfo
-}
-
-func checkForReAnchor() {
-	timeNow := time.Now().Unix()
-	time0 := 60 * 60 * reAnchorAfter
-	for _, dirBlockInfo := range dirBlockInfoMap {
-		if timeNow-dirBlockInfo.Timestamp > int64(time0) {
-			anchorLog.Debug("re-anchor: ")
-			SendRawTransactionToBTC(dirBlockInfo.DBMerkleRoot, dirBlockInfo.DBHeight)
-		}
-	}
+	dirBlockInfoMutex.Unlock()
 }
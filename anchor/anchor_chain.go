@@ -12,9 +12,11 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/p2p"
 	"github.com/FactomProject/FactomCode/util"
 	factomwire "github.com/FactomProject/btcd/wire"
 )
@@ -75,11 +77,13 @@ func submitEntryToAnchorChain(aRecord *AnchorRecord) error {
 	// create a CommitEntry msg and send it to the local inmsgQ
 	cm := factomwire.NewMsgCommitEntry()
 	cm.CommitEntry = commit
+	p2p.NoteOrigin(cm, p2p.OriginAnchor)
 	inMsgQ <- cm
 
 	// create a RevealEntry msg and send it to the local inmsgQ
 	rm := factomwire.NewMsgRevealEntry()
 	rm.Entry = entry
+	p2p.NoteOrigin(rm, p2p.OriginAnchor)
 	inMsgQ <- rm
 
 	return nil
@@ -93,3 +97,40 @@ func milliTime() (r []byte) {
 	binary.Write(buf, binary.BigEndian, m)
 	return buf.Bytes()[2:]
 }
+
+// AnchorRecordEntry is an anchor record read from an entry of the anchor
+// chain, with its signature by the server key
+type AnchorRecordEntry struct {
+	EntryHash      string
+	Record         *AnchorRecord
+	Signature      string
+	SignatureValid bool
+}
+
+// ParseAnchorRecordEntry reads the anchor record of an anchor chain entry,
+// the record JSON followed by the hex of its signature, and checks the
+// signature with the server public key
+func ParseAnchorRecordEntry(entry *common.Entry, serverPubKey common.PublicKey) (*AnchorRecordEntry, error) {
+	content := entry.Content
+	if len(content) < 128 {
+		return nil, errors.New("anchor record entry is too short")
+	}
+	jsonARecord := content[:len(content)-128]
+	jsonSigBytes := content[len(content)-128:]
+	sig, err := hex.DecodeString(string(jsonSigBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	e := new(AnchorRecordEntry)
+	e.EntryHash = entry.Hash().String()
+	e.Record = new(AnchorRecord)
+	if err := json.Unmarshal(jsonARecord, e.Record); err != nil {
+		return nil, err
+	}
+	e.Signature = string(jsonSigBytes)
+	if serverPubKey.Key != nil {
+		e.SignatureValid = common.VerifySlice(serverPubKey.Key[:], jsonARecord, sig)
+	}
+	return e, nil
+}
@@ -0,0 +1,430 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package anchor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/p2p"
+	"github.com/FactomProject/FactomCode/util"
+)
+
+// An ethereum anchor is a transaction from the configured account of the
+// ethereum node, to the anchor contract or to the account itself, with the
+// same data as the OP_RETURN of the bitcoin anchor: "Fa", the 6 byte dir
+// block height and the dir block key MR. The account key is kept by the
+// ethereum node, which signs each anchor with the passphrase read from
+// PassphraseFile through personal_sendTransaction, so the account is never
+// left unlocked. The anchors waiting for confirmations are saved in
+// PendingFile, so they are checked again after a restart.
+
+// ethCheckEvery is how often the pending ethereum anchors are checked
+const ethCheckEvery = 15 * time.Second
+
+var (
+	ethClient     *ethRPCClient
+	ethPassphrase string
+
+	// ethPending are the sent ethereum anchors waiting for confirmations,
+	// by dir block key MR
+	ethPending = make(map[string]*ethAnchor)
+	ethMutex   sync.Mutex
+
+	// ethLastAnchoredHeight is the highest dir block confirmed in ethereum,
+	// -1 if none
+	ethLastAnchoredHeight int64 = -1
+)
+
+type ethAnchor struct {
+	dbHeight uint32
+	keyMR    *common.Hash
+	txHash   string // empty until the transaction is sent
+	sent     time.Time
+	sending  bool
+}
+
+// savedEthAnchor is an ethereum anchor in the PendingFile
+type savedEthAnchor struct {
+	DBHeight uint32
+	KeyMR    string
+	TxHash   string
+	Sent     time.Time
+}
+
+type ethReceipt struct {
+	TransactionHash  string
+	TransactionIndex string
+	BlockHash        string
+	BlockNumber      string
+	Status           string
+}
+
+// initEthereum reads the passphrase of the anchor account, connects to the
+// ethereum node, reloads the pending anchors and starts checking their
+// confirmations
+func initEthereum() error {
+	if cfg.Eth.Account == "" {
+		return errors.New("No ethereum account configured for anchoring")
+	}
+	passphrase, err := readEthPassphrase(cfg.Eth.PassphraseFile)
+	if err != nil {
+		return err
+	}
+	ethPassphrase = passphrase
+	ethClient = &ethRPCClient{url: cfg.Eth.RpcHost, client: p2p.HTTPClient(30 * time.Second)}
+
+	if err := loadEthAnchors(); err != nil {
+		anchorLog.Error("cannot load the pending ethereum anchors: ", err)
+	}
+	anchorLog.Info("ethereum anchoring from account ", cfg.Eth.Account)
+
+	ticker := time.NewTicker(ethCheckEvery)
+	go func() {
+		for _ = range ticker.C {
+			checkEthAnchors()
+		}
+	}()
+	return nil
+}
+
+// SendTransactionToETH anchors the dir block key MR into ethereum. The
+// anchor is recorded in the anchor chain once it has the configured number
+// of confirmations.
+func SendTransactionToETH(hash *common.Hash, blockHeight uint32) (string, error) {
+	anchorLog.Debug("SendTransactionToETH: hash=", hash.String(), ", dir block height=", blockHeight)
+	if ethClient == nil {
+		s := "ethereum client is not initiated successfully. No ethereum anchoring for now."
+		anchorLog.Warning(s)
+		return "", errors.New(s)
+	}
+
+	a := &ethAnchor{dbHeight: blockHeight, keyMR: hash}
+	ethMutex.Lock()
+	ethPending[hash.String()] = a
+	saveEthAnchors()
+	ethMutex.Unlock()
+
+	if err := sendEthAnchor(a); err != nil {
+		anchorLog.Error("ethereum anchor of dir block ", blockHeight, " not sent: ", err.Error())
+		return "", err
+	}
+	return a.txHash, nil
+}
+
+// sendEthAnchor sends the transaction of the anchor. An anchor that is not
+// sent, because the gas price is over the limit, is sent again by
+// checkEthAnchors.
+func sendEthAnchor(a *ethAnchor) error {
+	ethMutex.Lock()
+	if a.sending {
+		ethMutex.Unlock()
+		return errors.New("anchor is being sent")
+	}
+	a.sending = true
+	ethMutex.Unlock()
+	defer func() {
+		ethMutex.Lock()
+		a.sending = false
+		ethMutex.Unlock()
+	}()
+
+	data, err := prependBlockHeight(a.dbHeight, a.keyMR.Bytes())
+	if err != nil {
+		return err
+	}
+
+	var price string
+	if err := ethClient.call("eth_gasPrice", &price); err != nil {
+		return fmt.Errorf("cannot get gas price: %s", err)
+	}
+	gasPrice, ok := new(big.Int).SetString(strings.TrimPrefix(price, "0x"), 16)
+	if !ok {
+		return fmt.Errorf("invalid gas price: %s", price)
+	}
+	limits := util.ReadConfig().Eth
+	if limits.MaxGasPriceGwei > 0 {
+		max := new(big.Int).Mul(new(big.Int).SetUint64(limits.MaxGasPriceGwei), big.NewInt(1e9))
+		if gasPrice.Cmp(max) > 0 {
+			return fmt.Errorf("gas price of %s wei is over the limit of %d gwei", gasPrice, limits.MaxGasPriceGwei)
+		}
+	}
+
+	to := cfg.Eth.ContractAddress
+	if to == "" {
+		to = cfg.Eth.Account
+	}
+	tx := map[string]string{
+		"from":     cfg.Eth.Account,
+		"to":       to,
+		"data":     "0x" + hex.EncodeToString(data),
+		"gasPrice": "0x" + gasPrice.Text(16),
+	}
+	if limits.GasLimit > 0 {
+		tx["gas"] = "0x" + strconv.FormatUint(limits.GasLimit, 16)
+	} else {
+		var gas string
+		if err := ethClient.call("eth_estimateGas", &gas, tx); err != nil {
+			return fmt.Errorf("cannot estimate gas: %s", err)
+		}
+		tx["gas"] = gas
+	}
+
+	var txHash string
+	if err := ethClient.call("personal_sendTransaction", &txHash, tx, ethPassphrase); err != nil {
+		return fmt.Errorf("cannot send transaction: %s", err)
+	}
+	anchorLog.Info("eth txHash returned: ", txHash, " for dir block ", a.dbHeight)
+
+	ethMutex.Lock()
+	a.txHash = txHash
+	a.sent = time.Now()
+	saveEthAnchors()
+	ethMutex.Unlock()
+	return nil
+}
+
+// readEthPassphrase reads the passphrase of the anchor account from the
+// file, which must not be readable by other users
+func readEthPassphrase(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("No passphrase file configured for the ethereum account")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("ethereum passphrase file %s must not be accessible by other users", path)
+	}
+	p, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(p), "\r\n"), nil
+}
+
+// saveEthAnchors writes the pending anchors to the PendingFile. ethMutex
+// must be held.
+func saveEthAnchors() {
+	if cfg.Eth.PendingFile == "" {
+		return
+	}
+	list := make([]*savedEthAnchor, 0, len(ethPending))
+	for _, a := range ethPending {
+		list = append(list, &savedEthAnchor{
+			DBHeight: a.dbHeight,
+			KeyMR:    a.keyMR.String(),
+			TxHash:   a.txHash,
+			Sent:     a.sent,
+		})
+	}
+	p, err := json.MarshalIndent(list, "", "\t")
+	if err == nil {
+		tmp := cfg.Eth.PendingFile + ".tmp"
+		if err = ioutil.WriteFile(tmp, p, 0600); err == nil {
+			err = os.Rename(tmp, cfg.Eth.PendingFile)
+		}
+	}
+	if err != nil {
+		anchorLog.Error("cannot save the pending ethereum anchors: ", err)
+	}
+}
+
+// loadEthAnchors reads the pending anchors saved before a restart
+func loadEthAnchors() error {
+	if cfg.Eth.PendingFile == "" {
+		return nil
+	}
+	p, err := ioutil.ReadFile(cfg.Eth.PendingFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var list []*savedEthAnchor
+	if err := json.Unmarshal(p, &list); err != nil {
+		return err
+	}
+
+	ethMutex.Lock()
+	defer ethMutex.Unlock()
+	for _, s := range list {
+		keyMR, err := common.HexToHash(s.KeyMR)
+		if err != nil {
+			return err
+		}
+		ethPending[s.KeyMR] = &ethAnchor{dbHeight: s.DBHeight, keyMR: keyMR, txHash: s.TxHash, sent: s.Sent}
+	}
+	anchorLog.Info(len(list), " pending ethereum anchors reloaded")
+	return nil
+}
+
+// checkEthAnchors records the anchors with enough confirmations, and sends
+// again the ones not sent, failed or not mined after reAnchorAfter hours
+func checkEthAnchors() {
+	var number string
+	if err := ethClient.call("eth_blockNumber", &number); err != nil {
+		anchorLog.Error("cannot get ethereum block number: ", err.Error())
+		return
+	}
+	current, err := hexToUint64(number)
+	if err != nil {
+		anchorLog.Error("invalid ethereum block number: ", number)
+		return
+	}
+
+	ethMutex.Lock()
+	anchors := make([]*ethAnchor, 0, len(ethPending))
+	for _, a := range ethPending {
+		anchors = append(anchors, a)
+	}
+	ethMutex.Unlock()
+
+	for _, a := range anchors {
+		ethMutex.Lock()
+		txHash, sent, sending := a.txHash, a.sent, a.sending
+		ethMutex.Unlock()
+		if sending {
+			continue
+		}
+		if txHash == "" {
+			if err := sendEthAnchor(a); err != nil {
+				anchorLog.Warning("ethereum anchor of dir block ", a.dbHeight, " not sent: ", err.Error())
+			}
+			continue
+		}
+
+		receipt := new(ethReceipt)
+		if err := ethClient.call("eth_getTransactionReceipt", &receipt, txHash); err != nil {
+			anchorLog.Error("cannot get receipt of eth tx ", txHash, ": ", err.Error())
+			continue
+		}
+		if receipt == nil {
+			if time.Since(sent) > time.Duration(reAnchorAfter)*time.Hour {
+				anchorLog.Debug("eth re-anchor: ", a.dbHeight)
+				sendEthAnchor(a)
+			}
+			continue
+		}
+		if receipt.Status == "0x0" {
+			anchorLog.Warning("eth tx ", txHash, " of dir block ", a.dbHeight, " failed, re-anchoring")
+			sendEthAnchor(a)
+			continue
+		}
+
+		mined, err := hexToUint64(receipt.BlockNumber)
+		if err != nil || current+1 < mined+uint64(cfg.Eth.ConfirmationsNeeded) {
+			continue
+		}
+		if err := saveEthAnchor(a, receipt); err != nil {
+			anchorLog.Error("Error in writing eth anchor into anchor chain: ", err.Error())
+			continue
+		}
+
+		ethMutex.Lock()
+		delete(ethPending, a.keyMR.String())
+		saveEthAnchors()
+		ethMutex.Unlock()
+		setEthLastAnchoredHeight(a.dbHeight)
+	}
+}
+
+// saveEthAnchor records the confirmed ethereum anchor in the anchor chain
+func saveEthAnchor(a *ethAnchor, receipt *ethReceipt) error {
+	blockHeight, _ := hexToUint64(receipt.BlockNumber)
+	offset, _ := hexToUint64(receipt.TransactionIndex)
+
+	anchorRec := new(AnchorRecord)
+	anchorRec.AnchorRecordVer = 1
+	anchorRec.DBHeight = a.dbHeight
+	anchorRec.KeyMR = a.keyMR.String()
+	_, recordHeight, _ := db.FetchBlockHeightCache()
+	anchorRec.RecordHeight = uint32(recordHeight)
+	anchorRec.Ethereum = &EthereumAnchor{
+		Account:     cfg.Eth.Account,
+		Contract:    cfg.Eth.ContractAddress,
+		TXID:        receipt.TransactionHash,
+		BlockHeight: int64(blockHeight),
+		BlockHash:   receipt.BlockHash,
+		Offset:      int64(offset),
+	}
+	anchorLog.Info("eth anchor.record saved for dir block ", a.dbHeight)
+	return submitEntryToAnchorChain(anchorRec)
+}
+
+func setEthLastAnchoredHeight(height uint32) {
+	if int64(height) > atomic.LoadInt64(&ethLastAnchoredHeight) {
+		atomic.StoreInt64(&ethLastAnchoredHeight, int64(height))
+	}
+}
+
+// EthLastAnchoredHeight returns the height of the highest dir block
+// confirmed in ethereum, or -1 if none is
+func EthLastAnchoredHeight() int64 {
+	return atomic.LoadInt64(&ethLastAnchoredHeight)
+}
+
+func hexToUint64(s string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+}
+
+// ethRPCClient calls the JSON-RPC api of an ethereum node over http
+type ethRPCClient struct {
+	url    string
+	client *http.Client
+	id     uint64
+}
+
+type ethRPCError struct {
+	Code    int
+	Message string
+}
+
+func (c *ethRPCClient) call(method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = make([]interface{}, 0)
+	}
+	req, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      atomic.AddUint64(&c.id, 1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post(c.url, "application/json", bytes.NewReader(req))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	r := new(struct {
+		Result json.RawMessage
+		Error  *ethRPCError
+	})
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return fmt.Errorf("%s: %s", method, err)
+	}
+	if r.Error != nil {
+		return fmt.Errorf("%s: %s (%d)", method, r.Error.Message, r.Error.Code)
+	}
+	return json.Unmarshal(r.Result, result)
+}
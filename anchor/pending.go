@@ -0,0 +1,182 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package anchor
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/btcsuitereleases/btcd/btcjson"
+	"github.com/btcsuitereleases/btcd/wire"
+)
+
+// The pending anchors are the unconfirmed dir block infos in the database,
+// with the hash of their btc transaction saved when it is sent. They are
+// checked against btcd at startup and every pendingCheckEvery, so an anchor
+// is not lost when the node restarts or the connection to btcd drops
+// before the transaction is confirmed. btcd must run with --txindex to find
+// the transactions mined while the node was down.
+
+const (
+	// pendingCheckEvery is how often the pending anchors are checked
+	pendingCheckEvery = 10 * time.Minute
+
+	// maxAnchorsPerCheck is the most anchors sent by one check, so a long
+	// backlog does not spend the wallet at once
+	maxAnchorsPerCheck = 10
+)
+
+var (
+	dirBlockInfoMutex sync.Mutex
+
+	// anchorTxs are the btc transactions sent for the pending anchors by
+	// dir block key MR, guarded by walletMutex
+	anchorTxs = make(map[string]*sentAnchor)
+)
+
+// sentAnchor is a btc transaction sent for an anchor, to rebroadcast it if
+// it is dropped from the mempool of btcd
+type sentAnchor struct {
+	msgtx *wire.MsgTx
+	sent  time.Time
+}
+
+// checkForReAnchor checks the pending anchors. It sends the anchors never
+// sent, confirms the anchors mined without a notification from btcd,
+// rebroadcasts the dropped transactions, and re-creates the transactions
+// which cannot be rebroadcast or are not mined in reAnchorAfter hours.
+func checkForReAnchor() {
+	if dclient == nil || wclient == nil {
+		return
+	}
+
+	dirBlockInfoMutex.Lock()
+	pending := make([]*common.DirBlockInfo, 0, len(dirBlockInfoMap))
+	heights := make(map[uint32]bool, len(dirBlockInfoMap))
+	for _, dirBlockInfo := range dirBlockInfoMap {
+		pending = append(pending, dirBlockInfo)
+		heights[dirBlockInfo.DBHeight] = true
+	}
+	dirBlockInfoMutex.Unlock()
+	sort.Sort(byDBHeight(pending))
+
+	sent := 0
+	for _, dirBlockInfo := range pending {
+		if sent >= maxAnchorsPerCheck {
+			anchorLog.Info("more pending anchors are left for the next check")
+			return
+		}
+		if checkPendingAnchor(dirBlockInfo, heights) {
+			sent++
+		}
+	}
+}
+
+// checkPendingAnchor checks the btc transaction of the anchor of the dir
+// block, and returns true if it sent a new one. pending are the heights of
+// all the pending anchors.
+func checkPendingAnchor(dirBlockInfo *common.DirBlockInfo, pending map[uint32]bool) bool {
+	keyMR := dirBlockInfo.DBMerkleRoot.String()
+	if !isSent(dirBlockInfo) {
+		if !isRangeEnd(dirBlockInfo.DBHeight) {
+			// the dir block is anchored with the one ending its range,
+			// unless that one was confirmed before AnchorEvery changed
+			end := rangeEnd(dirBlockInfo.DBHeight)
+			if pending[end] {
+				return false
+			}
+			if _, top, err := db.FetchBlockHeightCache(); err != nil || int64(end) > top {
+				return false
+			}
+		}
+		anchorLog.Info("anchoring dir block ", dirBlockInfo.DBHeight, " not anchored before")
+		return reAnchor(dirBlockInfo)
+	}
+
+	txHash, err := wire.NewShaHash(dirBlockInfo.BTCTxHash.Bytes())
+	if err != nil {
+		anchorLog.Error("invalid btc tx hash of dir block ", dirBlockInfo.DBHeight, ": ", err)
+		return false
+	}
+
+	walletMutex.Lock()
+	s := anchorTxs[keyMR]
+	if s == nil {
+		// sent before a restart, the time is counted from now
+		s = &sentAnchor{sent: time.Now()}
+		anchorTxs[keyMR] = s
+	}
+	walletMutex.Unlock()
+
+	tx, err := dclient.GetRawTransactionVerbose(txHash)
+	if rpcErr, ok := err.(*btcjson.RPCError); err != nil && (!ok || rpcErr.Code != btcjson.ErrRPCNoTxInfo) {
+		anchorLog.Error("cannot get btc tx ", txHash, ": ", err)
+		return false
+	}
+	if err != nil {
+		// neither in the mempool nor in a block
+		if s.msgtx != nil {
+			if _, err := dclient.SendRawTransaction(s.msgtx, false); err == nil {
+				anchorLog.Info("rebroadcast btc tx ", txHash, " of dir block ", dirBlockInfo.DBHeight)
+				return false
+			}
+		}
+		anchorLog.Info("btc tx ", txHash, " of dir block ", dirBlockInfo.DBHeight, " was dropped, re-anchoring")
+		return reAnchor(dirBlockInfo)
+	}
+
+	if tx.BlockHash == "" {
+		if time.Since(s.sent) > time.Duration(reAnchorAfter)*time.Hour {
+			anchorLog.Info("btc tx ", txHash, " of dir block ", dirBlockInfo.DBHeight, " is not mined after ",
+				reAnchorAfter, " hours, re-anchoring")
+			return reAnchor(dirBlockInfo)
+		}
+		return false
+	}
+
+	// mined while no notification came from btcd
+	blockHash, err := wire.NewShaHashFromStr(tx.BlockHash)
+	if err != nil {
+		anchorLog.Error("invalid btc block hash ", tx.BlockHash, ": ", err)
+		return false
+	}
+	block, err := dclient.GetBlockVerbose(blockHash, false)
+	if err != nil {
+		anchorLog.Error("cannot get btc block ", tx.BlockHash, ": ", err)
+		return false
+	}
+	for i, txid := range block.Tx {
+		if txid == tx.Txid {
+			anchorLog.Info("btc tx ", txHash, " of dir block ", dirBlockInfo.DBHeight, " is in btc block ", block.Height)
+			confirmAnchor(dirBlockInfo, tx.Txid, tx.BlockHash, int32(block.Height), int32(i))
+			return false
+		}
+	}
+	anchorLog.Error("btc tx ", txHash, " is not in btc block ", tx.BlockHash)
+	return false
+}
+
+// reAnchor sends a new btc transaction for the anchor of the dir block
+func reAnchor(dirBlockInfo *common.DirBlockInfo) bool {
+	_, err := SendRawTransactionToBTC(dirBlockInfo.DBMerkleRoot, dirBlockInfo.DBHeight)
+	if err != nil {
+		anchorLog.Error("cannot re-anchor dir block ", dirBlockInfo.DBHeight, ": ", err)
+		return false
+	}
+	return true
+}
+
+// isSent tells if a btc transaction was sent for the anchor of the dir block
+func isSent(dirBlockInfo *common.DirBlockInfo) bool {
+	return dirBlockInfo.BTCTxHash != nil && !dirBlockInfo.BTCTxHash.IsSameAs(common.NewHash())
+}
+
+type byDBHeight []*common.DirBlockInfo
+
+func (b byDBHeight) Len() int           { return len(b) }
+func (b byDBHeight) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byDBHeight) Less(i, j int) bool { return b[i].DBHeight < b[j].DBHeight }
@@ -0,0 +1,93 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package anchor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/btcsuitereleases/btcd/wire"
+)
+
+// spvProofHeaders is the most headers of the blocks built on the anchor
+// block put in a proof
+const spvProofHeaders = 6
+
+// FetchBitcoinAnchorProof gets from btcd the SPV proof of the confirmed
+// bitcoin anchor of the dir block
+func FetchBitcoinAnchorProof(dirBlockInfo *common.DirBlockInfo) (*common.BitcoinAnchorProof, error) {
+	if !dirBlockInfo.BTCConfirmed {
+		return nil, fmt.Errorf("Dir block %d is not anchored in bitcoin yet", dirBlockInfo.DBHeight)
+	}
+	if dclient == nil {
+		return nil, errors.New("rpc client for btcd is not initiated")
+	}
+
+	blockHash, err := wire.NewShaHash(dirBlockInfo.BTCBlockHash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	block, err := dclient.GetBlock(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get btc block %s: %s", blockHash, err)
+	}
+
+	p := new(common.BitcoinAnchorProof)
+	txids := make([][]byte, 0, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		txids = append(txids, tx.Sha().Bytes())
+		if bytes.Equal(tx.Sha().Bytes(), dirBlockInfo.BTCTxHash.Bytes()) {
+			buf := new(bytes.Buffer)
+			if err := tx.MsgTx().Serialize(buf); err != nil {
+				return nil, err
+			}
+			p.RawTx = buf.Bytes()
+			p.TxIndex = uint32(i)
+		}
+	}
+	if p.RawTx == nil {
+		return nil, fmt.Errorf("btc tx %s is not in block %s", dirBlockInfo.BTCTxHash, blockHash)
+	}
+	if p.MerkleBranch, err = common.BitcoinMerkleBranch(txids, int(p.TxIndex)); err != nil {
+		return nil, err
+	}
+
+	header, err := serializeHeader(&block.MsgBlock().Header)
+	if err != nil {
+		return nil, err
+	}
+	p.Headers = [][]byte{header}
+
+	count, err := dclient.GetBlockCount()
+	if err != nil {
+		return nil, err
+	}
+	for h := int64(dirBlockInfo.BTCBlockHeight) + 1; h <= count && len(p.Headers) <= spvProofHeaders; h++ {
+		hash, err := dclient.GetBlockHash(h)
+		if err != nil {
+			return nil, err
+		}
+		next, err := dclient.GetBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+		header, err := serializeHeader(&next.MsgBlock().Header)
+		if err != nil {
+			return nil, err
+		}
+		p.Headers = append(p.Headers, header)
+	}
+	return p, nil
+}
+
+func serializeHeader(h *wire.BlockHeader) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := h.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
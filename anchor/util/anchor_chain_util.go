@@ -112,17 +112,39 @@ func processAnchorChain() {
 				if err != nil {
 					fmt.Println(err)
 				}
-				dirBlockInfo, _ := anchorChainToDirBlockInfo(aRecord)
-				err = db.InsertDirBlockInfo(dirBlockInfo)
-				if err != nil {
-					fmt.Printf("InsertDirBlockInfo error: %s, DirBlockInfo=%s\n", err, spew.Sdump(dirBlockInfo))
+				// the ethereum anchors have no bitcoin info
+				if aRecord == nil || aRecord.Bitcoin.TXID == "" {
+					continue
+				}
+				insertAnchorRecord(aRecord)
+
+				// an aggregated anchor confirms every dir block of its range
+				for h := aRecord.FirstDBHeight; aRecord.MerkleRoot != "" && h < aRecord.DBHeight; h++ {
+					dblock, err := db.FetchDBlockByHeight(h)
+					if err != nil || dblock == nil {
+						fmt.Printf("err in FetchDBlockByHeight: %d\n", h)
+						continue
+					}
+					dblock.BuildKeyMerkleRoot()
+					member := *aRecord
+					member.DBHeight = h
+					member.KeyMR = dblock.KeyMR.String()
+					insertAnchorRecord(&member)
 				}
-				dirBlockInfoMap[dirBlockInfo.DBHeight] = dirBlockInfo
 			}
 		}
 	}
 }
 
+func insertAnchorRecord(aRecord *anchor.AnchorRecord) {
+	dirBlockInfo, _ := anchorChainToDirBlockInfo(aRecord)
+	err := db.InsertDirBlockInfo(dirBlockInfo)
+	if err != nil {
+		fmt.Printf("InsertDirBlockInfo error: %s, DirBlockInfo=%s\n", err, spew.Sdump(dirBlockInfo))
+	}
+	dirBlockInfoMap[dirBlockInfo.DBHeight] = dirBlockInfo
+}
+
 func anchorChainToDirBlockInfo(aRecord *anchor.AnchorRecord) (*common.DirBlockInfo, error) {
 	dirBlockInfo := new(common.DirBlockInfo)
 	dirBlockInfo.DBHeight = aRecord.DBHeight
@@ -0,0 +1,293 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package anchor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/btcsuitereleases/btcd/btcjson"
+	"github.com/btcsuitereleases/btcd/txscript"
+	"github.com/btcsuitereleases/btcd/wire"
+	"github.com/btcsuitereleases/btcutil"
+)
+
+// The anchor wallet keeps the outputs it can spend in balances, including
+// the change of its own transactions before the change is confirmed, so
+// anchoring does not stop for ConfirmationsNeeded blocks after each anchor.
+// It splits a large output when it has fewer than MinUTXOs outputs, and
+// consolidates the outputs too small to pay for an anchor.
+
+const (
+	// anchorTxSize is the size in bytes of an anchor transaction: one P2PKH
+	// input, the OP_RETURN output and the change output
+	anchorTxSize = 250
+
+	// inputSize and outputSize are the bytes a P2PKH input and output add
+	// to a transaction
+	inputSize  = 148
+	outputSize = 34
+
+	// anchorsPerOutput is the number of anchors an output made by a split
+	// pays for
+	anchorsPerOutput = 10
+
+	// maxConsolidate is the most outputs spent by a consolidation
+	maxConsolidate = 50
+)
+
+// errLowFunds is returned when no output can pay for an anchor
+var errLowFunds = errors.New("anchor wallet has no output to pay for an anchor")
+
+// feeRate returns the fee per kB estimated by btcd to get in a block within
+// FeeTargetBlocks blocks. The configured BtcTransFee per anchor is used as
+// the minimum, and when btcd has no estimate.
+func feeRate() btcutil.Amount {
+	btc := util.ReadConfig().Btc
+	min, _ := btcutil.NewAmount(btc.BtcTransFee * 1000 / anchorTxSize)
+	if dclient == nil {
+		return min
+	}
+	estimate, err := dclient.EstimateFee(int64(btc.FeeTargetBlocks))
+	if err != nil || estimate <= 0 {
+		return min
+	}
+	rate, err := btcutil.NewAmount(estimate)
+	if err != nil || rate < min {
+		return min
+	}
+	return rate
+}
+
+// feeFor returns the fee of a transaction of size bytes at the rate
+func feeFor(rate btcutil.Amount, size int) btcutil.Amount {
+	return rate * btcutil.Amount(size) / 1000
+}
+
+// updateFee sets the fee of the next anchor. It fails, with an alert, when
+// fees spike over MaxBtcTransFee.
+func updateFee() error {
+	f := feeFor(feeRate(), anchorTxSize)
+	max, _ := btcutil.NewAmount(util.ReadConfig().Btc.MaxBtcTransFee)
+	if max > 0 && f > max {
+		anchorLog.Alertf("Anchor fee of %s is over the limit of %s, anchoring waits for lower fees", f, max)
+		return fmt.Errorf("anchor fee of %s is over the limit of %s", f, max)
+	}
+	fee = f
+	return nil
+}
+
+// selectBalance removes from balances and returns the smallest output that
+// pays for an anchor with change
+func selectBalance() (balance, error) {
+	best := -1
+	for i, b := range balances {
+		amount, _ := btcutil.NewAmount(b.unspentResult.Amount)
+		if amount <= fee {
+			continue
+		}
+		if best < 0 || b.unspentResult.Amount < balances[best].unspentResult.Amount {
+			best = i
+		}
+	}
+	if best < 0 {
+		anchorLog.Alert("Anchor wallet has no output to pay the fee of ", fee, ", add funds to anchor again")
+		return balance{}, errLowFunds
+	}
+
+	b := balances[best]
+	balances = append(balances[:best], balances[best+1:]...)
+	return b, nil
+}
+
+// addOutputs adds the outputs of a transaction sent by the anchor wallet to
+// our address to balances
+func addOutputs(msgtx *wire.MsgTx, txHash *wire.ShaHash, b balance) {
+	script := hex.EncodeToString(mustPayToAddrScript(b.address))
+	for i, out := range msgtx.TxOut {
+		if hex.EncodeToString(out.PkScript) != script {
+			continue
+		}
+		balances = append(balances, balance{
+			unspentResult: btcjson.ListUnspentResult{
+				TxID:         txHash.String(),
+				Vout:         uint32(i),
+				Address:      b.address.String(),
+				ScriptPubKey: script,
+				Amount:       btcutil.Amount(out.Value).ToBTC(),
+			},
+			address: b.address,
+			wif:     b.wif,
+		})
+	}
+}
+
+func mustPayToAddrScript(address btcutil.Address) []byte {
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		anchorLog.Error("cannot create txout script: ", err)
+	}
+	return script
+}
+
+// checkFunds alerts when the anchor wallet is running low
+func checkFunds() {
+	var total btcutil.Amount
+	for _, b := range balances {
+		amount, _ := btcutil.NewAmount(b.unspentResult.Amount)
+		total += amount
+	}
+	low, _ := btcutil.NewAmount(util.ReadConfig().Btc.LowBalanceAlert)
+	if total < low {
+		anchorLog.Alertf("Anchor wallet balance of %s is below %s, about %d anchors are left",
+			total, low, int64(total/(fee+1)))
+	}
+}
+
+// manageUTXO consolidates the outputs too small for an anchor and splits a
+// large output when the wallet has fewer than MinUTXOs outputs
+func manageUTXO() {
+	if len(balances) == 0 || dclient == nil || fee == 0 {
+		return
+	}
+	rate := feeRate()
+
+	var small []balance
+	for _, b := range balances {
+		amount, _ := btcutil.NewAmount(b.unspentResult.Amount)
+		if amount <= 2*fee {
+			small = append(small, b)
+		}
+	}
+	if len(small) > 1 {
+		if len(small) > maxConsolidate {
+			small = small[:maxConsolidate]
+		}
+		if err := consolidateUTXO(small, rate); err != nil {
+			anchorLog.Error("cannot consolidate anchor outputs: ", err)
+		}
+	}
+
+	if min := util.ReadConfig().Btc.MinUTXOs; len(balances) < min {
+		if err := splitUTXO(min-len(balances), rate); err != nil {
+			anchorLog.Error("cannot split anchor outputs: ", err)
+		}
+	}
+}
+
+// consolidateUTXO spends the outputs into one output
+func consolidateUTXO(inputs []balance, rate btcutil.Amount) error {
+	var total btcutil.Amount
+	for _, b := range inputs {
+		amount, _ := btcutil.NewAmount(b.unspentResult.Amount)
+		total += amount
+	}
+	txFee := feeFor(rate, 10+len(inputs)*inputSize+outputSize)
+	if total <= txFee+fee {
+		// not worth the fee yet
+		return nil
+	}
+
+	msgtx := wire.NewMsgTx()
+	msgtx.AddTxOut(wire.NewTxOut(int64(total-txFee), mustPayToAddrScript(inputs[0].address)))
+	if err := sendFromBalances(msgtx, inputs); err != nil {
+		return err
+	}
+	anchorLog.Info("consolidated ", len(inputs), " anchor outputs")
+	return nil
+}
+
+// splitUTXO splits the largest output into up to n outputs paying for
+// anchorsPerOutput anchors each
+func splitUTXO(n int, rate btcutil.Amount) error {
+	sort.Sort(sort.Reverse(byAmount(balances)))
+	b := balances[0]
+	amount, _ := btcutil.NewAmount(b.unspentResult.Amount)
+
+	part := fee * anchorsPerOutput
+	for ; n > 1; n-- {
+		// the rest pays for an anchor too
+		if amount > part*btcutil.Amount(n)+feeFor(rate, 10+inputSize+(n+1)*outputSize)+fee {
+			break
+		}
+	}
+	if n < 2 {
+		return nil
+	}
+	txFee := feeFor(rate, 10+inputSize+(n+1)*outputSize)
+
+	msgtx := wire.NewMsgTx()
+	script := mustPayToAddrScript(b.address)
+	for i := 0; i < n; i++ {
+		msgtx.AddTxOut(wire.NewTxOut(int64(part), script))
+	}
+	// the rest stays in one output
+	msgtx.AddTxOut(wire.NewTxOut(int64(amount-part*btcutil.Amount(n)-txFee), script))
+
+	balances = balances[1:]
+	if err := sendFromBalances(msgtx, []balance{b}); err != nil {
+		balances = append(balances, b)
+		return err
+	}
+	anchorLog.Info("split an anchor output into ", n+1, " outputs")
+	return nil
+}
+
+// sendFromBalances signs the transaction spending the inputs, sends it and
+// adds its outputs to balances
+func sendFromBalances(msgtx *wire.MsgTx, inputs []balance) error {
+	unspent := make([]btcjson.ListUnspentResult, len(inputs))
+	for i, b := range inputs {
+		prevTxHash, err := wire.NewShaHashFromStr(b.unspentResult.TxID)
+		if err != nil {
+			return fmt.Errorf("cannot get sha hash from str: %s", err)
+		}
+		msgtx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(prevTxHash, b.unspentResult.Vout), nil))
+		unspent[i] = b.unspentResult
+	}
+	for i, b := range inputs {
+		subscript, err := hex.DecodeString(b.unspentResult.ScriptPubKey)
+		if err != nil {
+			return fmt.Errorf("cannot decode scriptPubKey: %s", err)
+		}
+		sigScript, err := txscript.SignatureScript(msgtx, i, subscript, txscript.SigHashAll, b.wif.PrivKey, true)
+		if err != nil {
+			return fmt.Errorf("cannot create scriptSig: %s", err)
+		}
+		msgtx.TxIn[i].SignatureScript = sigScript
+	}
+	if err := validateMsgTx(msgtx, unspent); err != nil {
+		return fmt.Errorf("cannot validateMsgTx: %s", err)
+	}
+
+	shaHash, err := sendRawTransaction(msgtx)
+	if err != nil {
+		return err
+	}
+
+	// the inputs are not spendable anymore
+	spent := make(map[string]bool, len(inputs))
+	for _, b := range inputs {
+		spent[fmt.Sprintf("%s:%d", b.unspentResult.TxID, b.unspentResult.Vout)] = true
+	}
+	kept := balances[:0]
+	for _, b := range balances {
+		if !spent[fmt.Sprintf("%s:%d", b.unspentResult.TxID, b.unspentResult.Vout)] {
+			kept = append(kept, b)
+		}
+	}
+	balances = kept
+	addOutputs(msgtx, shaHash, inputs[0])
+	return nil
+}
+
+type byAmount []balance
+
+func (b byAmount) Len() int           { return len(b) }
+func (b byAmount) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byAmount) Less(i, j int) bool { return b[i].unspentResult.Amount < b[j].unspentResult.Amount }
@@ -176,6 +176,8 @@ func (b *AdminBlock) UnmarshalBinaryData(data []byte) (newData []byte, err error
 			b.ABEntries[i] = new(DBSignatureEntry)
 		} else if newData[0] == TYPE_MINUTE_NUM {
 			b.ABEntries[i] = new(EndOfMinuteEntry)
+		} else {
+			return newData, fmt.Errorf("Unknown admin block entry type %d", newData[0])
 		}
 		newData, err = b.ABEntries[i].UnmarshalBinaryData(newData)
 		if err != nil {
@@ -0,0 +1,35 @@
+package common_test
+
+import (
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/common"
+)
+
+func TestUnknownAdminBlockEntry(t *testing.T) {
+	chain := new(AdminChain)
+	chain.ChainID = NewHash()
+	chain.ChainID.SetBytes(ADMIN_CHAINID)
+
+	ab, err := CreateAdminBlock(chain, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ab.AddEndOfMinuteMarker(1)
+	ab.Header.MessageCount = uint32(len(ab.ABEntries))
+	ab.Header.BodySize = uint32(ab.MarshalledSize() - ab.Header.MarshalledSize())
+
+	b, err := ab.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := new(AdminBlock).UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// an unknown entry type is an error, not a panic
+	b[len(b)-2] = 0xff
+	if err := new(AdminBlock).UnmarshalBinary(b); err == nil {
+		t.Error("Unknown entry type accepted")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/lightclient"
+)
+
+// MaxAnchorRange is the most directory blocks an aggregated anchor covers
+const MaxAnchorRange = lightclient.MaxAnchorRange
+
+// AnchorRange is the aggregated anchor a directory block is in. The anchor
+// writes MR, the merkle root of the KeyMRs of the directory blocks from
+// FirstHeight to LastHeight in height order, instead of a single KeyMR.
+// Branch leads from the KeyMR of the directory block to MR.
+type AnchorRange struct {
+	FirstHeight uint32
+	LastHeight  uint32
+	MR          *Hash
+	Branch      []*MerkleStep
+}
+
+// NewAnchorRange returns the range of the directory block at height, with
+// the KeyMRs of the blocks from first on
+func NewAnchorRange(first, height uint32, keyMRs []*Hash) (*AnchorRange, error) {
+	if height < first || int(height-first) >= len(keyMRs) {
+		return nil, fmt.Errorf("Height %d is not in the %d directory blocks from %d", height, len(keyMRs), first)
+	}
+	r := new(AnchorRange)
+	r.FirstHeight = first
+	r.LastHeight = first + uint32(len(keyMRs)) - 1
+	r.MR = AnchorMerkleRoot(keyMRs)
+	branch, err := MerkleBranch(keyMRs, int(height-first))
+	if err != nil {
+		return nil, err
+	}
+	r.Branch = branch
+	return r, nil
+}
+
+// Verify checks that the directory block keyMR at height leads to MR
+func (r *AnchorRange) Verify(keyMR *Hash, height uint32) error {
+	return r.light().Verify(keyMR.light(), height)
+}
+
+// Payload returns the OP_RETURN data of the anchor of the range
+func (r *AnchorRange) Payload() []byte {
+	return AnchorPayload(r.FirstHeight, r.LastHeight, r.MR)
+}
+
+// AnchorMerkleRoot returns the merkle root of the KeyMRs of an aggregated
+// anchor, which is the KeyMR itself for a single directory block
+func AnchorMerkleRoot(keyMRs []*Hash) *Hash {
+	merkles := BuildMerkleTreeStore(keyMRs)
+	return merkles[len(merkles)-1]
+}
+
+// AnchorPayload returns the OP_RETURN data anchoring the directory blocks
+// from first to last with root. The anchor of one directory block is "Fa",
+// its 6 byte height and its KeyMR; an aggregated anchor is "FA", the 6 byte
+// first and last heights and the merkle root of their KeyMRs.
+func AnchorPayload(first, last uint32, root *Hash) []byte {
+	return lightclient.AnchorPayload(first, last, root.light())
+}
+
+func (r *AnchorRange) light() *lightclient.AnchorRange {
+	if r == nil {
+		return nil
+	}
+	return &lightclient.AnchorRange{
+		FirstHeight: r.FirstHeight,
+		LastHeight:  r.LastHeight,
+		MR:          r.MR.light(),
+		Branch:      lightBranch(r.Branch),
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// BalanceState is a snapshot of the entry credit and factoid balances after
+// the directory block at DBHeight has been processed. It lets a node restart
+// from the snapshot instead of replaying the whole chain.
+type BalanceState struct {
+	DBHeight    uint32
+	ECBalances  map[string]int32  // keyed by string(pubkey[:])
+	FCTBalances map[string]uint64 // keyed by string(address[:])
+}
+
+var _ Printable = (*BalanceState)(nil)
+var _ BinaryMarshallable = (*BalanceState)(nil)
+
+func NewBalanceState() *BalanceState {
+	b := new(BalanceState)
+	b.ECBalances = make(map[string]int32)
+	b.FCTBalances = make(map[string]uint64)
+	return b
+}
+
+func (b *BalanceState) MarshalledSize() uint64 {
+	return uint64(4 + 4 + len(b.ECBalances)*(32+4) + 4 + len(b.FCTBalances)*(32+8))
+}
+
+func (b *BalanceState) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	binary.Write(buf, binary.BigEndian, b.DBHeight)
+
+	// the keys are sorted so the same balances always marshal the same way
+	ecKeys := make([]string, 0, len(b.ECBalances))
+	for k := range b.ECBalances {
+		if len(k) != 32 {
+			return nil, fmt.Errorf("Invalid entry credit key length: %d", len(k))
+		}
+		ecKeys = append(ecKeys, k)
+	}
+	sort.Strings(ecKeys)
+
+	binary.Write(buf, binary.BigEndian, uint32(len(ecKeys)))
+	for _, k := range ecKeys {
+		buf.WriteString(k)
+		binary.Write(buf, binary.BigEndian, b.ECBalances[k])
+	}
+
+	fctKeys := make([]string, 0, len(b.FCTBalances))
+	for k := range b.FCTBalances {
+		if len(k) != 32 {
+			return nil, fmt.Errorf("Invalid factoid address length: %d", len(k))
+		}
+		fctKeys = append(fctKeys, k)
+	}
+	sort.Strings(fctKeys)
+
+	binary.Write(buf, binary.BigEndian, uint32(len(fctKeys)))
+	for _, k := range fctKeys {
+		buf.WriteString(k)
+		binary.Write(buf, binary.BigEndian, b.FCTBalances[k])
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (b *BalanceState) UnmarshalBinaryData(data []byte) (newData []byte, err error) {
+	buf := bytes.NewBuffer(data)
+	var count uint32
+
+	if err = binary.Read(buf, binary.BigEndian, &b.DBHeight); err != nil {
+		return
+	}
+
+	if err = binary.Read(buf, binary.BigEndian, &count); err != nil {
+		return
+	}
+	b.ECBalances = make(map[string]int32, count)
+	for i := uint32(0); i < count; i++ {
+		var v int32
+		k := buf.Next(32)
+		if len(k) != 32 {
+			err = fmt.Errorf("Could not read entry credit key")
+			return
+		}
+		if err = binary.Read(buf, binary.BigEndian, &v); err != nil {
+			return
+		}
+		b.ECBalances[string(k)] = v
+	}
+
+	if err = binary.Read(buf, binary.BigEndian, &count); err != nil {
+		return
+	}
+	b.FCTBalances = make(map[string]uint64, count)
+	for i := uint32(0); i < count; i++ {
+		var v uint64
+		k := buf.Next(32)
+		if len(k) != 32 {
+			err = fmt.Errorf("Could not read factoid address")
+			return
+		}
+		if err = binary.Read(buf, binary.BigEndian, &v); err != nil {
+			return
+		}
+		b.FCTBalances[string(k)] = v
+	}
+
+	newData = buf.Bytes()
+	return
+}
+
+func (b *BalanceState) UnmarshalBinary(data []byte) (err error) {
+	_, err = b.UnmarshalBinaryData(data)
+	return
+}
+
+func (b *BalanceState) JSONByte() ([]byte, error) {
+	return EncodeJSON(b)
+}
+
+func (b *BalanceState) JSONString() (string, error) {
+	return EncodeJSONString(b)
+}
+
+func (b *BalanceState) JSONBuffer(buf *bytes.Buffer) error {
+	return EncodeJSONToBuffer(b, buf)
+}
+
+func (b *BalanceState) Spew() string {
+	return Spew(b)
+}
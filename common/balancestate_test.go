@@ -0,0 +1,50 @@
+package common_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/FactomProject/FactomCode/common"
+)
+
+func TestBalanceStateMarshal(t *testing.T) {
+	fmt.Printf("---\nTestBalanceStateMarshal\n---\n")
+
+	b := common.NewBalanceState()
+	b.DBHeight = 42
+	b.ECBalances[strings.Repeat("a", 32)] = 100
+	b.ECBalances[strings.Repeat("b", 32)] = -3
+	b.FCTBalances[strings.Repeat("c", 32)] = 2000000000
+
+	p, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(p)) != b.MarshalledSize() {
+		t.Errorf("MarshalledSize %d does not match marshalled length %d", b.MarshalledSize(), len(p))
+	}
+
+	b2 := common.NewBalanceState()
+	if err := b2.UnmarshalBinary(p); err != nil {
+		t.Fatal(err)
+	}
+	if b2.DBHeight != b.DBHeight || len(b2.ECBalances) != 2 || len(b2.FCTBalances) != 1 {
+		t.Errorf("BalanceState does not match after unmarshalbinary")
+	}
+	if b2.ECBalances[strings.Repeat("b", 32)] != -3 || b2.FCTBalances[strings.Repeat("c", 32)] != 2000000000 {
+		t.Errorf("balances do not match after unmarshalbinary")
+	}
+
+	// marshalling has to be deterministic
+	p2, _ := b2.MarshalBinary()
+	if !bytes.Equal(p, p2) {
+		t.Errorf("BalanceState marshalled differently after unmarshalbinary")
+	}
+
+	b.ECBalances["short"] = 1
+	if _, err := b.MarshalBinary(); err == nil {
+		t.Errorf("MarshalBinary should fail on an invalid key")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// A block stream is a sequence of records of a 1 byte block type, the 4 byte
+// big endian length of the block and the binary block. The blocks of a dir
+// block height are written in the order they are stored: the dir block, the
+// admin, entry credit and factoid blocks, then the entries of each entry
+// block followed by the entry block.
+const (
+	StreamDBlock byte = iota + 1
+	StreamABlock
+	StreamECBlock
+	StreamFBlock
+	StreamEBlock
+	StreamEntry
+)
+
+// maxStreamBlockSize bounds the blocks read from a stream
+const maxStreamBlockSize = 64 * 1024 * 1024
+
+// WriteStreamBlock writes a block record to a block stream
+func WriteStreamBlock(w io.Writer, blockType byte, data []byte) error {
+	header := make([]byte, 5)
+	header[0] = blockType
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// ReadStreamBlock reads the next block record of a block stream. It returns
+// io.EOF at the end of the stream.
+func ReadStreamBlock(r io.Reader) (blockType byte, data []byte, err error) {
+	header := make([]byte, 5)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	blockType = header[0]
+	if blockType < StreamDBlock || blockType > StreamEntry {
+		return 0, nil, fmt.Errorf("Unknown block type %d in stream", blockType)
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxStreamBlockSize {
+		return 0, nil, fmt.Errorf("Block of %d bytes in stream exceeds the limit", size)
+	}
+	data = make([]byte, size)
+	if _, err = io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return blockType, data, nil
+}
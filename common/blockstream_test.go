@@ -0,0 +1,40 @@
+package common_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/common"
+)
+
+func TestBlockStream(t *testing.T) {
+	blocks := [][]byte{[]byte("dblock"), {}, []byte("entry")}
+	types := []byte{StreamDBlock, StreamABlock, StreamEntry}
+
+	buf := new(bytes.Buffer)
+	for i, b := range blocks {
+		if err := WriteStreamBlock(buf, types[i], b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, b := range blocks {
+		typ, data, err := ReadStreamBlock(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != types[i] || !bytes.Equal(data, b) {
+			t.Errorf("Read block %d of type %d %x, wrote type %d %x", i, typ, data, types[i], b)
+		}
+	}
+	if _, _, err := ReadStreamBlock(buf); err != io.EOF {
+		t.Errorf("Read %v at the end of the stream, not EOF", err)
+	}
+
+	WriteStreamBlock(buf, StreamEBlock, []byte("eblock"))
+	buf.Truncate(buf.Len() - 1)
+	if _, _, err := ReadStreamBlock(buf); err != io.ErrUnexpectedEOF {
+		t.Errorf("Read %v from a truncated block, not ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Checkpoint is a known good directory block hash at a block height
+type Checkpoint struct {
+	DBHeight uint32
+	Hash     string
+}
+
+// builtinCheckpoints are the known good directory blocks of each network,
+// keyed by network id. New checkpoints must be appended in height order.
+var builtinCheckpoints = map[uint32][]Checkpoint{
+	NETWORK_ID_EB: {
+		{0, GENESIS_DIR_BLOCK_HASH},
+	},
+}
+
+// checkpoints are the built-in checkpoints with the ones of the config
+var checkpoints = builtinCheckpoints
+
+// SetCheckpoints sets the checkpoints of the network to the built-in ones
+// and the ones given as height:hash, such as those the config of the node
+// ships. It must be called before the checkpoints are used.
+func SetCheckpoints(networkID uint32, specs []string) error {
+	list := append([]Checkpoint(nil), builtinCheckpoints[networkID]...)
+	for _, spec := range specs {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		c, err := parseCheckpoint(spec)
+		if err != nil {
+			return err
+		}
+		if h, ok := checkpointAt(list, c.DBHeight); ok {
+			if h != c.Hash {
+				return fmt.Errorf("Checkpoint %s contradicts the checkpoint %s at the same height", spec, h)
+			}
+			continue
+		}
+		list = append(list, c)
+	}
+	sort.Sort(byCheckpointHeight(list))
+
+	set := make(map[uint32][]Checkpoint, len(checkpoints)+1)
+	for id, l := range checkpoints {
+		set[id] = l
+	}
+	set[networkID] = list
+	checkpoints = set
+	return nil
+}
+
+// parseCheckpoint parses a checkpoint given as height:hash
+func parseCheckpoint(spec string) (Checkpoint, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 2 {
+		return Checkpoint{}, fmt.Errorf("Invalid checkpoint %s, expected height:hash", spec)
+	}
+	height, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("Invalid height of checkpoint %s: %s", spec, err)
+	}
+	h, err := HexToHash(parts[1])
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("Invalid hash of checkpoint %s: %s", spec, err)
+	}
+	return Checkpoint{uint32(height), h.String()}, nil
+}
+
+type byCheckpointHeight []Checkpoint
+
+func (f byCheckpointHeight) Len() int {
+	return len(f)
+}
+func (f byCheckpointHeight) Less(i, j int) bool {
+	return f[i].DBHeight < f[j].DBHeight
+}
+func (f byCheckpointHeight) Swap(i, j int) {
+	f[i], f[j] = f[j], f[i]
+}
+
+// Checkpoints returns the checkpoints of the network
+func Checkpoints(networkID uint32) []Checkpoint {
+	return checkpoints[networkID]
+}
+
+// CheckpointHash returns the checkpointed directory block hash at the height
+func CheckpointHash(networkID uint32, height uint32) (string, bool) {
+	return checkpointAt(checkpoints[networkID], height)
+}
+
+func checkpointAt(list []Checkpoint, height uint32) (string, bool) {
+	for _, c := range list {
+		if c.DBHeight == height {
+			return c.Hash, true
+		}
+	}
+	return "", false
+}
+
+// LastCheckpoint returns the highest checkpoint of the network, or nil if the
+// network has none
+func LastCheckpoint(networkID uint32) *Checkpoint {
+	c := checkpoints[networkID]
+	if len(c) == 0 {
+		return nil
+	}
+	return &c[len(c)-1]
+}
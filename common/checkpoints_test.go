@@ -0,0 +1,72 @@
+package common_test
+
+import (
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/common"
+)
+
+func TestCheckpoints(t *testing.T) {
+	for _, id := range []uint32{NETWORK_ID_EB} {
+		cps := Checkpoints(id)
+		if len(cps) == 0 {
+			t.Fatalf("No checkpoints for network %d", id)
+		}
+		for i := 1; i < len(cps); i++ {
+			if cps[i].DBHeight <= cps[i-1].DBHeight {
+				t.Errorf("Checkpoints of network %d are not in height order", id)
+			}
+		}
+
+		h, ok := CheckpointHash(id, 0)
+		if !ok || h != GENESIS_DIR_BLOCK_HASH {
+			t.Errorf("Genesis checkpoint of network %d is %s", id, h)
+		}
+
+		last := LastCheckpoint(id)
+		if last == nil || last.DBHeight != cps[len(cps)-1].DBHeight {
+			t.Errorf("Wrong last checkpoint for network %d", id)
+		}
+	}
+
+	// the mainnet genesis block is never a checkpoint of another network
+	if h, ok := CheckpointHash(NETWORK_ID_TEST, 0); ok && h == GENESIS_DIR_BLOCK_HASH {
+		t.Errorf("Mainnet genesis checkpoint for network %d", NETWORK_ID_TEST)
+	}
+
+	if _, ok := CheckpointHash(NETWORK_ID_CB, 0); ok {
+		t.Errorf("Unexpected checkpoint for network %d", NETWORK_ID_CB)
+	}
+	if LastCheckpoint(NETWORK_ID_CB) != nil {
+		t.Errorf("Unexpected last checkpoint for network %d", NETWORK_ID_CB)
+	}
+}
+
+func TestSetCheckpoints(t *testing.T) {
+	defer SetCheckpoints(NETWORK_ID_EB, nil)
+	h1 := "1111111111111111111111111111111111111111111111111111111111111111"
+	h2 := "2222222222222222222222222222222222222222222222222222222222222222"
+
+	if err := SetCheckpoints(NETWORK_ID_EB, []string{"20:" + h2, " 10:" + h1, "0:" + GENESIS_DIR_BLOCK_HASH}); err != nil {
+		t.Fatal(err)
+	}
+	cps := Checkpoints(NETWORK_ID_EB)
+	if len(cps) != 3 || cps[1].DBHeight != 10 || cps[1].Hash != h1 || cps[2].DBHeight != 20 {
+		t.Errorf("Checkpoints are %+v", cps)
+	}
+	if h, ok := CheckpointHash(NETWORK_ID_EB, 0); !ok || h != GENESIS_DIR_BLOCK_HASH {
+		t.Errorf("Genesis checkpoint is %s", h)
+	}
+	if _, ok := CheckpointHash(NETWORK_ID_CB, 10); ok {
+		t.Errorf("Checkpoint set for network %d", NETWORK_ID_CB)
+	}
+
+	for _, spec := range []string{"0:" + h1, "10", "x:" + h1, "10:xyz"} {
+		if err := SetCheckpoints(NETWORK_ID_EB, []string{spec}); err == nil {
+			t.Errorf("Checkpoint %s accepted", spec)
+		}
+	}
+	if err := SetCheckpoints(NETWORK_ID_EB, []string{"10:" + h1, "10:" + h2}); err == nil {
+		t.Errorf("Contradicting checkpoints accepted")
+	}
+}
@@ -41,6 +41,9 @@ const (
 	//For Factom TestNet
 	NETWORK_ID_TEST = uint32(0) //0x0
 
+	//For a Factom network on one machine or LAN
+	NETWORK_ID_LOCAL = uint32(4203931044) //0xFA92E5A4
+
 	//Server running mode
 	FULL_NODE   = "FULL"
 	SERVER_NODE = "SERVER"
@@ -112,6 +112,17 @@ func (e *EBlock) KeyMR() (*Hash, error) {
 	return Sha(append(h.Bytes(), e.Header.BodyMR.Bytes()...)), nil
 }
 
+// HeaderHash returns the Sha256 hash of the serialized Entry Block Header,
+// which is hashed with the Body Merkle Root into the KeyMR.
+func (e *EBlock) HeaderHash() (*Hash, error) {
+	e.BuildHeader()
+	header, err := e.marshalHeaderBinary()
+	if err != nil {
+		return nil, err
+	}
+	return Sha(header), nil
+}
+
 // MarshalBinary returns the serialized binary form of the Entry Block.
 func (e *EBlock) MarshalBinary() ([]byte, error) {
 	buf := new(bytes.Buffer)
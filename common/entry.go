@@ -24,6 +24,13 @@ type Entry struct {
 var _ Printable = (*Entry)(nil)
 var _ BinaryMarshallable = (*Entry)(nil)
 
+// ExtIDRef locates an entry found in the ExtID index
+type ExtIDRef struct {
+	ChainID   *Hash
+	EntryHash *Hash
+	DBHeight  uint32
+}
+
 func (c *Entry) MarshalledSize() uint64 {
 	panic("Function not implemented")
 	return 0
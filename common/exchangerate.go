@@ -0,0 +1,161 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	ed "github.com/FactomProject/ed25519"
+)
+
+const (
+	// ExchangeRateChangeSize = 1 + 8 + 4 + 32 + 64
+	ExchangeRateChangeSize int = 109
+)
+
+// ExchangeRateChange is a signed request to change the number of factoshis
+// per entry credit. It is recorded as the content of an entry in the
+// exchange rate chain and takes effect at ActivationHeight.
+type ExchangeRateChange struct {
+	Version          uint8
+	Rate             uint64
+	ActivationHeight uint32
+	PubKey           *[32]byte
+	Sig              *[64]byte
+}
+
+var _ Printable = (*ExchangeRateChange)(nil)
+var _ BinaryMarshallable = (*ExchangeRateChange)(nil)
+
+func (c *ExchangeRateChange) MarshalledSize() uint64 {
+	return uint64(ExchangeRateChangeSize)
+}
+
+func NewExchangeRateChange() *ExchangeRateChange {
+	c := new(ExchangeRateChange)
+	c.Version = 0
+	c.PubKey = new([32]byte)
+	c.Sig = new([64]byte)
+	return c
+}
+
+// RateMsg returns the binary marshaled message section of the
+// ExchangeRateChange that is covered by the ExchangeRateChange.Sig.
+func (c *ExchangeRateChange) RateMsg() []byte {
+	p, err := c.MarshalBinary()
+	if err != nil {
+		return []byte{byte(0)}
+	}
+	return p[:len(p)-64-32]
+}
+
+// Sign sets the PubKey and Sig of the ExchangeRateChange from the private key.
+func (c *ExchangeRateChange) Sign(priv PrivateKey) {
+	copy(c.PubKey[:], priv.Pub.Key[:])
+	sig := priv.Sign(c.RateMsg())
+	copy(c.Sig[:], sig.Sig[:])
+}
+
+func (c *ExchangeRateChange) IsValid() bool {
+	if c.Rate == 0 || c.Version != 0 {
+		return false
+	}
+
+	return ed.VerifyCanonical(c.PubKey, c.RateMsg(), c.Sig)
+}
+
+func (c *ExchangeRateChange) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	// 1 byte Version
+	if err := binary.Write(buf, binary.BigEndian, c.Version); err != nil {
+		return buf.Bytes(), err
+	}
+
+	// 8 byte Rate
+	if err := binary.Write(buf, binary.BigEndian, c.Rate); err != nil {
+		return buf.Bytes(), err
+	}
+
+	// 4 byte Activation Height
+	if err := binary.Write(buf, binary.BigEndian, c.ActivationHeight); err != nil {
+		return buf.Bytes(), err
+	}
+
+	// 32 byte Public Key
+	buf.Write(c.PubKey[:])
+
+	// 64 byte Signature
+	buf.Write(c.Sig[:])
+
+	return buf.Bytes(), nil
+}
+
+func (c *ExchangeRateChange) UnmarshalBinaryData(data []byte) (newData []byte, err error) {
+	if len(data) < ExchangeRateChangeSize {
+		err = io.EOF
+		return
+	}
+	buf := bytes.NewBuffer(data)
+
+	// 1 byte Version
+	if err = binary.Read(buf, binary.BigEndian, &c.Version); err != nil {
+		return
+	}
+
+	// 8 byte Rate
+	if err = binary.Read(buf, binary.BigEndian, &c.Rate); err != nil {
+		return
+	}
+
+	// 4 byte Activation Height
+	if err = binary.Read(buf, binary.BigEndian, &c.ActivationHeight); err != nil {
+		return
+	}
+
+	// 32 byte Public Key
+	if p := buf.Next(32); len(p) != 32 {
+		err = fmt.Errorf("Could not read PubKey")
+		return
+	} else {
+		copy(c.PubKey[:], p)
+	}
+
+	// 64 byte Signature
+	if p := buf.Next(64); len(p) != 64 {
+		err = fmt.Errorf("Could not read Sig")
+		return
+	} else {
+		copy(c.Sig[:], p)
+	}
+
+	newData = buf.Bytes()
+
+	return
+}
+
+func (c *ExchangeRateChange) UnmarshalBinary(data []byte) (err error) {
+	_, err = c.UnmarshalBinaryData(data)
+	return
+}
+
+func (e *ExchangeRateChange) JSONByte() ([]byte, error) {
+	return EncodeJSON(e)
+}
+
+func (e *ExchangeRateChange) JSONString() (string, error) {
+	return EncodeJSONString(e)
+}
+
+func (e *ExchangeRateChange) JSONBuffer(b *bytes.Buffer) error {
+	return EncodeJSONToBuffer(e, b)
+}
+
+func (e *ExchangeRateChange) Spew() string {
+	return Spew(e)
+}
@@ -0,0 +1,54 @@
+package common_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FactomProject/FactomCode/common"
+)
+
+func TestExchangeRateChangeMarshal(t *testing.T) {
+	fmt.Printf("---\nTestExchangeRateChangeMarshal\n---\n")
+
+	rc := common.NewExchangeRateChange()
+
+	// test MarshalBinary on a zeroed ExchangeRateChange
+	if p, err := rc.MarshalBinary(); err != nil {
+		t.Error(err)
+	} else if z := make([]byte, common.ExchangeRateChangeSize); string(p) != string(z) {
+		t.Errorf("Marshal failed on zeroed ExchangeRateChange")
+	}
+
+	rc.Rate = 700000
+	rc.ActivationHeight = 1000
+
+	var priv common.PrivateKey
+	if err := priv.GenerateKey(); err != nil {
+		t.Fatal(err)
+	}
+	rc.Sign(priv)
+
+	rc2 := common.NewExchangeRateChange()
+	if p, err := rc.MarshalBinary(); err != nil {
+		t.Error(err)
+	} else if err := rc2.UnmarshalBinary(p); err != nil {
+		t.Error(err)
+	}
+
+	if rc2.Rate != rc.Rate || rc2.ActivationHeight != rc.ActivationHeight {
+		t.Errorf("ExchangeRateChange does not match after unmarshalbinary")
+	}
+	if !rc2.IsValid() {
+		t.Errorf("signature did not match after unmarshalbinary")
+	}
+
+	// a changed rate must invalidate the signature
+	rc2.Rate++
+	if rc2.IsValid() {
+		t.Errorf("signature should not match a modified rate")
+	}
+
+	if err := rc2.UnmarshalBinary(make([]byte, common.ExchangeRateChangeSize-1)); err == nil {
+		t.Errorf("UnmarshalBinary should fail on short data")
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	ed "github.com/FactomProject/ed25519"
+)
+
+const (
+	// IdentityRecordSize = 3 + 32 + 32 + 4 + 32 + 64
+	IdentityRecordSize int = 167
+
+	// IdentityRegistration records a server identity in the identity
+	// chain, signed by an identity authority key
+	IdentityRegistration uint8 = 0
+
+	// IdentityKeyRotation records a new signing key in the chain of the
+	// identity, signed by the identity key
+	IdentityKeyRotation uint8 = 1
+
+	// Roles of a server identity
+	FederatedServer uint8 = 0
+	AuditServer     uint8 = 1
+)
+
+// IdentityRecord is a signed registration or key rotation of a server
+// identity. It is recorded as the content of an entry: registrations in the
+// identity chain, and key rotations in the chain of the identity, whose
+// chain ID is IdentityChainID. The Key of a registration is the identity
+// key, which signs the key rotations of the identity, and its Role is the
+// role of the server from ActivationHeight. The Key of a rotation signs the
+// acks and dir blocks of the server from ActivationHeight.
+type IdentityRecord struct {
+	Version          uint8
+	Type             uint8
+	Role             uint8
+	IdentityChainID  *Hash
+	Key              *[32]byte
+	ActivationHeight uint32
+	PubKey           *[32]byte
+	Sig              *[64]byte
+}
+
+var _ Printable = (*IdentityRecord)(nil)
+var _ BinaryMarshallable = (*IdentityRecord)(nil)
+
+func (r *IdentityRecord) MarshalledSize() uint64 {
+	return uint64(IdentityRecordSize)
+}
+
+func NewIdentityRecord() *IdentityRecord {
+	r := new(IdentityRecord)
+	r.Version = 0
+	r.IdentityChainID = NewHash()
+	r.Key = new([32]byte)
+	r.PubKey = new([32]byte)
+	r.Sig = new([64]byte)
+	return r
+}
+
+// RecordMsg returns the binary marshaled message section of the
+// IdentityRecord that is covered by the IdentityRecord.Sig.
+func (r *IdentityRecord) RecordMsg() []byte {
+	p, err := r.MarshalBinary()
+	if err != nil {
+		return []byte{byte(0)}
+	}
+	return p[:len(p)-64-32]
+}
+
+// Sign sets the PubKey and Sig of the IdentityRecord from the private key.
+func (r *IdentityRecord) Sign(priv PrivateKey) {
+	copy(r.PubKey[:], priv.Pub.Key[:])
+	sig := priv.Sign(r.RecordMsg())
+	copy(r.Sig[:], sig.Sig[:])
+}
+
+func (r *IdentityRecord) IsValid() bool {
+	if r.Version != 0 || r.Type > IdentityKeyRotation || r.Role > AuditServer {
+		return false
+	}
+
+	return ed.VerifyCanonical(r.PubKey, r.RecordMsg(), r.Sig)
+}
+
+func (r *IdentityRecord) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	// 3 bytes Version, Type and Role
+	buf.Write([]byte{r.Version, r.Type, r.Role})
+
+	// 32 byte Identity Chain ID
+	buf.Write(r.IdentityChainID.Bytes())
+
+	// 32 byte Key
+	buf.Write(r.Key[:])
+
+	// 4 byte Activation Height
+	if err := binary.Write(buf, binary.BigEndian, r.ActivationHeight); err != nil {
+		return buf.Bytes(), err
+	}
+
+	// 32 byte Public Key
+	buf.Write(r.PubKey[:])
+
+	// 64 byte Signature
+	buf.Write(r.Sig[:])
+
+	return buf.Bytes(), nil
+}
+
+func (r *IdentityRecord) UnmarshalBinaryData(data []byte) (newData []byte, err error) {
+	if len(data) < IdentityRecordSize {
+		err = io.EOF
+		return
+	}
+	buf := bytes.NewBuffer(data)
+
+	// 3 bytes Version, Type and Role
+	p := buf.Next(3)
+	r.Version, r.Type, r.Role = p[0], p[1], p[2]
+
+	// 32 byte Identity Chain ID
+	if p := buf.Next(32); len(p) != 32 {
+		err = fmt.Errorf("Could not read IdentityChainID")
+		return
+	} else {
+		r.IdentityChainID = NewHash()
+		r.IdentityChainID.SetBytes(p)
+	}
+
+	// 32 byte Key
+	if p := buf.Next(32); len(p) != 32 {
+		err = fmt.Errorf("Could not read Key")
+		return
+	} else {
+		copy(r.Key[:], p)
+	}
+
+	// 4 byte Activation Height
+	if err = binary.Read(buf, binary.BigEndian, &r.ActivationHeight); err != nil {
+		return
+	}
+
+	// 32 byte Public Key
+	if p := buf.Next(32); len(p) != 32 {
+		err = fmt.Errorf("Could not read PubKey")
+		return
+	} else {
+		copy(r.PubKey[:], p)
+	}
+
+	// 64 byte Signature
+	if p := buf.Next(64); len(p) != 64 {
+		err = fmt.Errorf("Could not read Sig")
+		return
+	} else {
+		copy(r.Sig[:], p)
+	}
+
+	newData = buf.Bytes()
+
+	return
+}
+
+func (r *IdentityRecord) UnmarshalBinary(data []byte) (err error) {
+	_, err = r.UnmarshalBinaryData(data)
+	return
+}
+
+func (e *IdentityRecord) JSONByte() ([]byte, error) {
+	return EncodeJSON(e)
+}
+
+func (e *IdentityRecord) JSONString() (string, error) {
+	return EncodeJSONString(e)
+}
+
+func (e *IdentityRecord) JSONBuffer(b *bytes.Buffer) error {
+	return EncodeJSONToBuffer(e, b)
+}
+
+func (e *IdentityRecord) Spew() string {
+	return Spew(e)
+}
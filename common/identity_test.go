@@ -0,0 +1,57 @@
+package common_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FactomProject/FactomCode/common"
+)
+
+func TestIdentityRecordMarshal(t *testing.T) {
+	fmt.Printf("---\nTestIdentityRecordMarshal\n---\n")
+
+	r := common.NewIdentityRecord()
+
+	// test MarshalBinary on a zeroed IdentityRecord
+	if p, err := r.MarshalBinary(); err != nil {
+		t.Error(err)
+	} else if z := make([]byte, common.IdentityRecordSize); string(p) != string(z) {
+		t.Errorf("Marshal failed on zeroed IdentityRecord")
+	}
+
+	var root common.PrivateKey
+	if err := root.GenerateKey(); err != nil {
+		t.Fatal(err)
+	}
+	r.Type = common.IdentityKeyRotation
+	r.Role = common.AuditServer
+	r.IdentityChainID = common.Sha([]byte("identity"))
+	copy(r.Key[:], common.Sha([]byte("signing key")).Bytes())
+	r.ActivationHeight = 1000
+	r.Sign(root)
+
+	r2 := common.NewIdentityRecord()
+	if p, err := r.MarshalBinary(); err != nil {
+		t.Error(err)
+	} else if err := r2.UnmarshalBinary(p); err != nil {
+		t.Error(err)
+	}
+
+	if r2.Type != r.Type || r2.Role != r.Role || r2.ActivationHeight != r.ActivationHeight ||
+		!r2.IdentityChainID.IsSameAs(r.IdentityChainID) || *r2.Key != *r.Key {
+		t.Errorf("IdentityRecord does not match after unmarshalbinary")
+	}
+	if !r2.IsValid() {
+		t.Errorf("signature did not match after unmarshalbinary")
+	}
+
+	// a changed key must invalidate the signature
+	r2.Key[0]++
+	if r2.IsValid() {
+		t.Errorf("signature should not match a modified key")
+	}
+
+	if err := r2.UnmarshalBinary(make([]byte, common.IdentityRecordSize-1)); err == nil {
+		t.Errorf("UnmarshalBinary should fail on short data")
+	}
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
@@ -44,6 +45,15 @@ func NewPrivateKeyFromHex(s string) (pk PrivateKey, err error) {
 	return
 }
 
+// Create a new private key from a 32 bytes ed25519 seed
+func NewPrivateKeyFromSeed(seed []byte) (pk PrivateKey, err error) {
+	if len(seed) != 32 {
+		return pk, errors.New("Invalid private key seed!")
+	}
+	pk.Pub.Key, pk.Key, err = ed25519.GenerateKey(bytes.NewReader(seed))
+	return
+}
+
 // PublicKey contains only Public part of Public/Private key pair
 type PublicKey struct {
 	Key *[ed25519.PublicKeySize]byte
@@ -0,0 +1,75 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NetworkParams are the parameters of a Factom network. Nodes of different
+// networks never talk to each other: their p2p messages start with the
+// Magic of their network, and their dir blocks carry its NetworkID.
+type NetworkParams struct {
+	Name      string
+	NetworkID uint32
+	Magic     uint32
+
+	// GenesisHash is the hash of the genesis dir block, empty if the
+	// network takes the genesis block built by its first server
+	GenesisHash string
+
+	// defaults for the settings left empty in the config
+	DefaultPort             int // p2p
+	APIPort                 int // wsapi
+	DirectoryBlockInSeconds int
+	DNSSeeds                []string
+}
+
+var (
+	MainNetParams = NetworkParams{
+		Name:                    "mainnet",
+		NetworkID:               NETWORK_ID_EB,
+		Magic:                   0xFA92E5A2,
+		GenesisHash:             GENESIS_DIR_BLOCK_HASH,
+		DefaultPort:             8108,
+		APIPort:                 8088,
+		DirectoryBlockInSeconds: 60,
+	}
+
+	TestNetParams = NetworkParams{
+		Name:                    "testnet",
+		NetworkID:               NETWORK_ID_TEST,
+		Magic:                   0xFEFEFEFE,
+		DefaultPort:             8118,
+		APIPort:                 8098,
+		DirectoryBlockInSeconds: 60,
+	}
+
+	// LocalNetParams are for a network of nodes on one machine or LAN,
+	// with short blocks
+	LocalNetParams = NetworkParams{
+		Name:                    "localnet",
+		NetworkID:               NETWORK_ID_LOCAL,
+		Magic:                   0xFA92E5A4,
+		DefaultPort:             8128,
+		APIPort:                 8088,
+		DirectoryBlockInSeconds: 10,
+	}
+)
+
+// NetworkParamsByName returns the parameters of the network, mainnet if the
+// name is empty
+func NetworkParamsByName(name string) (*NetworkParams, error) {
+	switch strings.ToLower(name) {
+	case "", MainNetParams.Name:
+		return &MainNetParams, nil
+	case TestNetParams.Name:
+		return &TestNetParams, nil
+	case LocalNetParams.Name:
+		return &LocalNetParams, nil
+	}
+	return nil, fmt.Errorf("Unknown network %s, expected mainnet, testnet or localnet", name)
+}
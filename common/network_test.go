@@ -0,0 +1,29 @@
+package common_test
+
+import (
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/common"
+)
+
+func TestNetworkParams(t *testing.T) {
+	nets := []*NetworkParams{&MainNetParams, &TestNetParams, &LocalNetParams}
+	for i, a := range nets {
+		p, err := NetworkParamsByName(a.Name)
+		if err != nil || p != a {
+			t.Errorf("NetworkParamsByName(%s) returned %v, %v", a.Name, p, err)
+		}
+		for _, b := range nets[i+1:] {
+			if a.NetworkID == b.NetworkID || a.Magic == b.Magic || a.DefaultPort == b.DefaultPort {
+				t.Errorf("%s and %s share a network id, magic or p2p port", a.Name, b.Name)
+			}
+		}
+	}
+
+	if p, err := NetworkParamsByName(""); err != nil || p != &MainNetParams {
+		t.Errorf("The default network is not mainnet")
+	}
+	if _, err := NetworkParamsByName("regtest"); err == nil {
+		t.Errorf("NetworkParamsByName should fail on an unknown network")
+	}
+}
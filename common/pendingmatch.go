@@ -0,0 +1,112 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Types of the pending commit/reveal halves
+const (
+	PENDING_COMMIT_ENTRY uint8 = iota
+	PENDING_COMMIT_CHAIN
+	PENDING_REVEAL
+)
+
+// PendingMatch is a commit waiting for its reveal, or a reveal waiting for
+// its commit. Data holds the marshalled CommitEntry, CommitChain or Entry.
+type PendingMatch struct {
+	Type      uint8
+	EntryHash *Hash
+	Timestamp int64 // unix time when it was first seen
+	Data      []byte
+}
+
+var _ Printable = (*PendingMatch)(nil)
+var _ BinaryMarshallable = (*PendingMatch)(nil)
+
+func NewPendingMatch() *PendingMatch {
+	p := new(PendingMatch)
+	p.EntryHash = NewHash()
+	return p
+}
+
+// IsReveal returns true if the PendingMatch is a reveal waiting for a commit
+func (p *PendingMatch) IsReveal() bool {
+	return p.Type == PENDING_REVEAL
+}
+
+func (p *PendingMatch) MarshalledSize() uint64 {
+	return uint64(1 + HASH_LENGTH + 8 + 4 + len(p.Data))
+}
+
+func (p *PendingMatch) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(p.Type)
+	buf.Write(p.EntryHash.Bytes())
+	binary.Write(buf, binary.BigEndian, p.Timestamp)
+	binary.Write(buf, binary.BigEndian, uint32(len(p.Data)))
+	buf.Write(p.Data)
+
+	return buf.Bytes(), nil
+}
+
+func (p *PendingMatch) UnmarshalBinaryData(data []byte) (newData []byte, err error) {
+	buf := bytes.NewBuffer(data)
+
+	if p.Type, err = buf.ReadByte(); err != nil {
+		return
+	}
+
+	h := buf.Next(HASH_LENGTH)
+	if len(h) != HASH_LENGTH {
+		err = fmt.Errorf("Could not read EntryHash")
+		return
+	}
+	p.EntryHash = NewHash()
+	p.EntryHash.SetBytes(h)
+
+	if err = binary.Read(buf, binary.BigEndian, &p.Timestamp); err != nil {
+		return
+	}
+
+	var size uint32
+	if err = binary.Read(buf, binary.BigEndian, &size); err != nil {
+		return
+	}
+	if uint32(buf.Len()) < size {
+		err = fmt.Errorf("Could not read Data")
+		return
+	}
+	p.Data = make([]byte, size)
+	copy(p.Data, buf.Next(int(size)))
+
+	newData = buf.Bytes()
+	return
+}
+
+func (p *PendingMatch) UnmarshalBinary(data []byte) (err error) {
+	_, err = p.UnmarshalBinaryData(data)
+	return
+}
+
+func (p *PendingMatch) JSONByte() ([]byte, error) {
+	return EncodeJSON(p)
+}
+
+func (p *PendingMatch) JSONString() (string, error) {
+	return EncodeJSONString(p)
+}
+
+func (p *PendingMatch) JSONBuffer(b *bytes.Buffer) error {
+	return EncodeJSONToBuffer(p, b)
+}
+
+func (p *PendingMatch) Spew() string {
+	return Spew(p)
+}
@@ -0,0 +1,131 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/lightclient"
+)
+
+// MerkleStep is one level of a merkle branch. The hash of the level below is
+// hashed with Sibling, Sibling on the left if Left is set, into the next
+// level.
+type MerkleStep struct {
+	Sibling *Hash
+	Left    bool `json:",omitempty"`
+}
+
+// Receipt proves that an entry is in a directory block and where the
+// directory block is anchored in Bitcoin.
+//
+// EntryBranch leads from the entry hash to the entry block KeyMR: through the
+// entry block body to its merkle root, then with the header hash on the left.
+// DBlockBranch leads from the directory block entry of the entry block, the
+// hash of its ChainID and KeyMR, to the directory block KeyMR in the same way.
+// The directory block KeyMR is what the anchor transaction writes in its
+// OP_RETURN output, after "Fa" and the 6 byte block height. When the
+// directory block is in an aggregated anchor, AnchorRange leads from the
+// KeyMR to the merkle root the anchor transaction writes instead.
+type Receipt struct {
+	EntryHash    *Hash
+	ChainID      *Hash
+	EntryBranch  []*MerkleStep
+	EBlockKeyMR  *Hash
+	DBlockBranch []*MerkleStep
+	DBlockKeyMR  *Hash
+	DBHeight     uint32
+
+	// The anchor is empty until the directory block is confirmed in Bitcoin
+	BitcoinTxID        *Hash        `json:",omitempty"`
+	BitcoinBlockHash   *Hash        `json:",omitempty"`
+	BitcoinBlockHeight int32        `json:",omitempty"`
+	AnchorRange        *AnchorRange `json:",omitempty"`
+}
+
+// IsAnchored tells if the directory block of the receipt is confirmed in
+// Bitcoin
+func (r *Receipt) IsAnchored() bool {
+	return r.BitcoinTxID != nil && !r.BitcoinTxID.IsSameAs(NewHash())
+}
+
+// Verify checks that the branches of the receipt lead from the entry hash to
+// the directory block KeyMR, with lightclient.Receipt.Verify
+func (r *Receipt) Verify() error {
+	return r.light().Verify()
+}
+
+// AnchorPayload returns the OP_RETURN data of the anchor transaction of the
+// receipt
+func (r *Receipt) AnchorPayload() []byte {
+	return r.light().AnchorPayload()
+}
+
+// FoldMerkleBranch returns the root the branch leads to from the leaf
+func FoldMerkleBranch(leaf *Hash, branch []*MerkleStep) *Hash {
+	return &Hash{bytes: *lightclient.FoldMerkleBranch(leaf.light(), lightBranch(branch))}
+}
+
+// MerkleBranch returns the branch from hashes[index] to the root of the
+// merkle tree built by BuildMerkleTreeStore
+func MerkleBranch(hashes []*Hash, index int) ([]*MerkleStep, error) {
+	if index < 0 || index >= len(hashes) {
+		return nil, fmt.Errorf("Index %d is out of the %d hashes", index, len(hashes))
+	}
+
+	merkles := BuildMerkleTreeStore(hashes)
+	branch := make([]*MerkleStep, 0)
+	start := 0
+	for size := nextPowerOfTwo(len(hashes)); size > 1; size /= 2 {
+		if index%2 == 0 {
+			// a missing right child is hashed with the left one itself
+			sibling := merkles[start+index+1]
+			if sibling == nil {
+				sibling = merkles[start+index]
+			}
+			branch = append(branch, &MerkleStep{Sibling: sibling})
+		} else {
+			branch = append(branch, &MerkleStep{Sibling: merkles[start+index-1], Left: true})
+		}
+		start += size
+		index /= 2
+	}
+	return branch, nil
+}
+
+// light returns the receipt as a lightclient.Receipt, which verifies it
+func (r *Receipt) light() *lightclient.Receipt {
+	return &lightclient.Receipt{
+		EntryHash:          r.EntryHash.light(),
+		ChainID:            r.ChainID.light(),
+		EntryBranch:        lightBranch(r.EntryBranch),
+		EBlockKeyMR:        r.EBlockKeyMR.light(),
+		DBlockBranch:       lightBranch(r.DBlockBranch),
+		DBlockKeyMR:        r.DBlockKeyMR.light(),
+		DBHeight:           r.DBHeight,
+		BitcoinTxID:        r.BitcoinTxID.light(),
+		BitcoinBlockHash:   r.BitcoinBlockHash.light(),
+		BitcoinBlockHeight: r.BitcoinBlockHeight,
+		AnchorRange:        r.AnchorRange.light(),
+	}
+}
+
+func lightBranch(branch []*MerkleStep) []*lightclient.MerkleStep {
+	l := make([]*lightclient.MerkleStep, len(branch))
+	for i, s := range branch {
+		if s != nil {
+			l[i] = &lightclient.MerkleStep{Sibling: s.Sibling.light(), Left: s.Left}
+		}
+	}
+	return l
+}
+
+func (h *Hash) light() *lightclient.Hash {
+	if h == nil {
+		return nil
+	}
+	l := lightclient.Hash(h.bytes)
+	return &l
+}
@@ -0,0 +1,128 @@
+package common_test
+
+import (
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/common"
+)
+
+func TestMerkleBranch(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		hashes := make([]*Hash, n)
+		for i := range hashes {
+			hashes[i] = Sha([]byte{byte(n), byte(i)})
+		}
+		merkles := BuildMerkleTreeStore(hashes)
+		root := merkles[len(merkles)-1]
+
+		for i := range hashes {
+			branch, err := MerkleBranch(hashes, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mr := FoldMerkleBranch(hashes[i], branch); !mr.IsSameAs(root) {
+				t.Errorf("Branch of hash %d of %d leads to %s, not to %s", i, n, mr, root)
+			}
+		}
+	}
+
+	if _, err := MerkleBranch([]*Hash{Sha(nil)}, 1); err == nil {
+		t.Errorf("No error for an index out of range")
+	}
+}
+
+func TestReceiptVerify(t *testing.T) {
+	chain := newEntryChain()
+	entry := NewEntry()
+	entry.ChainID = chain
+	entry.Content = []byte("receipt")
+
+	eb := NewEBlock()
+	eb.Header.ChainID = chain
+	eb.AddEBEntry(entry)
+	eb.AddEndOfMinuteMarker(1)
+	ebKeyMR, _ := eb.KeyMR()
+	ebHeader, _ := eb.HeaderHash()
+
+	db := NewDBlock()
+	db.DBEntries = append(db.DBEntries,
+		&DBEntry{ChainID: NewHash(), KeyMR: Sha([]byte("other"))},
+		&DBEntry{ChainID: chain, KeyMR: ebKeyMR})
+	db.Header.BodyMR, _ = db.BuildBodyMR()
+	db.BuildKeyMerkleRoot()
+	dbHeader, _ := db.Header.MarshalBinary()
+
+	r := new(Receipt)
+	r.EntryHash = entry.Hash()
+	r.ChainID = chain
+	r.EntryBranch, _ = MerkleBranch(eb.Body.EBEntries, 0)
+	r.EntryBranch = append(r.EntryBranch, &MerkleStep{Sibling: ebHeader, Left: true})
+	r.EBlockKeyMR = ebKeyMR
+
+	hashes := make([]*Hash, len(db.DBEntries))
+	for i, v := range db.DBEntries {
+		data, _ := v.MarshalBinary()
+		hashes[i] = Sha(data)
+	}
+	r.DBlockBranch, _ = MerkleBranch(hashes, 1)
+	r.DBlockBranch = append(r.DBlockBranch, &MerkleStep{Sibling: Sha(dbHeader), Left: true})
+	r.DBlockKeyMR = db.KeyMR
+
+	if err := r.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsAnchored() {
+		t.Errorf("Receipt without a Bitcoin transaction is anchored")
+	}
+
+	r.EntryHash = Sha([]byte("forged"))
+	if err := r.Verify(); err == nil {
+		t.Errorf("Receipt of a forged entry verified")
+	}
+}
+
+func newEntryChain() *Hash {
+	h, _ := HexToHash("df3ade9eec4b08d5379cc64270c30ea7315d8a8a1a69efe2b98a60ecdd69e604")
+	return h
+}
+
+func TestAnchorRange(t *testing.T) {
+	keyMRs := make([]*Hash, 5)
+	for i := range keyMRs {
+		keyMRs[i] = Sha([]byte{byte(i)})
+	}
+
+	for i := range keyMRs {
+		r, err := NewAnchorRange(10, uint32(10+i), keyMRs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.LastHeight != 14 {
+			t.Errorf("Range of 5 blocks from 10 ends at %d", r.LastHeight)
+		}
+		if err := r.Verify(keyMRs[i], uint32(10+i)); err != nil {
+			t.Errorf("Block %d: %s", i, err)
+		}
+		if err := r.Verify(keyMRs[i], 15); err == nil {
+			t.Errorf("Block %d verified out of the range", i)
+		}
+		if err := r.Verify(Sha([]byte("forged")), uint32(10+i)); err == nil {
+			t.Errorf("Forged block %d verified", i)
+		}
+	}
+
+	r, _ := NewAnchorRange(10, 12, keyMRs)
+	if p := r.Payload(); len(p) != 46 || string(p[:2]) != "FA" {
+		t.Errorf("Aggregated anchor payload is %x", p)
+	}
+	if p := AnchorPayload(12, 12, keyMRs[2]); len(p) != 40 || string(p[:2]) != "Fa" {
+		t.Errorf("Single anchor payload is %x", p)
+	}
+	if !AnchorMerkleRoot(keyMRs[2:3]).IsSameAs(keyMRs[2]) {
+		t.Errorf("Merkle root of one KeyMR is not the KeyMR")
+	}
+
+	if _, err := NewAnchorRange(10, 15, keyMRs); err == nil {
+		t.Errorf("No error for a height out of the range")
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	fct "github.com/FactomProject/factoid"
+)
+
+// Types of the signing requests
+const (
+	SignCommitChain = "commit-chain"
+	SignCommitEntry = "commit-entry"
+	SignFactoidTx   = "factoid-tx"
+)
+
+// SigningRequest is an unsigned commit or factoid transaction, portable to
+// an air-gapped machine holding the private keys. Every key of Signers signs
+// Data, the part of Unsigned covered by the signatures, and the signed
+// request is submitted back to a node.
+type SigningRequest struct {
+	Type     string
+	Network  string // name of the network the request is for
+	Unsigned string // hex of the binary commit or transaction
+	Data     string // hex of the bytes to sign
+	Signers  []*RequestSigner
+}
+
+// RequestSigner is a public key signing a request, with its signature once
+// signed
+type RequestSigner struct {
+	PubKey string
+	Sig    string `json:",omitempty"`
+}
+
+// NewCommitChainRequest returns the request to sign the commit with its EC key
+func NewCommitChainRequest(c *CommitChain, network string) (*SigningRequest, error) {
+	p, err := c.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return newSigningRequest(SignCommitChain, network, p, c.CommitMsg(), c.ECPubKey[:]), nil
+}
+
+// NewCommitEntryRequest returns the request to sign the commit with its EC key
+func NewCommitEntryRequest(c *CommitEntry, network string) (*SigningRequest, error) {
+	p, err := c.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return newSigningRequest(SignCommitEntry, network, p, c.CommitMsg(), c.ECPubKey[:]), nil
+}
+
+// NewFactoidTxRequest returns the request to sign the transaction with the
+// keys of its inputs
+func NewFactoidTxRequest(t *fct.Transaction, network string) (*SigningRequest, error) {
+	p, err := t.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	data, err := t.MarshalBinarySig()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := rcdPubKeys(t)
+	if err != nil {
+		return nil, err
+	}
+	return newSigningRequest(SignFactoidTx, network, p, data, keys...), nil
+}
+
+func newSigningRequest(typ, network string, unsigned, data []byte, keys ...[]byte) *SigningRequest {
+	r := &SigningRequest{
+		Type:     typ,
+		Network:  network,
+		Unsigned: hex.EncodeToString(unsigned),
+		Data:     hex.EncodeToString(data),
+		Signers:  make([]*RequestSigner, 0, len(keys)),
+	}
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		pub := hex.EncodeToString(k)
+		if !seen[pub] {
+			seen[pub] = true
+			r.Signers = append(r.Signers, &RequestSigner{PubKey: pub})
+		}
+	}
+	return r
+}
+
+// rcdPubKeys returns the public keys of the inputs of the transaction
+func rcdPubKeys(t *fct.Transaction) ([][]byte, error) {
+	keys := make([][]byte, 0)
+	for i, rcd := range t.GetRCDs() {
+		r, ok := rcd.(*fct.RCD_1)
+		if !ok {
+			return nil, fmt.Errorf("Input %d is not signed by a single key", i)
+		}
+		keys = append(keys, r.GetPublicKey())
+	}
+	return keys, nil
+}
+
+// Check verifies that Data is what the signers of the Unsigned commit or
+// transaction must sign. A signer checks it before signing, so a node
+// cannot get anything else signed.
+func (r *SigningRequest) Check() error {
+	unsigned, err := hex.DecodeString(r.Unsigned)
+	if err != nil {
+		return fmt.Errorf("Invalid Unsigned: %s", err)
+	}
+
+	var data []byte
+	var keys [][]byte
+	switch r.Type {
+	case SignCommitChain:
+		c := NewCommitChain()
+		if err := c.UnmarshalBinary(unsigned); err != nil {
+			return err
+		}
+		data, keys = c.CommitMsg(), [][]byte{c.ECPubKey[:]}
+	case SignCommitEntry:
+		c := NewCommitEntry()
+		if err := c.UnmarshalBinary(unsigned); err != nil {
+			return err
+		}
+		data, keys = c.CommitMsg(), [][]byte{c.ECPubKey[:]}
+	case SignFactoidTx:
+		t := new(fct.Transaction)
+		if _, err := t.UnmarshalBinaryData(unsigned); err != nil {
+			return err
+		}
+		if data, err = t.MarshalBinarySig(); err != nil {
+			return err
+		}
+		if keys, err = rcdPubKeys(t); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown signing request type %s", r.Type)
+	}
+
+	if hex.EncodeToString(data) != r.Data {
+		return fmt.Errorf("Data is not the signed part of the %s", r.Type)
+	}
+	expected := newSigningRequest(r.Type, r.Network, nil, nil, keys...)
+	if len(expected.Signers) != len(r.Signers) {
+		return fmt.Errorf("%d signers expected, %d in the request", len(expected.Signers), len(r.Signers))
+	}
+	for i, s := range expected.Signers {
+		if r.Signers[i].PubKey != s.PubKey {
+			return fmt.Errorf("Signer %d is %s, expected %s", i, r.Signers[i].PubKey, s.PubKey)
+		}
+	}
+	return nil
+}
+
+// Sign signs the request with the key, and returns the number of signers
+// it signed for
+func (r *SigningRequest) Sign(priv PrivateKey) (int, error) {
+	data, err := hex.DecodeString(r.Data)
+	if err != nil {
+		return 0, err
+	}
+	pub := hex.EncodeToString(priv.Public())
+	n := 0
+	for _, s := range r.Signers {
+		if s.PubKey == pub {
+			sig := priv.Sign(data)
+			s.Sig = hex.EncodeToString(sig.Sig[:])
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Verify checks the request, and the signatures of all the signers
+func (r *SigningRequest) Verify() error {
+	if err := r.Check(); err != nil {
+		return err
+	}
+	data, _ := hex.DecodeString(r.Data)
+	for _, s := range r.Signers {
+		if s.Sig == "" {
+			return fmt.Errorf("%s has not signed", s.PubKey)
+		}
+		pub, err := hex.DecodeString(s.PubKey)
+		if err != nil {
+			return err
+		}
+		sig, err := hex.DecodeString(s.Sig)
+		if err != nil || len(sig) != 64 || !VerifySlice(pub, data, sig) {
+			return fmt.Errorf("Invalid signature of %s", s.PubKey)
+		}
+	}
+	return nil
+}
+
+// Signed returns the binary commit or transaction with the signatures of
+// the verified request
+func (r *SigningRequest) Signed() ([]byte, error) {
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	unsigned, _ := hex.DecodeString(r.Unsigned)
+	sigs := make(map[string][]byte)
+	for _, s := range r.Signers {
+		sigs[s.PubKey], _ = hex.DecodeString(s.Sig)
+	}
+
+	switch r.Type {
+	case SignCommitChain:
+		c := NewCommitChain()
+		c.UnmarshalBinary(unsigned)
+		copy(c.Sig[:], sigs[r.Signers[0].PubKey])
+		return c.MarshalBinary()
+	case SignCommitEntry:
+		c := NewCommitEntry()
+		c.UnmarshalBinary(unsigned)
+		copy(c.Sig[:], sigs[r.Signers[0].PubKey])
+		return c.MarshalBinary()
+	}
+
+	t := new(fct.Transaction)
+	t.UnmarshalBinaryData(unsigned)
+	keys, _ := rcdPubKeys(t)
+	for i, k := range keys {
+		sig := new(fct.FactoidSignature)
+		if err := sig.SetSignature(sigs[hex.EncodeToString(k)]); err != nil {
+			return nil, err
+		}
+		block := new(fct.SignatureBlock)
+		block.AddSignature(sig)
+		t.SetSignatureBlock(i, block)
+	}
+	return t.MarshalBinary()
+}
+
+// Summary describes what the request pays, for the signer to review
+func (r *SigningRequest) Summary() string {
+	unsigned, err := hex.DecodeString(r.Unsigned)
+	if err != nil {
+		return err.Error()
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%s on %s\n", r.Type, r.Network)
+	switch r.Type {
+	case SignCommitChain:
+		c := NewCommitChain()
+		if err := c.UnmarshalBinary(unsigned); err == nil {
+			fmt.Fprintf(buf, "entry %s, chain id hash %s\n", c.EntryHash, c.ChainIDHash)
+			fmt.Fprintf(buf, "%d entry credits from %x\n", c.Credits, c.ECPubKey[:])
+		}
+	case SignCommitEntry:
+		c := NewCommitEntry()
+		if err := c.UnmarshalBinary(unsigned); err == nil {
+			fmt.Fprintf(buf, "entry %s\n", c.EntryHash)
+			fmt.Fprintf(buf, "%d entry credits from %x\n", c.Credits, c.ECPubKey[:])
+		}
+	case SignFactoidTx:
+		t := new(fct.Transaction)
+		if _, err := t.UnmarshalBinaryData(unsigned); err == nil {
+			for _, v := range t.GetInputs() {
+				fmt.Fprintf(buf, "input     %x %s\n", v.GetAddress().Bytes(), fct.ConvertDecimal(v.GetAmount()))
+			}
+			for _, v := range t.GetOutputs() {
+				fmt.Fprintf(buf, "output    %x %s\n", v.GetAddress().Bytes(), fct.ConvertDecimal(v.GetAmount()))
+			}
+			for _, v := range t.GetECOutputs() {
+				fmt.Fprintf(buf, "ec output %x %s\n", v.GetAddress().Bytes(), fct.ConvertDecimal(v.GetAmount()))
+			}
+		}
+	}
+	return buf.String()
+}
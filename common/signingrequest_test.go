@@ -0,0 +1,57 @@
+package common_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/common"
+)
+
+func TestSigningRequestCommitEntry(t *testing.T) {
+	var priv PrivateKey
+	if err := priv.GenerateKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCommitEntry()
+	c.MilliTime = &[6]byte{1, 1, 1, 1, 1, 1}
+	c.EntryHash = Sha([]byte("entry"))
+	c.Credits = 1
+	copy(c.ECPubKey[:], priv.Public())
+
+	r, err := NewCommitEntryRequest(c, "localnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Check(); err != nil {
+		t.Errorf("Check failed on a new request: %v", err)
+	}
+	if err := r.Verify(); err == nil {
+		t.Errorf("Verify should fail on an unsigned request")
+	}
+
+	if n, err := r.Sign(priv); err != nil || n != 1 {
+		t.Errorf("Sign signed for %d signers: %v", n, err)
+	}
+	if err := r.Verify(); err != nil {
+		t.Errorf("Verify failed on a signed request: %v", err)
+	}
+
+	p, err := r.Signed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := NewCommitEntry()
+	if err := signed.UnmarshalBinary(p); err != nil {
+		t.Fatal(err)
+	}
+	if !signed.IsValid() {
+		t.Errorf("The signed commit has an invalid signature")
+	}
+
+	// a request whose data is not the commit must not be signed
+	r.Data = hex.EncodeToString([]byte("something else"))
+	if err := r.Check(); err == nil {
+		t.Errorf("Check should fail when the data is not the commit")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/lightclient"
+)
+
+// The SPV verification of the anchors is in the lightclient package, which
+// verifies them without a node.
+
+// BitcoinAnchorProof is the SPV proof that a Bitcoin transaction anchoring a
+// directory block is in the Bitcoin block chain, see
+// lightclient.BitcoinAnchorProof
+type BitcoinAnchorProof lightclient.BitcoinAnchorProof
+
+// BitcoinNet is a Bitcoin network the anchors are written to, see
+// lightclient.BitcoinNet
+type BitcoinNet lightclient.BitcoinNet
+
+var (
+	BitcoinMainNet  = (*BitcoinNet)(lightclient.BitcoinMainNet)
+	BitcoinTestNet3 = (*BitcoinNet)(lightclient.BitcoinTestNet3)
+	BitcoinRegTest  = (*BitcoinNet)(lightclient.BitcoinRegTest)
+)
+
+// BitcoinAnchor is what a verified BitcoinAnchorProof proves
+type BitcoinAnchor lightclient.BitcoinAnchor
+
+// VerifyBitcoinAnchor checks that the transaction of the proof writes the
+// directory block keyMR at height in its OP_RETURN output, that it is in the
+// block of the first header and that the headers are a chain with the proof
+// of work of net. It does not know if the chain is the Bitcoin one: the block
+// hashes are to be checked against a trusted header source.
+func VerifyBitcoinAnchor(net *BitcoinNet, keyMR *Hash, height uint32, p *BitcoinAnchorProof) (*BitcoinAnchor, error) {
+	a, err := lightclient.VerifyBitcoinAnchor((*lightclient.BitcoinNet)(net), keyMR.light(), height,
+		(*lightclient.BitcoinAnchorProof)(p))
+	return (*BitcoinAnchor)(a), err
+}
+
+// VerifyBitcoinAnchorPayload is VerifyBitcoinAnchor for an anchor writing
+// payload, such as the payload of an AnchorRange
+func VerifyBitcoinAnchorPayload(net *BitcoinNet, payload []byte, p *BitcoinAnchorProof) (*BitcoinAnchor, error) {
+	a, err := lightclient.VerifyBitcoinAnchorPayload((*lightclient.BitcoinNet)(net), payload,
+		(*lightclient.BitcoinAnchorProof)(p))
+	return (*BitcoinAnchor)(a), err
+}
+
+// FoldBitcoinMerkleBranch returns the merkle root the branch leads to from
+// the transaction id at index in the block
+func FoldBitcoinMerkleBranch(txid []byte, index uint32, branch [][]byte) []byte {
+	return lightclient.FoldBitcoinMerkleBranch(txid, index, branch)
+}
+
+// BitcoinMerkleBranch returns the merkle branch of the transaction at index
+// in a block with the transaction ids txids
+func BitcoinMerkleBranch(txids [][]byte, index int) ([][]byte, error) {
+	if index < 0 || index >= len(txids) {
+		return nil, fmt.Errorf("Index %d out of range of %d transactions", index, len(txids))
+	}
+
+	branch := make([][]byte, 0)
+	level := txids
+	for len(level) > 1 {
+		// an odd level is completed with its last hash, as Bitcoin does
+		if len(level)%2 == 1 {
+			level = append(level[:len(level):len(level)], level[len(level)-1])
+		}
+		branch = append(branch, level[index^1])
+
+		next := make([][]byte, len(level)/2)
+		for i := range next {
+			next[i] = doubleSha(append(append([]byte{}, level[2*i]...), level[2*i+1]...))
+		}
+		level = next
+		index >>= 1
+	}
+	return branch, nil
+}
+
+func doubleSha(p []byte) []byte {
+	h := sha256.Sum256(p)
+	h = sha256.Sum256(h[:])
+	return h[:]
+}
@@ -0,0 +1,90 @@
+package common_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/common"
+)
+
+func TestVerifyBitcoinAnchor(t *testing.T) {
+	keyMR := Sha([]byte("dblock"))
+	height := uint32(1234)
+
+	tx := anchorTx(keyMR, height)
+	txids := [][]byte{dsha([]byte("coinbase")), dsha([]byte("other")), dsha(tx)}
+	branch, err := BitcoinMerkleBranch(txids, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the odd level is completed with the last hash
+	root := dsha(append(dsha(append(append([]byte{}, txids[0]...), txids[1]...)),
+		dsha(append(append([]byte{}, txids[2]...), txids[2]...))...))
+
+	block := minedHeader(make([]byte, 32), root)
+	next := minedHeader(dsha(block), dsha([]byte("next")))
+	p := &BitcoinAnchorProof{RawTx: tx, TxIndex: 2, MerkleBranch: branch, Headers: [][]byte{block, next}}
+
+	a, err := VerifyBitcoinAnchor(BitcoinRegTest, keyMR, height, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.TxID, txids[2]) || !bytes.Equal(a.BlockHash, dsha(block)) || a.Confirmations != 2 {
+		t.Errorf("Wrong anchor %x %x %d", a.TxID, a.BlockHash, a.Confirmations)
+	}
+
+	if _, err := VerifyBitcoinAnchor(BitcoinRegTest, keyMR, height+1, p); err == nil {
+		t.Errorf("Anchor of another height verified")
+	}
+	if _, err := VerifyBitcoinAnchor(BitcoinTestNet3, keyMR, height, p); err == nil {
+		t.Errorf("Anchor with the regtest proof of work verified on testnet3")
+	}
+	p.TxIndex = 1
+	if _, err := VerifyBitcoinAnchor(BitcoinRegTest, keyMR, height, p); err == nil {
+		t.Errorf("Anchor with a wrong tx index verified")
+	}
+	p.TxIndex = 2
+	p.Headers[1] = minedHeader(make([]byte, 32), dsha([]byte("next")))
+	if _, err := VerifyBitcoinAnchor(BitcoinRegTest, keyMR, height, p); err == nil {
+		t.Errorf("Anchor with unlinked headers verified")
+	}
+}
+
+// anchorTx returns a transaction with one input and the OP_RETURN output of
+// the anchor
+func anchorTx(keyMR *Hash, height uint32) []byte {
+	h := make([]byte, 8)
+	binary.BigEndian.PutUint64(h, uint64(height))
+	data := append([]byte{'F', 'a'}, append(h[2:], keyMR.Bytes()...)...)
+
+	tx := []byte{1, 0, 0, 0, 1}
+	tx = append(tx, make([]byte, 36)...)
+	tx = append(tx, 0, 0xff, 0xff, 0xff, 0xff, 1)
+	tx = append(tx, make([]byte, 8)...)
+	tx = append(tx, byte(len(data)+2), 0x6a, byte(len(data)))
+	tx = append(tx, data...)
+	return append(tx, 0, 0, 0, 0)
+}
+
+// minedHeader returns a header with the regtest target and a nonce meeting it
+func minedHeader(prev, merkleRoot []byte) []byte {
+	header := make([]byte, 80)
+	header[0] = 1
+	copy(header[4:36], prev)
+	copy(header[36:68], merkleRoot)
+	binary.LittleEndian.PutUint32(header[72:76], 0x207fffff)
+	for nonce := uint32(0); ; nonce++ {
+		binary.LittleEndian.PutUint32(header[76:], nonce)
+		if h := dsha(header); h[31] < 0x7f {
+			return header
+		}
+	}
+}
+
+func dsha(p []byte) []byte {
+	h := sha256.Sum256(p)
+	h = sha256.Sum256(h[:])
+	return h[:]
+}
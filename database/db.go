@@ -72,6 +72,10 @@ type Db interface {
 	// FetchEBHashByMR gets an entry by hash from the database.
 	FetchEBHashByMR(eBMR *common.Hash) (eBlockHash *common.Hash, err error)
 
+	// FetchEBlockBySequence gets the entry block of a chain with the
+	// sequence number, or nil if there is none
+	FetchEBlockBySequence(chainID *common.Hash, sequence uint32) (eBlock *common.EBlock, err error)
+
 	// FetchAllEBlocksByChain gets all of the blocks by chain id
 	FetchAllEBlocksByChain(chainID *common.Hash) (eBlocks *[]common.EBlock, err error)
 
@@ -178,6 +182,59 @@ type Db interface {
 	// FtchHeadMRByChainID gets a MR of the highest block from the database.
 	FetchHeadMRByChainID(chainID *common.Hash) (blkMR *common.Hash, err error)
 
+	// InsertBalanceState stores a balance snapshot and prunes all but the
+	// previous one
+	InsertBalanceState(state *common.BalanceState) error
+
+	// FetchBalanceState returns the most recent balance snapshot at or below
+	// maxHeight, or nil if there is none
+	FetchBalanceState(maxHeight uint32) (state *common.BalanceState, err error)
+
+	// InsertPendingMatch stores an unmatched commit or reveal
+	InsertPendingMatch(pending *common.PendingMatch) error
+
+	// DeletePendingMatch removes an unmatched commit or reveal
+	DeletePendingMatch(pendingType uint8, entryHash *common.Hash) error
+
+	// FetchAllPendingMatches gets all of the unmatched commits and reveals
+	FetchAllPendingMatches() (pendings []*common.PendingMatch, err error)
+
+	// FetchEntriesByExtID gets the entries with the ExtID, in the chain if
+	// chainID is not nil, stored in dir blocks startHeight to endHeight
+	FetchEntriesByExtID(extID []byte, chainID *common.Hash, startHeight, endHeight uint32) (refs []*common.ExtIDRef, err error)
+
+	// InitializeExtIDIndex indexes the ExtIDs of the stored entry blocks if
+	// the index is empty
+	InitializeExtIDIndex() error
+
+	// FetchECBlockHeightsByAddress gets the heights of the entry credit
+	// blocks with a transaction of the public key, in ascending order
+	FetchECBlockHeightsByAddress(pubKey []byte) (heights []uint32, err error)
+
+	// InitializeECAddressIndex indexes the public keys of the stored entry
+	// credit blocks if the index is empty
+	InitializeECAddressIndex() error
+
+	// FetchFactoidTxHeight gets the height of the factoid block with the
+	// transaction, and false if the transaction is not stored
+	FetchFactoidTxHeight(txid []byte) (height uint32, found bool, err error)
+
+	// FetchFBlockHeightsByAddress gets the heights of the factoid blocks
+	// with a transaction of the address, in ascending order
+	FetchFBlockHeightsByAddress(address []byte) (heights []uint32, err error)
+
+	// InitializeFactoidIndex indexes the transactions and the addresses of
+	// the stored factoid blocks if the index is empty
+	InitializeFactoidIndex() error
+
+	// FetchDBlockHeight returns the height of the highest dir block in the
+	// height index, or -1 if there is none
+	FetchDBlockHeight() (int64, error)
+
+	// DeleteDBlocksFrom rolls the database back to the dir block before
+	// height, deleting the dir blocks from height up and their blocks
+	DeleteDBlocksFrom(height uint32) error
+
 	StartBatch()
 	EndBatch() error
 }
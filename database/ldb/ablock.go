@@ -32,7 +32,7 @@ func (db *LevelDb) ProcessABlockBatch(block *common.AdminBlock) error {
 
 	err = db.lDb.Write(db.lbatch, db.wo)
 	if err != nil {
-		fmt.Printf("batch failed %v\n", err)
+		dbLog.Error("batch failed ", err)
 		return err
 	}
 	return nil
@@ -0,0 +1,79 @@
+package ldb
+
+import (
+	"encoding/binary"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/goleveldb/leveldb"
+	"github.com/FactomProject/goleveldb/leveldb/util"
+)
+
+// InsertBalanceState stores a balance snapshot keyed by its dir block height.
+// Only the new snapshot and the one right before it are kept in db.
+func (db *LevelDb) InsertBalanceState(state *common.BalanceState) error {
+	if state == nil {
+		return nil
+	}
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	if db.lbatch == nil {
+		db.lbatch = new(leveldb.Batch)
+	}
+	defer db.lbatch.Reset()
+
+	binaryState, err := state.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	db.lbatch.Put(balanceStateKey(state.DBHeight), binaryState)
+
+	// prune the older snapshots
+	iter := db.lDb.NewIterator(&util.Range{Start: []byte{byte(TBL_BALANCE)}, Limit: []byte{byte(TBL_BALANCE + 1)}}, db.ro)
+	for iter.Next() {
+		height := binary.BigEndian.Uint32(iter.Key()[1:])
+		if height+1 < state.DBHeight {
+			key := make([]byte, len(iter.Key()))
+			copy(key, iter.Key())
+			db.lbatch.Delete(key)
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	err = db.lDb.Write(db.lbatch, db.wo)
+	if err != nil {
+		dbLog.Error("batch failed ", err)
+		return err
+	}
+	return nil
+}
+
+// FetchBalanceState returns the most recent balance snapshot at or below
+// maxHeight, or nil if there is none
+func (db *LevelDb) FetchBalanceState(maxHeight uint32) (state *common.BalanceState, err error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	iter := db.lDb.NewIterator(&util.Range{Start: []byte{byte(TBL_BALANCE)}, Limit: balanceStateKey(maxHeight + 1)}, db.ro)
+	if iter.Last() {
+		state = common.NewBalanceState()
+		if err = state.UnmarshalBinary(iter.Value()); err != nil {
+			state = nil
+		}
+	}
+	iter.Release()
+	if err == nil {
+		err = iter.Error()
+	}
+	return state, err
+}
+
+func balanceStateKey(height uint32) []byte {
+	var key = []byte{byte(TBL_BALANCE)} // Table Name (1 bytes)
+	bytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(bytes, height)
+	return append(key, bytes...)
+}
@@ -0,0 +1,14 @@
+package ldb
+
+import (
+	"github.com/FactomProject/goleveldb/leveldb/util"
+)
+
+// Compact compacts the whole database, dropping the space of the deleted
+// and overwritten records
+func (db *LevelDb) Compact() error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	return db.lDb.CompactRange(util.Range{})
+}
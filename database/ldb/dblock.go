@@ -76,7 +76,7 @@ func (db *LevelDb) ProcessDBlockBatch(dblock *common.DirectoryBlock) error {
 
 	err = db.lDb.Write(db.lbatch, db.wo)
 	if err != nil {
-		fmt.Printf("batch failed %v\n", err)
+		dbLog.Error("batch failed ", err)
 		return err
 	}
 	return nil
@@ -234,7 +234,7 @@ func (db *LevelDb) InsertDirBlockInfo(dirBlockInfo *common.DirBlockInfo) (err er
 
 	err = db.lDb.Write(db.lbatch, db.wo)
 	if err != nil {
-		fmt.Printf("batch failed %v\n", err)
+		dbLog.Error("batch failed ", err)
 		return err
 	}
 	return nil
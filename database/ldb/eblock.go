@@ -30,7 +30,7 @@ func (db *LevelDb) ProcessEBlockBatch(eblock *common.EBlock) error {
 
 	err = db.lDb.Write(db.lbatch, db.wo)
 	if err != nil {
-		fmt.Printf("batch failed %v\n", err)
+		dbLog.Error("batch failed ", err)
 		return err
 	}
 	return nil
@@ -97,7 +97,8 @@ func (db *LevelDb) ProcessEBlockMultiBatch(eblock *common.EBlock) error {
 	}
 	db.lbatch.Put(key, keyMR.Bytes())
 
-	return nil
+	// Index the entries by their ExtIDs
+	return db.indexExtIDsMultiBatch(eblock)
 }
 
 // FetchEBlockByMR gets an entry block by merkle root from the database.
@@ -205,7 +206,7 @@ func (db *LevelDb) InsertChain(chain *common.EChain) error {
 
 	err = db.lDb.Write(db.lbatch, db.wo)
 	if err != nil {
-		fmt.Printf("batch failed %v\n", err)
+		dbLog.Error("batch failed ", err)
 		return err
 	}
 	return nil
@@ -321,6 +322,42 @@ func (db *LevelDb) FetchAllEBlocksByChain(chainID *common.Hash) (eBlocks *[]comm
 	return &eBlockSlice, nil
 }
 
+// FetchEBlockBySequence gets the entry block of a chain with the sequence
+// number, or nil if the chain has no such block
+func (db *LevelDb) FetchEBlockBySequence(chainID *common.Hash, sequence uint32) (*common.EBlock, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	var key []byte = []byte{byte(TBL_EB_CHAIN_NUM)}
+	key = append(key, chainID.Bytes()...)
+	bytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(bytes, sequence)
+	key = append(key, bytes...)
+	data, err := db.lDb.Get(key, db.ro)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	eBlockHash := common.NewHash()
+	if _, err := eBlockHash.UnmarshalBinaryData(data); err != nil {
+		return nil, err
+	}
+
+	key = []byte{byte(TBL_EB)}
+	key = append(key, eBlockHash.Bytes()...)
+	data, err = db.lDb.Get(key, db.ro)
+	if err != nil {
+		return nil, err
+	}
+	eBlock := common.NewEBlock()
+	if _, err := eBlock.UnmarshalBinaryData(data); err != nil {
+		return nil, err
+	}
+	return eBlock, nil
+}
+
 // Internal db use only
 func addOneToByteArray(input []byte) (output []byte) {
 	if input == nil {
@@ -0,0 +1,123 @@
+package ldb
+
+import (
+	"encoding/binary"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/goleveldb/leveldb"
+	"github.com/FactomProject/goleveldb/leveldb/util"
+)
+
+// The entry credit address index key is the table name (1 byte), the public
+// key (32 bytes) and the height (4 bytes) of an entry credit block with a
+// transaction of the key, so the blocks of a key are ordered by height.
+const ecAddressKeyLength = 1 + 32 + 4
+
+func ecAddressKey(pubKey []byte, height uint32) []byte {
+	key := append([]byte{byte(TBL_EC_ADDRESS)}, pubKey...)
+	h := make([]byte, 4)
+	binary.BigEndian.PutUint32(h, height)
+	return append(key, h...)
+}
+
+// ecBlockAddresses returns the public keys with a transaction in the block
+func ecBlockAddresses(block *common.ECBlock) [][]byte {
+	keys := make([][]byte, 0)
+	seen := make(map[[32]byte]bool)
+	for _, entry := range block.Body.Entries {
+		var pub *[32]byte
+		switch e := entry.(type) {
+		case *common.CommitChain:
+			pub = e.ECPubKey
+		case *common.CommitEntry:
+			pub = e.ECPubKey
+		case *common.IncreaseBalance:
+			pub = e.ECPubKey
+		}
+		if pub == nil || seen[*pub] {
+			continue
+		}
+		seen[*pub] = true
+		keys = append(keys, pub[:])
+	}
+	return keys
+}
+
+// indexECAddressesMultiBatch adds the public keys with a transaction in the
+// block to the batch
+func (db *LevelDb) indexECAddressesMultiBatch(block *common.ECBlock) {
+	for _, pub := range ecBlockAddresses(block) {
+		db.lbatch.Put(ecAddressKey(pub, block.Header.EBHeight), []byte{})
+	}
+}
+
+// FetchECBlockHeightsByAddress gets the heights of the entry credit blocks
+// with a transaction of the public key, in ascending order
+func (db *LevelDb) FetchECBlockHeightsByAddress(pubKey []byte) (heights []uint32, err error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	prefix := append([]byte{byte(TBL_EC_ADDRESS)}, pubKey...)
+	limit := make([]byte, len(prefix))
+	copy(limit, prefix)
+	for i := len(limit) - 1; i >= 0; i-- {
+		limit[i]++
+		if limit[i] != 0 {
+			break
+		}
+	}
+
+	iter := db.lDb.NewIterator(&util.Range{Start: prefix, Limit: limit}, db.ro)
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != ecAddressKeyLength {
+			continue
+		}
+		heights = append(heights, binary.BigEndian.Uint32(key[33:]))
+	}
+	iter.Release()
+	err = iter.Error()
+
+	return heights, err
+}
+
+// InitializeECAddressIndex indexes the public keys of the stored entry
+// credit blocks if the index is empty, as it is in databases created before
+// the index
+func (db *LevelDb) InitializeECAddressIndex() error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	iter := db.lDb.NewIterator(&util.Range{Start: []byte{byte(TBL_EC_ADDRESS)}, Limit: []byte{byte(TBL_EC_ADDRESS + 1)}}, db.ro)
+	indexed := iter.Next()
+	iter.Release()
+	if indexed {
+		return nil
+	}
+
+	if db.lbatch == nil {
+		db.lbatch = new(leveldb.Batch)
+	}
+	defer db.lbatch.Reset()
+
+	iter = db.lDb.NewIterator(&util.Range{Start: []byte{byte(TBL_CB)}, Limit: []byte{byte(TBL_CB + 1)}}, db.ro)
+	for iter.Next() {
+		block := common.NewECBlock()
+		if _, err := block.UnmarshalBinaryData(iter.Value()); err != nil {
+			iter.Release()
+			return err
+		}
+		db.indexECAddressesMultiBatch(block)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	err := db.lDb.Write(db.lbatch, db.wo)
+	if err != nil {
+		dbLog.Error("batch failed ", err)
+		return err
+	}
+	return nil
+}
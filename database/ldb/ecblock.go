@@ -31,7 +31,7 @@ func (db *LevelDb) ProcessECBlockBatch(block *common.ECBlock) error {
 
 	err = db.lDb.Write(db.lbatch, db.wo)
 	if err != nil {
-		fmt.Printf("batch failed %v\n", err)
+		dbLog.Error("batch failed ", err)
 		return err
 	}
 	return nil
@@ -78,6 +78,8 @@ func (db *LevelDb) ProcessECBlockMultiBatch(block *common.ECBlock) error {
 	//}
 	db.lbatch.Put(key, hash.Bytes())
 
+	db.indexECAddressesMultiBatch(block)
+
 	return nil
 }
 
@@ -30,7 +30,7 @@ func (db *LevelDb) InsertEntry(entry *common.Entry) error {
 
 	err = db.lDb.Write(db.lbatch, db.wo)
 	if err != nil {
-		fmt.Printf("batch failed %v\n", err)
+		dbLog.Error("batch failed ", err)
 		return err
 	}
 	return nil
@@ -0,0 +1,156 @@
+package ldb
+
+import (
+	"encoding/binary"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/goleveldb/leveldb"
+	"github.com/FactomProject/goleveldb/leveldb/util"
+)
+
+// The ExtID index key is the table name (1 byte), the hash of the ExtID
+// (32 bytes), the ChainID (32 bytes), the dir block height (4 bytes) and the
+// entry hash (32 bytes), so the entries of an ExtID are ordered by chain and
+// height.
+const extIDKeyLength = 1 + 32 + 32 + 4 + 32
+
+func extIDKey(extID []byte, chainID *common.Hash, height uint32, entryHash *common.Hash) []byte {
+	key := extIDPrefix(extID, chainID)
+	h := make([]byte, 4)
+	binary.BigEndian.PutUint32(h, height)
+	key = append(key, h...)
+	return append(key, entryHash.Bytes()...)
+}
+
+func extIDPrefix(extID []byte, chainID *common.Hash) []byte {
+	var key = []byte{byte(TBL_EXTID)}
+	key = append(key, common.Sha(extID).Bytes()...)
+	if chainID != nil {
+		key = append(key, chainID.Bytes()...)
+	}
+	return key
+}
+
+// indexExtIDsMultiBatch adds the ExtIDs of the entries of the eblock to the
+// batch. The entries are stored before their eblock.
+func (db *LevelDb) indexExtIDsMultiBatch(eblock *common.EBlock) error {
+	keys, err := db.extIDKeys(eblock)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		db.lbatch.Put(key, []byte{})
+	}
+	return nil
+}
+
+// extIDKeys returns the ExtID index keys of the stored entries of the
+// eblock
+func (db *LevelDb) extIDKeys(eblock *common.EBlock) ([][]byte, error) {
+	keys := make([][]byte, 0)
+	for _, h := range eblock.Body.EBEntries {
+		if h.IsMinuteMarker() {
+			continue
+		}
+
+		data, err := db.lDb.Get(append([]byte{byte(TBL_ENTRY)}, h.Bytes()...), db.ro)
+		if err == leveldb.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		entry := new(common.Entry)
+		if _, err := entry.UnmarshalBinaryData(data); err != nil {
+			return nil, err
+		}
+		for _, extID := range entry.ExtIDs {
+			keys = append(keys, extIDKey(extID, eblock.Header.ChainID, eblock.Header.EBHeight, h))
+		}
+	}
+	return keys, nil
+}
+
+// FetchEntriesByExtID gets the entries with the ExtID, in the chain if
+// chainID is not nil, stored in dir blocks startHeight to endHeight
+func (db *LevelDb) FetchEntriesByExtID(extID []byte, chainID *common.Hash, startHeight, endHeight uint32) (refs []*common.ExtIDRef, err error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	prefix := extIDPrefix(extID, chainID)
+	limit := make([]byte, len(prefix))
+	copy(limit, prefix)
+	for i := len(limit) - 1; i >= 0; i-- {
+		limit[i]++
+		if limit[i] != 0 {
+			break
+		}
+	}
+
+	iter := db.lDb.NewIterator(&util.Range{Start: prefix, Limit: limit}, db.ro)
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != extIDKeyLength {
+			continue
+		}
+		height := binary.BigEndian.Uint32(key[65:69])
+		if height < startHeight || height > endHeight {
+			continue
+		}
+
+		ref := new(common.ExtIDRef)
+		ref.ChainID = common.NewHash()
+		ref.ChainID.SetBytes(key[33:65])
+		ref.EntryHash = common.NewHash()
+		ref.EntryHash.SetBytes(key[69:])
+		ref.DBHeight = height
+		refs = append(refs, ref)
+	}
+	iter.Release()
+	err = iter.Error()
+
+	return refs, err
+}
+
+// InitializeExtIDIndex indexes the ExtIDs of the stored entry blocks if the
+// index is empty, as it is in databases created before the index
+func (db *LevelDb) InitializeExtIDIndex() error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	iter := db.lDb.NewIterator(&util.Range{Start: []byte{byte(TBL_EXTID)}, Limit: []byte{byte(TBL_EXTID + 1)}}, db.ro)
+	indexed := iter.Next()
+	iter.Release()
+	if indexed {
+		return nil
+	}
+
+	if db.lbatch == nil {
+		db.lbatch = new(leveldb.Batch)
+	}
+	defer db.lbatch.Reset()
+
+	iter = db.lDb.NewIterator(&util.Range{Start: []byte{byte(TBL_EB)}, Limit: []byte{byte(TBL_EB + 1)}}, db.ro)
+	for iter.Next() {
+		eblock := common.NewEBlock()
+		if _, err := eblock.UnmarshalBinaryData(iter.Value()); err != nil {
+			iter.Release()
+			return err
+		}
+		if err := db.indexExtIDsMultiBatch(eblock); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	err := db.lDb.Write(db.lbatch, db.wo)
+	if err != nil {
+		dbLog.Error("batch failed ", err)
+		return err
+	}
+	return nil
+}
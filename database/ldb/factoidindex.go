@@ -0,0 +1,160 @@
+package ldb
+
+import (
+	"encoding/binary"
+
+	"github.com/FactomProject/factoid/block"
+	"github.com/FactomProject/goleveldb/leveldb"
+	"github.com/FactomProject/goleveldb/leveldb/util"
+)
+
+// The factoid transaction index key is the table name (1 byte) and the
+// transaction id (32 bytes), its value the height of the factoid block.
+//
+// The factoid address index key is the table name (1 byte), the address
+// (32 bytes) and the height (4 bytes) of a factoid block with a transaction
+// of the address as an input or an output, so the blocks of an address are
+// ordered by height.
+const fctAddressKeyLength = 1 + 32 + 4
+
+func fctTxKey(txid []byte) []byte {
+	return append([]byte{byte(TBL_FCT_TX)}, txid...)
+}
+
+func fctAddressKey(address []byte, height uint32) []byte {
+	key := append([]byte{byte(TBL_FCT_ADDRESS)}, address...)
+	h := make([]byte, 4)
+	binary.BigEndian.PutUint32(h, height)
+	return append(key, h...)
+}
+
+// fBlockIndexKeys returns the transaction and the address index keys of the
+// factoid block
+func fBlockIndexKeys(fBlock block.IFBlock) (txKeys, addressKeys [][]byte) {
+	seen := make(map[string]bool)
+	for _, t := range fBlock.GetTransactions() {
+		txKeys = append(txKeys, fctTxKey(t.GetHash().Bytes()))
+
+		var addresses [][]byte
+		for _, v := range t.GetInputs() {
+			addresses = append(addresses, v.GetAddress().Bytes())
+		}
+		for _, v := range t.GetOutputs() {
+			addresses = append(addresses, v.GetAddress().Bytes())
+		}
+		for _, v := range t.GetECOutputs() {
+			addresses = append(addresses, v.GetAddress().Bytes())
+		}
+		for _, adr := range addresses {
+			if !seen[string(adr)] {
+				seen[string(adr)] = true
+				addressKeys = append(addressKeys, fctAddressKey(adr, fBlock.GetDBHeight()))
+			}
+		}
+	}
+	return txKeys, addressKeys
+}
+
+// indexFBlockMultiBatch adds the transactions and the addresses of the
+// factoid block to the batch
+func (db *LevelDb) indexFBlockMultiBatch(fBlock block.IFBlock) {
+	height := make([]byte, 4)
+	binary.BigEndian.PutUint32(height, fBlock.GetDBHeight())
+
+	txKeys, addressKeys := fBlockIndexKeys(fBlock)
+	for _, key := range txKeys {
+		db.lbatch.Put(key, height)
+	}
+	for _, key := range addressKeys {
+		db.lbatch.Put(key, []byte{})
+	}
+}
+
+// FetchFactoidTxHeight gets the height of the factoid block with the
+// transaction, and false if the transaction is not stored
+func (db *LevelDb) FetchFactoidTxHeight(txid []byte) (uint32, bool, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	data, err := db.lDb.Get(fctTxKey(txid), db.ro)
+	if err == leveldb.ErrNotFound {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	if len(data) != 4 {
+		return 0, false, nil
+	}
+	return binary.BigEndian.Uint32(data), true, nil
+}
+
+// FetchFBlockHeightsByAddress gets the heights of the factoid blocks with a
+// transaction of the address, in ascending order
+func (db *LevelDb) FetchFBlockHeightsByAddress(address []byte) (heights []uint32, err error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	prefix := append([]byte{byte(TBL_FCT_ADDRESS)}, address...)
+	limit := make([]byte, len(prefix))
+	copy(limit, prefix)
+	for i := len(limit) - 1; i >= 0; i-- {
+		limit[i]++
+		if limit[i] != 0 {
+			break
+		}
+	}
+
+	iter := db.lDb.NewIterator(&util.Range{Start: prefix, Limit: limit}, db.ro)
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != fctAddressKeyLength {
+			continue
+		}
+		heights = append(heights, binary.BigEndian.Uint32(key[33:]))
+	}
+	iter.Release()
+	err = iter.Error()
+
+	return heights, err
+}
+
+// InitializeFactoidIndex indexes the transactions and the addresses of the
+// stored factoid blocks if the index is empty, as it is in databases
+// created before the index
+func (db *LevelDb) InitializeFactoidIndex() error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	iter := db.lDb.NewIterator(&util.Range{Start: []byte{byte(TBL_FCT_TX)}, Limit: []byte{byte(TBL_FCT_TX + 1)}}, db.ro)
+	indexed := iter.Next()
+	iter.Release()
+	if indexed {
+		return nil
+	}
+
+	if db.lbatch == nil {
+		db.lbatch = new(leveldb.Batch)
+	}
+	defer db.lbatch.Reset()
+
+	iter = db.lDb.NewIterator(&util.Range{Start: []byte{byte(TBL_SC)}, Limit: []byte{byte(TBL_SC + 1)}}, db.ro)
+	for iter.Next() {
+		fBlock := new(block.FBlock)
+		if _, err := fBlock.UnmarshalBinaryData(iter.Value()); err != nil {
+			iter.Release()
+			return err
+		}
+		db.indexFBlockMultiBatch(fBlock)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	err := db.lDb.Write(db.lbatch, db.wo)
+	if err != nil {
+		dbLog.Error("batch failed ", err)
+		return err
+	}
+	return nil
+}
@@ -7,7 +7,6 @@ package ldb
 import (
 	"encoding/binary"
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 	"sync"
@@ -17,6 +16,7 @@ import (
 	"github.com/FactomProject/btcd/wire"
 	"github.com/FactomProject/goleveldb/leveldb"
 	//	"github.com/FactomProject/goleveldb/leveldb/cache"
+	lerrors "github.com/FactomProject/goleveldb/leveldb/errors"
 	"github.com/FactomProject/goleveldb/leveldb/opt"
 )
 
@@ -60,6 +60,22 @@ const (
 
 	//Entry
 	TBL_ENTRY
+
+	// Balance state snapshots
+	TBL_BALANCE
+
+	// Unmatched commits and reveals
+	TBL_PENDING
+
+	// Entries by ExtID
+	TBL_EXTID
+
+	// Entry credit blocks by public key
+	TBL_EC_ADDRESS
+
+	// Factoid blocks by transaction id and by address
+	TBL_FCT_TX
+	TBL_FCT_ADDRESS
 )
 
 // the process status in db
@@ -123,7 +139,7 @@ func openDB(dbpath string, create bool) (pbdb database.Db, err error) {
 	if create == true {
 		err = os.MkdirAll(dbpath, 0750)
 		if err != nil {
-			log.Println("mkdir failed %v %v", dbpath, err)
+			dbLog.Errorf("mkdir failed %v %v", dbpath, err)
 			return
 		}
 	} else {
@@ -165,6 +181,12 @@ func openDB(dbpath string, create bool) (pbdb database.Db, err error) {
 	}
 
 	tlDb, err = leveldb.OpenFile(dbpath, opts)
+	if lerrors.IsCorrupted(err) {
+		// rebuild the manifest from the table files, the dir blocks are
+		// verified by the processor when it starts
+		dbLog.Errorf("Database %s is corrupted, recovering it: %v", dbpath, err)
+		tlDb, err = leveldb.RecoverFile(dbpath, opts)
+	}
 	if err != nil {
 		return
 	}
@@ -199,7 +221,7 @@ func (db *LevelDb) EndBatch() error {
 
 	err := db.lDb.Write(db.lbatch, db.wo)
 	if err != nil {
-		fmt.Printf("batch failed %v\n", err)
+		dbLog.Error("batch failed ", err)
 		return err
 	}
 
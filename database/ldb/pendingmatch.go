@@ -0,0 +1,71 @@
+package ldb
+
+import (
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/goleveldb/leveldb"
+	"github.com/FactomProject/goleveldb/leveldb/util"
+)
+
+// InsertPendingMatch stores an unmatched commit or reveal
+func (db *LevelDb) InsertPendingMatch(pending *common.PendingMatch) error {
+	if pending == nil {
+		return nil
+	}
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	if db.lbatch == nil {
+		db.lbatch = new(leveldb.Batch)
+	}
+	defer db.lbatch.Reset()
+
+	binaryPending, err := pending.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	db.lbatch.Put(pendingMatchKey(pending.Type, pending.EntryHash), binaryPending)
+
+	err = db.lDb.Write(db.lbatch, db.wo)
+	if err != nil {
+		dbLog.Error("batch failed ", err)
+		return err
+	}
+	return nil
+}
+
+// DeletePendingMatch removes an unmatched commit or reveal
+func (db *LevelDb) DeletePendingMatch(pendingType uint8, entryHash *common.Hash) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	return db.lDb.Delete(pendingMatchKey(pendingType, entryHash), db.wo)
+}
+
+// FetchAllPendingMatches gets all of the unmatched commits and reveals
+func (db *LevelDb) FetchAllPendingMatches() (pendings []*common.PendingMatch, err error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	var fromkey = []byte{byte(TBL_PENDING)}   // Table Name (1 bytes)
+	var tokey = []byte{byte(TBL_PENDING + 1)} // Table Name (1 bytes)
+
+	iter := db.lDb.NewIterator(&util.Range{Start: fromkey, Limit: tokey}, db.ro)
+
+	for iter.Next() {
+		pending := common.NewPendingMatch()
+		_, err := pending.UnmarshalBinaryData(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		pendings = append(pendings, pending)
+	}
+	iter.Release()
+	err = iter.Error()
+
+	return pendings, err
+}
+
+func pendingMatchKey(pendingType uint8, entryHash *common.Hash) []byte {
+	var key = []byte{byte(TBL_PENDING), pendingType} // Table Name (1 bytes) + type (1 bytes)
+	return append(key, entryHash.Bytes()...)
+}
@@ -0,0 +1,196 @@
+package ldb
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/goleveldb/leveldb"
+	"github.com/FactomProject/goleveldb/leveldb/util"
+)
+
+// FetchDBlockHeight returns the height of the highest dir block in the
+// height index, or -1 if there is none
+func (db *LevelDb) FetchDBlockHeight() (int64, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	height := int64(-1)
+	iter := db.lDb.NewIterator(&util.Range{Start: []byte{byte(TBL_DB_NUM)}, Limit: []byte{byte(TBL_DB_NUM + 1)}}, db.ro)
+	if iter.Last() && len(iter.Key()) == 5 {
+		height = int64(binary.BigEndian.Uint32(iter.Key()[1:]))
+	}
+	iter.Release()
+	return height, iter.Error()
+}
+
+// DeleteDBlocksFrom rolls the database back to the dir block before height:
+// it deletes the dir blocks from height up, the admin, entry credit, factoid
+// and entry blocks they reference with their index entries, including the
+// ExtIDs of their entries, and the balance snapshots after the roll back,
+// and moves the chain heads back. The blocks referenced by a dir block
+// that cannot be read are left, they are overwritten when stored again.
+func (db *LevelDb) DeleteDBlocksFrom(height uint32) error {
+	top, err := db.FetchDBlockHeight()
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	// the new heads of the entry chains, nil for a chain created in the
+	// deleted blocks
+	heads := make(map[string]*common.Hash)
+	chains := make(map[string]*common.Hash)
+
+	for h := int64(height); h <= top; h++ {
+		heightBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(heightBytes, uint32(h))
+		batch.Delete(tableKey(TBL_DB_NUM, heightBytes))
+		batch.Delete(tableKey(TBL_AB_NUM, common.ADMIN_CHAINID, heightBytes))
+		batch.Delete(tableKey(TBL_CB_NUM, common.EC_CHAINID, heightBytes))
+		batch.Delete(tableKey(TBL_SC_NUM, common.FACTOID_CHAINID, heightBytes))
+
+		dbHash, err := db.FetchDBHashByHeight(uint32(h))
+		if err != nil || dbHash == nil {
+			continue
+		}
+		batch.Delete(tableKey(TBL_DB, dbHash.Bytes()))
+		batch.Delete(tableKey(TBL_DB_INFO, dbHash.Bytes()))
+
+		dblock, err := db.FetchDBlockByHash(dbHash)
+		if err != nil || dblock == nil {
+			dbLog.Warningf("Dir block %d cannot be read, the blocks it references are left", h)
+			continue
+		}
+		dblock.BuildKeyMerkleRoot()
+		batch.Delete(tableKey(TBL_DB_MR, dblock.KeyMR.Bytes()))
+
+		for _, dbEntry := range dblock.DBEntries {
+			chainID := dbEntry.ChainID.Bytes()
+			switch {
+			case bytes.Equal(chainID, common.ADMIN_CHAINID):
+				batch.Delete(tableKey(TBL_AB, dbEntry.KeyMR.Bytes()))
+			case bytes.Equal(chainID, common.EC_CHAINID):
+				if ecBlock, _ := db.FetchECBlockByHash(dbEntry.KeyMR); ecBlock != nil {
+					for _, pub := range ecBlockAddresses(ecBlock) {
+						batch.Delete(ecAddressKey(pub, ecBlock.Header.EBHeight))
+					}
+				}
+				batch.Delete(tableKey(TBL_CB, dbEntry.KeyMR.Bytes()))
+			case bytes.Equal(chainID, common.FACTOID_CHAINID):
+				if fBlock, _ := db.FetchFBlockByHash(dbEntry.KeyMR); fBlock != nil {
+					txKeys, addressKeys := fBlockIndexKeys(fBlock)
+					for _, key := range append(txKeys, addressKeys...) {
+						batch.Delete(key)
+					}
+				}
+				batch.Delete(tableKey(TBL_SC, dbEntry.KeyMR.Bytes()))
+			default:
+				eblock, err := db.FetchEBlockByMR(dbEntry.KeyMR)
+				if err != nil || eblock == nil {
+					continue
+				}
+				if keys, err := db.extIDKeys(eblock); err == nil {
+					for _, key := range keys {
+						batch.Delete(key)
+					}
+				}
+				batch.Delete(tableKey(TBL_EB_MR, dbEntry.KeyMR.Bytes()))
+				if ebHash, err := eblock.Hash(); err == nil {
+					batch.Delete(tableKey(TBL_EB, ebHash.Bytes()))
+				}
+				seq := make([]byte, 4)
+				binary.BigEndian.PutUint32(seq, eblock.Header.EBSequence)
+				batch.Delete(tableKey(TBL_EB_CHAIN_NUM, chainID, seq))
+
+				key := dbEntry.ChainID.String()
+				if _, ok := heads[key]; !ok {
+					chains[key] = dbEntry.ChainID
+					heads[key] = nil
+					if eblock.Header.EBSequence > 0 {
+						heads[key] = eblock.Header.PrevKeyMR
+					}
+				}
+			}
+		}
+	}
+
+	// the heads of the dir block and the special chains
+	var dHead *common.Hash
+	if height > 0 {
+		if dbHash, _ := db.FetchDBHashByHeight(height - 1); dbHash != nil {
+			if prev, _ := db.FetchDBlockByHash(dbHash); prev != nil {
+				prev.BuildKeyMerkleRoot()
+				dHead = prev.KeyMR
+			}
+		}
+	}
+
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	setHead := func(chainID []byte, head []byte) {
+		if head == nil {
+			batch.Delete(tableKey(TBL_CHAIN_HEAD, chainID))
+		} else {
+			batch.Put(tableKey(TBL_CHAIN_HEAD, chainID), head)
+		}
+	}
+	if dHead != nil {
+		setHead(common.D_CHAINID, dHead.Bytes())
+	} else {
+		setHead(common.D_CHAINID, nil)
+	}
+	for _, s := range []struct {
+		table   uint8
+		chainID []byte
+	}{
+		{TBL_AB_NUM, common.ADMIN_CHAINID},
+		{TBL_CB_NUM, common.EC_CHAINID},
+		{TBL_SC_NUM, common.FACTOID_CHAINID},
+	} {
+		var head []byte
+		if height > 0 {
+			heightBytes := make([]byte, 4)
+			binary.BigEndian.PutUint32(heightBytes, height-1)
+			head, _ = db.lDb.Get(tableKey(s.table, s.chainID, heightBytes), db.ro)
+		}
+		setHead(s.chainID, head)
+	}
+	for key, head := range heads {
+		if head == nil {
+			setHead(chains[key].Bytes(), nil)
+			batch.Delete(tableKey(TBL_CHAIN_HASH, chains[key].Bytes()))
+		} else {
+			setHead(chains[key].Bytes(), head.Bytes())
+		}
+	}
+
+	// the balance snapshots after the roll back
+	iter := db.lDb.NewIterator(&util.Range{Start: balanceStateKey(height), Limit: []byte{byte(TBL_BALANCE + 1)}}, db.ro)
+	for iter.Next() {
+		key := make([]byte, len(iter.Key()))
+		copy(key, iter.Key())
+		batch.Delete(key)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if err := db.lDb.Write(batch, db.wo); err != nil {
+		dbLog.Error("batch failed ", err)
+		return err
+	}
+	db.lastDirBlkHeight = int64(height) - 1
+	db.lastDirBlkShaCached = false
+	return nil
+}
+
+func tableKey(table uint8, parts ...[]byte) []byte {
+	key := []byte{byte(table)}
+	for _, p := range parts {
+		key = append(key, p...)
+	}
+	return key
+}
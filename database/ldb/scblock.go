@@ -32,7 +32,7 @@ func (db *LevelDb) ProcessFBlockBatch(block block.IFBlock) error {
 
 	err = db.lDb.Write(db.lbatch, db.wo)
 	if err != nil {
-		fmt.Printf("batch failed %v\n", err)
+		dbLog.Error("batch failed ", err)
 		return err
 	}
 	return nil
@@ -72,6 +72,8 @@ func (db *LevelDb) ProcessFBlockMultiBatch(block block.IFBlock) error {
 	key = append(key, common.FACTOID_CHAINID...)
 	db.lbatch.Put(key, scHash.Bytes())
 
+	db.indexFBlockMultiBatch(block)
+
 	return nil
 }
 
@@ -5,14 +5,21 @@
 package factomapi
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"sort"
 
+	"github.com/FactomProject/FactomCode/anchor"
 	"github.com/FactomProject/FactomCode/common"
 	"github.com/FactomProject/FactomCode/database"
+	"github.com/FactomProject/FactomCode/factomlog"
+	"github.com/FactomProject/FactomCode/p2p"
 	"github.com/FactomProject/FactomCode/process"
+	"github.com/FactomProject/FactomCode/util"
 	"github.com/FactomProject/btcd/wire"
 	fct "github.com/FactomProject/factoid"
+	"github.com/FactomProject/factoid/block"
 )
 
 var (
@@ -35,6 +42,7 @@ func ChainHead(chainid string) (*common.Hash, error) {
 func CommitChain(c *common.CommitChain) error {
 	m := wire.NewMsgCommitChain()
 	m.CommitChain = c
+	p2p.NoteOrigin(m, p2p.OriginAPI)
 	inMsgQ <- m
 	return nil
 }
@@ -42,6 +50,7 @@ func CommitChain(c *common.CommitChain) error {
 func CommitEntry(c *common.CommitEntry) error {
 	m := wire.NewMsgCommitEntry()
 	m.CommitEntry = c
+	p2p.NoteOrigin(m, p2p.OriginAPI)
 	inMsgQ <- m
 	return nil
 }
@@ -49,6 +58,7 @@ func CommitEntry(c *common.CommitEntry) error {
 func FactoidTX(t fct.ITransaction) error {
 	m := new(wire.MsgFactoidTX)
 	m.SetTransaction(t)
+	p2p.NoteOrigin(m, p2p.OriginAPI)
 	inMsgQ <- m
 	return nil
 }
@@ -90,6 +100,182 @@ func EBlockByKeyMR(keymr string) (*common.EBlock, error) {
 	return r, nil
 }
 
+// ChainEntry is an entry of a chain and the entry block it was recorded in
+type ChainEntry struct {
+	EntryHash  *common.Hash
+	EBSequence uint32
+	DBHeight   uint32
+}
+
+// ChainCursor is the position of a page of chain entries: the entry of
+// index Offset of the entry block of sequence EBSequence, counting the
+// entries in the order of the pages and skipping the minute markers
+type ChainCursor struct {
+	EBSequence uint32
+	Offset     int
+}
+
+// ChainEntries returns limit entries of a chain starting at the cursor,
+// oldest first or newest first if descending. A nil cursor starts at the
+// first entry block, or at the chain head if descending. It also returns
+// the cursor of the next page, nil at the end of the chain, and the number
+// of entry blocks of the chain. Only the entry blocks of the page are read.
+func ChainEntries(chainid string, cursor *ChainCursor, limit int, descending bool) ([]*ChainEntry, *ChainCursor, uint32, error) {
+	if limit < 1 {
+		return nil, nil, 0, fmt.Errorf("Invalid limit: %d", limit)
+	}
+	h, err := atoh(chainid)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	mr, err := db.FetchHeadMRByChainID(h)
+	if err != nil || mr == nil {
+		return nil, nil, 0, fmt.Errorf("Chain not found")
+	}
+	head, err := db.FetchEBlockByMR(mr)
+	if err != nil || head == nil {
+		return nil, nil, 0, fmt.Errorf("Chain not found")
+	}
+	count := head.Header.EBSequence + 1
+
+	c := ChainCursor{}
+	if cursor != nil {
+		c = *cursor
+	} else if descending {
+		c.EBSequence = head.Header.EBSequence
+	}
+	if c.EBSequence >= count || c.Offset < 0 {
+		return nil, nil, 0, fmt.Errorf("Invalid cursor %d/%d", c.EBSequence, c.Offset)
+	}
+
+	entries := make([]*ChainEntry, 0, limit)
+	for {
+		eb, err := db.FetchEBlockBySequence(h, c.EBSequence)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if eb == nil {
+			return nil, nil, 0, fmt.Errorf("Entry block %d of the chain not found", c.EBSequence)
+		}
+
+		hashes := make([]*common.Hash, 0, len(eb.Body.EBEntries))
+		for _, v := range eb.Body.EBEntries {
+			if !v.IsMinuteMarker() {
+				hashes = append(hashes, v)
+			}
+		}
+		if descending {
+			for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+				hashes[i], hashes[j] = hashes[j], hashes[i]
+			}
+		}
+		for ; c.Offset < len(hashes) && len(entries) < limit; c.Offset++ {
+			entries = append(entries, &ChainEntry{
+				EntryHash:  hashes[c.Offset],
+				EBSequence: eb.Header.EBSequence,
+				DBHeight:   eb.Header.EBHeight,
+			})
+		}
+		if c.Offset < len(hashes) {
+			return entries, &c, count, nil
+		}
+
+		// the page goes on in the next entry block
+		if (descending && c.EBSequence == 0) || (!descending && c.EBSequence+1 == count) {
+			return entries, nil, count, nil
+		}
+		if descending {
+			c.EBSequence--
+		} else {
+			c.EBSequence++
+		}
+		c.Offset = 0
+		if len(entries) == limit {
+			return entries, &c, count, nil
+		}
+	}
+}
+
+// EntriesByExtIDs returns limit entries starting at offset that have all of
+// the ExtIDs, in the chain unless chainid is empty, stored in dir blocks
+// startHeight to endHeight, and the total number of such entries. The entries
+// are in height order, newest first if descending.
+func EntriesByExtIDs(extIDs [][]byte, chainid string, startHeight, endHeight uint32, offset, limit int, descending bool) ([]*common.ExtIDRef, int, error) {
+	if len(extIDs) == 0 {
+		return nil, 0, fmt.Errorf("No ExtID to search")
+	}
+	var chainID *common.Hash
+	if chainid != "" {
+		h, err := atoh(chainid)
+		if err != nil {
+			return nil, 0, err
+		}
+		chainID = h
+	}
+
+	var refs []*common.ExtIDRef
+	for i, extID := range extIDs {
+		found, err := db.FetchEntriesByExtID(extID, chainID, startHeight, endHeight)
+		if err != nil {
+			return nil, 0, err
+		}
+		if i == 0 {
+			refs = found
+			continue
+		}
+		has := make(map[string]bool, len(found))
+		for _, r := range found {
+			has[r.EntryHash.String()] = true
+		}
+		matched := refs[:0]
+		for _, r := range refs {
+			if has[r.EntryHash.String()] {
+				matched = append(matched, r)
+			}
+		}
+		refs = matched
+	}
+
+	// an entry with the same ExtID twice is listed once
+	seen := make(map[string]bool, len(refs))
+	unique := make([]*common.ExtIDRef, 0, len(refs))
+	for _, r := range refs {
+		if !seen[r.EntryHash.String()] {
+			seen[r.EntryHash.String()] = true
+			unique = append(unique, r)
+		}
+	}
+	if descending {
+		sort.Sort(sort.Reverse(byExtIDRefHeight(unique)))
+	} else {
+		sort.Sort(byExtIDRefHeight(unique))
+	}
+
+	total := len(unique)
+	if offset >= total {
+		return make([]*common.ExtIDRef, 0), total, nil
+	}
+	unique = unique[offset:]
+	if limit > 0 && limit < len(unique) {
+		unique = unique[:limit]
+	}
+	return unique, total, nil
+}
+
+type byExtIDRefHeight []*common.ExtIDRef
+
+func (r byExtIDRefHeight) Len() int      { return len(r) }
+func (r byExtIDRefHeight) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byExtIDRefHeight) Less(i, j int) bool {
+	if r[i].DBHeight != r[j].DBHeight {
+		return r[i].DBHeight < r[j].DBHeight
+	}
+	if c := bytes.Compare(r[i].ChainID.Bytes(), r[j].ChainID.Bytes()); c != 0 {
+		return c < 0
+	}
+	return bytes.Compare(r[i].EntryHash.Bytes(), r[j].EntryHash.Bytes()) < 0
+}
+
 func ECBalance(eckey string) (uint32, error) {
 	key := new([32]byte)
 	if p, err := hex.DecodeString(eckey); err != nil {
@@ -101,6 +287,349 @@ func ECBalance(eckey string) (uint32, error) {
 	return uint32(val), nil
 }
 
+// AddressBalance is the balance of a factoid or entry credit address
+type AddressBalance struct {
+	Address string
+	Balance int64
+}
+
+// AddressBalances are the balances of addresses at a dir block height
+type AddressBalances struct {
+	DBHeight            uint32
+	FactoidBalances     []AddressBalance
+	EntryCreditBalances []AddressBalance
+}
+
+// balanceAttempts is how many times the balances are read again when a dir
+// block is stored while they are read
+const balanceAttempts = 3
+
+// Balances returns the balances of the factoid and entry credit addresses
+// and the height of the last dir block they include.
+func Balances(fctAddresses, ecAddresses []string) (*AddressBalances, error) {
+	fctKeys, err := balanceKeys(fctAddresses)
+	if err != nil {
+		return nil, err
+	}
+	ecKeys, err := balanceKeys(ecAddresses)
+	if err != nil {
+		return nil, err
+	}
+
+	var b *AddressBalances
+	for i := 0; i < balanceAttempts; i++ {
+		_, height, err := db.FetchBlockHeightCache()
+		if err != nil {
+			return nil, err
+		}
+
+		b = new(AddressBalances)
+		if height > 0 {
+			b.DBHeight = uint32(height)
+		}
+		b.FactoidBalances = make([]AddressBalance, len(fctKeys))
+		for j, k := range fctKeys {
+			b.FactoidBalances[j] = AddressBalance{
+				Address: fctAddresses[j],
+				Balance: int64(common.FactoidState.GetBalance(fct.NewAddress(k))),
+			}
+		}
+		b.EntryCreditBalances = make([]AddressBalance, len(ecKeys))
+		for j, k := range ecKeys {
+			key := new([32]byte)
+			copy(key[:], k)
+			val, _ := process.GetEntryCreditBalance(key)
+			b.EntryCreditBalances[j] = AddressBalance{Address: ecAddresses[j], Balance: int64(val)}
+		}
+
+		if _, after, err := db.FetchBlockHeightCache(); err == nil && after == height {
+			break
+		}
+	}
+	return b, nil
+}
+
+// balanceKeys decodes the hex addresses
+func balanceKeys(addresses []string) ([][]byte, error) {
+	keys := make([][]byte, len(addresses))
+	for i, a := range addresses {
+		k, err := hex.DecodeString(a)
+		if err != nil || len(k) != common.HASH_LENGTH {
+			return nil, fmt.Errorf("Invalid address: %s", a)
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+// Factoid transaction status
+const (
+	FactoidTxConfirmed = "confirmed" // in a stored factoid block
+	FactoidTxUnknown   = "unknown"
+)
+
+// FactoidIO is an input or output of a factoid transaction
+type FactoidIO struct {
+	Address string
+	Amount  uint64
+}
+
+// FactoidTx is a factoid transaction stored in a factoid block
+type FactoidTx struct {
+	TxID      string
+	DBHeight  uint32
+	Timestamp uint64 // milliseconds
+	Inputs    []FactoidIO
+	Outputs   []FactoidIO
+	ECOutputs []FactoidIO
+}
+
+func newFactoidTx(t fct.ITransaction, height uint32) *FactoidTx {
+	tx := &FactoidTx{
+		TxID:      hex.EncodeToString(t.GetHash().Bytes()),
+		DBHeight:  height,
+		Timestamp: t.GetMilliTimestamp(),
+		Inputs:    make([]FactoidIO, 0),
+		Outputs:   make([]FactoidIO, 0),
+		ECOutputs: make([]FactoidIO, 0),
+	}
+	for _, v := range t.GetInputs() {
+		tx.Inputs = append(tx.Inputs, FactoidIO{hex.EncodeToString(v.GetAddress().Bytes()), v.GetAmount()})
+	}
+	for _, v := range t.GetOutputs() {
+		tx.Outputs = append(tx.Outputs, FactoidIO{hex.EncodeToString(v.GetAddress().Bytes()), v.GetAmount()})
+	}
+	for _, v := range t.GetECOutputs() {
+		tx.ECOutputs = append(tx.ECOutputs, FactoidIO{hex.EncodeToString(v.GetAddress().Bytes()), v.GetAmount()})
+	}
+	return tx
+}
+
+// hasAddress tells if the address is an input or an output of the transaction
+func (t *FactoidTx) hasAddress(address string) bool {
+	for _, l := range [][]FactoidIO{t.Inputs, t.Outputs, t.ECOutputs} {
+		for _, v := range l {
+			if v.Address == address {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// factoidTxs returns the transactions of the factoid blocks at the heights
+// for which match returns true, oldest first
+func factoidTxs(heights []uint32, match func(t fct.ITransaction) bool) ([]*FactoidTx, error) {
+	txs := make([]*FactoidTx, 0)
+	for _, height := range heights {
+		b, err := db.FetchFBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			continue
+		}
+		for _, t := range b.GetTransactions() {
+			if match(t) {
+				txs = append(txs, newFactoidTx(t, b.GetDBHeight()))
+			}
+		}
+	}
+	return txs, nil
+}
+
+// FactoidTxByID returns the stored factoid transaction with the txid
+func FactoidTxByID(txid string) (*FactoidTx, error) {
+	id, err := hex.DecodeString(txid)
+	if err != nil {
+		return nil, err
+	}
+	height, found, err := db.FetchFactoidTxHeight(id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Factoid transaction not found")
+	}
+	txs, err := factoidTxs([]uint32{height}, func(t fct.ITransaction) bool {
+		return bytes.Equal(t.GetHash().Bytes(), id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(txs) == 0 {
+		return nil, fmt.Errorf("Factoid transaction not found")
+	}
+	return txs[0], nil
+}
+
+// FactoidTxStatus returns the status of the factoid transaction: its ack
+// status while it is pending, confirmed with the height of its factoid block,
+// or unknown.
+func FactoidTxStatus(txid string) (string, uint32, error) {
+	for _, p := range process.GetPending().FactoidTxs {
+		if p.Hash == txid {
+			return p.Status, 0, nil
+		}
+	}
+
+	tx, err := FactoidTxByID(txid)
+	if err != nil {
+		if _, err := hex.DecodeString(txid); err != nil {
+			return "", 0, err
+		}
+		return FactoidTxUnknown, 0, nil
+	}
+	return FactoidTxConfirmed, tx.DBHeight, nil
+}
+
+// FactoidTxsByAddress returns limit stored transactions of the factoid or EC
+// address starting at offset, oldest first or newest first if descending,
+// and the total number of transactions of the address.
+func FactoidTxsByAddress(address string, offset, limit int, descending bool) ([]*FactoidTx, int, error) {
+	adr, err := hex.DecodeString(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(adr) != common.HASH_LENGTH {
+		return nil, 0, fmt.Errorf("Invalid address: %s", address)
+	}
+	address = hex.EncodeToString(adr)
+
+	heights, err := db.FetchFBlockHeightsByAddress(adr)
+	if err != nil {
+		return nil, 0, err
+	}
+	txs, err := factoidTxs(heights, func(t fct.ITransaction) bool {
+		return newFactoidTx(t, 0).hasAddress(address)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if descending {
+		for i, j := 0, len(txs)-1; i < j; i, j = i+1, j-1 {
+			txs[i], txs[j] = txs[j], txs[i]
+		}
+	}
+
+	total := len(txs)
+	if offset >= total {
+		return make([]*FactoidTx, 0), total, nil
+	}
+	txs = txs[offset:]
+	if limit > 0 && limit < len(txs) {
+		txs = txs[:limit]
+	}
+	return txs, total, nil
+}
+
+// EC transaction types
+const (
+	ECPurchase    = "purchase"
+	ECCommitChain = "commit-chain"
+	ECCommitEntry = "commit-entry"
+)
+
+// ECTransaction is a purchase or a spend of the entry credits of an address
+type ECTransaction struct {
+	Type      string
+	DBHeight  uint32
+	Minute    uint8
+	Credits   int32        // positive for purchases, negative for commits
+	Balance   int32        // balance after the transaction
+	EntryHash *common.Hash `json:",omitempty"` // the entry of a commit
+	TxID      *common.Hash `json:",omitempty"` // the factoid transaction of a purchase
+}
+
+// ECHistory returns limit transactions of the EC address starting at offset,
+// oldest first or newest first if descending, and the total number of
+// transactions. Only the transactions in stored blocks are listed.
+func ECHistory(eckey string, offset, limit int, descending bool) ([]*ECTransaction, int, error) {
+	key, err := hex.DecodeString(eckey)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(key) != 32 {
+		return nil, 0, fmt.Errorf("Invalid EC address: %s", eckey)
+	}
+
+	heights, err := db.FetchECBlockHeightsByAddress(key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	history := make([]*ECTransaction, 0)
+	var balance int32
+	for _, height := range heights {
+		block, err := db.FetchECBlockByHeight(height)
+		if err != nil {
+			return nil, 0, err
+		}
+		// a minute number marks the end of the minute, the transactions
+		// before it are in that minute
+		var minute []*ECTransaction
+		for _, entry := range block.Body.Entries {
+			var t *ECTransaction
+			switch e := entry.(type) {
+			case *common.MinuteNumber:
+				for _, t := range minute {
+					t.Minute = e.Number
+				}
+				minute = minute[:0]
+			case *common.CommitChain:
+				if bytes.Equal(e.ECPubKey[:], key) {
+					t = &ECTransaction{Type: ECCommitChain, Credits: -int32(e.Credits), EntryHash: e.EntryHash}
+				}
+			case *common.CommitEntry:
+				if bytes.Equal(e.ECPubKey[:], key) {
+					t = &ECTransaction{Type: ECCommitEntry, Credits: -int32(e.Credits), EntryHash: e.EntryHash}
+				}
+			case *common.IncreaseBalance:
+				if bytes.Equal(e.ECPubKey[:], key) {
+					t = &ECTransaction{Type: ECPurchase, Credits: int32(e.NumEC), TxID: e.TXID}
+				}
+			}
+			if t == nil {
+				continue
+			}
+			balance += t.Credits
+			t.DBHeight = block.Header.EBHeight
+			t.Balance = balance
+			minute = append(minute, t)
+			history = append(history, t)
+		}
+	}
+
+	if descending {
+		for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+			history[i], history[j] = history[j], history[i]
+		}
+	}
+
+	total := len(history)
+	if offset >= total {
+		return make([]*ECTransaction, 0), total, nil
+	}
+	history = history[offset:]
+	if limit > 0 && limit < len(history) {
+		history = history[:limit]
+	}
+	return history, total, nil
+}
+
+// ExchangeRate returns the current factoshis per entry credit and the signed
+// rate changes waiting for their activation height.
+func ExchangeRate() (uint64, []*common.ExchangeRateChange) {
+	return process.GetExchangeRates()
+}
+
+// ServerIdentities returns the registered federated and audit server
+// identities with their signing keys.
+func ServerIdentities() []process.ServerIdentity {
+	return process.GetServerIdentities()
+}
+
 func EntryByHash(hash string) (*common.Entry, error) {
 	h, err := atoh(hash)
 	if err != nil {
@@ -116,9 +645,385 @@ func EntryByHash(hash string) (*common.Entry, error) {
 	return r, nil
 }
 
+// Receipt returns the proof that the entry is in a directory block, with the
+// Bitcoin anchor of the directory block once it is confirmed.
+func Receipt(entryhash string) (*common.Receipt, error) {
+	entry, err := EntryByHash(entryhash)
+	if err != nil {
+		return nil, err
+	}
+	h, _ := atoh(entryhash)
+
+	eblocks, err := db.FetchAllEBlocksByChain(entry.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	var eblock *common.EBlock
+	index := -1
+	if eblocks != nil {
+		for i := 0; i < len(*eblocks) && eblock == nil; i++ {
+			for j, v := range (*eblocks)[i].Body.EBEntries {
+				if v.IsSameAs(h) {
+					eblock, index = &(*eblocks)[i], j
+					break
+				}
+			}
+		}
+	}
+	if eblock == nil {
+		return nil, fmt.Errorf("Entry is not in an entry block yet")
+	}
+
+	r := new(common.Receipt)
+	r.EntryHash = h
+	r.ChainID = eblock.Header.ChainID
+	if r.EntryBranch, err = common.MerkleBranch(eblock.Body.EBEntries, index); err != nil {
+		return nil, err
+	}
+	header, err := eblock.HeaderHash()
+	if err != nil {
+		return nil, err
+	}
+	r.EntryBranch = append(r.EntryBranch, &common.MerkleStep{Sibling: header, Left: true})
+	if r.EBlockKeyMR, err = eblock.KeyMR(); err != nil {
+		return nil, err
+	}
+
+	dbHash, err := db.FetchDBHashByHeight(eblock.Header.EBHeight)
+	if err != nil {
+		return nil, err
+	}
+	dblock, err := db.FetchDBlockByHash(dbHash)
+	if err != nil {
+		return nil, err
+	}
+	if dblock == nil {
+		return nil, fmt.Errorf("DBlock not found")
+	}
+
+	hashes := make([]*common.Hash, len(dblock.DBEntries))
+	index = -1
+	for i, v := range dblock.DBEntries {
+		data, _ := v.MarshalBinary()
+		hashes[i] = common.Sha(data)
+		if v.KeyMR.IsSameAs(r.EBlockKeyMR) {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("Entry block %s is not in the directory block", r.EBlockKeyMR)
+	}
+	if r.DBlockBranch, err = common.MerkleBranch(hashes, index); err != nil {
+		return nil, err
+	}
+	dheader, err := dblock.Header.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	r.DBlockBranch = append(r.DBlockBranch, &common.MerkleStep{Sibling: common.Sha(dheader), Left: true})
+	dblock.BuildKeyMerkleRoot()
+	r.DBlockKeyMR = dblock.KeyMR
+	r.DBHeight = dblock.Header.DBHeight
+
+	info, err := db.FetchDirBlockInfoByHash(dbHash)
+	if err != nil {
+		return nil, err
+	}
+	if info != nil && info.BTCConfirmed {
+		r.BitcoinTxID = info.BTCTxHash
+		r.BitcoinBlockHash = info.BTCBlockHash
+		r.BitcoinBlockHeight = info.BTCBlockHeight
+		if r.AnchorRange, err = anchorRange(r.DBHeight, info); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// The bitcoin anchor status of a dir block
+const (
+	AnchorNotAnchored = "not anchored"
+	AnchorPending     = "pending"
+	AnchorConfirmed   = "confirmed"
+)
+
+// AnchorStatus is the anchor of a dir block, with the records of the anchor
+// chain for it. The bitcoin record is written once the anchor is confirmed.
+type AnchorStatus struct {
+	DBHeight    uint32
+	KeyMR       string
+	DBHash      string
+	Status      string
+	BitcoinTxID string                      `json:",omitempty"`
+	Records     []*anchor.AnchorRecordEntry `json:",omitempty"`
+}
+
+// AnchorRecords returns the anchor status and records of the dir block at
+// height
+func AnchorRecords(height uint32) (*AnchorStatus, error) {
+	dbHash, err := db.FetchDBHashByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	if dbHash == nil {
+		return nil, fmt.Errorf("DBlock not found")
+	}
+	info, err := db.FetchDirBlockInfoByHash(dbHash)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AnchorStatus{DBHeight: height, DBHash: dbHash.String(), Status: AnchorNotAnchored}
+	if info != nil {
+		a.KeyMR = info.DBMerkleRoot.String()
+		if info.BTCConfirmed {
+			a.Status = AnchorConfirmed
+		} else if info.BTCTxHash != nil && !info.BTCTxHash.IsSameAs(common.NewHash()) {
+			a.Status = AnchorPending
+		}
+		if a.Status != AnchorNotAnchored {
+			a.BitcoinTxID = info.BTCTxHash.String()
+		}
+	}
+
+	cfg := util.ReadConfig()
+	chainID, err := common.HexToHash(cfg.Anchor.AnchorChainID)
+	if err != nil {
+		return nil, err
+	}
+	eblocks, err := db.FetchAllEBlocksByChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	if eblocks == nil {
+		return a, nil
+	}
+	pubKey := common.PubKeyFromString(cfg.App.ServerPubKey)
+
+	// the records of a dir block are written in later dir blocks
+	for _, eblock := range *eblocks {
+		if eblock.Header.EBHeight < height {
+			continue
+		}
+		for _, h := range eblock.Body.EBEntries {
+			entry, err := db.FetchEntryByHash(h)
+			if err != nil || entry == nil {
+				continue
+			}
+			e, err := anchor.ParseAnchorRecordEntry(entry, pubKey)
+			if err != nil {
+				continue
+			}
+			r := e.Record
+			if r.DBHeight == height || (r.MerkleRoot != "" && r.FirstDBHeight <= height && height < r.DBHeight) {
+				a.Records = append(a.Records, e)
+			}
+		}
+	}
+	return a, nil
+}
+
+// BitcoinAnchorProof returns the SPV proof of the bitcoin anchor of the dir
+// block with the keymr, the height of the dir block, and its anchor range if
+// the anchor is aggregated
+func BitcoinAnchorProof(keymr string) (uint32, *common.AnchorRange, *common.BitcoinAnchorProof, error) {
+	dblock, err := DBlockByKeyMR(keymr)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if dblock == nil {
+		return 0, nil, nil, fmt.Errorf("DBlock not found")
+	}
+	height := dblock.Header.DBHeight
+
+	dbHash, err := db.FetchDBHashByHeight(height)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	info, err := db.FetchDirBlockInfoByHash(dbHash)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if info == nil {
+		return 0, nil, nil, fmt.Errorf("Directory block %d is not anchored", height)
+	}
+
+	p, err := anchor.FetchBitcoinAnchorProof(info)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	r, err := anchorRange(height, info)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return height, r, p, nil
+}
+
+// anchorRange returns the aggregated anchor range of the confirmed dir block
+// at height: the dir blocks around it confirmed in the same bitcoin
+// transaction. It is nil if the dir block is anchored alone.
+func anchorRange(height uint32, info *common.DirBlockInfo) (*common.AnchorRange, error) {
+	sameAnchor := func(h uint32) *common.Hash {
+		dbHash, err := db.FetchDBHashByHeight(h)
+		if err != nil || dbHash == nil {
+			return nil
+		}
+		i, err := db.FetchDirBlockInfoByHash(dbHash)
+		if err != nil || i == nil || !i.BTCConfirmed || !i.BTCTxHash.IsSameAs(info.BTCTxHash) {
+			return nil
+		}
+		return i.DBMerkleRoot
+	}
+
+	keyMRs := []*common.Hash{info.DBMerkleRoot}
+	first := height
+	for first > 0 && len(keyMRs) < common.MaxAnchorRange {
+		keyMR := sameAnchor(first - 1)
+		if keyMR == nil {
+			break
+		}
+		keyMRs = append([]*common.Hash{keyMR}, keyMRs...)
+		first--
+	}
+	for h := height + 1; len(keyMRs) < common.MaxAnchorRange; h++ {
+		keyMR := sameAnchor(h)
+		if keyMR == nil {
+			break
+		}
+		keyMRs = append(keyMRs, keyMR)
+	}
+
+	if len(keyMRs) == 1 {
+		return nil, nil
+	}
+	return common.NewAnchorRange(first, height, keyMRs)
+}
+
+// HeightBlocks are the blocks of a dir block height. The child blocks are set
+// only if they are requested.
+type HeightBlocks struct {
+	DBlock  *common.DirectoryBlock
+	ABlock  *common.AdminBlock `json:",omitempty"`
+	ECBlock *common.ECBlock    `json:",omitempty"`
+	FBlock  block.IFBlock      `json:",omitempty"`
+	EBlocks []*common.EBlock   `json:",omitempty"`
+	Entries []*common.Entry    `json:",omitempty"`
+}
+
+// BlocksAt returns the dir block at height, with its admin, entry credit,
+// factoid and entry blocks and their entries if children is true.
+func BlocksAt(height uint32, children bool) (*HeightBlocks, error) {
+	dblock, err := db.FetchDBlockByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	if dblock == nil {
+		return nil, fmt.Errorf("Directory block %d not found", height)
+	}
+	b := &HeightBlocks{DBlock: dblock}
+	if !children {
+		return b, nil
+	}
+
+	if b.ABlock, err = db.FetchABlockByHeight(height); err != nil {
+		return nil, err
+	}
+	if b.ECBlock, err = db.FetchECBlockByHeight(height); err != nil {
+		return nil, err
+	}
+	if b.FBlock, err = db.FetchFBlockByHeight(height); err != nil {
+		return nil, err
+	}
+
+	for _, dbEntry := range dblock.DBEntries {
+		switch dbEntry.ChainID.String() {
+		case hex.EncodeToString(common.ADMIN_CHAINID),
+			hex.EncodeToString(common.EC_CHAINID),
+			hex.EncodeToString(common.FACTOID_CHAINID):
+			continue
+		}
+
+		eblock, err := db.FetchEBlockByMR(dbEntry.KeyMR)
+		if err != nil {
+			return nil, err
+		}
+		if eblock == nil {
+			return nil, fmt.Errorf("Entry block %s not found", dbEntry.KeyMR)
+		}
+		b.EBlocks = append(b.EBlocks, eblock)
+
+		for _, h := range eblock.Body.EBEntries {
+			if h.IsMinuteMarker() {
+				continue
+			}
+			entry, err := db.FetchEntryByHash(h)
+			if err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				return nil, fmt.Errorf("Entry %s not found", h)
+			}
+			b.Entries = append(b.Entries, entry)
+		}
+	}
+	return b, nil
+}
+
+// PendingMatches returns the commits without reveals and the reveals without
+// commits that are waiting to be paired.
+func PendingMatches() ([]*common.PendingMatch, error) {
+	return process.GetPendingMatches()
+}
+
+// Pending returns the commits, reveals and factoid transactions that are not
+// in a block yet, with their ack status.
+func Pending() *process.PendingView {
+	return process.GetPending()
+}
+
+// AuditRecords returns the recent records of the audit log matching the
+// filter
+func AuditRecords(f *factomlog.AuditFilter) ([]*factomlog.AuditRecord, error) {
+	records := process.AuditRecords(f)
+	if records == nil {
+		return nil, fmt.Errorf("The audit log is not enabled")
+	}
+	return records, nil
+}
+
+// NodeStatus returns the sync, mem pool and anchor state of the node
+func NodeStatus() *process.NodeStatus {
+	return process.GetNodeStatus()
+}
+
+// StateHash returns the state hash of the dir block at height
+func StateHash(height uint32) (*common.Hash, error) {
+	h, ok := process.GetStateHash(height)
+	if !ok {
+		return nil, fmt.Errorf("State hash not found")
+	}
+	return h, nil
+}
+
+// LatestStateHash returns the height and the state hash of the last dir block
+func LatestStateHash() (uint32, *common.Hash) {
+	return process.GetLatestStateHash()
+}
+
+// Subscribe registers a subscription for the events of the new blocks. The
+// caller must Unsubscribe it when done.
+func Subscribe() *process.Subscription {
+	return process.Subscribe()
+}
+
 func RevealEntry(e *common.Entry) error {
 	m := wire.NewMsgRevealEntry()
 	m.Entry = e
+	p2p.NoteOrigin(m, p2p.OriginAPI)
 	inMsgQ <- m
 	return nil
 }
@@ -0,0 +1,143 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package factomapi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/process"
+	fct "github.com/FactomProject/factoid"
+)
+
+// TxBuilder builds a factoid transaction paying its outputs from a set of
+// addresses. The inputs are selected from the largest balances, each spends
+// its whole balance, and what is left after the outputs and the fee goes to
+// the change address.
+type TxBuilder struct {
+	PubKeys   []string // hex public keys the inputs are selected from
+	Change    string   // hex factoid address, the first selected input if empty
+	Outputs   []FactoidIO
+	ECOutputs []FactoidIO
+}
+
+// NewTxBuilder returns a builder selecting its inputs from the public keys
+func NewTxBuilder(pubKeys ...string) *TxBuilder {
+	return &TxBuilder{
+		PubKeys:   pubKeys,
+		Outputs:   make([]FactoidIO, 0),
+		ECOutputs: make([]FactoidIO, 0),
+	}
+}
+
+// AddOutput pays factoshis to the factoid address
+func (b *TxBuilder) AddOutput(address string, amount uint64) {
+	b.Outputs = append(b.Outputs, FactoidIO{address, amount})
+}
+
+// AddECOutput converts factoshis into entry credits of the EC public key
+func (b *TxBuilder) AddECOutput(ecPubKey string, amount uint64) {
+	b.ECOutputs = append(b.ECOutputs, FactoidIO{ecPubKey, amount})
+}
+
+// Build returns the request to sign the transaction
+func (b *TxBuilder) Build() (*common.SigningRequest, error) {
+	t, _, err := b.build()
+	if err != nil {
+		return nil, err
+	}
+	return common.NewFactoidTxRequest(t, process.NetworkName())
+}
+
+// builderInput is an address the inputs can be selected from
+type builderInput struct {
+	pubKey  string
+	address string
+	balance uint64
+}
+
+type byBalance []builderInput
+
+func (f byBalance) Len() int           { return len(f) }
+func (f byBalance) Less(i, j int) bool { return f[i].balance > f[j].balance }
+func (f byBalance) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// build returns the unsigned transaction with its fee
+func (b *TxBuilder) build() (*fct.Transaction, uint64, error) {
+	if len(b.Outputs)+len(b.ECOutputs) == 0 {
+		return nil, 0, fmt.Errorf("The transaction has no outputs")
+	}
+	var out uint64
+	for _, l := range [][]FactoidIO{b.Outputs, b.ECOutputs} {
+		for _, v := range l {
+			if v.Amount == 0 {
+				return nil, 0, fmt.Errorf("The output to %s has no amount", v.Address)
+			}
+			out += v.Amount
+		}
+	}
+
+	candidates := make([]builderInput, 0, len(b.PubKeys))
+	var available uint64
+	for _, k := range b.PubKeys {
+		pub, err := decodePubKey(k)
+		if err != nil {
+			return nil, 0, err
+		}
+		adr, err := fct.NewRCD_1(pub[:]).GetAddress()
+		if err != nil {
+			return nil, 0, err
+		}
+		balance := uint64(common.FactoidState.GetBalance(adr))
+		if balance > 0 {
+			candidates = append(candidates, builderInput{k, hex.EncodeToString(adr.Bytes()), balance})
+			available += balance
+		}
+	}
+	sort.Sort(byBalance(candidates))
+
+	rate := common.FactoidState.GetFactoshisPerEC()
+	inputs := make([]FactoidInput, 0)
+	var in, fee uint64
+	for _, c := range candidates {
+		inputs = append(inputs, FactoidInput{c.pubKey, c.balance})
+		in += c.balance
+		if in < out {
+			continue
+		}
+
+		change := b.Change
+		if change == "" {
+			change = candidates[0].address
+		}
+		t, err := buildFactoidTx(inputs, append(b.Outputs, FactoidIO{change, 0}), b.ECOutputs)
+		if err != nil {
+			return nil, 0, err
+		}
+		if fee, err = t.CalculateFee(rate); err != nil {
+			return nil, 0, err
+		}
+		if in < out+fee {
+			continue
+		}
+
+		// without change, the fee computed with the change output is
+		// slightly overpaid
+		outputs := b.Outputs
+		if in > out+fee {
+			outputs = append(outputs, FactoidIO{change, in - out - fee})
+		}
+		t, err = buildFactoidTx(inputs, outputs, b.ECOutputs)
+		if err != nil {
+			return nil, 0, err
+		}
+		return t, fee, nil
+	}
+
+	return nil, 0, fmt.Errorf("Insufficient funds: %s available in %d addresses, %s needed for the outputs and the fee",
+		fct.ConvertDecimal(available), len(candidates), fct.ConvertDecimal(out+fee))
+}
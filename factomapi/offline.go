@@ -0,0 +1,216 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package factomapi
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/process"
+	"github.com/FactomProject/FactomCode/util"
+	fct "github.com/FactomProject/factoid"
+)
+
+// The unsigned commits and factoid transactions are returned as signing
+// requests, signed with factom-sign on a machine holding the keys, and
+// submitted back with SubmitSigned. The node never sees the private keys.
+
+// FactoidInput is an input of an unsigned factoid transaction, given by the
+// public key which signs it
+type FactoidInput struct {
+	PubKey string
+	Amount uint64
+}
+
+// UnsignedCommitEntry returns the request to sign the commit of the entry,
+// paid by the EC public key
+func UnsignedCommitEntry(e *common.Entry, ecPubKey string) (*common.SigningRequest, error) {
+	pub, err := decodePubKey(ecPubKey)
+	if err != nil {
+		return nil, err
+	}
+	credits, err := entryCredits(e)
+	if err != nil {
+		return nil, err
+	}
+
+	c := common.NewCommitEntry()
+	c.MilliTime = milliTime()
+	c.EntryHash = e.Hash()
+	c.Credits = credits
+	c.ECPubKey = pub
+	return common.NewCommitEntryRequest(c, process.NetworkName())
+}
+
+// UnsignedCommitChain returns the request to sign the commit of the new
+// chain whose first entry is e, paid by the EC public key
+func UnsignedCommitChain(e *common.Entry, ecPubKey string) (*common.SigningRequest, error) {
+	pub, err := decodePubKey(ecPubKey)
+	if err != nil {
+		return nil, err
+	}
+	e.ChainID = common.NewChainID(e)
+	credits, err := entryCredits(e)
+	if err != nil {
+		return nil, err
+	}
+
+	c := common.NewCommitChain()
+	c.MilliTime = milliTime()
+	c.ChainIDHash.SetBytes(common.DoubleSha(e.ChainID.Bytes()))
+	c.EntryHash = e.Hash()
+	c.Weld.SetBytes(common.DoubleSha(append(c.EntryHash.Bytes(), e.ChainID.Bytes()...)))
+	// 10 credits are for the chain creation
+	c.Credits = credits + 10
+	c.ECPubKey = pub
+	return common.NewCommitChainRequest(c, process.NetworkName())
+}
+
+// UnsignedFactoidTx returns the request to sign the transaction spending the
+// inputs to the factoid and entry credit outputs. The inputs must pay the
+// outputs and the fee.
+func UnsignedFactoidTx(inputs []FactoidInput, outputs, ecOutputs []FactoidIO) (*common.SigningRequest, error) {
+	t, err := buildFactoidTx(inputs, outputs, ecOutputs)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFactoidTxAmounts(t); err != nil {
+		return nil, err
+	}
+	return common.NewFactoidTxRequest(t, process.NetworkName())
+}
+
+// buildFactoidTx returns the unsigned transaction spending the inputs to the
+// outputs
+func buildFactoidTx(inputs []FactoidInput, outputs, ecOutputs []FactoidIO) (*fct.Transaction, error) {
+	t := new(fct.Transaction)
+	t.SetMilliTimestamp(uint64(time.Now().UnixNano() / 1e6))
+
+	for _, in := range inputs {
+		pub, err := decodePubKey(in.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		rcd := fct.NewRCD_1(pub[:])
+		adr, err := rcd.GetAddress()
+		if err != nil {
+			return nil, err
+		}
+		t.AddInput(adr, in.Amount)
+		t.AddAuthorization(rcd)
+	}
+	for _, out := range outputs {
+		adr, err := decodeAddress(out.Address)
+		if err != nil {
+			return nil, err
+		}
+		t.AddOutput(adr, out.Amount)
+	}
+	for _, out := range ecOutputs {
+		adr, err := decodeAddress(out.Address)
+		if err != nil {
+			return nil, err
+		}
+		t.AddECOutput(adr, out.Amount)
+	}
+	return t, nil
+}
+
+// checkFactoidTxAmounts checks that the inputs pay the outputs and the fee
+func checkFactoidTxAmounts(t *fct.Transaction) error {
+	fee, err := t.CalculateFee(common.FactoidState.GetFactoshisPerEC())
+	if err != nil {
+		return err
+	}
+	var in, out uint64
+	for _, v := range t.GetInputs() {
+		in += v.GetAmount()
+	}
+	for _, v := range t.GetOutputs() {
+		out += v.GetAmount()
+	}
+	for _, v := range t.GetECOutputs() {
+		out += v.GetAmount()
+	}
+	if in < out+fee {
+		return fmt.Errorf("The inputs of %s do not pay the outputs of %s and the fee of %s",
+			fct.ConvertDecimal(in), fct.ConvertDecimal(out), fct.ConvertDecimal(fee))
+	}
+	return nil
+}
+
+// SubmitSigned verifies the signatures of the signed request and submits
+// the commit or transaction
+func SubmitSigned(r *common.SigningRequest) (string, error) {
+	if r.Network != process.NetworkName() {
+		return "", fmt.Errorf("The request is for %s, not %s", r.Network, process.NetworkName())
+	}
+	p, err := r.Signed()
+	if err != nil {
+		return "", err
+	}
+
+	switch r.Type {
+	case common.SignCommitChain:
+		c := common.NewCommitChain()
+		if err := c.UnmarshalBinary(p); err != nil {
+			return "", err
+		}
+		return c.EntryHash.String(), CommitChain(c)
+	case common.SignCommitEntry:
+		c := common.NewCommitEntry()
+		if err := c.UnmarshalBinary(p); err != nil {
+			return "", err
+		}
+		return c.EntryHash.String(), CommitEntry(c)
+	}
+
+	t := new(fct.Transaction)
+	if _, err := t.UnmarshalBinaryData(p); err != nil {
+		return "", err
+	}
+	if err := common.FactoidState.Validate(1, t); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(t.GetHash().Bytes()), FactoidTX(t)
+}
+
+func entryCredits(e *common.Entry) (uint8, error) {
+	bin, err := e.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	return util.EntryCost(bin)
+}
+
+// milliTime returns the current time in milliseconds on 6 bytes
+func milliTime() *[6]byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(time.Now().UnixNano()/1e6))
+	t := new([6]byte)
+	copy(t[:], b[2:])
+	return t
+}
+
+func decodePubKey(s string) (*[32]byte, error) {
+	p, err := hex.DecodeString(s)
+	if err != nil || len(p) != 32 {
+		return nil, fmt.Errorf("Invalid public key %s", s)
+	}
+	pub := new([32]byte)
+	copy(pub[:], p)
+	return pub, nil
+}
+
+func decodeAddress(s string) (fct.IAddress, error) {
+	p, err := hex.DecodeString(s)
+	if err != nil || len(p) != 32 {
+		return nil, fmt.Errorf("Invalid address %s", s)
+	}
+	return fct.NewAddress(p), nil
+}
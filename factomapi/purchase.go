@@ -0,0 +1,83 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package factomapi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/process"
+)
+
+// purchasePoll is how often the EC balance is read while waiting for a
+// purchase to be credited
+const purchasePoll = time.Second
+
+// CreditPurchase is a conversion of factoids into entry credits
+type CreditPurchase struct {
+	TxID      string
+	Credits   uint64 // entry credits bought
+	Factoshis uint64 // paid for the credits, without the fee
+	Fee       uint64
+	ECBalance uint32 // balance of the EC key once credited
+}
+
+// BuyEntryCredits converts factoids of the factoid key into credits of the EC
+// public key at the current rate. It builds, signs and submits the factoid
+// transaction, then waits until the EC balance reflects the purchase. On a
+// timeout the submitted purchase is returned with the error.
+func BuyEntryCredits(fctKey common.PrivateKey, ecPubKey string, credits uint64, timeout time.Duration) (*CreditPurchase, error) {
+	if credits == 0 {
+		return nil, fmt.Errorf("No entry credits to buy")
+	}
+	if _, err := decodePubKey(ecPubKey); err != nil {
+		return nil, err
+	}
+	before, err := ECBalance(ecPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := credits * common.FactoidState.GetFactoshisPerEC()
+	b := NewTxBuilder(hex.EncodeToString(fctKey.Public()))
+	b.AddECOutput(ecPubKey, amount)
+	t, fee, err := b.build()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := common.NewFactoidTxRequest(t, process.NetworkName())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Sign(fctKey); err != nil {
+		return nil, err
+	}
+	txid, err := SubmitSigned(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &CreditPurchase{TxID: txid, Credits: credits, Factoshis: amount, Fee: fee}
+	deadline := time.Now().Add(timeout)
+	for {
+		if p.ECBalance, err = ECBalance(ecPubKey); err != nil {
+			return p, err
+		}
+		if uint64(p.ECBalance) >= uint64(before)+credits {
+			return p, nil
+		}
+		// the balance may have been spent meanwhile
+		if status, _, _ := FactoidTxStatus(txid); status == FactoidTxConfirmed {
+			return p, nil
+		}
+		if time.Now().After(deadline) {
+			return p, fmt.Errorf("Timed out after %s waiting for the purchase %s to be credited", timeout, txid)
+		}
+		time.Sleep(purchasePoll)
+	}
+}
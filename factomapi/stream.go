@@ -0,0 +1,139 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package factomapi
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/factoid/block"
+)
+
+// WriteStreamBlocks writes the blocks of a height in the block stream order
+// (see common.WriteStreamBlock)
+func WriteStreamBlocks(w io.Writer, b *HeightBlocks) error {
+	write := func(blockType byte, m encoding.BinaryMarshaler) error {
+		data, err := m.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return common.WriteStreamBlock(w, blockType, data)
+	}
+
+	if err := write(common.StreamDBlock, b.DBlock); err != nil {
+		return err
+	}
+	if b.ABlock != nil {
+		if err := write(common.StreamABlock, b.ABlock); err != nil {
+			return err
+		}
+	}
+	if b.ECBlock != nil {
+		if err := write(common.StreamECBlock, b.ECBlock); err != nil {
+			return err
+		}
+	}
+	if b.FBlock != nil {
+		if err := write(common.StreamFBlock, b.FBlock); err != nil {
+			return err
+		}
+	}
+
+	entries := make(map[string]*common.Entry, len(b.Entries))
+	for _, e := range b.Entries {
+		entries[e.Hash().String()] = e
+	}
+	for _, eb := range b.EBlocks {
+		for _, h := range eb.Body.EBEntries {
+			if e, ok := entries[h.String()]; ok {
+				if err := write(common.StreamEntry, e); err != nil {
+					return err
+				}
+			}
+		}
+		if err := write(common.StreamEBlock, eb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamReader reads a block stream height by height
+type StreamReader struct {
+	r io.Reader
+
+	// the dir block starting the next height, read with the last height
+	next *common.DirectoryBlock
+}
+
+// NewStreamReader returns a StreamReader reading the block stream from r
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{r: r}
+}
+
+// Next returns the blocks of the next height in the stream, or io.EOF at
+// the end of the stream
+func (s *StreamReader) Next() (*HeightBlocks, error) {
+	b := new(HeightBlocks)
+	if s.next != nil {
+		b.DBlock, s.next = s.next, nil
+	}
+
+	for {
+		blockType, data, err := common.ReadStreamBlock(s.r)
+		if err == io.EOF && b.DBlock != nil {
+			return b, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b.DBlock == nil && blockType != common.StreamDBlock {
+			return nil, fmt.Errorf("Block stream does not start with a directory block")
+		}
+
+		switch blockType {
+		case common.StreamDBlock:
+			dblock := common.NewDirectoryBlock()
+			if err := dblock.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+			if b.DBlock != nil {
+				s.next = dblock
+				return b, nil
+			}
+			b.DBlock = dblock
+		case common.StreamABlock:
+			b.ABlock = new(common.AdminBlock)
+			if err := b.ABlock.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+		case common.StreamECBlock:
+			b.ECBlock = common.NewECBlock()
+			if err := b.ECBlock.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+		case common.StreamFBlock:
+			fblock := new(block.FBlock)
+			if err := fblock.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+			b.FBlock = fblock
+		case common.StreamEBlock:
+			eblock := common.NewEBlock()
+			if err := eblock.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+			b.EBlocks = append(b.EBlocks, eblock)
+		case common.StreamEntry:
+			entry := common.NewEntry()
+			if err := entry.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+			b.Entries = append(b.Entries, entry)
+		}
+	}
+}
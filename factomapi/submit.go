@@ -0,0 +1,180 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package factomapi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/process"
+)
+
+// Stages of an entry submission
+const (
+	StageCommit = "commit" // waiting for the commit ack
+	StageReveal = "reveal" // waiting for the reveal ack
+	StageDone   = "done"
+)
+
+const (
+	// submitPoll is how often the pending messages are read while waiting
+	// for an ack
+	submitPoll = 200 * time.Millisecond
+	// submitRetry is how long a message may be missing from the pending
+	// messages before it is sent again
+	submitRetry = 5 * time.Second
+)
+
+// EntrySubmission is the progress of an entry submitted with SubmitEntry or
+// SubmitChain
+type EntrySubmission struct {
+	EntryHash string
+	ChainID   string
+	Stage     string
+	Retries   int // messages sent again
+}
+
+// SubmitEntry commits the entry paid by the EC key, waits for the commit
+// ack, reveals the entry and returns once the reveal is acknowledged. On a
+// node which is not the leader, nothing is acked and the messages count as
+// acknowledged once the node accepts them.
+//
+// The signed commit and the reveal are sent again while they are missing
+// from the pending messages, which is idempotent: a node drops a message it
+// has already seen. On a timeout the submission has the stage it reached.
+func SubmitEntry(e *common.Entry, ecKey common.PrivateKey, timeout time.Duration) (*EntrySubmission, error) {
+	r, err := UnsignedCommitEntry(e, hex.EncodeToString(ecKey.Public()))
+	if err != nil {
+		return nil, err
+	}
+	return submit(e, r, ecKey, 0, timeout)
+}
+
+// SubmitChain creates the chain whose first entry is e, like SubmitEntry
+func SubmitChain(e *common.Entry, ecKey common.PrivateKey, timeout time.Duration) (*EntrySubmission, error) {
+	r, err := UnsignedCommitChain(e, hex.EncodeToString(ecKey.Public()))
+	if err != nil {
+		return nil, err
+	}
+	return submit(e, r, ecKey, 10, timeout)
+}
+
+func submit(e *common.Entry, r *common.SigningRequest, ecKey common.PrivateKey, extra uint32, timeout time.Duration) (*EntrySubmission, error) {
+	if n, err := r.Sign(ecKey); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, fmt.Errorf("The EC key does not sign the commit")
+	}
+
+	ecPubKey := hex.EncodeToString(ecKey.Public())
+	credits, err := entryCredits(e)
+	if err != nil {
+		return nil, err
+	}
+	if balance, err := ECBalance(ecPubKey); err != nil {
+		return nil, err
+	} else if balance < uint32(credits)+extra {
+		return nil, fmt.Errorf("Insufficient entry credits: %s has %d, %d needed",
+			ecPubKey, balance, uint32(credits)+extra)
+	}
+
+	s := &EntrySubmission{
+		EntryHash: e.Hash().String(),
+		ChainID:   e.ChainID.String(),
+		Stage:     StageCommit,
+	}
+	deadline := time.Now().Add(timeout)
+
+	commit := func() error {
+		_, err := SubmitSigned(r)
+		return err
+	}
+	if err := commit(); err != nil {
+		return s, err
+	}
+	commitAcked := func() bool {
+		switch pendingStatus(process.GetPending().Commits, s.EntryHash) {
+		case process.PendingAcked:
+			return true
+		case process.PendingUnacked:
+			return !process.IsLeader()
+		}
+		// already revealed
+		return entryStored(s.EntryHash)
+	}
+	commitPending := func() bool {
+		return pendingStatus(process.GetPending().Commits, s.EntryHash) != ""
+	}
+	if err := s.wait(deadline, commitAcked, commitPending, commit); err != nil {
+		return s, err
+	}
+
+	s.Stage = StageReveal
+	reveal := func() error {
+		return RevealEntry(e)
+	}
+	if err := reveal(); err != nil {
+		return s, err
+	}
+	revealAcked := func() bool {
+		p := process.GetPending()
+		switch pendingStatus(p.Reveals, s.EntryHash) {
+		case process.PendingAcked:
+			return true
+		case "":
+			// a follower accepting the reveal removes its commit
+			if !process.IsLeader() && pendingStatus(p.Commits, s.EntryHash) == "" {
+				return true
+			}
+		}
+		return entryStored(s.EntryHash)
+	}
+	revealPending := func() bool {
+		return pendingStatus(process.GetPending().Reveals, s.EntryHash) != ""
+	}
+	if err := s.wait(deadline, revealAcked, revealPending, reveal); err != nil {
+		return s, err
+	}
+
+	s.Stage = StageDone
+	return s, nil
+}
+
+// wait polls until done, sending the message again every submitRetry while
+// it is not pending
+func (s *EntrySubmission) wait(deadline time.Time, done, pending func() bool, send func() error) error {
+	sent := time.Now()
+	for !done() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out waiting for the %s ack of entry %s", s.Stage, s.EntryHash)
+		}
+		if !pending() && time.Since(sent) >= submitRetry {
+			if err := send(); err != nil {
+				return err
+			}
+			s.Retries++
+			sent = time.Now()
+		}
+		time.Sleep(submitPoll)
+	}
+	return nil
+}
+
+// pendingStatus returns the status of the pending item with the hash, or ""
+func pendingStatus(items []*process.PendingItem, hash string) string {
+	for _, p := range items {
+		if p.Hash == hash {
+			return p.Status
+		}
+	}
+	return ""
+}
+
+func entryStored(hash string) bool {
+	e, err := EntryByHash(hash)
+	return err == nil && e != nil
+}
@@ -0,0 +1,384 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/database"
+	"github.com/FactomProject/FactomCode/database/ldb"
+	"github.com/FactomProject/FactomCode/factomapi"
+	"github.com/FactomProject/FactomCode/process"
+	"github.com/FactomProject/FactomCode/util"
+)
+
+// The db subcommands work on the database of the data directory while
+// factomd is stopped. The database is locked by a running factomd, so they
+// refuse to open it then.
+
+const dbUsage = `Usage: factomd db <command> [flags]
+
+Commands:
+  check                          verify that every dir block links to the
+                                 previous one and its blocks and entries
+                                 are stored and match their hashes
+  compact                        compact the database files
+  export [-from N] [-to N] FILE  write the blocks to FILE, - for stdout, as
+                                 a block stream
+  import FILE                    validate and store the blocks of a block
+                                 stream after the last stored dir block
+  prune [-older-than 24h]        delete the unmatched commits and reveals
+                                 older than the duration
+`
+
+// dbMain runs a db subcommand and returns the exit code
+func dbMain(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, dbUsage)
+		return 2
+	}
+
+	var run func(database.Db, []string) error
+	switch args[0] {
+	case "check":
+		run = dbCheck
+	case "compact":
+		run = dbCompact
+	case "export":
+		run = dbExport
+	case "import":
+		run = dbImport
+	case "prune":
+		run = dbPrune
+	default:
+		fmt.Fprint(os.Stderr, dbUsage)
+		return 2
+	}
+
+	path := util.ReadConfig().App.LdbPath
+	db, err := ldb.OpenLevelDB(path, false)
+	if err != nil || db == nil {
+		fmt.Fprintf(os.Stderr, "Cannot open the database at %s, is factomd running? %v\n", path, err)
+		return 1
+	}
+	defer db.Close()
+	factomapi.SetDB(db)
+
+	if err := run(db, args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func dbCheck(db database.Db, args []string) error {
+	_, head, err := db.FetchBlockHeightCache()
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	report := func(height int64, format string, a ...interface{}) {
+		problems++
+		fmt.Fprintf(os.Stderr, "height %d: %s\n", height, fmt.Sprintf(format, a...))
+	}
+
+	var prevHash *common.Hash
+	for h := int64(0); h <= head; h++ {
+		b, err := factomapi.BlocksAt(uint32(h), true)
+		if err != nil {
+			report(h, "%v", err)
+			prevHash = nil
+			continue
+		}
+		if h > 0 && prevHash != nil && !prevHash.IsSameAs(b.DBlock.Header.PrevLedgerKeyMR) {
+			report(h, "does not link to the previous dir block %s", prevHash)
+		}
+		if b.DBlock.Header.DBHeight != uint32(h) {
+			report(h, "dir block has the height %d", b.DBlock.Header.DBHeight)
+		}
+		if err := checkHeightBlocks(b); err != nil {
+			report(h, "%v", err)
+		}
+		prevHash, _ = common.CreateHash(b.DBlock)
+
+		if h > 0 && h%10000 == 0 {
+			fmt.Fprintf(os.Stderr, "checked %d of %d dir blocks\n", h, head+1)
+		}
+	}
+	if b, _ := db.FetchDBlockByHeight(uint32(head + 1)); b != nil {
+		report(head+1, "dir block stored above the height cache")
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems found in %d dir blocks", problems, head+1)
+	}
+	fmt.Fprintf(os.Stderr, "%d dir blocks checked, no problem found\n", head+1)
+	return nil
+}
+
+// checkHeightBlocks returns an error if a block referenced by the dir block
+// is missing or does not match its KeyMR, or if an entry is missing
+func checkHeightBlocks(b *factomapi.HeightBlocks) error {
+	keyMRs := make(map[string]bool)
+	if b.ABlock != nil {
+		if h, err := b.ABlock.PartialHash(); err == nil {
+			keyMRs[h.String()] = true
+		}
+	}
+	if b.ECBlock != nil {
+		if h, err := b.ECBlock.HeaderHash(); err == nil {
+			keyMRs[h.String()] = true
+		}
+	}
+	if b.FBlock != nil {
+		keyMRs[b.FBlock.GetHash().String()] = true
+	}
+	for _, eb := range b.EBlocks {
+		if h, err := eb.KeyMR(); err == nil {
+			keyMRs[h.String()] = true
+		}
+	}
+	for _, dbEntry := range b.DBlock.DBEntries {
+		if !keyMRs[dbEntry.KeyMR.String()] {
+			return fmt.Errorf("block %s of chain %s is missing or does not match", dbEntry.KeyMR, dbEntry.ChainID)
+		}
+	}
+
+	entries := make(map[string]bool)
+	for _, e := range b.Entries {
+		entries[e.Hash().String()] = true
+	}
+	for _, eb := range b.EBlocks {
+		for _, h := range eb.Body.EBEntries {
+			if !h.IsMinuteMarker() && !entries[h.String()] {
+				return fmt.Errorf("entry %s is missing", h)
+			}
+		}
+	}
+	return nil
+}
+
+func dbCompact(db database.Db, args []string) error {
+	c, ok := db.(interface {
+		Compact() error
+	})
+	if !ok {
+		return fmt.Errorf("The database cannot be compacted")
+	}
+	start := time.Now()
+	if err := c.Compact(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "database compacted in %s\n", time.Since(start))
+	return nil
+}
+
+func dbExport(db database.Db, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	from := fs.Int64("from", 0, "first dir block height")
+	to := fs.Int64("to", -1, "last dir block height, the last stored if -1")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("export needs the file to write, - for stdout")
+	}
+
+	_, head, err := db.FetchBlockHeightCache()
+	if err != nil {
+		return err
+	}
+	if *to < 0 || *to > head {
+		*to = head
+	}
+	if *from < 0 || *from > *to {
+		return fmt.Errorf("No dir block from height %d to %d", *from, *to)
+	}
+
+	out := os.Stdout
+	if fs.Arg(0) != "-" {
+		if out, err = os.Create(fs.Arg(0)); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	w := bufio.NewWriter(out)
+	for h := *from; h <= *to; h++ {
+		b, err := factomapi.BlocksAt(uint32(h), true)
+		if err != nil {
+			return err
+		}
+		if err := factomapi.WriteStreamBlocks(w, b); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported dir blocks %d to %d\n", *from, *to)
+	return nil
+}
+
+func dbImport(db database.Db, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("import needs the file to read, - for stdin")
+	}
+	in := os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	process.LoadConfigurations(util.ReadConfig())
+	im, err := process.NewBlockImport(db)
+	if err != nil {
+		return err
+	}
+	_, head, err := db.FetchBlockHeightCache()
+	if err != nil {
+		return err
+	}
+	var prevHash *common.Hash
+	if head >= 0 {
+		prev, err := db.FetchDBlockByHeight(uint32(head))
+		if err != nil || prev == nil {
+			return fmt.Errorf("Last stored dir block %d not found", head)
+		}
+		prevHash, _ = common.CreateHash(prev)
+	}
+
+	stream := factomapi.NewStreamReader(bufio.NewReader(in))
+	imported := 0
+	for {
+		b, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		height := int64(b.DBlock.Header.DBHeight)
+		if height <= head {
+			continue
+		}
+		if height != head+1 {
+			return fmt.Errorf("Dir blocks %d to %d are missing from the stream", head+1, height-1)
+		}
+		if prevHash != nil && !prevHash.IsSameAs(b.DBlock.Header.PrevLedgerKeyMR) {
+			return fmt.Errorf("Dir block %d does not link to the stored dir block %d", height, head)
+		}
+		if err := checkHeightBlocks(b); err != nil {
+			return fmt.Errorf("Dir block %d: %v", height, err)
+		}
+		if err := im.Validate(b.DBlock, b.ABlock, b.ECBlock, b.FBlock, b.EBlocks, b.Entries); err != nil {
+			return fmt.Errorf("Dir block %d: %v", height, err)
+		}
+		if err := storeHeightBlocks(db, b); err != nil {
+			return err
+		}
+		if err := im.Stored(b.DBlock, b.EBlocks); err != nil {
+			return err
+		}
+
+		prevHash, _ = common.CreateHash(b.DBlock)
+		head = height
+		imported++
+		if imported%10000 == 0 {
+			fmt.Fprintf(os.Stderr, "imported up to dir block %d\n", head)
+		}
+	}
+	if err := im.Finish(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "imported %d dir blocks, the last stored is %d\n", imported, head)
+	return nil
+}
+
+// storeHeightBlocks stores the blocks of a height like a follower storing
+// the blocks downloaded from its peers
+func storeHeightBlocks(db database.Db, b *factomapi.HeightBlocks) error {
+	if b.ECBlock != nil {
+		if err := db.ProcessECBlockBatch(b.ECBlock); err != nil {
+			return err
+		}
+	}
+	if b.ABlock != nil {
+		if err := db.ProcessABlockBatch(b.ABlock); err != nil {
+			return err
+		}
+	}
+	if b.FBlock != nil {
+		if err := db.ProcessFBlockBatch(b.FBlock); err != nil {
+			return err
+		}
+	}
+	for _, e := range b.Entries {
+		if err := db.InsertEntry(e); err != nil {
+			return err
+		}
+	}
+	for _, eb := range b.EBlocks {
+		if err := db.ProcessEBlockBatch(eb); err != nil {
+			return err
+		}
+		// create a chain when it's the first block of the entry chain
+		if eb.Header.EBSequence == 0 && len(eb.Body.EBEntries) > 0 {
+			chain := new(common.EChain)
+			chain.ChainID = eb.Header.ChainID
+			chain.FirstEntry, _ = db.FetchEntryByHash(eb.Body.EBEntries[0])
+			if chain.FirstEntry == nil {
+				return fmt.Errorf("First entry not found for chain %s", chain.ChainID)
+			}
+			if err := db.InsertChain(chain); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := db.ProcessDBlockBatch(b.DBlock); err != nil {
+		return err
+	}
+	hash, err := common.CreateHash(b.DBlock)
+	if err != nil {
+		return err
+	}
+	return db.UpdateBlockHeightCache(b.DBlock.Header.DBHeight, hash)
+}
+
+func dbPrune(db database.Db, args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 24*time.Hour, "age of the unmatched commits and reveals deleted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pendings, err := db.FetchAllPendingMatches()
+	if err != nil {
+		return err
+	}
+	pruned := 0
+	for _, p := range pendings {
+		if time.Since(time.Unix(p.Timestamp, 0)) <= *olderThan {
+			continue
+		}
+		if err := db.DeletePendingMatch(p.Type, p.EntryHash); err != nil {
+			return err
+		}
+		pruned++
+	}
+	fmt.Fprintf(os.Stderr, "deleted %d of %d unmatched commits and reveals, run db compact to free the space\n", pruned, len(pendings))
+	return nil
+}
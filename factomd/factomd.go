@@ -10,6 +10,9 @@ import (
 	cp "github.com/FactomProject/FactomCode/controlpanel"
 	"github.com/FactomProject/FactomCode/database"
 	"github.com/FactomProject/FactomCode/database/ldb"
+	"github.com/FactomProject/FactomCode/factomlog"
+	"github.com/FactomProject/FactomCode/grpcapi"
+	"github.com/FactomProject/FactomCode/p2p"
 	"github.com/FactomProject/FactomCode/process"
 	"github.com/FactomProject/FactomCode/util"
 	"github.com/FactomProject/FactomCode/wsapi"
@@ -43,6 +46,13 @@ var (
 //var winServiceMain func() (bool, error)
 
 func main() {
+	// btcd parses the command line too, without the config flags
+	os.Args = append(os.Args[:1], util.StripConfigFlags(os.Args[1:])...)
+
+	if len(os.Args) >= 2 && os.Args[1] == "db" {
+		os.Exit(dbMain(os.Args[2:]))
+	}
+
 	ftmdLog.Info("//////////////////////// Copyright 2015 Factom Foundation")
 	ftmdLog.Info("//////////////////////// Use of this source code is governed by the MIT")
 	ftmdLog.Info("//////////////////////// license that can be found in the LICENSE file.")
@@ -102,6 +112,11 @@ func factomdMain() error {
 	// Start the wsapi server module in a separate go-routine
 	wsapi.Start(db, inMsgQueue)
 
+	// Start the gRPC server module if enabled
+	if cfg.Grpc.Enabled {
+		grpcapi.Start(cfg.Grpc.PortNumber)
+	}
+
 	// wait till the initialization is complete in processor
 	hash, _ := db.FetchDBHashByHeight(0)
 	if hash != nil {
@@ -126,6 +141,15 @@ func factomdMain() error {
 		fmt.Println("\n'factomd initializeonly' will do just that.  Initialize and stop.")
 	}
 
+	// Find the peers from the DNS seeds and relay the btcd connections
+	if err := p2p.Start(cfg); err != nil {
+		ftmdLog.Error("cannot start p2p: ", err)
+		return err
+	}
+	process.SetPeerCounter(p2p.PeerCount)
+	process.SetPeerRotator(p2p.RotatePeers)
+	process.SetPeerLister(p2p.PeerAddresses)
+
 	// Start the factoid (btcd) component and P2P component
 	btcd.Start_btcd(db, inMsgQueue, outMsgQueue, inCtlMsgQueue, outCtlMsgQueue, process.FactomdUser, process.FactomdPass, common.SERVER_NODE != cfg.App.NodeMode)
 
@@ -137,6 +161,11 @@ func loadConfigurations() {
 
 	cfg = util.ReadConfig()
 
+	if err := factomlog.Configure(cfg.Log.LogFormat, int64(cfg.Log.MaxSizeMB)<<20, cfg.Log.MaxBackups, cfg.Log.SubsystemLevel); err != nil {
+		ftmdLog.Error("invalid log settings: ", err)
+	}
+	reloadOnHangup()
+
 	homeDir = cfg.App.HomeDir
 	ldbpath = cfg.App.LdbPath
 	boltDBpath = cfg.App.BoltDBPath
@@ -0,0 +1,51 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/FactomProject/FactomCode/factomlog"
+	"github.com/FactomProject/FactomCode/util"
+)
+
+// reloadOnHangup reloads the config file each time the process gets a
+// SIGHUP
+func reloadOnHangup() {
+	util.OnReload(configureLogs, "Log.LogLevel", "Log.LogFormat", "Log.SubsystemLevel", "Log.MaxSizeMB", "Log.MaxBackups")
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for _ = range hup {
+			ftmdLog.Info("SIGHUP received, reloading the config file")
+			r, err := util.ReloadConfig()
+			if err != nil {
+				ftmdLog.Error("cannot reload the config file: ", err)
+				continue
+			}
+			if len(r.Applied) > 0 {
+				ftmdLog.Info("reloaded settings: ", r.Applied)
+			}
+			if len(r.RestartRequired) > 0 {
+				ftmdLog.Warning("changed settings needing a restart: ", r.RestartRequired)
+			}
+			for _, e := range r.Errors {
+				ftmdLog.Error("cannot reload settings ", e)
+			}
+		}
+	}()
+}
+
+// configureLogs sets the level of all the subsystems, then the format,
+// rotation and subsystem levels of the config
+func configureLogs(cfg *util.FactomdConfig) error {
+	if err := factomlog.SetSubsystemLevel("all", cfg.Log.LogLevel); err != nil {
+		return err
+	}
+	return factomlog.Configure(cfg.Log.LogFormat, int64(cfg.Log.MaxSizeMB)<<20, cfg.Log.MaxBackups, cfg.Log.SubsystemLevel)
+}
@@ -0,0 +1,94 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// factomload submits new chains and entries at a steady rate, and reports
+// how many entries a second reach a block and their latency from the commit
+// to the block. The load goes to a node, paid by an entry credit key:
+//
+//	factomload -url http://localhost:8088 -eckey <hex private key> -rate 50 -duration 5m
+//
+// or to servers simulated in the process, which seal a block every -block:
+//
+//	factomload -sim 4 -block 1s -rate 1000 -duration 1m
+//
+// The report is printed as text, or as JSON with -json, and factomload
+// exits with 1 if entries were refused or not sealed within -wait.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/loadtest"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8088", "API of the node")
+	apiKey := flag.String("apikey", "", "API key with the submit permission")
+	ecKey := flag.String("eckey", "", "hex private key of the entry credits paying the entries")
+	sim := flag.Int("sim", 0, "number of simulated servers, instead of a node")
+	block := flag.Duration("block", time.Second, "time between the blocks of the simulation")
+	rate := flag.Float64("rate", 10, "entries submitted a second")
+	duration := flag.Duration("duration", time.Minute, "duration of the submission")
+	chains := flag.Int("chains", 1, "new chains the entries are spread over")
+	size := flag.Int("size", 100, "bytes of content of the entries")
+	wait := flag.Duration("wait", 15*time.Minute, "time to wait for the last entries to be sealed")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	config := &loadtest.Config{
+		Rate:      *rate,
+		Duration:  *duration,
+		Chains:    *chains,
+		EntrySize: *size,
+		Wait:      *wait,
+	}
+	r, err := run(*url, *apiKey, *ecKey, *sim, *block, config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "factomload:", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		p, _ := json.MarshalIndent(r, "", "\t")
+		fmt.Println(string(p))
+	} else {
+		r.Write(os.Stdout)
+	}
+	if r.Failed > 0 || r.Unsealed() > 0 {
+		os.Exit(1)
+	}
+}
+
+func run(url, apiKey, ecKey string, sim int, block time.Duration, config *loadtest.Config) (*loadtest.Report, error) {
+	var target loadtest.Target
+	if sim > 0 {
+		var err error
+		if target, err = loadtest.NewSimTarget(sim, block); err != nil {
+			return nil, err
+		}
+	} else {
+		if ecKey == "" {
+			return nil, fmt.Errorf("-eckey is needed to pay the entries")
+		}
+		key, err := common.NewPrivateKeyFromHex(strings.TrimSpace(ecKey))
+		if err != nil {
+			return nil, err
+		}
+		if target, err = loadtest.NewNodeTarget(url, apiKey, key); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := loadtest.Run(target, config)
+	if cerr := target.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	return r, err
+}
@@ -0,0 +1,341 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package factomlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The audit log is a file of JSON records chained by hash: the Hash of a
+// record is the sha256 of its JSON with Hash empty, and PrevHash is the Hash
+// of the record before it, so a record removed or changed breaks the chain.
+// The file is only appended to. When it reaches its max size it is renamed
+// to path.N, N being the Seq of its first record, and kept; the chain goes
+// on in the new file.
+
+const (
+	// auditRecent is the number of records kept in memory for queries
+	auditRecent = 10000
+	// maxAuditError bounds the error of a record
+	maxAuditError = 512
+	// auditTail is read from the end of a file to find its last record
+	auditTail = 64 << 10
+)
+
+// Outcomes of the audited messages
+const (
+	AuditAccepted = "accepted"
+	AuditRejected = "rejected"
+)
+
+// AuditRecord is a message processed by the node and the outcome of its
+// validation
+type AuditRecord struct {
+	Seq      uint64
+	Time     time.Time
+	Command  string
+	MsgHash  string `json:",omitempty"`
+	Origin   string
+	Outcome  string
+	Error    string `json:",omitempty"`
+	PrevHash string
+	Hash     string
+}
+
+// hash returns the hash of the record with Hash empty
+func (r *AuditRecord) hash() string {
+	c := *r
+	c.Hash = ""
+	p, _ := json.Marshal(&c)
+	h := sha256.Sum256(p)
+	return hex.EncodeToString(h[:])
+}
+
+// AuditFilter selects audit records. Empty fields match any record.
+type AuditFilter struct {
+	Command string
+	Origin  string
+	Outcome string
+	After   uint64 // records with a greater Seq
+	Limit   int    // the most recent records, 0 for all those kept
+}
+
+func (f *AuditFilter) matches(r *AuditRecord) bool {
+	return r.Seq > f.After &&
+		(f.Command == "" || f.Command == r.Command) &&
+		(f.Origin == "" || f.Origin == r.Origin) &&
+		(f.Outcome == "" || f.Outcome == r.Outcome)
+}
+
+// AuditLog appends hash chained records to a file
+type AuditLog struct {
+	mutex   sync.Mutex
+	path    string
+	maxSize int64 // 0 never rotates
+	file    *os.File
+	size    int64
+	first   uint64 // Seq of the first record of the file
+	last    AuditRecord
+	recent  []*AuditRecord // ring of the last records
+	next    int
+}
+
+// OpenAuditLog opens the audit log at path, going on with the chain of the
+// records already in it or in its last rotated file
+func OpenAuditLog(path string, maxSize int64) (*AuditLog, error) {
+	l := &AuditLog{path: path, maxSize: maxSize, recent: make([]*AuditRecord, 0, auditRecent)}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	last, err := lastAuditRecord(path)
+	if err != nil {
+		return nil, err
+	}
+	if last == nil {
+		if last, err = lastAuditRecord(lastRotatedAuditFile(path)); err != nil {
+			return nil, err
+		}
+		l.first = 1
+		if last != nil {
+			l.first = last.Seq + 1
+		}
+	} else if first, err := firstAuditRecord(path); err != nil {
+		return nil, err
+	} else {
+		l.first = first.Seq
+	}
+	if last != nil {
+		l.last = *last
+	}
+	return l, nil
+}
+
+func (l *AuditLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	l.file, l.size = file, 0
+	if info, err := file.Stat(); err == nil {
+		l.size = info.Size()
+	}
+	return nil
+}
+
+// Append chains the record to the last one and writes it. Seq, PrevHash
+// and Hash are set, and Time if it is zero.
+func (l *AuditLog) Append(r *AuditRecord) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return os.ErrInvalid
+	}
+
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Time = r.Time.UTC()
+	if len(r.Error) > maxAuditError {
+		r.Error = r.Error[:maxAuditError]
+	}
+	r.Seq = l.last.Seq + 1
+	r.PrevHash = l.last.Hash
+	r.Hash = r.hash()
+	p, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	p = append(p, '\n')
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+		l.first = r.Seq
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	l.last = *r
+	if len(l.recent) < auditRecent {
+		l.recent = append(l.recent, r)
+	} else {
+		l.recent[l.next] = r
+		l.next = (l.next + 1) % auditRecent
+	}
+	return nil
+}
+
+func (l *AuditLog) rotate() error {
+	l.file.Sync()
+	l.file.Close()
+	l.file = nil
+	if err := os.Rename(l.path, fmt.Sprintf("%s.%d", l.path, l.first)); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// Recent returns the records kept in memory matching the filter, oldest
+// first
+func (l *AuditLog) Recent(f *AuditFilter) []*AuditRecord {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	records := make([]*AuditRecord, 0)
+	for i := range l.recent {
+		r := l.recent[(l.next+i)%len(l.recent)]
+		if f.matches(r) {
+			records = append(records, r)
+		}
+	}
+	if f.Limit > 0 && len(records) > f.Limit {
+		records = records[len(records)-f.Limit:]
+	}
+	return records
+}
+
+// Close syncs and closes the file
+func (l *AuditLog) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	l.file.Sync()
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// VerifyAuditFile checks the chain of the records of an audit file, the
+// first one following the record of prevHash if it is not empty, and
+// returns the last record
+func VerifyAuditFile(path, prevHash string) (*AuditRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var last *AuditRecord
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			rec := new(AuditRecord)
+			if err := json.Unmarshal(line, rec); err != nil {
+				return last, fmt.Errorf("Record after %d: %s", seqOf(last), err)
+			}
+			if rec.Hash != rec.hash() {
+				return last, fmt.Errorf("Record %d was changed", rec.Seq)
+			}
+			if last != nil && (rec.Seq != last.Seq+1 || rec.PrevHash != last.Hash) {
+				return last, fmt.Errorf("Record %d does not follow record %d", rec.Seq, last.Seq)
+			}
+			if last == nil && prevHash != "" && rec.PrevHash != prevHash {
+				return last, fmt.Errorf("Record %d does not follow %s", rec.Seq, prevHash)
+			}
+			last = rec
+		}
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return last, err
+		}
+	}
+}
+
+func seqOf(r *AuditRecord) uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.Seq
+}
+
+// firstAuditRecord returns the first record of the file
+func firstAuditRecord(path string) (*AuditRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	r := new(AuditRecord)
+	if err := json.Unmarshal(line, r); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return r, nil
+}
+
+// lastAuditRecord returns the last record of the file, nil if it has none
+func lastAuditRecord(path string) (*AuditRecord, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - auditTail
+	if offset < 0 {
+		offset = 0
+	}
+	p := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(p, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	lines := bytes.Split(bytes.TrimSpace(p), []byte{'\n'})
+	line := lines[len(lines)-1]
+	if len(line) == 0 {
+		return nil, nil
+	}
+	r := new(AuditRecord)
+	if err := json.Unmarshal(line, r); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return r, nil
+}
+
+// lastRotatedAuditFile returns the rotated file of path with the greatest
+// first Seq, empty if there is none
+func lastRotatedAuditFile(path string) string {
+	matches, _ := filepath.Glob(path + ".*")
+	last, lastSeq := "", uint64(0)
+	for _, m := range matches {
+		seq, err := strconv.ParseUint(strings.TrimPrefix(m, path+"."), 10, 64)
+		if err == nil && seq >= lastSeq {
+			last, lastSeq = m, seq
+		}
+	}
+	return last
+}
@@ -0,0 +1,104 @@
+package factomlog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factomlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	// about two records a file
+	l, err := OpenAuditLog(path, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := []string{AuditAccepted, AuditRejected, AuditAccepted, AuditAccepted, AuditRejected}
+	for _, o := range outcomes {
+		if err := l.Append(&AuditRecord{Command: "commitentry", Origin: "api", Outcome: o}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	// the chain goes on after a restart
+	if l, err = OpenAuditLog(path, 500); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(&AuditRecord{Command: "revealentry", Origin: "network", Outcome: AuditAccepted}); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	// the rotated files are named by their first record
+	files := make([]string, 0)
+	for seq := 1; seq <= 6; seq++ {
+		if _, err := os.Stat(fmt.Sprintf("%s.%d", path, seq)); err == nil {
+			files = append(files, fmt.Sprintf("%s.%d", path, seq))
+		}
+	}
+	if len(files) < 2 {
+		t.Fatalf("The log was rotated into %d files", len(files))
+	}
+	prev, seq := "", uint64(0)
+	for _, name := range append(files, path) {
+		last, err := VerifyAuditFile(name, prev)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		prev, seq = last.Hash, last.Seq
+	}
+	if seq != 6 {
+		t.Errorf("The last record is %d, not 6", seq)
+	}
+
+	if r := l.Recent(&AuditFilter{Outcome: AuditAccepted}); len(r) != 1 || r[0].Seq != 6 {
+		t.Errorf("Unexpected recent records %+v", r)
+	}
+
+	p, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = bytes.Replace(p, []byte(AuditRejected), []byte(AuditAccepted), 1)
+	if err := ioutil.WriteFile(files[0], p, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyAuditFile(files[0], ""); err == nil {
+		t.Errorf("A changed record verified")
+	}
+}
+
+func TestAuditRecent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factomlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := OpenAuditLog(filepath.Join(dir, "audit.log"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < auditRecent+10; i++ {
+		l.Append(&AuditRecord{Command: "factoidtx", Outcome: AuditAccepted})
+	}
+
+	r := l.Recent(&AuditFilter{})
+	if len(r) != auditRecent || r[0].Seq != 11 || r[len(r)-1].Seq != auditRecent+10 {
+		t.Errorf("Unexpected %d recent records from %d", len(r), r[0].Seq)
+	}
+	if r := l.Recent(&AuditFilter{After: auditRecent + 5, Limit: 2}); len(r) != 2 || r[0].Seq != auditRecent+9 {
+		t.Errorf("Unexpected records after %d", auditRecent+5)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package factomlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Format is how the log lines are written
+type Format int32
+
+const (
+	// Console lines are: time [LEVEL] SUBSYSTEM: message key=value ...
+	Console Format = iota
+	// JSON lines are objects with the time, level, subsystem, msg and
+	// fields
+	JSON
+)
+
+var format int32
+
+// SetFormat sets the format of the lines of all the loggers
+func SetFormat(f Format) {
+	atomic.StoreInt32(&format, int32(f))
+}
+
+// ParseFormat returns the format of the name: console or json
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "console":
+		return Console, nil
+	case "json":
+		return JSON, nil
+	}
+	return Console, fmt.Errorf("Invalid log format %q, allowed values are: console and json", name)
+}
+
+func currentFormat() Format {
+	return Format(atomic.LoadInt32(&format))
+}
+
+func consoleLine(now string, level Level, prefix, msg string, fields Fields) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s [%s] %s: %s", now, levelPrefix[level], prefix, msg)
+	for _, k := range sortedKeys(fields) {
+		fmt.Fprintf(&b, " %s=%v", k, fields[k])
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+func jsonLine(now string, level Level, prefix, msg string, fields Fields) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSON(&b, now)
+	b.WriteString(`,"level":`)
+	writeJSON(&b, level.String())
+	b.WriteString(`,"subsystem":`)
+	writeJSON(&b, prefix)
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, msg)
+	for _, k := range sortedKeys(fields) {
+		b.WriteByte(',')
+		writeJSON(&b, k)
+		b.WriteByte(':')
+		writeJSON(&b, fields[k])
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// writeJSON writes v in JSON, or its string if it cannot be marshaled
+func writeJSON(b *bytes.Buffer, v interface{}) {
+	p, err := json.Marshal(v)
+	if err != nil {
+		p, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(p)
+}
+
+func sortedKeys(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
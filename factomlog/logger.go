@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,21 +36,53 @@ const (
 // to an io.Writer.
 type FLogger struct {
 	out    io.Writer
-	level  Level
+	level  *int32 // shared with the loggers made by WithFields
 	prefix string
+	fields Fields
 }
 
+// Fields are keys and values written on every line of a logger
+type Fields map[string]interface{}
+
+// New returns a logger of the subsystem named by prefix, whose level can be
+// changed with SetSubsystemLevel
 func New(w io.Writer, level, prefix string) *FLogger {
-	return &FLogger{
+	logger := &FLogger{
 		out:    w,
-		level:  levelFromString(level),
+		level:  new(int32),
 		prefix: prefix,
 	}
+	logger.SetLevel(levelFromString(level))
+	register(logger)
+	return logger
 }
 
 // Get the current log level
 func (logger *FLogger) Level() (level Level) {
-	return logger.level
+	return Level(atomic.LoadInt32(logger.level))
+}
+
+// SetLevel sets the log level, also of the loggers made by WithFields
+func (logger *FLogger) SetLevel(level Level) {
+	atomic.StoreInt32(logger.level, int32(level))
+}
+
+// WithFields returns a logger writing the fields, and the fields of this
+// logger, on every line
+func (logger *FLogger) WithFields(fields Fields) *FLogger {
+	f := make(Fields, len(logger.fields)+len(fields))
+	for k, v := range logger.fields {
+		f[k] = v
+	}
+	for k, v := range fields {
+		f[k] = v
+	}
+	return &FLogger{
+		out:    logger.out,
+		level:  logger.level,
+		prefix: logger.prefix,
+		fields: f,
+	}
 }
 
 // Emergency logs with an emergency level and exits the program.
@@ -142,12 +176,17 @@ func (logger *FLogger) Debugf(format string, args ...interface{}) {
 // write outputs to the FLogger.out based on the FLogger.level and calls os.Exit
 // if the level is <= Error
 func (logger *FLogger) write(level Level, args ...interface{}) {
-	if level > logger.level {
+	if level > logger.Level() {
 		return
 	}
 
 	l := fmt.Sprint(args...) // get string for formatting
-	fmt.Fprintf(logger.out, "%s [%s] %s: %s\n", time.Now().Format(time.RFC3339), levelPrefix[level], logger.prefix, l)
+	now := time.Now().Format(time.RFC3339)
+	if currentFormat() == JSON {
+		logger.out.Write(jsonLine(now, level, logger.prefix, l, logger.fields))
+	} else {
+		logger.out.Write(consoleLine(now, level, logger.prefix, l, logger.fields))
+	}
 
 	if level <= Critical {
 		os.Exit(1)
@@ -165,30 +204,45 @@ var levelPrefix = map[Level]string{
 	Debug:     "DEBUG",
 }
 
-func levelFromString(levelName string) (level Level) {
+// String returns the name of the level, as in the config
+func (level Level) String() string {
+	if level == None {
+		return "none"
+	}
+	return strings.ToLower(levelPrefix[level])
+}
+
+// ParseLevel returns the level of the name: debug, info, notice, warning,
+// error, critical, alert, emergency or none
+func ParseLevel(levelName string) (Level, error) {
 	switch levelName {
 	case "debug":
-		level = Debug
+		return Debug, nil
 	case "info":
-		level = Info
+		return Info, nil
 	case "notice":
-		level = Notice
+		return Notice, nil
 	case "warning":
-		level = Warning
+		return Warning, nil
 	case "error":
-		level = Error
+		return Error, nil
 	case "critical":
-		level = Critical
+		return Critical, nil
 	case "alert":
-		level = Alert
+		return Alert, nil
 	case "emergency":
-		level = Emergency
+		return Emergency, nil
 	case "none":
-		level = None
-	default:
-		fmt.Fprintf(os.Stderr, "Invalid level value %q, allowed values are: debug, info, notice, warning, error, critical, alert, emergency and none\n", levelName)
+		return None, nil
+	}
+	return Warning, fmt.Errorf("Invalid level value %q, allowed values are: debug, info, notice, warning, error, critical, alert, emergency and none", levelName)
+}
+
+func levelFromString(levelName string) (level Level) {
+	level, err := ParseLevel(levelName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, "Using log level of warning")
-		level = Warning
 	}
 	return
 }
@@ -2,7 +2,12 @@ package factomlog
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -19,3 +24,77 @@ func TestNew(t *testing.T) {
 
 	fmt.Print(&buf)
 }
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	SetFormat(JSON)
+	defer SetFormat(Console)
+
+	logger := New(&buf, "info", "JSONTEST").WithFields(Fields{"height": 10})
+	logger.Info("stored")
+	logger.Debug("not written")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("%s: %s", err, buf.String())
+	}
+	if line["level"] != "info" || line["subsystem"] != "JSONTEST" || line["msg"] != "stored" || line["height"] != 10.0 {
+		t.Errorf("JSON line is %s", buf.String())
+	}
+}
+
+func TestSubsystemLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "info", "LEVELTEST")
+	child := logger.WithFields(Fields{"peer": "10.0.0.1"})
+
+	if err := SetSubsystemLevel("LEVELTEST", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	if child.Level() != Debug || SubsystemLevels()["LEVELTEST"] != "debug" {
+		t.Errorf("Level not set to debug")
+	}
+	child.Debug("now written")
+	if !strings.Contains(buf.String(), "LEVELTEST: now written peer=10.0.0.1") {
+		t.Errorf("Debug line is %q", buf.String())
+	}
+
+	if SetSubsystemLevel("LEVELTEST", "loud") == nil || SetSubsystemLevel("NOSUCH", "info") == nil {
+		t.Errorf("No error for an invalid level or subsystem")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factomlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := Configure("console", 100, 2, []string{"ROTATETEST=error"}); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure("console", 0, 0, nil)
+
+	path := filepath.Join(dir, "test.log")
+	logger := New(Open(path), "info", "ROTATETEST")
+	if logger.Level() != Error {
+		t.Errorf("Level of the config not applied")
+	}
+	for i := 0; i < 10; i++ {
+		logger.Error("a line of about sixty bytes")
+	}
+
+	for _, name := range []string{"test.log", "test.log.1", "test.log.2"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 100 {
+			t.Errorf("%s has %d bytes", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Errorf("More than 2 rotated files kept")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package factomlog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	rotationMutex sync.Mutex
+	maxFileSize   int64 // 0 for no rotation
+	maxBackups    int
+
+	filesMutex sync.Mutex
+	files      = make(map[string]*RotatingFile)
+)
+
+func setRotation(maxSize int64, backups int) {
+	rotationMutex.Lock()
+	defer rotationMutex.Unlock()
+	maxFileSize, maxBackups = maxSize, backups
+}
+
+func rotation() (int64, int) {
+	rotationMutex.Lock()
+	defer rotationMutex.Unlock()
+	return maxFileSize, maxBackups
+}
+
+// RotatingFile is a log file renamed to path.1 when it reaches the size set
+// by Configure, path.1 being renamed to path.2 and so on up to the backups
+// kept
+type RotatingFile struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+	size  int64
+}
+
+// Open returns the log file at path, shared by all the loggers writing to it
+// so they rotate it together
+func Open(path string) *RotatingFile {
+	filesMutex.Lock()
+	defer filesMutex.Unlock()
+	if f := files[path]; f != nil {
+		return f
+	}
+	f := &RotatingFile{path: path}
+	if err := f.open(); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot open the log file:", err)
+	}
+	files[path] = f
+	return f
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0660)
+	if err != nil {
+		return err
+	}
+	f.file, f.size = file, 0
+	if info, err := file.Stat(); err == nil {
+		f.size = info.Size()
+	}
+	return nil
+}
+
+// Write writes the line, rotating the file first if it would get too big
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return 0, os.ErrInvalid
+	}
+	if max, backups := rotation(); max > 0 && f.size > 0 && f.size+int64(len(p)) > max {
+		if err := f.rotate(backups); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) rotate(backups int) error {
+	f.file.Close()
+	f.file = nil
+	if backups > 0 {
+		for i := backups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Truncate(f.path, 0); err != nil {
+		return err
+	}
+	return f.open()
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package factomlog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// The loggers are kept by subsystem, their prefix, so the level of a
+// subsystem can be set from the config or changed while the node runs.
+
+var (
+	subsystemsMutex sync.Mutex
+	subsystems      = make(map[string][]*FLogger)
+	subsystemLevels = make(map[string]Level) // set by Configure
+)
+
+func register(logger *FLogger) {
+	subsystemsMutex.Lock()
+	defer subsystemsMutex.Unlock()
+	subsystems[logger.prefix] = append(subsystems[logger.prefix], logger)
+	if level, ok := subsystemLevels[logger.prefix]; ok {
+		logger.SetLevel(level)
+	}
+}
+
+// SetSubsystemLevel sets the level of the loggers of the subsystem, or of
+// all the subsystems if it is "all"
+func SetSubsystemLevel(subsystem, levelName string) error {
+	level, err := ParseLevel(levelName)
+	if err != nil {
+		return err
+	}
+	subsystemsMutex.Lock()
+	defer subsystemsMutex.Unlock()
+	if subsystem == "all" {
+		for _, loggers := range subsystems {
+			for _, logger := range loggers {
+				logger.SetLevel(level)
+			}
+		}
+		return nil
+	}
+	loggers, ok := subsystems[subsystem]
+	if !ok {
+		return fmt.Errorf("Unknown log subsystem %s", subsystem)
+	}
+	for _, logger := range loggers {
+		logger.SetLevel(level)
+	}
+	return nil
+}
+
+// SubsystemLevels returns the level of each subsystem
+func SubsystemLevels() map[string]string {
+	subsystemsMutex.Lock()
+	defer subsystemsMutex.Unlock()
+	levels := make(map[string]string, len(subsystems))
+	for name, loggers := range subsystems {
+		levels[name] = loggers[0].Level().String()
+	}
+	return levels
+}
+
+// Configure sets the format of the lines, the size in bytes at which the log
+// files are rotated and the rotated files kept, and the levels of the
+// subsystems given as SUBSYSTEM=level
+func Configure(formatName string, maxSize int64, backups int, levels []string) error {
+	f, err := ParseFormat(formatName)
+	if err != nil {
+		return err
+	}
+	parsed := make(map[string]Level)
+	for _, l := range levels {
+		if l == "" {
+			continue
+		}
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("Invalid subsystem level %q, expected SUBSYSTEM=level", l)
+		}
+		level, err := ParseLevel(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return err
+		}
+		parsed[strings.TrimSpace(kv[0])] = level
+	}
+
+	SetFormat(f)
+	setRotation(maxSize, backups)
+
+	subsystemsMutex.Lock()
+	defer subsystemsMutex.Unlock()
+	subsystemLevels = parsed
+	for name, level := range parsed {
+		for _, logger := range subsystems[name] {
+			logger.SetLevel(level)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// factomsign signs the commits and factoid transactions returned unsigned by
+// the factomd API, on a machine holding the private keys and never
+// connected to the network:
+//
+//	factomsign -keys keys.txt -in request.json -out signed.json
+//
+// The keys file has one hex private key per line, the lines starting with #
+// are ignored. The signed request is submitted to /v1/submit-signed.
+//
+// The keys may rather be kept encrypted in a key store, unlocked with its
+// passphrase for each signature:
+//
+//	factomsign -keystore keys.json -new-key ec1 -type ec
+//	factomsign -keystore keys.json -in request.json -out signed.json
+//
+// A key store with a 12 words phrase derives its new keys from it, and is
+// restored from the phrase. A Koinify phrase imports its factoid key:
+//
+//	factomsign -keystore keys.json -seed new
+//	factomsign -keystore keys.json -seed show
+//	factomsign -keystore keys.json -seed restore -count 5
+//	factomsign -keystore keys.json -koinify fct1
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/wallet"
+)
+
+// stdin reads the passphrase and the confirmation
+var stdin = bufio.NewReader(os.Stdin)
+
+func main() {
+	keysFile := flag.String("keys", "", "file of the hex private keys, one per line")
+	keyStore := flag.String("keystore", "", "encrypted key store, instead of -keys")
+	newKey := flag.String("new-key", "", "name of a key to generate in the key store, created if missing")
+	keyType := flag.String("type", wallet.KeyFactoid, "type of the new key, fct or ec")
+	seed := flag.String("seed", "", "new, show or restore the phrase of the key store")
+	count := flag.Int("count", 1, "keys of each type derived by -seed restore")
+	koinify := flag.String("koinify", "", "name of the factoid key to import from a Koinify phrase")
+	in := flag.String("in", "", "signing request to sign, stdin if empty")
+	out := flag.String("out", "", "file written with the signed request, stdout if empty")
+	yes := flag.Bool("yes", false, "sign without asking for a confirmation")
+	flag.Parse()
+
+	var err error
+	switch {
+	case *newKey != "":
+		err = generateKey(*keyStore, *newKey, *keyType)
+	case *seed != "":
+		err = keyStoreSeed(*keyStore, *seed, *count)
+	case *koinify != "":
+		err = importKoinify(*keyStore, *koinify)
+	default:
+		err = sign(*keysFile, *keyStore, *in, *out, *yes)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "factomsign:", err)
+		os.Exit(1)
+	}
+}
+
+func sign(keysFile, keyStore, in, out string, yes bool) error {
+	var keys []common.PrivateKey
+	var store *wallet.KeyStore
+	var err error
+	switch {
+	case keyStore != "":
+		if in == "" {
+			return fmt.Errorf("the passphrase is read from stdin, set -in")
+		}
+		if store, err = unlockKeyStore(keyStore); err != nil {
+			return err
+		}
+		defer store.Lock()
+	case keysFile != "":
+		if keys, err = readKeys(keysFile); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("no keys, set -keys or -keystore")
+	}
+
+	var p []byte
+	if in == "" {
+		if !yes {
+			return fmt.Errorf("the request is read from stdin, confirm it with -yes")
+		}
+		p, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		p, err = ioutil.ReadFile(in)
+	}
+	if err != nil {
+		return err
+	}
+	r := new(common.SigningRequest)
+	if err := json.Unmarshal(p, r); err != nil {
+		return err
+	}
+
+	// never sign anything but the request shown
+	if err := r.Check(); err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stderr, r.Summary())
+	if !yes && !confirm(stdin, os.Stderr) {
+		return fmt.Errorf("not signed")
+	}
+
+	signed := 0
+	if store != nil {
+		if signed, err = store.Sign(r); err != nil {
+			return err
+		}
+	}
+	for _, k := range keys {
+		n, err := r.Sign(k)
+		if err != nil {
+			return err
+		}
+		signed += n
+	}
+	if signed == 0 {
+		return fmt.Errorf("none of the keys signs this request")
+	}
+	if err := r.Verify(); err != nil {
+		fmt.Fprintln(os.Stderr, "partially signed:", err)
+	} else {
+		fmt.Fprintln(os.Stderr, "signed, ready to submit")
+	}
+
+	if p, err = json.MarshalIndent(r, "", "\t"); err != nil {
+		return err
+	}
+	p = append(p, '\n')
+	if out == "" {
+		_, err = os.Stdout.Write(p)
+		return err
+	}
+	return ioutil.WriteFile(out, p, 0600)
+}
+
+// readKeys reads the hex private keys of the file, one per line
+func readKeys(path string) ([]common.PrivateKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keys := make([]common.PrivateKey, 0)
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		t := strings.TrimSpace(s.Text())
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		k, err := common.NewPrivateKeyFromHex(t)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %s", path, line, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, s.Err()
+}
+
+// unlockKeyStore opens the key store and unlocks it with the passphrase
+// read from stdin
+func unlockKeyStore(path string) (*wallet.KeyStore, error) {
+	s, err := wallet.OpenKeyStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Unlock(readPassphrase(), 0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// openKeyStore opens the key store, created if missing, and unlocks it with
+// the passphrase read from stdin
+func openKeyStore(path string) (*wallet.KeyStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no key store, set -keystore")
+	}
+	var s *wallet.KeyStore
+	var err error
+	if _, err = os.Stat(path); os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "creating the key store", path)
+	}
+	passphrase := readPassphrase()
+	if os.IsNotExist(err) {
+		s, err = wallet.CreateKeyStore(path, passphrase)
+	} else {
+		s, err = wallet.OpenKeyStore(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Unlock(passphrase, 0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// generateKey adds a new key to the key store
+func generateKey(path, name, typ string) error {
+	s, err := openKeyStore(path)
+	if err != nil {
+		return err
+	}
+	defer s.Lock()
+
+	pub, err := s.GenerateKey(name, typ)
+	if err != nil {
+		return err
+	}
+	fmt.Println(name, typ, pub)
+	return nil
+}
+
+// keyStoreSeed creates, shows or restores the phrase of the key store
+func keyStoreSeed(path, cmd string, count int) error {
+	s, err := openKeyStore(path)
+	if err != nil {
+		return err
+	}
+	defer s.Lock()
+
+	switch cmd {
+	case "new":
+		m, err := wallet.NewMnemonic()
+		if err != nil {
+			return err
+		}
+		if err := s.SetMnemonic(m); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "write down the phrase, it restores all the keys derived from now on:")
+		fmt.Println(m)
+	case "show":
+		m, err := s.Mnemonic()
+		if err != nil {
+			return err
+		}
+		fmt.Println(m)
+	case "restore":
+		if err := s.Restore(readLine("Phrase: "), count); err != nil {
+			return err
+		}
+		for _, k := range s.Status().Keys {
+			fmt.Println(k.Name, k.Type, k.PubKey)
+		}
+	default:
+		return fmt.Errorf("unknown -seed %s, use new, show or restore", cmd)
+	}
+	return nil
+}
+
+// importKoinify adds the factoid key of a Koinify phrase to the key store
+func importKoinify(path, name string) error {
+	s, err := openKeyStore(path)
+	if err != nil {
+		return err
+	}
+	defer s.Lock()
+
+	if err := s.ImportKoinify(name, readLine("Koinify phrase: ")); err != nil {
+		return err
+	}
+	k, err := s.Key(name)
+	if err != nil {
+		return err
+	}
+	fmt.Println(name, wallet.KeyFactoid, k.Pub)
+	return nil
+}
+
+func readPassphrase() string {
+	return readLine("Passphrase: ")
+}
+
+func readLine(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	p, _ := stdin.ReadString('\n')
+	return strings.TrimRight(p, "\r\n")
+}
+
+func confirm(in io.Reader, out io.Writer) bool {
+	fmt.Fprint(out, "Sign? [y/N] ")
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"github.com/FactomProject/FactomCode/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// The gRPC api uses the API keys of the wsapi, sent in the x-factom-key
+// metadata
+var (
+	authcfg       = util.ReadConfig().Wsapi
+	requireAPIKey = authcfg.RequireAPIKey
+	apiKeys       util.APIKeys
+)
+
+// methodPerms are the permissions needed by the methods. Unlisted methods
+// need the read permission.
+var methodPerms = map[string]uint8{
+	"/grpcapi.Factom/CommitChain":   util.PermSubmit,
+	"/grpcapi.Factom/CommitEntry":   util.PermSubmit,
+	"/grpcapi.Factom/RevealEntry":   util.PermSubmit,
+	"/grpcapi.Factom/FactoidSubmit": util.PermSubmit,
+}
+
+func initAuth() error {
+	keys, err := util.ParseAPIKeys(authcfg.APIKey)
+	if err != nil {
+		return err
+	}
+	apiKeys = keys
+	return nil
+}
+
+func authorize(ctx context.Context, method string) error {
+	if !requireAPIKey {
+		return nil
+	}
+
+	perm, ok := methodPerms[method]
+	if !ok {
+		perm = util.PermRead
+	}
+
+	var key string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md["x-factom-key"]; len(v) > 0 {
+			key = v[0]
+		}
+	}
+	if key == "" {
+		return grpc.Errorf(codes.Unauthenticated, "API key required")
+	}
+	if !apiKeys.Allows(key, perm) {
+		return grpc.Errorf(codes.PermissionDenied, "API key does not have the %s permission", util.PermissionName(perm))
+	}
+	return nil
+}
+
+func unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := authorize(ctx, info.FullMethod); err != nil {
+		grpcLog.Warning(info.FullMethod, ": ", err)
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authorize(ss.Context(), info.FullMethod); err != nil {
+		grpcLog.Warning(info.FullMethod, ": ", err)
+		return err
+	}
+	return handler(srv, ss)
+}
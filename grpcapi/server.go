@@ -0,0 +1,220 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package grpcapi serves the core operations of the wsapi over gRPC. Both
+// share the backend in factomapi.
+package grpcapi
+
+//go:generate protoc --go_out=plugins=grpc:. factom.proto
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/factomapi"
+	"github.com/FactomProject/FactomCode/process"
+	fct "github.com/FactomProject/factoid"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var server *grpc.Server
+
+// Start serves the gRPC api on the port in a separate go-routine
+func Start(port int) error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		grpcLog.Error(err)
+		return err
+	}
+
+	if err := initAuth(); err != nil {
+		grpcLog.Error(err)
+		return err
+	}
+
+	server = grpc.NewServer(grpc.UnaryInterceptor(unaryAuth), grpc.StreamInterceptor(streamAuth))
+	RegisterFactomServer(server, new(factomServer))
+
+	grpcLog.Info("Starting gRPC server on port ", port)
+	go server.Serve(l)
+	return nil
+}
+
+func Stop() {
+	if server != nil {
+		server.Stop()
+	}
+}
+
+type factomServer struct{}
+
+var _ FactomServer = (*factomServer)(nil)
+
+func (s *factomServer) DirectoryBlockHead(ctx context.Context, in *Empty) (*DirectoryBlock, error) {
+	block, err := factomapi.DBlockHead()
+	if err != nil {
+		return nil, err
+	}
+	return newDirectoryBlock(block), nil
+}
+
+func (s *factomServer) DirectoryBlockByKeyMR(ctx context.Context, in *KeyMRRequest) (*DirectoryBlock, error) {
+	block, err := factomapi.DBlockByKeyMR(in.KeyMR)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("DBlock not found")
+	}
+	block.BuildKeyMerkleRoot()
+	return newDirectoryBlock(block), nil
+}
+
+func (s *factomServer) EntryBlockByKeyMR(ctx context.Context, in *KeyMRRequest) (*EntryBlock, error) {
+	block, err := factomapi.EBlockByKeyMR(in.KeyMR)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("EBlock not found")
+	}
+
+	e := &EntryBlock{
+		ChainID:    block.Header.ChainID.String(),
+		PrevKeyMR:  block.Header.PrevKeyMR.String(),
+		EBSequence: block.Header.EBSequence,
+		DBHeight:   block.Header.EBHeight,
+	}
+	for _, v := range block.Body.EBEntries {
+		e.EntryHashes = append(e.EntryHashes, v.String())
+	}
+	return e, nil
+}
+
+func (s *factomServer) EntryByHash(ctx context.Context, in *HashRequest) (*Entry, error) {
+	entry, err := factomapi.EntryByHash(in.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{
+		ChainID: entry.ChainID.String(),
+		ExtIDs:  entry.ExtIDs,
+		Content: entry.Content,
+	}, nil
+}
+
+func (s *factomServer) ChainHead(ctx context.Context, in *ChainRequest) (*ChainHeadResponse, error) {
+	head, err := factomapi.ChainHead(in.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	return &ChainHeadResponse{KeyMR: head.String()}, nil
+}
+
+func (s *factomServer) CommitChain(ctx context.Context, in *RawRequest) (*SubmitResponse, error) {
+	commit := common.NewCommitChain()
+	if _, err := commit.UnmarshalBinaryData(in.Data); err != nil {
+		return failure(err), nil
+	}
+	if err := factomapi.CommitChain(commit); err != nil {
+		return failure(err), nil
+	}
+	return success("Chain Committed"), nil
+}
+
+func (s *factomServer) CommitEntry(ctx context.Context, in *RawRequest) (*SubmitResponse, error) {
+	commit := common.NewCommitEntry()
+	if _, err := commit.UnmarshalBinaryData(in.Data); err != nil {
+		return failure(err), nil
+	}
+	if err := factomapi.CommitEntry(commit); err != nil {
+		return failure(err), nil
+	}
+	return success("Entry Committed"), nil
+}
+
+func (s *factomServer) RevealEntry(ctx context.Context, in *RawRequest) (*SubmitResponse, error) {
+	entry := common.NewEntry()
+	if _, err := entry.UnmarshalBinaryData(in.Data); err != nil {
+		return failure(err), nil
+	}
+	if err := factomapi.RevealEntry(entry); err != nil {
+		return failure(err), nil
+	}
+	return success("Entry Revealed"), nil
+}
+
+func (s *factomServer) FactoidSubmit(ctx context.Context, in *RawRequest) (*SubmitResponse, error) {
+	t := new(fct.Transaction)
+	if _, err := t.UnmarshalBinaryData(in.Data); err != nil {
+		return failure(err), nil
+	}
+	if err := common.FactoidState.Validate(1, t); err != nil {
+		return failure(err), nil
+	}
+	if err := factomapi.FactoidTX(t); err != nil {
+		return failure(err), nil
+	}
+	return success("Successfully submitted the transaction"), nil
+}
+
+func (s *factomServer) Subscribe(in *SubscribeRequest, stream Factom_SubscribeServer) error {
+	sub := factomapi.Subscribe()
+	defer sub.Unsubscribe()
+
+	for _, f := range in.Filters {
+		sub.AddFilter(f.Type, f.Key)
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e := <-sub.C:
+			if err := stream.Send(newEvent(e)); err != nil {
+				grpcLog.Debug("Subscriber disconnected: ", err)
+				return err
+			}
+		}
+	}
+}
+
+func newDirectoryBlock(block *common.DirectoryBlock) *DirectoryBlock {
+	d := &DirectoryBlock{
+		PrevKeyMR: block.Header.PrevKeyMR.String(),
+		DBHeight:  block.Header.DBHeight,
+		Timestamp: block.Header.Timestamp * 60,
+	}
+	if block.KeyMR != nil {
+		d.KeyMR = block.KeyMR.String()
+	}
+	for _, v := range block.DBEntries {
+		d.Entries = append(d.Entries, &DBEntry{ChainID: v.ChainID.String(), KeyMR: v.KeyMR.String()})
+	}
+	return d
+}
+
+func newEvent(e *process.Event) *Event {
+	return &Event{
+		Type:      e.Type,
+		DBHeight:  e.DBHeight,
+		KeyMR:     e.KeyMR,
+		ChainID:   e.ChainID,
+		EntryHash: e.EntryHash,
+		TxID:      e.TxID,
+		Address:   e.Address,
+		Balance:   e.Balance,
+	}
+}
+
+func success(msg string) *SubmitResponse {
+	return &SubmitResponse{Success: true, Message: msg}
+}
+
+func failure(err error) *SubmitResponse {
+	grpcLog.Error(err)
+	return &SubmitResponse{Success: false, Message: err.Error()}
+}
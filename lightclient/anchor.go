@@ -0,0 +1,102 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package lightclient
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MaxAnchorRange is the most directory blocks an aggregated anchor covers
+const MaxAnchorRange = 1000
+
+// AnchorRange is the aggregated anchor a directory block is in. The anchor
+// writes MR, the merkle root of the KeyMRs of the directory blocks from
+// FirstHeight to LastHeight in height order, instead of a single KeyMR.
+// Branch leads from the KeyMR of the directory block to MR.
+type AnchorRange struct {
+	FirstHeight uint32
+	LastHeight  uint32
+	MR          *Hash
+	Branch      []*MerkleStep
+}
+
+// Verify checks that the directory block keyMR at height leads to MR
+func (r *AnchorRange) Verify(keyMR *Hash, height uint32) error {
+	if r.MR == nil || keyMR == nil || !isComplete(r.Branch) {
+		return fmt.Errorf("Incomplete anchor range")
+	}
+	if height < r.FirstHeight || height > r.LastHeight || r.LastHeight-r.FirstHeight >= MaxAnchorRange {
+		return fmt.Errorf("Directory block %d is not in the anchor range from %d to %d", height, r.FirstHeight, r.LastHeight)
+	}
+	if mr := FoldMerkleBranch(keyMR, r.Branch); *mr != *r.MR {
+		return fmt.Errorf("Anchor branch leads to %s, not to the anchor merkle root %s", mr, r.MR)
+	}
+	return nil
+}
+
+// Payload returns the OP_RETURN data of the anchor of the range
+func (r *AnchorRange) Payload() []byte {
+	return AnchorPayload(r.FirstHeight, r.LastHeight, r.MR)
+}
+
+// AnchorPayload returns the OP_RETURN data anchoring the directory blocks
+// from first to last with root. The anchor of one directory block is "Fa",
+// its 6 byte height and its KeyMR; an aggregated anchor is "FA", the 6 byte
+// first and last heights and the merkle root of their KeyMRs.
+func AnchorPayload(first, last uint32, root *Hash) []byte {
+	height := func(h uint32) []byte {
+		p := make([]byte, 8)
+		binary.BigEndian.PutUint64(p, uint64(h))
+		return p[2:]
+	}
+
+	if first == last {
+		return append(append([]byte{'F', 'a'}, height(last)...), root[:]...)
+	}
+	p := append([]byte{'F', 'A'}, height(first)...)
+	p = append(p, height(last)...)
+	return append(p, root[:]...)
+}
+
+// AnchorProof is the proof of the Bitcoin anchor of a directory block, as
+// the API /v1/anchor-proof returns it: a BitcoinAnchorProof in hex, with the
+// AnchorRange of the directory block when the anchor is aggregated.
+type AnchorProof struct {
+	KeyMR        *Hash
+	DBHeight     uint32
+	AnchorRange  *AnchorRange `json:",omitempty"`
+	RawTx        Bytes
+	TxIndex      uint32
+	MerkleBranch []Bytes
+	Headers      []Bytes
+}
+
+// Verify checks that the directory block of the proof is anchored in the
+// block of the first header, and that the headers are a chain with the proof
+// of work of net
+func (a *AnchorProof) Verify(net *BitcoinNet) (*BitcoinAnchor, error) {
+	if a.KeyMR == nil {
+		return nil, fmt.Errorf("Incomplete anchor proof")
+	}
+	p := &BitcoinAnchorProof{RawTx: a.RawTx, TxIndex: a.TxIndex}
+	for _, h := range a.MerkleBranch {
+		if len(h) != HashLength {
+			return nil, fmt.Errorf("Invalid merkle branch hash: %x", []byte(h))
+		}
+		p.MerkleBranch = append(p.MerkleBranch, h)
+	}
+	for _, h := range a.Headers {
+		p.Headers = append(p.Headers, h)
+	}
+
+	if a.AnchorRange != nil {
+		if err := a.AnchorRange.Verify(a.KeyMR, a.DBHeight); err != nil {
+			return nil, err
+		}
+		return VerifyBitcoinAnchorPayload(net, a.AnchorRange.Payload(), p)
+	}
+	return VerifyBitcoinAnchor(net, a.KeyMR, a.DBHeight, p)
+}
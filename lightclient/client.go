@@ -0,0 +1,222 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package lightclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxResponse bounds the responses read from the node
+const maxResponse = 10 << 20
+
+// Client gets the receipts of entries and the proofs of their anchors from
+// the API of any Factom node, and verifies them. It trusts only the anchors
+// in a Bitcoin block TrustedBlock accepts, or built on by one.
+type Client struct {
+	// URL of the API of the node, such as http://localhost:8088
+	URL string
+
+	// APIKey is sent in the X-Factom-Key header to nodes requiring a key
+	APIKey string
+
+	// HTTPClient is http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Net is the Bitcoin network the node anchors to, BitcoinTestNet3 by
+	// default
+	Net *BitcoinNet
+
+	// MinConfirmations is the least number of Bitcoin headers an anchor
+	// proof must have, from the block of the anchor on
+	MinConfirmations int
+
+	// TrustedBlock tells if a Bitcoin block hash, in the byte order Bitcoin
+	// displays it, is in the Bitcoin block chain, for instance by asking a
+	// Bitcoin node the application trusts or with Checkpoints. An anchor is
+	// verified only if one of the headers of its proof is trusted: the
+	// proof of work alone does not tell the headers are the Bitcoin ones.
+	TrustedBlock func(hash string) bool
+}
+
+// NewClient returns a Client of the API at url, which trusts the Bitcoin
+// blocks trusted tells are in the Bitcoin block chain
+func NewClient(url string, trusted func(hash string) bool) *Client {
+	return &Client{URL: strings.TrimRight(url, "/"), Net: BitcoinTestNet3, TrustedBlock: trusted}
+}
+
+// Checkpoints returns a TrustedBlock trusting the block hashes, in the byte
+// order Bitcoin displays them
+func Checkpoints(hashes ...string) func(hash string) bool {
+	m := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		m[strings.ToLower(h)] = true
+	}
+	return func(hash string) bool { return m[hash] }
+}
+
+// Verification is what VerifyEntry verified
+type Verification struct {
+	Receipt *Receipt
+	Entry   []byte
+	// Anchor is nil until the directory block is anchored in Bitcoin
+	Anchor *BitcoinAnchor
+}
+
+// VerifyEntry gets the entry of the hash, its receipt and the proof of its
+// anchor, and verifies that the entry is in the directory block of the
+// receipt and, once the directory block is anchored, that the anchor is in
+// the Bitcoin block chain
+func (c *Client) VerifyEntry(entryHash string) (*Verification, error) {
+	v := new(Verification)
+	var err error
+	if v.Receipt, err = c.Receipt(entryHash); err != nil {
+		return nil, err
+	}
+	if v.Entry, err = c.Entry(entryHash); err != nil {
+		return nil, err
+	}
+	if len(v.Entry) < 1+HashLength || !bytes.Equal(v.Entry[1:1+HashLength], v.Receipt.ChainID[:]) {
+		return nil, fmt.Errorf("Entry %s is not in the chain %s of its receipt", entryHash, v.Receipt.ChainID)
+	}
+	if !v.Receipt.IsAnchored() {
+		return v, nil
+	}
+
+	a, err := c.AnchorProof(v.Receipt.DBlockKeyMR.String())
+	if err != nil {
+		return nil, err
+	}
+	if a.DBHeight != v.Receipt.DBHeight {
+		return nil, fmt.Errorf("Anchor proof of directory block %d, not %d", a.DBHeight, v.Receipt.DBHeight)
+	}
+	if v.Anchor, err = c.VerifyAnchor(a); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(v.Anchor.TxID, v.Receipt.BitcoinTxID[:]) {
+		return nil, fmt.Errorf("Anchor proof of transaction %x, not of the transaction %x of the receipt",
+			reverseBytes(v.Anchor.TxID), reverseBytes(v.Receipt.BitcoinTxID[:]))
+	}
+	return v, nil
+}
+
+// Receipt gets the receipt of the entry hash and verifies it
+func (c *Client) Receipt(entryHash string) (*Receipt, error) {
+	h, err := HexToHash(entryHash)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Receipt)
+	if err := c.get("/v1/receipt/"+entryHash, r); err != nil {
+		return nil, err
+	}
+	if r.EntryHash == nil || *r.EntryHash != *h {
+		return nil, fmt.Errorf("Receipt of entry %s, not %s", r.EntryHash, entryHash)
+	}
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Entry gets the marshaled entry of the hash and checks its hash
+func (c *Client) Entry(entryHash string) ([]byte, error) {
+	h, err := HexToHash(entryHash)
+	if err != nil {
+		return nil, err
+	}
+	d := new(struct {
+		Data Bytes
+	})
+	if err := c.get("/v1/get-raw-data/"+entryHash, d); err != nil {
+		return nil, err
+	}
+	if eh := EntryHash(d.Data); *eh != *h {
+		return nil, fmt.Errorf("Entry data hashes to %s, not to %s", eh, entryHash)
+	}
+	return d.Data, nil
+}
+
+// AnchorProof gets the proof of the anchor of the directory block keyMR. The
+// proof is not verified yet: the verification of the node is ignored.
+func (c *Client) AnchorProof(keyMR string) (*AnchorProof, error) {
+	h, err := HexToHash(keyMR)
+	if err != nil {
+		return nil, err
+	}
+	p := new(struct {
+		Proof *AnchorProof
+	})
+	if err := c.get("/v1/anchor-proof/"+keyMR, p); err != nil {
+		return nil, err
+	}
+	if p.Proof == nil || p.Proof.KeyMR == nil || *p.Proof.KeyMR != *h {
+		return nil, fmt.Errorf("No anchor proof of directory block %s", keyMR)
+	}
+	return p.Proof, nil
+}
+
+// VerifyAnchor verifies the anchor proof, its confirmations and that one of
+// its headers is a TrustedBlock
+func (c *Client) VerifyAnchor(p *AnchorProof) (*BitcoinAnchor, error) {
+	if c.TrustedBlock == nil {
+		return nil, fmt.Errorf("No trusted Bitcoin blocks to verify the anchor against")
+	}
+	net := c.Net
+	if net == nil {
+		net = BitcoinTestNet3
+	}
+	a, err := p.Verify(net)
+	if err != nil {
+		return nil, err
+	}
+	if a.Confirmations < c.MinConfirmations {
+		return nil, fmt.Errorf("Anchor has %d confirmations, not %d", a.Confirmations, c.MinConfirmations)
+	}
+	// a trusted block built on the block of the anchor vouches for it too
+	for _, header := range p.Headers {
+		if c.TrustedBlock(fmt.Sprintf("%x", reverseBytes(doubleSha(header)))) {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("Anchor block %x is not in a trusted Bitcoin chain", reverseBytes(a.BlockHash))
+}
+
+// get decodes the JSON response of the API to path into v
+func (c *Client) get(path string, v interface{}) error {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest("GET", c.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-Factom-Key", c.APIKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	p, err := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: maxResponse})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s: %s", path, resp.Status, strings.TrimSpace(string(p)))
+	}
+	if err := json.Unmarshal(p, v); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
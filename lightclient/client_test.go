@@ -0,0 +1,176 @@
+package lightclient_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/lightclient"
+)
+
+// node serves a receipt, an entry and an anchor proof like the API
+type node struct {
+	receipt *Receipt
+	entry   []byte
+	proof   *AnchorProof
+}
+
+func (n *node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var v interface{}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/receipt/"):
+		v = n.receipt
+	case strings.HasPrefix(r.URL.Path, "/v1/get-raw-data/"):
+		v = map[string]string{"Data": hex.EncodeToString(n.entry)}
+	case strings.HasPrefix(r.URL.Path, "/v1/anchor-proof/"):
+		// the verification of an untrusted node means nothing
+		v = map[string]interface{}{"Proof": n.proof, "Verification": map[string]bool{"Valid": true}}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	p, _ := json.Marshal(v)
+	w.Write(p)
+}
+
+func TestVerifyEntry(t *testing.T) {
+	n := newNode()
+	server := httptest.NewServer(n)
+	defer server.Close()
+	c := NewClient(server.URL, Checkpoints(hex.EncodeToString(reverse(dsha(n.proof.Headers[1])))))
+	c.Net = BitcoinRegTest
+	entryHash := n.receipt.EntryHash.String()
+
+	v, err := c.VerifyEntry(entryHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Anchor == nil || v.Anchor.Confirmations != 2 || string(v.Entry[35:]) != "hello" {
+		t.Errorf("Unexpected verification %+v", v)
+	}
+
+	c.MinConfirmations = 3
+	if _, err := c.VerifyEntry(entryHash); err == nil {
+		t.Errorf("Anchor with too few confirmations verified")
+	}
+	c.MinConfirmations = 0
+	trusted := c.TrustedBlock
+	c.TrustedBlock = func(hash string) bool { return false }
+	if _, err := c.VerifyEntry(entryHash); err == nil {
+		t.Errorf("Anchor in an untrusted block verified")
+	}
+	c.TrustedBlock = nil
+	if _, err := c.VerifyEntry(entryHash); err == nil {
+		t.Errorf("Anchor verified without trusted blocks")
+	}
+	c.TrustedBlock = trusted
+	c.Net = BitcoinTestNet3
+	if _, err := c.VerifyEntry(entryHash); err == nil {
+		t.Errorf("Anchor with the regtest proof of work verified on testnet3")
+	}
+	c.Net = BitcoinRegTest
+
+	n.entry[len(n.entry)-1] = 'O'
+	if _, err := c.VerifyEntry(entryHash); err == nil {
+		t.Errorf("Changed entry verified")
+	}
+	n.entry[len(n.entry)-1] = 'o'
+
+	n.receipt.EntryBranch[0].Sibling = sha([]byte("forged"))
+	if _, err := c.VerifyEntry(entryHash); err == nil {
+		t.Errorf("Forged receipt verified")
+	}
+
+	n = newNode()
+	n.receipt.BitcoinTxID = nil
+	n.proof = nil
+	server.Config.Handler = n
+	if v, err := c.VerifyEntry(entryHash); err != nil || v.Anchor != nil {
+		t.Errorf("Unexpected verification of an entry not anchored yet %+v: %v", v, err)
+	}
+}
+
+// newNode returns a node with an entry of a chain, in an entry block of
+// two entries, anchored in a Bitcoin block of two transactions
+func newNode() *node {
+	n := new(node)
+	chainID := sha([]byte("chain"))
+	n.entry = append(append([]byte{0}, chainID[:]...), 0, 0)
+	n.entry = append(n.entry, "hello"...)
+
+	r := &Receipt{EntryHash: EntryHash(n.entry), ChainID: chainID, DBHeight: 1234}
+	r.EntryBranch = []*MerkleStep{{Sibling: sha([]byte("entry"))}, {Sibling: sha([]byte("eheader")), Left: true}}
+	r.EBlockKeyMR = FoldMerkleBranch(r.EntryHash, r.EntryBranch)
+	dbentry := sha(append(append([]byte{}, chainID[:]...), r.EBlockKeyMR[:]...))
+	r.DBlockBranch = []*MerkleStep{{Sibling: sha([]byte("dbentry")), Left: true}, {Sibling: sha([]byte("dheader")), Left: true}}
+	r.DBlockKeyMR = FoldMerkleBranch(dbentry, r.DBlockBranch)
+
+	tx := anchorTx(AnchorPayload(r.DBHeight, r.DBHeight, r.DBlockKeyMR))
+	txid, coinbase := dsha(tx), dsha([]byte("coinbase"))
+	block := minedHeader(make([]byte, 32), dsha(append(append([]byte{}, coinbase...), txid...)))
+	next := minedHeader(dsha(block), dsha([]byte("next")))
+	r.BitcoinTxID = new(Hash)
+	copy(r.BitcoinTxID[:], txid)
+	n.receipt = r
+
+	n.proof = &AnchorProof{
+		KeyMR:        r.DBlockKeyMR,
+		DBHeight:     r.DBHeight,
+		RawTx:        tx,
+		TxIndex:      1,
+		MerkleBranch: []Bytes{coinbase},
+		Headers:      []Bytes{block, next},
+	}
+	return n
+}
+
+// anchorTx returns a transaction with one input and the OP_RETURN output of
+// the anchor
+func anchorTx(data []byte) []byte {
+	tx := []byte{1, 0, 0, 0, 1}
+	tx = append(tx, make([]byte, 36)...)
+	tx = append(tx, 0, 0xff, 0xff, 0xff, 0xff, 1)
+	tx = append(tx, make([]byte, 8)...)
+	tx = append(tx, byte(len(data)+2), 0x6a, byte(len(data)))
+	tx = append(tx, data...)
+	return append(tx, 0, 0, 0, 0)
+}
+
+// minedHeader returns a header with the regtest target and a nonce meeting it
+func minedHeader(prev, merkleRoot []byte) []byte {
+	header := make([]byte, 80)
+	header[0] = 1
+	copy(header[4:36], prev)
+	copy(header[36:68], merkleRoot)
+	binary.LittleEndian.PutUint32(header[72:76], 0x207fffff)
+	for nonce := uint32(0); ; nonce++ {
+		binary.LittleEndian.PutUint32(header[76:], nonce)
+		if h := dsha(header); h[31] < 0x7f {
+			return header
+		}
+	}
+}
+
+func sha(p []byte) *Hash {
+	h := Hash(sha256.Sum256(p))
+	return &h
+}
+
+func reverse(p []byte) []byte {
+	r := make([]byte, len(p))
+	for i, b := range p {
+		r[len(p)-1-i] = b
+	}
+	return r
+}
+
+func dsha(p []byte) []byte {
+	h := sha256.Sum256(p)
+	h = sha256.Sum256(h[:])
+	return h[:]
+}
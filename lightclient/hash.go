@@ -0,0 +1,81 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package lightclient
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+)
+
+// HashLength is the length of the hashes of the blocks and the entries
+const HashLength = 32
+
+// Hash is a hash in the byte order Factom hashes it, in hex in JSON like the
+// hashes of the API
+type Hash [HashLength]byte
+
+// HexToHash returns the hash of a hex string
+func HexToHash(s string) (*Hash, error) {
+	h := new(Hash)
+	if err := h.UnmarshalText([]byte(s)); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h[:])), nil
+}
+
+func (h *Hash) UnmarshalText(b []byte) error {
+	p, err := hex.DecodeString(string(b))
+	if err != nil {
+		return err
+	}
+	if len(p) != HashLength {
+		return fmt.Errorf("Invalid hash %s of %d bytes", b, len(p))
+	}
+	copy(h[:], p)
+	return nil
+}
+
+func (h *Hash) String() string {
+	if h == nil {
+		return ""
+	}
+	return hex.EncodeToString(h[:])
+}
+
+// Bytes is a byte string in hex in JSON
+type Bytes []byte
+
+func (b Bytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+func (b *Bytes) UnmarshalText(p []byte) error {
+	d, err := hex.DecodeString(string(p))
+	if err != nil {
+		return err
+	}
+	*b = d
+	return nil
+}
+
+// EntryHash returns the hash of a marshaled entry: the sha256 of its sha512
+// followed by the entry
+func EntryHash(entry []byte) *Hash {
+	h1 := sha512.Sum512(entry)
+	h := Hash(sha256.Sum256(append(h1[:], entry...)))
+	return &h
+}
+
+// hashMerkleBranches returns the sha256 of the left and right nodes
+func hashMerkleBranches(left, right *Hash) *Hash {
+	h := Hash(sha256.Sum256(append(append(make([]byte, 0, 2*HashLength), left[:]...), right[:]...)))
+	return &h
+}
@@ -0,0 +1,112 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package lightclient verifies the data of a Factom node without running
+// one: the receipt of an entry, from the entry to its entry block and
+// directory block, and the SPV proof that the directory block is anchored in
+// Bitcoin. It has no database or p2p dependency, and trusts nothing of the
+// node it gets the receipts and proofs from: only the Bitcoin blocks the
+// application trusts.
+package lightclient
+
+import (
+	"fmt"
+)
+
+// MerkleStep is one level of a merkle branch. The hash of the level below is
+// hashed with Sibling, Sibling on the left if Left is set, into the next
+// level.
+type MerkleStep struct {
+	Sibling *Hash
+	Left    bool `json:",omitempty"`
+}
+
+// Receipt proves that an entry is in a directory block and where the
+// directory block is anchored in Bitcoin. It is the receipt of the API
+// /v1/receipt.
+//
+// EntryBranch leads from the entry hash to the entry block KeyMR: through the
+// entry block body to its merkle root, then with the header hash on the left.
+// DBlockBranch leads from the directory block entry of the entry block, the
+// hash of its ChainID and KeyMR, to the directory block KeyMR in the same way.
+// When the directory block is in an aggregated anchor, AnchorRange leads from
+// the KeyMR to the merkle root the anchor transaction writes.
+type Receipt struct {
+	EntryHash    *Hash
+	ChainID      *Hash
+	EntryBranch  []*MerkleStep
+	EBlockKeyMR  *Hash
+	DBlockBranch []*MerkleStep
+	DBlockKeyMR  *Hash
+	DBHeight     uint32
+
+	// The anchor is empty until the directory block is confirmed in Bitcoin
+	BitcoinTxID        *Hash        `json:",omitempty"`
+	BitcoinBlockHash   *Hash        `json:",omitempty"`
+	BitcoinBlockHeight int32        `json:",omitempty"`
+	AnchorRange        *AnchorRange `json:",omitempty"`
+}
+
+// IsAnchored tells if the directory block of the receipt is confirmed in
+// Bitcoin
+func (r *Receipt) IsAnchored() bool {
+	return r.BitcoinTxID != nil && *r.BitcoinTxID != Hash{}
+}
+
+// Verify checks that the branches of the receipt lead from the entry hash to
+// the directory block KeyMR
+func (r *Receipt) Verify() error {
+	if r.EntryHash == nil || r.ChainID == nil || r.EBlockKeyMR == nil || r.DBlockKeyMR == nil ||
+		!isComplete(r.EntryBranch) || !isComplete(r.DBlockBranch) {
+		return fmt.Errorf("Incomplete receipt")
+	}
+
+	if mr := FoldMerkleBranch(r.EntryHash, r.EntryBranch); *mr != *r.EBlockKeyMR {
+		return fmt.Errorf("Entry branch leads to %s, not to the entry block %s", mr, r.EBlockKeyMR)
+	}
+
+	dbentry := hashMerkleBranches(r.ChainID, r.EBlockKeyMR)
+	if mr := FoldMerkleBranch(dbentry, r.DBlockBranch); *mr != *r.DBlockKeyMR {
+		return fmt.Errorf("Directory block branch leads to %s, not to the directory block %s", mr, r.DBlockKeyMR)
+	}
+
+	if r.AnchorRange != nil {
+		return r.AnchorRange.Verify(r.DBlockKeyMR, r.DBHeight)
+	}
+	return nil
+}
+
+// AnchorPayload returns the OP_RETURN data of the anchor transaction of the
+// receipt
+func (r *Receipt) AnchorPayload() []byte {
+	if r.AnchorRange != nil {
+		return r.AnchorRange.Payload()
+	}
+	return AnchorPayload(r.DBHeight, r.DBHeight, r.DBlockKeyMR)
+}
+
+// FoldMerkleBranch returns the root the branch leads to from the leaf. The
+// branch must have all its siblings.
+func FoldMerkleBranch(leaf *Hash, branch []*MerkleStep) *Hash {
+	h := leaf
+	for _, s := range branch {
+		if s.Left {
+			h = hashMerkleBranches(s.Sibling, h)
+		} else {
+			h = hashMerkleBranches(h, s.Sibling)
+		}
+	}
+	return h
+}
+
+// isComplete tells if the branch has all its siblings, which a branch from
+// an untrusted node may not have
+func isComplete(branch []*MerkleStep) bool {
+	for _, s := range branch {
+		if s == nil || s.Sibling == nil {
+			return false
+		}
+	}
+	return true
+}
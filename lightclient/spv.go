@@ -0,0 +1,257 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package lightclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// BitcoinAnchorProof is the SPV proof that a Bitcoin transaction anchoring a
+// directory block is in the Bitcoin block chain. The hashes are in the byte
+// order they are hashed in, the reverse of the order Bitcoin displays them.
+//
+// MerkleBranch leads from the transaction id, at TxIndex in the block, to
+// the merkle root of the first header. Headers are the 80 byte headers of
+// the block of the transaction and of the blocks built on it, in order.
+type BitcoinAnchorProof struct {
+	RawTx        []byte
+	TxIndex      uint32
+	MerkleBranch [][]byte
+	Headers      [][]byte
+}
+
+// BitcoinNet is a Bitcoin network the anchors are written to. The headers of
+// a proof must have at least the proof of work of PowLimitBits, the compact
+// target of the easiest block of the network: without it, anyone could mine
+// a forged chain of headers in no time.
+type BitcoinNet struct {
+	Name         string
+	PowLimitBits uint32
+}
+
+var (
+	BitcoinMainNet  = &BitcoinNet{Name: "mainnet", PowLimitBits: 0x1d00ffff}
+	BitcoinTestNet3 = &BitcoinNet{Name: "testnet3", PowLimitBits: 0x1d00ffff}
+
+	// BitcoinRegTest is for tests only: its headers take no work to mine
+	BitcoinRegTest = &BitcoinNet{Name: "regtest", PowLimitBits: 0x207fffff}
+)
+
+// BitcoinAnchor is what a verified BitcoinAnchorProof proves
+type BitcoinAnchor struct {
+	TxID          []byte
+	BlockHash     []byte
+	Confirmations int // number of headers of the proof
+}
+
+// VerifyBitcoinAnchor checks that the transaction of the proof writes the
+// directory block keyMR at height in its OP_RETURN output, that it is in the
+// block of the first header and that the headers are a chain with the proof
+// of work of net. It does not know if the chain is the Bitcoin one: the block
+// hashes are to be checked against a trusted header source.
+func VerifyBitcoinAnchor(net *BitcoinNet, keyMR *Hash, height uint32, p *BitcoinAnchorProof) (*BitcoinAnchor, error) {
+	if keyMR == nil {
+		return nil, fmt.Errorf("Incomplete anchor proof")
+	}
+	return VerifyBitcoinAnchorPayload(net, AnchorPayload(height, height, keyMR), p)
+}
+
+// VerifyBitcoinAnchorPayload is VerifyBitcoinAnchor for an anchor writing
+// payload, such as the payload of an AnchorRange
+func VerifyBitcoinAnchorPayload(net *BitcoinNet, payload []byte, p *BitcoinAnchorProof) (*BitcoinAnchor, error) {
+	if p == nil || len(p.Headers) == 0 {
+		return nil, fmt.Errorf("Incomplete anchor proof")
+	}
+	if net == nil {
+		return nil, fmt.Errorf("No Bitcoin network to verify the anchor on")
+	}
+	powLimit, err := compactToBig(net.PowLimitBits)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := bitcoinOpReturn(p.RawTx)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(data, payload) {
+		return nil, fmt.Errorf("Transaction anchors %x, not %x", data, payload)
+	}
+
+	a := new(BitcoinAnchor)
+	a.TxID = doubleSha(p.RawTx)
+	root := FoldBitcoinMerkleBranch(a.TxID, p.TxIndex, p.MerkleBranch)
+
+	var prev []byte
+	for i, header := range p.Headers {
+		if len(header) != 80 {
+			return nil, fmt.Errorf("Header %d is %d bytes, not 80", i, len(header))
+		}
+		hash := doubleSha(header)
+		if err := checkProofOfWork(hash, binary.LittleEndian.Uint32(header[72:76]), powLimit); err != nil {
+			return nil, fmt.Errorf("Header %d: %s", i, err)
+		}
+		if i == 0 {
+			if !bytes.Equal(header[36:68], root) {
+				return nil, fmt.Errorf("Merkle branch leads to %x, not to the merkle root of the block %x",
+					reverseBytes(root), reverseBytes(header[36:68]))
+			}
+			a.BlockHash = hash
+		} else if !bytes.Equal(header[4:36], prev) {
+			return nil, fmt.Errorf("Header %d does not follow header %d", i, i-1)
+		}
+		prev = hash
+	}
+	a.Confirmations = len(p.Headers)
+	return a, nil
+}
+
+// FoldBitcoinMerkleBranch returns the merkle root the branch leads to from
+// the transaction id at index in the block
+func FoldBitcoinMerkleBranch(txid []byte, index uint32, branch [][]byte) []byte {
+	h := txid
+	for _, s := range branch {
+		if index&1 == 1 {
+			h = doubleSha(append(append([]byte{}, s...), h...))
+		} else {
+			h = doubleSha(append(append([]byte{}, h...), s...))
+		}
+		index >>= 1
+	}
+	return h
+}
+
+// bitcoinOpReturn returns the data pushed by the OP_RETURN output of a
+// serialized transaction
+func bitcoinOpReturn(tx []byte) ([]byte, error) {
+	r := &txReader{data: tx}
+	r.skip(4) // version
+	ins := r.varInt()
+	if ins == 0 && r.err == nil {
+		return nil, fmt.Errorf("Segwit transactions are not anchors")
+	}
+	for i := uint64(0); i < ins && r.err == nil; i++ {
+		r.skip(36) // outpoint
+		r.skip(int(r.varInt()))
+		r.skip(4) // sequence
+	}
+
+	outs := r.varInt()
+	for i := uint64(0); i < outs && r.err == nil; i++ {
+		r.skip(8) // value
+		script := r.bytes(int(r.varInt()))
+		if r.err != nil || len(script) < 2 || script[0] != 0x6a {
+			continue
+		}
+		switch {
+		case script[1] <= 75 && len(script) == 2+int(script[1]):
+			return script[2:], nil
+		case script[1] == 0x4c && len(script) > 2 && len(script) == 3+int(script[2]):
+			return script[3:], nil
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return nil, fmt.Errorf("Transaction has no OP_RETURN output")
+}
+
+// txReader reads the fields of a serialized transaction, keeping the first
+// error
+type txReader struct {
+	data []byte
+	err  error
+}
+
+func (r *txReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = fmt.Errorf("Truncated transaction")
+		return nil
+	}
+	p := r.data[:n]
+	r.data = r.data[n:]
+	return p
+}
+
+func (r *txReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *txReader) varInt() uint64 {
+	p := r.bytes(1)
+	if p == nil {
+		return 0
+	}
+	switch p[0] {
+	case 0xfd:
+		if p = r.bytes(2); p != nil {
+			return uint64(binary.LittleEndian.Uint16(p))
+		}
+	case 0xfe:
+		if p = r.bytes(4); p != nil {
+			return uint64(binary.LittleEndian.Uint32(p))
+		}
+	case 0xff:
+		if p = r.bytes(8); p != nil {
+			return binary.LittleEndian.Uint64(p)
+		}
+	default:
+		return uint64(p[0])
+	}
+	return 0
+}
+
+// checkProofOfWork checks that the target encoded in the compact bits of the
+// header is at most powLimit, and that the block hash is at most the target
+func checkProofOfWork(hash []byte, bits uint32, powLimit *big.Int) error {
+	target, err := compactToBig(bits)
+	if err != nil {
+		return err
+	}
+	if target.Cmp(powLimit) > 0 {
+		return fmt.Errorf("Target bits %08x are below the proof of work of the network", bits)
+	}
+	if new(big.Int).SetBytes(reverseBytes(hash)).Cmp(target) > 0 {
+		return fmt.Errorf("Block hash %x is above the target", reverseBytes(hash))
+	}
+	return nil
+}
+
+// compactToBig returns the target encoded in compact bits
+func compactToBig(bits uint32) (*big.Int, error) {
+	exponent := uint(bits >> 24)
+	mantissa := int64(bits & 0x007fffff)
+	if bits&0x00800000 != 0 || mantissa == 0 {
+		return nil, fmt.Errorf("Invalid target bits %08x", bits)
+	}
+	target := big.NewInt(mantissa)
+	if exponent <= 3 {
+		target.Rsh(target, 8*(3-exponent))
+	} else {
+		target.Lsh(target, 8*(exponent-3))
+	}
+	return target, nil
+}
+
+func doubleSha(p []byte) []byte {
+	h := sha256.Sum256(p)
+	h = sha256.Sum256(h[:])
+	return h[:]
+}
+
+func reverseBytes(p []byte) []byte {
+	r := make([]byte, len(p))
+	for i, b := range p {
+		r[len(p)-1-i] = b
+	}
+	return r
+}
@@ -0,0 +1,263 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package loadtest submits synthetic chains and entries at a steady rate to
+// a node or to the in-process simulation, and measures how many entries a
+// second reach a block and how long they take from their commit to the
+// block, so a slower processor or wire layer shows before a release.
+package loadtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+)
+
+// Target is where the load is submitted. Its methods are called from one
+// goroutine.
+type Target interface {
+	// Submit commits and reveals the entry, the first entry of a new chain
+	// if newChain is true, and returns its entry hash
+	Submit(e *common.Entry, newChain bool) (string, error)
+	// Sealed returns the hashes of the entries found in a block since the
+	// last call
+	Sealed() ([]string, error)
+	// Close releases the target, and returns an error if it ended in a bad
+	// state
+	Close() error
+}
+
+// Config is the load to generate. The zero fields take the default values.
+type Config struct {
+	Rate      float64       // entries submitted a second, 10 by default
+	Duration  time.Duration // of the submission, 1 minute by default
+	Chains    int           // new chains the entries are spread over, 1 by default
+	EntrySize int           // bytes of content of the entries, 100 by default
+	Wait      time.Duration // for the last entries to reach a block, 15 minutes by default
+	Poll      time.Duration // between two checks for sealed entries, 100ms by default
+}
+
+func (c *Config) complete() {
+	if c.Rate <= 0 {
+		c.Rate = 10
+	}
+	if c.Duration <= 0 {
+		c.Duration = time.Minute
+	}
+	if c.Chains <= 0 {
+		c.Chains = 1
+	}
+	if c.EntrySize <= 0 {
+		c.EntrySize = 100
+	}
+	if c.Wait <= 0 {
+		c.Wait = 15 * time.Minute
+	}
+	if c.Poll <= 0 {
+		c.Poll = 100 * time.Millisecond
+	}
+}
+
+// Latency is the distribution of the time from the commit of the entries to
+// the block sealing them
+type Latency struct {
+	Min, Avg, P50, P95, P99, Max time.Duration
+}
+
+// Report is the outcome of a run
+type Report struct {
+	Submitted  int
+	Failed     int           // submissions refused by the target
+	Sealed     int           // entries found in a block
+	Elapsed    time.Duration // from the first submission to the last entry sealed
+	SubmitRate float64       // entries submitted a second
+	SealRate   float64       // entries sealed a second
+	Latency    Latency
+	LastError  string `json:",omitempty"` // of the last submission refused
+}
+
+// Unsealed returns the number of entries accepted by the target but not
+// found in a block
+func (r *Report) Unsealed() int {
+	return r.Submitted - r.Failed - r.Sealed
+}
+
+// Write prints the report
+func (r *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "Submitted:  %d entries, %.1f/s\n", r.Submitted, r.SubmitRate)
+	fmt.Fprintf(w, "Failed:     %d\n", r.Failed)
+	fmt.Fprintf(w, "Sealed:     %d entries in %v, %.1f/s\n", r.Sealed, r.Elapsed, r.SealRate)
+	fmt.Fprintf(w, "Unsealed:   %d\n", r.Unsealed())
+	if r.Sealed > 0 {
+		l := r.Latency
+		fmt.Fprintf(w, "Latency:    min %v, avg %v, p50 %v, p95 %v, p99 %v, max %v\n",
+			l.Min, l.Avg, l.P50, l.P95, l.P99, l.Max)
+	}
+	if r.LastError != "" {
+		fmt.Fprintf(w, "Last error: %s\n", r.LastError)
+	}
+}
+
+// run is the state of a run
+type run struct {
+	target    Target
+	config    *Config
+	id        string // tells the chains of the run from those of the others
+	chains    []*common.Hash
+	submitted map[string]time.Time // entries not sealed yet, by hash
+	latencies durations
+	lastSeal  time.Time
+	report    *Report
+}
+
+// Run submits the load of the config to the target, then waits for the
+// submitted entries to reach a block, and reports the throughput and the
+// latency. The first entries of the run create its chains.
+func Run(target Target, config *Config) (*Report, error) {
+	c := *config
+	c.complete()
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	r := &run{
+		target:    target,
+		config:    &c,
+		id:        hex.EncodeToString(nonce),
+		submitted: make(map[string]time.Time),
+		report:    new(Report),
+	}
+
+	start := time.Now()
+	interval := time.Duration(float64(time.Second) / c.Rate)
+	nextPoll := start.Add(c.Poll)
+	for n := 0; ; n++ {
+		at := start.Add(time.Duration(n) * interval)
+		if at.Sub(start) >= c.Duration {
+			break
+		}
+		// poll while waiting for the time of the next entry
+		for now := time.Now(); now.Before(at); now = time.Now() {
+			if !now.Before(nextPoll) {
+				if err := r.collect(); err != nil {
+					return nil, err
+				}
+				nextPoll = time.Now().Add(c.Poll)
+				continue
+			}
+			wake := at
+			if nextPoll.Before(wake) {
+				wake = nextPoll
+			}
+			time.Sleep(wake.Sub(now))
+		}
+		if err := r.submit(n); err != nil {
+			return nil, err
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 0 {
+		r.report.SubmitRate = float64(r.report.Submitted) / elapsed.Seconds()
+	}
+
+	for deadline := time.Now().Add(c.Wait); len(r.submitted) > 0 && time.Now().Before(deadline); {
+		time.Sleep(c.Poll)
+		if err := r.collect(); err != nil {
+			return nil, err
+		}
+	}
+
+	rep := r.report
+	if rep.Sealed > 0 {
+		rep.Elapsed = r.lastSeal.Sub(start)
+		rep.SealRate = float64(rep.Sealed) / rep.Elapsed.Seconds()
+		rep.Latency = r.latencies.latency()
+	}
+	return rep, nil
+}
+
+// submit generates and submits the entry n of the run. A submission refused
+// by the target is counted as failed, only the errors of the generation are
+// returned.
+func (r *run) submit(n int) error {
+	e := common.NewEntry()
+	newChain := len(r.chains) < r.config.Chains
+	if newChain {
+		e.ExtIDs = append(e.ExtIDs, []byte("loadtest"), []byte(r.id), []byte(fmt.Sprint(n)))
+		e.ChainID = common.NewChainID(e)
+	} else {
+		e.ChainID = r.chains[n%len(r.chains)]
+		e.ExtIDs = append(e.ExtIDs, []byte(fmt.Sprint(n)))
+	}
+	e.Content = make([]byte, r.config.EntrySize)
+	if _, err := rand.Read(e.Content); err != nil {
+		return err
+	}
+
+	sent := time.Now()
+	hash, err := r.target.Submit(e, newChain)
+	r.report.Submitted++
+	if err != nil {
+		r.report.Failed++
+		r.report.LastError = err.Error()
+		return nil
+	}
+	if newChain {
+		r.chains = append(r.chains, e.ChainID)
+	}
+	r.submitted[hash] = sent
+	return nil
+}
+
+// collect takes the entries sealed since the last call
+func (r *run) collect() error {
+	hashes, err := r.target.Sealed()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, h := range hashes {
+		sent, ok := r.submitted[h]
+		if !ok {
+			// not an entry of the run
+			continue
+		}
+		delete(r.submitted, h)
+		r.latencies = append(r.latencies, now.Sub(sent))
+		r.report.Sealed++
+		r.lastSeal = now
+	}
+	return nil
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// latency returns the distribution of the durations, which must not be
+// empty
+func (d durations) latency() Latency {
+	sort.Sort(d)
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	percentile := func(p int) time.Duration {
+		return d[(len(d)-1)*p/100]
+	}
+	return Latency{
+		Min: d[0],
+		Avg: sum / time.Duration(len(d)),
+		P50: percentile(50),
+		P95: percentile(95),
+		P99: percentile(99),
+		Max: d[len(d)-1],
+	}
+}
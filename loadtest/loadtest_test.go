@@ -0,0 +1,46 @@
+package loadtest_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/FactomProject/FactomCode/loadtest"
+)
+
+func TestRunSimulation(t *testing.T) {
+	target, err := NewSimTarget(3, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Run(target, &Config{
+		Rate:     200,
+		Duration: 300 * time.Millisecond,
+		Chains:   2,
+		Wait:     time.Second,
+		Poll:     10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if r.Submitted != 60 || r.Failed != 0 || r.Sealed != r.Submitted || r.Unsealed() != 0 {
+		t.Errorf("Unexpected report %+v", r)
+	}
+	if l := r.Latency; l.Min <= 0 || l.Min > l.P50 || l.P50 > l.P99 || l.P99 > l.Max {
+		t.Errorf("Unexpected latency %+v", l)
+	}
+	if target.Sim.Leader().Height() < 2 {
+		t.Errorf("The entries were sealed in %d blocks", target.Sim.Leader().Height())
+	}
+
+	var b bytes.Buffer
+	r.Write(&b)
+	if !strings.Contains(b.String(), "Sealed:     60 entries") {
+		t.Errorf("Unexpected report:\n%s", b.String())
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package loadtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/FactomProject/websocket"
+)
+
+// maxResponse bounds the responses read from the node
+const maxResponse = 1 << 20
+
+// NodeTarget submits the entries to a node through its API, paid by the
+// entry credits of Key, and learns the sealed entries from the entry events
+// of /v1/subscribe. The API key, if any, needs the submit permission and a
+// rate limit above the load.
+type NodeTarget struct {
+	URL        string
+	APIKey     string
+	Key        common.PrivateKey
+	HTTPClient *http.Client
+
+	ws     *websocket.Conn
+	mutex  sync.Mutex
+	sealed []string
+	err    error // of the subscription
+}
+
+var _ Target = (*NodeTarget)(nil)
+
+// NewNodeTarget subscribes to the entry events of the node at url, such as
+// http://localhost:8088
+func NewNodeTarget(url, apiKey string, key common.PrivateKey) (*NodeTarget, error) {
+	t := &NodeTarget{URL: strings.TrimSuffix(url, "/"), APIKey: apiKey, Key: key}
+
+	header := make(http.Header)
+	if apiKey != "" {
+		header.Set("X-Factom-Key", apiKey)
+	}
+	var dialer websocket.Dialer
+	var err error
+	if t.ws, _, err = dialer.Dial("ws"+strings.TrimPrefix(t.URL, "http")+"/v1/subscribe", header); err != nil {
+		return nil, err
+	}
+	req := map[string]string{"Action": "subscribe", "Type": "entry", "Key": ""}
+	resp := new(struct {
+		Response string
+		Success  bool
+	})
+	if err := t.ws.WriteJSON(req); err != nil {
+		t.ws.Close()
+		return nil, err
+	}
+	if err := t.ws.ReadJSON(resp); err != nil {
+		t.ws.Close()
+		return nil, err
+	}
+	if !resp.Success {
+		t.ws.Close()
+		return nil, fmt.Errorf("Subscription refused: %s", resp.Response)
+	}
+
+	go t.receive()
+	return t, nil
+}
+
+// receive keeps the hashes of the entry events until the connection closes
+func (t *NodeTarget) receive() {
+	for {
+		e := new(struct {
+			Type      string
+			EntryHash string
+		})
+		err := t.ws.ReadJSON(e)
+		t.mutex.Lock()
+		if err != nil {
+			t.err = err
+			t.mutex.Unlock()
+			return
+		}
+		if e.Type == "entry" {
+			t.sealed = append(t.sealed, e.EntryHash)
+		}
+		t.mutex.Unlock()
+	}
+}
+
+// Submit signs the commit of the entry, posts it and then the reveal
+func (t *NodeTarget) Submit(e *common.Entry, newChain bool) (string, error) {
+	bin, err := e.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+
+	if newChain {
+		c, err := commitChain(e, t.Key)
+		if err != nil {
+			return "", err
+		}
+		p, err := c.MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+		if err := t.post("/v1/commit-chain", map[string]string{"CommitChainMsg": hex.EncodeToString(p)}); err != nil {
+			return "", err
+		}
+		return c.EntryHash.String(), t.post("/v1/reveal-chain", map[string]string{"Entry": hex.EncodeToString(bin)})
+	}
+
+	c, err := commitEntry(e, t.Key)
+	if err != nil {
+		return "", err
+	}
+	p, err := c.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	if err := t.post("/v1/commit-entry", map[string]string{"CommitEntryMsg": hex.EncodeToString(p)}); err != nil {
+		return "", err
+	}
+	return c.EntryHash.String(), t.post("/v1/reveal-entry", map[string]string{"Entry": hex.EncodeToString(bin)})
+}
+
+// commitChain returns the commit of the chain of its first entry e, signed
+// with key
+func commitChain(e *common.Entry, key common.PrivateKey) (*common.CommitChain, error) {
+	credits, err := entryCost(e)
+	if err != nil {
+		return nil, err
+	}
+	c := common.NewCommitChain()
+	c.MilliTime = milliTime()
+	c.ChainIDHash.SetBytes(common.DoubleSha(e.ChainID.Bytes()))
+	c.EntryHash = e.Hash()
+	c.Weld.SetBytes(common.DoubleSha(append(c.EntryHash.Bytes(), e.ChainID.Bytes()...)))
+	// 10 credits are for the chain creation
+	c.Credits = credits + 10
+	c.ECPubKey = key.Pub.Key
+	c.Sig = key.Sign(c.CommitMsg()).Sig
+	return c, nil
+}
+
+// commitEntry returns the commit of the entry e, signed with key
+func commitEntry(e *common.Entry, key common.PrivateKey) (*common.CommitEntry, error) {
+	credits, err := entryCost(e)
+	if err != nil {
+		return nil, err
+	}
+	c := common.NewCommitEntry()
+	c.MilliTime = milliTime()
+	c.EntryHash = e.Hash()
+	c.Credits = credits
+	c.ECPubKey = key.Pub.Key
+	c.Sig = key.Sign(c.CommitMsg()).Sig
+	return c, nil
+}
+
+func entryCost(e *common.Entry) (uint8, error) {
+	bin, err := e.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	return util.EntryCost(bin)
+}
+
+// Sealed returns the entries of the events received since the last call
+func (t *NodeTarget) Sealed() ([]string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.err != nil {
+		return nil, fmt.Errorf("Subscription lost: %s", t.err)
+	}
+	sealed := t.sealed
+	t.sealed = nil
+	return sealed, nil
+}
+
+// Close closes the subscription
+func (t *NodeTarget) Close() error {
+	return t.ws.Close()
+}
+
+func (t *NodeTarget) post(path string, v interface{}) error {
+	client := t.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	p, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", t.URL+path, bytes.NewReader(p))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.APIKey != "" {
+		req.Header.Set("X-Factom-Key", t.APIKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: maxResponse})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// milliTime returns the current time in milliseconds on 6 bytes
+func milliTime() *[6]byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(time.Now().UnixNano()/1e6))
+	t := new([6]byte)
+	copy(t[:], b[2:])
+	return t
+}
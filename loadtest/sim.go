@@ -0,0 +1,157 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package loadtest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/simulation"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// simCredits are the entry credits the simulation gives to the key of the
+// target, enough for any load
+const simCredits = 1 << 30
+
+// SimTarget submits the entries to the leader of an in-process simulation,
+// paid by the entry credits of Key, and seals a block every BlockTime. The
+// followers store the blocks of the leader.
+type SimTarget struct {
+	Sim       *simulation.Simulation
+	BlockTime time.Duration
+	Key       common.PrivateKey
+
+	dir      string
+	lastSeal time.Time
+	height   uint32 // dir blocks already searched for sealed entries
+}
+
+var _ Target = (*SimTarget)(nil)
+
+// NewSimTarget returns a target simulating the servers, with their files
+// in a temporary directory, and funds a new entry credit key
+func NewSimTarget(servers int, blockTime time.Duration) (*SimTarget, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	key, err := common.NewPrivateKeyFromSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "factomload")
+	if err != nil {
+		return nil, err
+	}
+	sim, err := simulation.NewSimulation(dir, servers)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	t := &SimTarget{
+		Sim:       sim,
+		BlockTime: blockTime,
+		Key:       key,
+		dir:       dir,
+		lastSeal:  time.Now(),
+		height:    sim.Leader().Height(),
+	}
+	if err := sim.Fund(key.Pub.Key, simCredits); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Submit sends the signed commit of the entry to the leader, and then the
+// reveal
+func (t *SimTarget) Submit(e *common.Entry, newChain bool) (string, error) {
+	var commit wire.FtmInternalMsg
+	var hash *common.Hash
+	if newChain {
+		c, err := commitChain(e, t.Key)
+		if err != nil {
+			return "", err
+		}
+		commit, hash = &wire.MsgCommitChain{CommitChain: c}, c.EntryHash
+	} else {
+		c, err := commitEntry(e, t.Key)
+		if err != nil {
+			return "", err
+		}
+		commit, hash = &wire.MsgCommitEntry{CommitEntry: c}, c.EntryHash
+	}
+	if err := t.Sim.Submit(commit); err != nil {
+		return "", err
+	}
+	if err := t.Sim.Submit(&wire.MsgRevealEntry{Entry: e}); err != nil {
+		return "", err
+	}
+	t.Sim.Net.Settle()
+	return hash.String(), nil
+}
+
+// Sealed seals a block if BlockTime has passed since the last one, and
+// returns the entries of the entry blocks of the new dir blocks of the
+// leader
+func (t *SimTarget) Sealed() ([]string, error) {
+	if time.Since(t.lastSeal) < t.BlockTime {
+		return nil, nil
+	}
+	t.lastSeal = time.Now()
+	if err := t.Sim.SealBlock(); err != nil {
+		return nil, err
+	}
+	t.Sim.Net.Settle()
+
+	leader := t.Sim.Leader()
+	hashes := make([]string, 0)
+	for ; t.height < leader.Height(); t.height++ {
+		b, err := leader.DBlock(t.height)
+		if err != nil {
+			return nil, err
+		}
+		for _, dbe := range b.DBEntries {
+			if isSystemChain(dbe.ChainID) {
+				continue
+			}
+			eb, err := leader.EBlock(dbe.KeyMR)
+			if err != nil {
+				return nil, err
+			}
+			for _, h := range eb.Body.EBEntries {
+				if !h.IsMinuteMarker() {
+					hashes = append(hashes, h.String())
+				}
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// Close returns an error if the servers do not have the same blocks, and
+// removes their files
+func (t *SimTarget) Close() error {
+	err := t.Sim.AssertConsistent()
+	t.Sim.Close()
+	os.RemoveAll(t.dir)
+	return err
+}
+
+// isSystemChain returns true for the chains of the admin, entry credit and
+// factoid blocks
+func isSystemChain(chainID *common.Hash) bool {
+	for _, id := range [][]byte{common.ADMIN_CHAINID, common.EC_CHAINID, common.FACTOID_CHAINID} {
+		if bytes.Equal(chainID.Bytes(), id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package metrics keeps the counters, gauges and histograms of the node and
+// writes them in the Prometheus text format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the histogram buckets, in seconds, for latencies
+var DefaultBuckets = []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10}
+
+type metric interface {
+	write(w io.Writer) error
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      = make(map[string]metric)
+	scrapeHooks   []func()
+)
+
+func register(name string, m metric) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("metric " + name + " registered twice")
+	}
+	registry[name] = m
+}
+
+// OnScrape adds a function run before the metrics are written, to set the
+// gauges read from the state of the node
+func OnScrape(f func()) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	scrapeHooks = append(scrapeHooks, f)
+}
+
+// WriteText runs the scrape hooks and writes the metrics, ordered by name,
+// in the Prometheus text format
+func WriteText(w io.Writer) error {
+	registryMutex.Lock()
+	hooks := append([]func(){}, scrapeHooks...)
+	registryMutex.Unlock()
+	for _, f := range hooks {
+		f()
+	}
+
+	registryMutex.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]metric, len(names))
+	for i, name := range names {
+		list[i] = registry[name]
+	}
+	registryMutex.Unlock()
+
+	for _, m := range list {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vec keeps the values of a counter or a gauge by label values
+type vec struct {
+	mutex  sync.Mutex
+	name   string
+	help   string
+	kind   string
+	labels []string
+	values map[string]float64 // by the label values joined with \xff
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	v := &vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]float64)}
+	if len(labels) == 0 {
+		v.values[""] = 0
+	}
+	register(name, v)
+	return v
+}
+
+func (v *vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, not %d", v.name, len(v.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (v *vec) write(w io.Writer) error {
+	v.mutex.Lock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		var labels string
+		if len(v.labels) > 0 {
+			labels = labelPairs(v.labels, strings.Split(k, "\xff"))
+		}
+		lines = append(lines, v.name+labels+" "+formatFloat(v.values[k])+"\n")
+	}
+	v.mutex.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, strings.Join(lines, ""))
+	return err
+}
+
+// Counter is a value only going up, such as a number of messages
+type Counter struct {
+	v *vec
+}
+
+// NewCounter registers a counter with the label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newVec(name, help, "counter", labels)}
+}
+
+// Add adds d to the counter of the label values
+func (c *Counter) Add(d float64, labelValues ...string) {
+	k := c.v.key(labelValues)
+	c.v.mutex.Lock()
+	defer c.v.mutex.Unlock()
+	c.v.values[k] += d
+}
+
+// Inc adds 1 to the counter of the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Gauge is a value going up and down, such as a height or a size
+type Gauge struct {
+	v *vec
+}
+
+// NewGauge registers a gauge with the label names
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newVec(name, help, "gauge", labels)}
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(x float64, labelValues ...string) {
+	k := g.v.key(labelValues)
+	g.v.mutex.Lock()
+	defer g.v.mutex.Unlock()
+	g.v.values[k] = x
+}
+
+// Histogram counts observed values, such as latencies, in buckets
+type Histogram struct {
+	mutex   sync.Mutex
+	name    string
+	help    string
+	buckets []float64
+	counts  []uint64 // by bucket, not cumulative
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram registers a histogram with the bucket upper bounds
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	h := &Histogram{name: name, help: help, buckets: b, counts: make([]uint64, len(b))}
+	register(name, h)
+	return h
+}
+
+// Observe adds the value to the histogram
+func (h *Histogram) Observe(x float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	i := sort.SearchFloat64s(h.buckets, x)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += x
+}
+
+// Since observes the seconds elapsed since start
+func (h *Histogram) Since(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mutex.Lock()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(&b, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(le), cumulative)
+	}
+	fmt.Fprintf(&b, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(&b, "%s_sum %s\n%s_count %d\n", h.name, formatFloat(h.sum), h.name, h.count)
+	h.mutex.Unlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelPairs(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, n := range names {
+		pairs[i] = n + "=\"" + labelEscaper.Replace(values[i]) + "\""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(x float64) string {
+	switch {
+	case math.IsInf(x, 1):
+		return "+Inf"
+	case math.IsInf(x, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
@@ -0,0 +1,45 @@
+package metrics_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/metrics"
+)
+
+func TestWriteText(t *testing.T) {
+	c := NewCounter("test_messages_total", "Messages.", "command")
+	g := NewGauge("test_height", "Height.")
+	h := NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1})
+
+	c.Inc("ack")
+	c.Add(2, "commit \"x\"")
+	OnScrape(func() { g.Set(42) })
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_height Height.
+# TYPE test_height gauge
+test_height 42
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 1
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3.55
+test_latency_seconds_count 3
+# HELP test_messages_total Messages.
+# TYPE test_messages_total counter
+test_messages_total{command="ack"} 1
+test_messages_total{command="commit \"x\""} 2
+`
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("Metrics are\n%s\nnot\n%s", got, want)
+	}
+}
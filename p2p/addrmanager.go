@@ -0,0 +1,214 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// recentSuccess is how long a successful address is preferred
+	recentSuccess = 24 * time.Hour
+
+	// maxFailedAttempts is the failed attempts after which an address is
+	// tried last
+	maxFailedAttempts = 10
+
+	// staleAfter is how long an address not seen nor connected to is kept
+	staleAfter = 30 * 24 * time.Hour
+)
+
+// KnownAddress is a peer address with when it was last seen, tried and
+// connected to
+type KnownAddress struct {
+	Addr        string
+	Source      string
+	LastSeen    time.Time
+	LastAttempt time.Time
+	LastSuccess time.Time
+	Attempts    int // failed attempts since the last success
+}
+
+// AddrManager keeps the known peer addresses in a JSON file, so they are
+// not lost on restart
+type AddrManager struct {
+	mutex sync.Mutex
+	path  string
+	addrs map[string]*KnownAddress
+}
+
+// NewAddrManager returns an AddrManager saving the addresses in the file at
+// path
+func NewAddrManager(path string) *AddrManager {
+	return &AddrManager{path: path, addrs: make(map[string]*KnownAddress)}
+}
+
+// Load reads the addresses saved in the file, if any
+func (a *AddrManager) Load() error {
+	p, err := ioutil.ReadFile(a.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []*KnownAddress
+	if err := json.Unmarshal(p, &list); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, k := range list {
+		if k.Addr != "" {
+			a.addrs[k.Addr] = k
+		}
+	}
+	return nil
+}
+
+// Save writes the addresses to the file, without the stale ones
+func (a *AddrManager) Save() error {
+	now := time.Now()
+	a.mutex.Lock()
+	list := make([]*KnownAddress, 0, len(a.addrs))
+	for addr, k := range a.addrs {
+		if now.Sub(k.LastSeen) > staleAfter && now.Sub(k.LastSuccess) > staleAfter {
+			delete(a.addrs, addr)
+			continue
+		}
+		list = append(list, k)
+	}
+	p, err := json.MarshalIndent(list, "", "\t")
+	a.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := a.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, p, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// Add adds the addresses, or updates when they were last seen
+func (a *AddrManager) Add(addrs []string, source string) {
+	now := time.Now()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, addr := range addrs {
+		k := a.addrs[addr]
+		if k == nil {
+			k = &KnownAddress{Addr: addr, Source: source}
+			a.addrs[addr] = k
+		}
+		k.LastSeen = now
+	}
+}
+
+// Attempt records a connection attempt to the address, counted as failed
+// until Connected is called
+func (a *AddrManager) Attempt(addr string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	k := a.addrs[addr]
+	if k == nil {
+		k = &KnownAddress{Addr: addr, Source: "peer", LastSeen: time.Now()}
+		a.addrs[addr] = k
+	}
+	k.LastAttempt = time.Now()
+	k.Attempts++
+}
+
+// Connected records a successful connection to the address
+func (a *AddrManager) Connected(addr string) {
+	now := time.Now()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	k := a.addrs[addr]
+	if k == nil {
+		k = &KnownAddress{Addr: addr, Source: "peer"}
+		a.addrs[addr] = k
+	}
+	k.LastSeen = now
+	k.LastSuccess = now
+	k.Attempts = 0
+}
+
+// Choose returns up to n addresses to connect to, all of them if n is 0,
+// recently successful ones first and banned or filtered ones left out
+func (a *AddrManager) Choose(n int) []string {
+	list := a.Addresses()
+	now := time.Now()
+	ranks := make([]int, len(list))
+	for i, k := range list {
+		switch {
+		case k.Attempts >= maxFailedAttempts:
+			ranks[i] = 3
+		case now.Sub(k.LastSuccess) < recentSuccess:
+			ranks[i] = 0
+		case !k.LastSuccess.IsZero():
+			ranks[i] = 1
+		default:
+			ranks[i] = 2
+		}
+	}
+	sort.Sort(byPreference{list, ranks})
+
+	addrs := make([]string, 0, len(list))
+	for _, k := range list {
+		if n > 0 && len(addrs) == n {
+			break
+		}
+		if !IsBanned(k.Addr) && Allowed(k.Addr) {
+			addrs = append(addrs, k.Addr)
+		}
+	}
+	return addrs
+}
+
+// Addresses returns the known addresses
+func (a *AddrManager) Addresses() []KnownAddress {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	list := make([]KnownAddress, 0, len(a.addrs))
+	for _, k := range a.addrs {
+		list = append(list, *k)
+	}
+	return list
+}
+
+// byPreference orders addresses by rank, then by last success and last seen,
+// the most recent first
+type byPreference struct {
+	list  []KnownAddress
+	ranks []int
+}
+
+func (b byPreference) Len() int { return len(b.list) }
+
+func (b byPreference) Swap(i, j int) {
+	b.list[i], b.list[j] = b.list[j], b.list[i]
+	b.ranks[i], b.ranks[j] = b.ranks[j], b.ranks[i]
+}
+
+func (b byPreference) Less(i, j int) bool {
+	if b.ranks[i] != b.ranks[j] {
+		return b.ranks[i] < b.ranks[j]
+	}
+	if !b.list[i].LastSuccess.Equal(b.list[j].LastSuccess) {
+		return b.list[i].LastSuccess.After(b.list[j].LastSuccess)
+	}
+	if !b.list[i].LastSeen.Equal(b.list[j].LastSeen) {
+		return b.list[i].LastSeen.After(b.list[j].LastSeen)
+	}
+	return b.list[i].Addr < b.list[j].Addr
+}
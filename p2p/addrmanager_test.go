@@ -0,0 +1,50 @@
+package p2p_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/p2p"
+)
+
+func TestAddrManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "addrmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peers.json")
+
+	a := NewAddrManager(path)
+	if err := a.Load(); err != nil {
+		t.Fatal(err)
+	}
+	a.Add([]string{"10.3.0.1:8108", "10.3.0.2:8108", "10.3.0.3:8108"}, "seed")
+	a.Attempt("10.3.0.2:8108")
+	a.Connected("10.3.0.2:8108")
+	for i := 0; i < 10; i++ {
+		a.Attempt("10.3.0.3:8108")
+	}
+
+	want := []string{"10.3.0.2:8108", "10.3.0.1:8108", "10.3.0.3:8108"}
+	if addrs := a.Choose(0); !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Addresses are chosen in the order %v, not %v", addrs, want)
+	}
+	if addrs := a.Choose(1); !reflect.DeepEqual(addrs, want[:1]) {
+		t.Errorf("Chose %v, not the most recently successful address", addrs)
+	}
+
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	b := NewAddrManager(path)
+	if err := b.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if addrs := b.Choose(0); !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Loaded addresses are chosen in the order %v, not %v", addrs, want)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Misbehavior is a kind of peer misbehavior adding to the ban score of the
+// peer IP. The relay of the peer messages finds the malformed, oversized and
+// checkpoint contradicting ones, see relay.go, and the processor reports the
+// others with ReportMisbehavior.
+type Misbehavior int
+
+const (
+	MalformedMessage Misbehavior = iota
+	OversizedPayload
+	ContradictsCheckpoint
+	InvalidSignature
+	StaleHeight
+)
+
+// misbehaviorScores are the points each misbehavior adds
+var misbehaviorScores = map[Misbehavior]float64{
+	MalformedMessage:      20,
+	OversizedPayload:      50,
+	ContradictsCheckpoint: 100,
+	InvalidSignature:      50,
+	StaleHeight:           10,
+}
+
+func (m Misbehavior) String() string {
+	switch m {
+	case MalformedMessage:
+		return "malformed message"
+	case OversizedPayload:
+		return "oversized payload"
+	case ContradictsCheckpoint:
+		return "dir block contradicting a checkpoint"
+	case InvalidSignature:
+		return "invalid signature"
+	case StaleHeight:
+		return "stale height"
+	}
+	return "unknown misbehavior"
+}
+
+// banScoreHalfLife is how long a ban score takes to halve, so occasional
+// misbehavior does not add up to a ban
+const banScoreHalfLife = 10 * time.Minute
+
+// BanInfo is a banned IP
+type BanInfo struct {
+	IP     string
+	Until  time.Time
+	Reason string
+}
+
+type banScore struct {
+	score float64
+	at    time.Time
+}
+
+var (
+	banMutex     sync.Mutex
+	banScores    = make(map[string]*banScore)
+	bans         = make(map[string]*BanInfo)
+	banThreshold = 100.0
+	banDuration  = 24 * time.Hour
+)
+
+// Misbehaving adds the score of the misbehavior to the ban score of the IP
+// of the peer at addr, unless it is whitelisted. It returns true when the
+// score crosses the threshold and the IP is banned, and the peer is to be
+// disconnected.
+func Misbehaving(addr string, m Misbehavior) bool {
+	if IsWhitelisted(addr) {
+		return false
+	}
+	ip := hostIP(addr)
+	now := time.Now()
+
+	banMutex.Lock()
+	defer banMutex.Unlock()
+	s := banScores[ip]
+	if s == nil {
+		s = &banScore{at: now}
+		banScores[ip] = s
+	}
+	s.score = decayed(s.score, now.Sub(s.at)) + misbehaviorScores[m]
+	s.at = now
+	p2pLog.Debug("ban score of ", ip, " is ", int(s.score), " after ", m)
+
+	if s.score < banThreshold {
+		return false
+	}
+	delete(banScores, ip)
+	bans[ip] = &BanInfo{IP: ip, Until: now.Add(banDuration), Reason: m.String()}
+	p2pLog.Warning("banned ", ip, " until ", now.Add(banDuration), " for ", m)
+	return true
+}
+
+// BanScore returns the current ban score of the IP
+func BanScore(ip string) int {
+	banMutex.Lock()
+	defer banMutex.Unlock()
+	if s := banScores[hostIP(ip)]; s != nil {
+		return int(decayed(s.score, time.Since(s.at)))
+	}
+	return 0
+}
+
+// IsBanned tells if the IP of the peer at addr is banned
+func IsBanned(addr string) bool {
+	ip := hostIP(addr)
+	banMutex.Lock()
+	defer banMutex.Unlock()
+	b := bans[ip]
+	if b == nil {
+		return false
+	}
+	if time.Now().After(b.Until) {
+		delete(bans, ip)
+		return false
+	}
+	return true
+}
+
+// Ban bans the IP for d, or for the ban duration of the config if d is 0,
+// and disconnects its peers
+func Ban(ip string, d time.Duration, reason string) {
+	ip = hostIP(ip)
+	banMutex.Lock()
+	if d == 0 {
+		d = banDuration
+	}
+	delete(banScores, ip)
+	bans[ip] = &BanInfo{IP: ip, Until: time.Now().Add(d), Reason: reason}
+	banMutex.Unlock()
+	p2pLog.Info("banned ", ip, " for ", d, ": ", reason)
+	disconnectIP(ip)
+}
+
+// Unban lifts the ban of the IP, and returns false if it was not banned
+func Unban(ip string) bool {
+	ip = hostIP(ip)
+	banMutex.Lock()
+	defer banMutex.Unlock()
+	if bans[ip] == nil {
+		return false
+	}
+	delete(bans, ip)
+	p2pLog.Info("unbanned ", ip)
+	return true
+}
+
+// Bans returns the banned IPs, ordered by IP
+func Bans() []BanInfo {
+	now := time.Now()
+	banMutex.Lock()
+	defer banMutex.Unlock()
+	list := make([]BanInfo, 0, len(bans))
+	for ip, b := range bans {
+		if now.After(b.Until) {
+			delete(bans, ip)
+			continue
+		}
+		list = append(list, *b)
+	}
+	sort.Sort(byIP(list))
+	return list
+}
+
+func decayed(score float64, elapsed time.Duration) float64 {
+	return score * math.Pow(0.5, float64(elapsed)/float64(banScoreHalfLife))
+}
+
+// hostIP returns the IP of a host:port address, or the address itself if it
+// has no port
+func hostIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+type byIP []BanInfo
+
+func (b byIP) Len() int           { return len(b) }
+func (b byIP) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byIP) Less(i, j int) bool { return b[i].IP < b[j].IP }
@@ -0,0 +1,44 @@
+package p2p_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/FactomProject/FactomCode/p2p"
+)
+
+func TestMisbehaving(t *testing.T) {
+	addr := "10.2.0.1:8108"
+	if Misbehaving(addr, MalformedMessage) {
+		t.Fatalf("Peer banned after one malformed message")
+	}
+	if s := BanScore("10.2.0.1"); s < 19 || s > 20 {
+		t.Errorf("Ban score is %d after a malformed message", s)
+	}
+	if Misbehaving(addr, InvalidSignature) {
+		t.Fatalf("Peer banned under the threshold")
+	}
+	if !Misbehaving(addr, OversizedPayload) {
+		t.Fatalf("Peer not banned over the threshold")
+	}
+	if !IsBanned("10.2.0.1:9999") {
+		t.Errorf("IP not banned on another port")
+	}
+
+	bans := Bans()
+	if len(bans) != 1 || bans[0].IP != "10.2.0.1" || bans[0].Reason != "oversized payload" {
+		t.Errorf("Bans are %v", bans)
+	}
+	if !Unban("10.2.0.1") || IsBanned(addr) {
+		t.Errorf("IP still banned after unban")
+	}
+	if Unban("10.2.0.1") {
+		t.Errorf("Unban of an IP not banned")
+	}
+
+	Ban("10.2.0.2", time.Millisecond, "test")
+	time.Sleep(2 * time.Millisecond)
+	if IsBanned("10.2.0.2") {
+		t.Errorf("Ban did not expire")
+	}
+}
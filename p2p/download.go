@@ -0,0 +1,297 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// During the initial sync, the dir blocks are downloaded in ranges from
+// several peers at once. A range getting no block for the stall timeout is
+// given to another peer, and the peer stalling gets no range for a while.
+// The fastest peers get the lowest ranges, which the validator waits for.
+
+// maxRangesPerPeer is how many ranges a peer downloads at once
+const maxRangesPerPeer = 2
+
+// RangeRequest asks the peer for the dir blocks from First to Last
+type RangeRequest struct {
+	Peer  string
+	First uint32
+	Last  uint32
+}
+
+// PeerThroughput is how fast a peer sends the blocks of its ranges
+type PeerThroughput struct {
+	Addr            string
+	Blocks          int
+	BlocksPerSecond float64
+	Ranges          int // ranges being downloaded
+	Stalls          int
+}
+
+type blockRange struct {
+	first, last uint32
+	peer        string
+	lastBlock   time.Time // assignment or last block received
+	received    map[uint32]bool
+}
+
+func (r *blockRange) complete() bool {
+	return len(r.received) == int(r.last-r.first+1)
+}
+
+type peerSync struct {
+	start        time.Time
+	blocks       int
+	stalls       int
+	stalledUntil time.Time
+}
+
+func (p *peerSync) rate(now time.Time) float64 {
+	if p.start.IsZero() || p.blocks == 0 {
+		return 0
+	}
+	return float64(p.blocks) / now.Sub(p.start).Seconds()
+}
+
+// Downloader schedules the download of the dir blocks from First to Last
+type Downloader struct {
+	mutex        sync.Mutex
+	rangeSize    uint32
+	stallTimeout time.Duration
+	next         uint32 // first height not in a range
+	last         uint32
+	ranges       []*blockRange // ranges not complete, lowest first
+	peers        map[string]*peerSync
+}
+
+// NewDownloader returns a Downloader of the dir blocks from first to last,
+// in ranges of rangeSize blocks
+func NewDownloader(first, last uint32, rangeSize int, stallTimeout time.Duration) *Downloader {
+	if rangeSize < 1 {
+		rangeSize = 1
+	}
+	return &Downloader{
+		rangeSize:    uint32(rangeSize),
+		stallTimeout: stallTimeout,
+		next:         first,
+		last:         last,
+		peers:        make(map[string]*peerSync),
+	}
+}
+
+// Extend moves the last height to download up, as the network grows
+func (d *Downloader) Extend(last uint32) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if last > d.last {
+		d.last = last
+	}
+}
+
+// Schedule takes back the ranges of the stalled peers and gives the ranges
+// not being downloaded to the connected peers, the fastest first. It
+// returns the requests to send.
+func (d *Downloader) Schedule(peers []string) []RangeRequest {
+	now := time.Now()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	connected := make(map[string]bool)
+	for _, p := range peers {
+		connected[p] = true
+	}
+	load := make(map[string]int)
+	for _, r := range d.ranges {
+		if r.peer == "" {
+			continue
+		}
+		switch {
+		case !connected[r.peer]:
+			p2pLog.Info("sync peer ", r.peer, " left, blocks ", r.first, "-", r.last, " reassigned")
+			r.peer = ""
+		case now.Sub(r.lastBlock) > d.stallTimeout:
+			p2pLog.Warning("sync peer ", r.peer, " stalled, blocks ", r.first, "-", r.last, " reassigned")
+			ps := d.peer(r.peer)
+			if !ps.stalledUntil.After(now) {
+				ps.stalls++
+			}
+			ps.stalledUntil = now.Add(2 * d.stallTimeout)
+			r.peer = ""
+		default:
+			load[r.peer]++
+		}
+	}
+
+	// the peers able to take a range, the fastest first
+	ready := make([]string, 0, len(peers))
+	for _, p := range peers {
+		if now.After(d.peer(p).stalledUntil) {
+			ready = append(ready, p)
+		}
+	}
+	sort.Sort(byRate{ready, d.peers, now})
+
+	requests := make([]RangeRequest, 0)
+	for _, p := range ready {
+		for load[p] < maxRangesPerPeer {
+			r := d.unassigned()
+			if r == nil {
+				return requests
+			}
+			r.peer = p
+			r.lastBlock = now
+			if ps := d.peer(p); ps.start.IsZero() {
+				ps.start = now
+			}
+			load[p]++
+			requests = append(requests, RangeRequest{Peer: p, First: r.first, Last: r.last})
+		}
+	}
+	return requests
+}
+
+// Received records the dir block at height received from the peer
+func (d *Downloader) Received(peer string, height uint32) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i, r := range d.ranges {
+		if height < r.first || height > r.last {
+			continue
+		}
+		if r.received[height] {
+			return
+		}
+		r.received[height] = true
+		if r.peer == peer {
+			r.lastBlock = time.Now()
+		}
+		d.peer(peer).blocks++
+		if r.complete() {
+			d.ranges = append(d.ranges[:i], d.ranges[i+1:]...)
+		}
+		return
+	}
+}
+
+// Assigned tells if the range of the dir block at height is still
+// downloaded by the peer, and not received in full or given to another peer
+func (d *Downloader) Assigned(peer string, height uint32) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, r := range d.ranges {
+		if height >= r.first && height <= r.last {
+			return r.peer == peer
+		}
+	}
+	return false
+}
+
+// Completed returns the height of the first dir block not received. All the
+// dir blocks below it were received, and can be validated in order.
+func (d *Downloader) Completed() uint32 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if len(d.ranges) == 0 {
+		return d.next
+	}
+	r := d.ranges[0]
+	h := r.first
+	for r.received[h] {
+		h++
+	}
+	return h
+}
+
+// Done tells if all the dir blocks were received
+func (d *Downloader) Done() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.ranges) == 0 && d.next > d.last
+}
+
+// Throughput returns how fast each peer sent its blocks, the fastest first
+func (d *Downloader) Throughput() []PeerThroughput {
+	now := time.Now()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	addrs := make([]string, 0, len(d.peers))
+	for p := range d.peers {
+		addrs = append(addrs, p)
+	}
+	sort.Sort(byRate{addrs, d.peers, now})
+
+	list := make([]PeerThroughput, 0, len(addrs))
+	for _, p := range addrs {
+		ps := d.peers[p]
+		t := PeerThroughput{Addr: p, Blocks: ps.blocks, BlocksPerSecond: ps.rate(now), Stalls: ps.stalls}
+		for _, r := range d.ranges {
+			if r.peer == p {
+				t.Ranges++
+			}
+		}
+		list = append(list, t)
+	}
+	return list
+}
+
+func (d *Downloader) peer(addr string) *peerSync {
+	ps := d.peers[addr]
+	if ps == nil {
+		ps = new(peerSync)
+		d.peers[addr] = ps
+	}
+	return ps
+}
+
+// unassigned returns the lowest range no peer downloads, making a new one
+// if needed, or nil if there is none left
+func (d *Downloader) unassigned() *blockRange {
+	for _, r := range d.ranges {
+		if r.peer == "" {
+			return r
+		}
+	}
+	if d.next > d.last {
+		return nil
+	}
+	r := &blockRange{first: d.next, last: d.next + d.rangeSize - 1, received: make(map[uint32]bool)}
+	if r.last > d.last || r.last < r.first {
+		r.last = d.last
+	}
+	d.next = r.last + 1
+	d.ranges = append(d.ranges, r)
+	return r
+}
+
+// byRate orders peers by throughput, the fastest first. Peers not measured
+// yet come after the measured ones.
+type byRate struct {
+	addrs []string
+	peers map[string]*peerSync
+	now   time.Time
+}
+
+func (b byRate) Len() int      { return len(b.addrs) }
+func (b byRate) Swap(i, j int) { b.addrs[i], b.addrs[j] = b.addrs[j], b.addrs[i] }
+
+func (b byRate) Less(i, j int) bool {
+	ri, rj := b.rate(b.addrs[i]), b.rate(b.addrs[j])
+	if ri != rj {
+		return ri > rj
+	}
+	return b.addrs[i] < b.addrs[j]
+}
+
+func (b byRate) rate(addr string) float64 {
+	if p := b.peers[addr]; p != nil {
+		return p.rate(b.now)
+	}
+	return 0
+}
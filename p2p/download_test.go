@@ -0,0 +1,66 @@
+package p2p_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/FactomProject/FactomCode/p2p"
+)
+
+func TestDownloader(t *testing.T) {
+	d := NewDownloader(0, 49, 10, 20*time.Millisecond)
+	peers := []string{"10.0.0.1:8108", "10.0.0.2:8108"}
+
+	reqs := d.Schedule(peers)
+	if len(reqs) != 4 {
+		t.Fatalf("%d ranges scheduled, not 4", len(reqs))
+	}
+	for i, r := range reqs {
+		if r.First != uint32(i*10) || r.Last != uint32(i*10+9) {
+			t.Errorf("Range %d is %d-%d", i, r.First, r.Last)
+		}
+	}
+
+	// the first peer sends its ranges, the second one only a block, out
+	// of order
+	for h := uint32(0); h < 10; h++ {
+		d.Received(reqs[0].Peer, h)
+		d.Received(reqs[1].Peer, h+10)
+	}
+	d.Received(reqs[2].Peer, reqs[2].First+3)
+	if c := d.Completed(); c != 20 {
+		t.Errorf("Completed at %d, not 20", c)
+	}
+
+	// the second peer stalls, and its ranges go to the first one
+	time.Sleep(30 * time.Millisecond)
+	reqs = d.Schedule(peers)
+	if len(reqs) != 2 {
+		t.Fatalf("%d ranges rescheduled, not 2", len(reqs))
+	}
+	for _, r := range reqs {
+		if r.Peer != peers[0] {
+			t.Errorf("Range %d-%d given to the stalled peer", r.First, r.Last)
+		}
+	}
+	if d.Assigned(peers[1], 25) || !d.Assigned(peers[0], 25) {
+		t.Error("Stalled range still assigned to the stalled peer")
+	}
+
+	for h := uint32(20); h < 40; h++ {
+		d.Received(peers[0], h)
+	}
+	reqs = d.Schedule(peers)
+	if len(reqs) != 1 || reqs[0].Peer != peers[0] || reqs[0].First != 40 || reqs[0].Last != 49 {
+		t.Fatalf("Last range scheduled as %+v", reqs)
+	}
+	for h := uint32(40); h < 50; h++ {
+		d.Received(peers[0], h)
+	}
+	if !d.Done() || d.Completed() != 50 {
+		t.Errorf("Not done at %d", d.Completed())
+	}
+	if tp := d.Throughput(); len(tp) != 2 || tp[0].Addr != peers[0] || tp[1].Stalls != 1 {
+		t.Errorf("Throughput is %+v", tp)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Whitelisted peers are always allowed and never get a ban score.
+// Blacklisted peers are refused, inbound and outbound. With whitelist only,
+// as in a private federation, the peers not whitelisted are refused too.
+
+var (
+	filterMutex   sync.RWMutex
+	whitelist     []*net.IPNet
+	blacklist     []*net.IPNet
+	whitelistOnly bool
+)
+
+// SetFilters sets the whitelist and the blacklist, CIDR ranges or IPs, and
+// if only the whitelisted peers are allowed
+func SetFilters(white, black []string, only bool) error {
+	w, err := ParseCIDRs(white)
+	if err != nil {
+		return err
+	}
+	b, err := ParseCIDRs(black)
+	if err != nil {
+		return err
+	}
+	filterMutex.Lock()
+	defer filterMutex.Unlock()
+	whitelist, blacklist, whitelistOnly = w, b, only
+	return nil
+}
+
+// ParseCIDRs parses the CIDR ranges, where an IP is a range of itself
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %s", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// IsWhitelisted tells if the IP of the peer at addr is whitelisted
+func IsWhitelisted(addr string) bool {
+	filterMutex.RLock()
+	defer filterMutex.RUnlock()
+	return inRanges(whitelist, addr)
+}
+
+// Allowed tells if the peer at addr may connect or be connected to. A peer
+// known by a host name is allowed unless only the whitelisted peers are.
+func Allowed(addr string) bool {
+	filterMutex.RLock()
+	defer filterMutex.RUnlock()
+	if inRanges(whitelist, addr) {
+		return true
+	}
+	return !whitelistOnly && !inRanges(blacklist, addr)
+}
+
+func inRanges(nets []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(hostIP(addr))
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
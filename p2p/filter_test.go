@@ -0,0 +1,45 @@
+package p2p_test
+
+import (
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/p2p"
+)
+
+func TestFilters(t *testing.T) {
+	if err := SetFilters([]string{"10.1.2.3", "fd00::/8"}, []string{"10.0.0.0/8"}, false); err != nil {
+		t.Fatal(err)
+	}
+	defer SetFilters(nil, nil, false)
+
+	allowed := map[string]bool{
+		"10.1.2.3:8108":  true, // whitelisted in a blacklisted range
+		"10.1.2.4:8108":  false,
+		"192.0.2.1:8108": true,
+		"[fd00::1]:8108": true,
+		"seed.example":   true,
+	}
+	for addr, want := range allowed {
+		if Allowed(addr) != want {
+			t.Errorf("Allowed(%s) is %v", addr, !want)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		if Misbehaving("10.1.2.3:8108", InvalidSignature) {
+			t.Fatalf("Whitelisted peer banned")
+		}
+	}
+	if BanScore("10.1.2.3") != 0 {
+		t.Errorf("Whitelisted peer has a ban score")
+	}
+
+	SetFilters([]string{"fd00::/8"}, nil, true)
+	if Allowed("192.0.2.1:8108") || Allowed("seed.example") || !Allowed("[fd00::2]:8108") {
+		t.Errorf("Peers not whitelisted allowed with whitelist only")
+	}
+
+	if err := SetFilters([]string{"10.0.0.0/33"}, nil, false); err == nil {
+		t.Errorf("No error for an invalid CIDR")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// The btcd server dials its peers through the gate, a SOCKS5 server on
+// loopback set as its proxy. The gate takes an outbound slot for the peer,
+// unless RotatePeers just dropped it, dials it with Dial, through the proxy
+// of the config if one is set, and relays the connection.
+
+// SOCKS5 replies of the gate
+const (
+	socksSucceeded   = 0
+	socksNotAllowed  = 2
+	socksRefused     = 5
+	socksUnsupported = 7
+)
+
+// startGate listens for the connections of the btcd server on loopback, and
+// returns the address to set as its proxy
+func startGate() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		for {
+			local, err := l.Accept()
+			if err != nil {
+				p2pLog.Error("p2p gate stopped: ", err)
+				return
+			}
+			go gateConnect(local)
+		}
+	}()
+	return l.Addr().String(), nil
+}
+
+// gateConnect serves a SOCKS5 connect of the btcd server, if the peer gets
+// an outbound slot and answers
+func gateConnect(local net.Conn) {
+	local.SetDeadline(time.Now().Add(dialTimeout))
+	addr, err := readSOCKS5Connect(local)
+	if err != nil {
+		p2pLog.Debug("p2p gate: ", err)
+		local.Close()
+		return
+	}
+	if isRotated(addr) || !Slots().Connect(addr) {
+		local.Write(socksReply(socksNotAllowed))
+		local.Close()
+		return
+	}
+
+	addrManager.Attempt(addr)
+	peer, err := Dial("tcp", addr)
+	if err != nil {
+		p2pLog.Debug("cannot connect to ", addr, ": ", err)
+		Slots().Disconnected(addr)
+		local.Write(socksReply(socksRefused))
+		local.Close()
+		return
+	}
+	addrManager.Connected(addr)
+	if _, err := local.Write(socksReply(socksSucceeded)); err != nil {
+		Slots().Disconnected(addr)
+		peer.Close()
+		local.Close()
+		return
+	}
+	local.SetDeadline(time.Time{})
+	relay(addr, peer, local)
+}
+
+// readSOCKS5Connect reads the greeting and the connect request of a SOCKS5
+// client without authentication, and returns the host:port to connect to
+func readSOCKS5Connect(c net.Conn) (string, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(c, head); err != nil {
+		return "", err
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(c, methods); err != nil {
+		return "", err
+	}
+	if head[0] != 5 {
+		return "", fmt.Errorf("unsupported SOCKS version %d", head[0])
+	}
+	noAuth := false
+	for _, m := range methods {
+		noAuth = noAuth || m == 0
+	}
+	if !noAuth {
+		c.Write([]byte{5, 0xff})
+		return "", fmt.Errorf("no acceptable authentication method")
+	}
+	c.Write([]byte{5, 0})
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(c, req); err != nil {
+		return "", err
+	}
+	if req[1] != 1 {
+		c.Write(socksReply(socksUnsupported))
+		return "", fmt.Errorf("unsupported SOCKS command %d", req[1])
+	}
+	var host string
+	switch req[3] {
+	case 1, 4:
+		ip := make([]byte, net.IPv4len)
+		if req[3] == 4 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(c, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(c, l); err != nil {
+			return "", err
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(c, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		c.Write(socksReply(socksUnsupported))
+		return "", fmt.Errorf("unknown address type %d", req[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(c, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksReply returns the SOCKS5 reply of the code, with an empty bound
+// address
+func socksReply(code byte) []byte {
+	return []byte{5, code, 0, 1, 0, 0, 0, 0, 0, 0}
+}
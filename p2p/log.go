@@ -0,0 +1,22 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"github.com/FactomProject/FactomCode/factomlog"
+	"github.com/FactomProject/FactomCode/util"
+)
+
+var (
+	logcfg   = util.ReadConfig().Log
+	logPath  = logcfg.LogPath
+	logLevel = logcfg.LogLevel
+	logfile  = factomlog.Open(logPath)
+)
+
+// setup subsystem loggers
+var (
+	p2pLog = factomlog.New(logfile, logLevel, "P2P")
+)
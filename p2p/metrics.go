@@ -0,0 +1,36 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"github.com/FactomProject/FactomCode/metrics"
+)
+
+var (
+	peersGauge = metrics.NewGauge("factomd_peers", "Connected peers, by direction.", "direction")
+	bansGauge  = metrics.NewGauge("factomd_banned_ips", "IPs currently banned.")
+
+	peerMessageCounter = metrics.NewCounter("factomd_peer_messages_total", "Messages sent to and received from peers, by direction and command.", "direction", "command")
+	peerBytesCounter   = metrics.NewCounter("factomd_peer_bytes_total", "Bytes sent to and received from peers, by direction.", "direction")
+)
+
+func init() {
+	metrics.OnScrape(updateMetrics)
+}
+
+// updateMetrics sets the gauges of the peers
+func updateMetrics() {
+	var inbound, outbound int
+	for _, p := range Slots().Peers() {
+		if p.Inbound {
+			inbound++
+		} else {
+			outbound++
+		}
+	}
+	peersGauge.Set(float64(inbound), "inbound")
+	peersGauge.Set(float64(outbound), "outbound")
+	bansGauge.Set(float64(len(Bans())))
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The p2p listen port is mapped on the router with NAT-PMP or UPnP, so a
+// node behind a home router can be reached by its peers. The mapping is
+// leased and renewed at half its lease, and the external address found is
+// advertised to the peers in the version message.
+
+const (
+	// mappingLease is the lease asked for a port mapping
+	mappingLease = 20 * time.Minute
+
+	// mappingWait is how long the first mapping is waited for on startup
+	mappingWait = 10 * time.Second
+)
+
+// NAT is a router mapping ports, with NAT-PMP or UPnP
+type NAT interface {
+	// GetExternalAddress returns the external IP of the router
+	GetExternalAddress() (net.IP, error)
+
+	// AddPortMapping maps the external port to the internal port for the
+	// lease, and returns the external port mapped, which may differ
+	AddPortMapping(protocol string, externalPort, internalPort int, description string, lease time.Duration) (int, error)
+
+	// DeletePortMapping removes the mapping of the external port
+	DeletePortMapping(protocol string, externalPort, internalPort int) error
+}
+
+var (
+	externalMutex sync.Mutex
+	externalAddr  string
+	mapped        = make(chan struct{})
+	mappedOnce    sync.Once
+)
+
+// ExternalAddress returns the external host:port of the node found with
+// the port mapping, or "" if the port is not mapped
+func ExternalAddress() string {
+	externalMutex.Lock()
+	defer externalMutex.Unlock()
+	return externalAddr
+}
+
+func setExternalAddress(addr string) {
+	externalMutex.Lock()
+	defer externalMutex.Unlock()
+	if addr != externalAddr {
+		p2pLog.Info("external address is ", addr)
+	}
+	externalAddr = addr
+	if addr != "" {
+		mappedOnce.Do(func() { close(mapped) })
+	}
+}
+
+// waitExternalAddress waits up to d for the first port mapping
+func waitExternalAddress(d time.Duration) {
+	select {
+	case <-mapped:
+	case <-time.After(d):
+		p2pLog.Warning("no port mapping after ", d)
+	}
+}
+
+// DiscoverNAT returns the router of the node, trying NAT-PMP first and then
+// UPnP
+func DiscoverNAT() (NAT, error) {
+	gateway, err := defaultGateway()
+	if err == nil {
+		nat := NewNATPMP(net.JoinHostPort(gateway.String(), strconv.Itoa(natPMPPort)))
+		if _, err = nat.GetExternalAddress(); err == nil {
+			return nat, nil
+		}
+		p2pLog.Debug("no NAT-PMP at ", gateway, ": ", err)
+	}
+	return DiscoverUPnP()
+}
+
+// mapPort keeps the listen port mapped on the router, finding the router
+// again when a renewal fails
+func mapPort(port int) {
+	var nat NAT
+	for ; ; time.Sleep(mappingLease / 2) {
+		if nat == nil {
+			var err error
+			if nat, err = DiscoverNAT(); err != nil {
+				p2pLog.Warning("no router found for port mapping: ", err)
+				continue
+			}
+		}
+
+		ext, err := nat.AddPortMapping("tcp", port, port, "factomd", mappingLease)
+		if err != nil {
+			p2pLog.Warning("cannot map port ", port, ": ", err)
+			setExternalAddress("")
+			nat = nil
+			continue
+		}
+		ip, err := nat.GetExternalAddress()
+		if err != nil {
+			p2pLog.Warning("cannot get the external address: ", err)
+			continue
+		}
+		setExternalAddress(net.JoinHostPort(ip.String(), strconv.Itoa(ext)))
+	}
+}
@@ -0,0 +1,67 @@
+package p2p_test
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/FactomProject/FactomCode/p2p"
+)
+
+// natPMPServer answers NAT-PMP requests like a router with the external IP
+// 203.0.113.7, mapping every port to the port after it
+func natPMPServer(conn net.PacketConn) {
+	p := make([]byte, 12)
+	for {
+		l, addr, err := conn.ReadFrom(p)
+		if err != nil {
+			return
+		}
+		r := make([]byte, 16)
+		r[1] = p[1] + 128
+		switch {
+		case l == 2 && p[1] == 0:
+			copy(r[8:], []byte{203, 0, 113, 7})
+			conn.WriteTo(r[:12], addr)
+		case l == 12 && p[1] == 2:
+			copy(r[8:10], p[4:6])
+			binary.BigEndian.PutUint16(r[10:], binary.BigEndian.Uint16(p[6:8])+1)
+			copy(r[12:], p[8:12])
+			conn.WriteTo(r, addr)
+		default:
+			binary.BigEndian.PutUint16(r[2:], 5)
+			conn.WriteTo(r[:8], addr)
+		}
+	}
+}
+
+func TestNATPMP(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go natPMPServer(conn)
+
+	nat := NewNATPMP(conn.LocalAddr().String())
+	ip, err := nat.GetExternalAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.IPv4(203, 0, 113, 7)) {
+		t.Errorf("External address is %s", ip)
+	}
+
+	port, err := nat.AddPortMapping("tcp", 8108, 8108, "factomd", 20*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 8109 {
+		t.Errorf("Mapped port is %d, not 8109", port)
+	}
+
+	if _, err := nat.AddPortMapping("udp", 8108, 8108, "factomd", time.Minute); err == nil {
+		t.Errorf("No error for an unsupported opcode")
+	}
+}
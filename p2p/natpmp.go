@@ -0,0 +1,152 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	natPMPPort = 5351
+
+	// natPMPTries is how many times a request is sent, waiting twice as
+	// long each time from natPMPTimeout
+	natPMPTries   = 4
+	natPMPTimeout = 250 * time.Millisecond
+)
+
+var natPMPResults = []string{
+	"success",
+	"unsupported version",
+	"not authorized or refused",
+	"network failure",
+	"out of resources",
+	"unsupported opcode",
+}
+
+// natPMP is a router speaking NAT-PMP (RFC 6886)
+type natPMP struct {
+	gateway string
+}
+
+// NewNATPMP returns the NAT-PMP router at the gateway host:port
+func NewNATPMP(gateway string) NAT {
+	return &natPMP{gateway: gateway}
+}
+
+func (n *natPMP) GetExternalAddress() (net.IP, error) {
+	r, err := n.request([]byte{0, 0}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IPv4(r[8], r[9], r[10], r[11]), nil
+}
+
+func (n *natPMP) AddPortMapping(protocol string, externalPort, internalPort int, description string, lease time.Duration) (int, error) {
+	r, err := n.mapping(protocol, externalPort, internalPort, lease)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(r[10:12])), nil
+}
+
+func (n *natPMP) DeletePortMapping(protocol string, externalPort, internalPort int) error {
+	_, err := n.mapping(protocol, 0, internalPort, 0)
+	return err
+}
+
+func (n *natPMP) mapping(protocol string, externalPort, internalPort int, lease time.Duration) ([]byte, error) {
+	req := make([]byte, 12)
+	switch protocol {
+	case "udp":
+		req[1] = 1
+	case "tcp":
+		req[1] = 2
+	default:
+		return nil, fmt.Errorf("unknown protocol %s", protocol)
+	}
+	binary.BigEndian.PutUint16(req[4:], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:], uint32(lease/time.Second))
+	return n.request(req, 16)
+}
+
+// request sends the request to the gateway until it replies, and returns
+// the reply after checking its opcode and result
+func (n *natPMP) request(req []byte, size int) ([]byte, error) {
+	conn, err := net.Dial("udp", n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	r := make([]byte, 16)
+	timeout := natPMPTimeout
+	for i := 0; i < natPMPTries; i, timeout = i+1, timeout*2 {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		l, err := conn.Read(r)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+		if l < size || r[0] != 0 || r[1] != req[1]+128 {
+			return nil, errors.New("invalid NAT-PMP reply")
+		}
+		if code := binary.BigEndian.Uint16(r[2:4]); code != 0 {
+			if int(code) < len(natPMPResults) {
+				return nil, errors.New(natPMPResults[code])
+			}
+			return nil, fmt.Errorf("NAT-PMP result code %d", code)
+		}
+		return r[:size], nil
+	}
+	return nil, fmt.Errorf("no NAT-PMP reply from %s", n.gateway)
+}
+
+// defaultGateway returns the IPv4 default gateway from the routing table
+// on Linux, or else guesses it is the first address of the network of the
+// node
+func defaultGateway() (net.IP, error) {
+	if f, err := os.Open("/proc/net/route"); err == nil {
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) < 3 || fields[1] != "00000000" {
+				continue
+			}
+			p, err := hex.DecodeString(fields[2])
+			if err != nil || len(p) != 4 {
+				continue
+			}
+			// the table is in host order, little endian
+			return net.IPv4(p[3], p[2], p[1], p[0]), nil
+		}
+	}
+
+	conn, err := net.Dial("udp", "198.51.100.1:9")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	if ip == nil || ip.IsLoopback() {
+		return nil, errors.New("no IPv4 gateway")
+	}
+	return net.IPv4(ip[0], ip[1], ip[2], 1), nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/FactomProject/btcd/wire"
+)
+
+// The origin of a message is noted when it is queued to the processor, and
+// taken back when the processor audits it or scores its rejection. The
+// origins of the API, anchor and timer messages are only kept while
+// tracked, which the audit log turns on. The messages of the peers reach
+// the processor through the btcd server, which does not tell their peer, so
+// the relay notes the address of the peer under the hash of the payload,
+// and the processor finds it back by encoding the message.
+
+// Origins of the messages not relayed by a known peer
+const (
+	OriginAPI    = "api"
+	OriginAnchor = "anchor"
+	OriginTimer  = "timer"
+	// OriginNetwork is a message received from a peer whose address was
+	// not noted
+	OriginNetwork = "network"
+)
+
+// maxOrigins bounds the origins noted but not taken yet
+const maxOrigins = 100000
+
+var (
+	trackOrigins int32
+	originMutex  sync.Mutex
+	origins      = make(map[interface{}]string)
+	peerOrigins  = make(map[[sha256.Size]byte][]string) // peers by payload hash
+)
+
+// encoder is a p2p message
+type encoder interface {
+	BtcEncode(w io.Writer, pver uint32) error
+}
+
+// TrackOrigins turns the recording of the origins on or off
+func TrackOrigins(on bool) {
+	if on {
+		atomic.StoreInt32(&trackOrigins, 1)
+		return
+	}
+	atomic.StoreInt32(&trackOrigins, 0)
+	originMutex.Lock()
+	origins = make(map[interface{}]string)
+	originMutex.Unlock()
+}
+
+// notePeerOrigin records that the peer at addr relayed the message with
+// the payload, whose double sha256 is sum. The peers relaying the same
+// payload are kept in order.
+func notePeerOrigin(sum [sha256.Size]byte, addr string) {
+	originMutex.Lock()
+	defer originMutex.Unlock()
+	if len(peerOrigins) >= maxOrigins {
+		// messages relayed and never processed, forget them all
+		peerOrigins = make(map[[sha256.Size]byte][]string)
+	}
+	peerOrigins[sum] = append(peerOrigins[sum], addr)
+}
+
+// NoteOrigin records where a message queued to the processor comes from:
+// the address of the peer that relayed it, or OriginAPI, OriginAnchor or
+// OriginTimer. The message must be a pointer.
+func NoteOrigin(msg interface{}, origin string) {
+	if atomic.LoadInt32(&trackOrigins) == 0 || !isPointer(msg) {
+		return
+	}
+	originMutex.Lock()
+	defer originMutex.Unlock()
+	if len(origins) >= maxOrigins {
+		// messages noted and never processed, forget them all
+		origins = make(map[interface{}]string)
+	}
+	origins[msg] = origin
+}
+
+// TakeOrigin returns and forgets the origin of the message: the origin
+// noted for it, or else the address of the first peer which relayed its
+// payload, or else OriginNetwork
+func TakeOrigin(msg interface{}) string {
+	if atomic.LoadInt32(&trackOrigins) != 0 && isPointer(msg) {
+		originMutex.Lock()
+		origin, ok := origins[msg]
+		delete(origins, msg)
+		originMutex.Unlock()
+		if ok {
+			return origin
+		}
+	}
+
+	m, ok := msg.(encoder)
+	if !ok {
+		return OriginNetwork
+	}
+	var buf bytes.Buffer
+	if err := m.BtcEncode(&buf, wire.ProtocolVersion); err != nil {
+		return OriginNetwork
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	sum = sha256.Sum256(sum[:])
+
+	originMutex.Lock()
+	defer originMutex.Unlock()
+	peers := peerOrigins[sum]
+	if len(peers) == 0 {
+		return OriginNetwork
+	}
+	if len(peers) == 1 {
+		delete(peerOrigins, sum)
+	} else {
+		peerOrigins[sum] = peers[1:]
+	}
+	return peers[0]
+}
+
+// IsPeerOrigin tells if the origin is the address of a peer
+func IsPeerOrigin(origin string) bool {
+	switch origin {
+	case OriginAPI, OriginAnchor, OriginTimer, OriginNetwork:
+		return false
+	}
+	return origin != ""
+}
+
+// isPointer tells if msg can be a key of origins, by its address
+func isPointer(msg interface{}) bool {
+	return msg != nil && reflect.TypeOf(msg).Kind() == reflect.Ptr
+}
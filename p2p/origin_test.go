@@ -0,0 +1,32 @@
+package p2p_test
+
+import (
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/p2p"
+)
+
+type message struct{ n int }
+
+func TestOrigin(t *testing.T) {
+	m1, m2 := &message{1}, &message{1}
+	NoteOrigin(m1, OriginAPI)
+	if o := TakeOrigin(m1); o != OriginNetwork {
+		t.Errorf("Origin %s noted while not tracked", o)
+	}
+
+	TrackOrigins(true)
+	defer TrackOrigins(false)
+	NoteOrigin(m1, OriginAPI)
+	NoteOrigin(m2, "10.2.0.1:8108")
+	NoteOrigin(message{2}, OriginAPI)
+	if o := TakeOrigin(m2); o != "10.2.0.1:8108" {
+		t.Errorf("Origin of the second message is %s", o)
+	}
+	if o := TakeOrigin(m1); o != OriginAPI {
+		t.Errorf("Origin of the first message is %s", o)
+	}
+	if o := TakeOrigin(m1); o != OriginNetwork {
+		t.Errorf("Origin %s taken twice", o)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package p2p discovers and keeps the addresses of the peers of the node,
+// and relays the connections of the btcd server to them.
+package p2p
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/util"
+)
+
+// saveAddrsEvery is how often the known addresses are saved
+const saveAddrsEvery = 10 * time.Minute
+
+var (
+	addrManager = NewAddrManager("peers.json")
+	connSlots   = NewConnSlots(100, 8, 8, nil)
+
+	syncMutex        sync.Mutex
+	downloader       *Downloader
+	syncRangeSize    = 500
+	syncStallTimeout = 30 * time.Second
+)
+
+// Start sets the network magic, the ban limits, filters and connection slots
+// of the config, loads the known addresses, adds the peers of the DNS seeds to them, maps
+// the listen port on the router and starts the relay of the btcd server,
+// whose flags it sets. It is called before the btcd server starts.
+func Start(cfg *util.FactomdConfig) error {
+	network, err := common.NetworkParamsByName(cfg.App.Network)
+	if err != nil {
+		return err
+	}
+	networkMagic, networkID = network.Magic, network.NetworkID
+	setBanLimits(cfg)
+
+	if err := SetFilters(cfg.P2p.Whitelist, cfg.P2p.Blacklist, cfg.P2p.WhitelistOnly); err != nil {
+		p2pLog.Error("invalid peer whitelist or blacklist: ", err)
+	}
+	util.OnReload(func(cfg *util.FactomdConfig) error {
+		setBanLimits(cfg)
+		return SetFilters(cfg.P2p.Whitelist, cfg.P2p.Blacklist, cfg.P2p.WhitelistOnly)
+	}, "P2p.BanThreshold", "P2p.BanDurationSeconds", "P2p.Whitelist", "P2p.Blacklist", "P2p.WhitelistOnly")
+
+	syncMutex.Lock()
+	if cfg.P2p.SyncRangeSize > 0 {
+		syncRangeSize = cfg.P2p.SyncRangeSize
+	}
+	if cfg.P2p.SyncStallSeconds > 0 {
+		syncStallTimeout = time.Duration(cfg.P2p.SyncStallSeconds) * time.Second
+	}
+	syncMutex.Unlock()
+
+	connSlots = NewConnSlots(cfg.P2p.MaxInbound, cfg.P2p.MaxOutbound, cfg.P2p.ReservedSlots, cfg.P2p.FederatedPeers)
+
+	addrManager = NewAddrManager(cfg.P2p.PeersFile)
+	if err := addrManager.Load(); err != nil {
+		p2pLog.Error("cannot load the peer addresses: ", err)
+	}
+
+	var seeds []string
+	if !cfg.DisableDNSSeed {
+		seeds = cfg.P2p.DNSSeeds
+	}
+	addrs := SeedPeers(seeds, cfg.P2p.DefaultPort, cfg.P2p.StaticPeers, net.LookupHost)
+	p2pLog.Info("peers from the seeds: ", addrs)
+	addrManager.Add(addrs, "seed")
+	if err := addrManager.Save(); err != nil {
+		p2pLog.Error("cannot save the peer addresses: ", err)
+	}
+
+	if cfg.P2p.PortMapping && !cfg.DisableListen {
+		go mapPort(cfg.P2p.DefaultPort)
+		// btcd advertises the external address it starts with
+		waitExternalAddress(mappingWait)
+	}
+	if err := startRelay(cfg); err != nil {
+		return err
+	}
+
+	go func() {
+		for _ = range time.Tick(saveAddrsEvery) {
+			if err := addrManager.Save(); err != nil {
+				p2pLog.Error("cannot save the peer addresses: ", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// startRelay starts the gate the btcd server dials its peers through and,
+// unless listening is disabled, the listener relaying the inbound peers to
+// the btcd server on loopback. The btcd server takes its first peers from
+// the known addresses, and leaves the banning and the DNS seeds to the
+// package.
+func startRelay(cfg *util.FactomdConfig) error {
+	gate, err := startGate()
+	if err != nil {
+		return err
+	}
+	cfg.Proxy = gate
+	cfg.AddPeers = addrManager.Choose(cfg.P2p.MaxOutbound)
+	cfg.MaxPeers = cfg.P2p.MaxInbound + cfg.P2p.MaxOutbound + 2*cfg.P2p.ReservedSlots
+	cfg.DisableBanning = true
+	cfg.DisableDNSSeed = true
+	if ext := ExternalAddress(); ext != "" {
+		cfg.ExternalIPs = []string{ext}
+	}
+	if cfg.DisableListen {
+		return nil
+	}
+
+	btcdAddr, err := loopbackAddr()
+	if err != nil {
+		return err
+	}
+	cfg.Listeners = []string{btcdAddr}
+	return listen(cfg.P2p.DefaultPort, btcdAddr)
+}
+
+// loopbackAddr returns a free loopback address for the btcd server to
+// listen on
+func loopbackAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+// Addresses returns the address manager of the node
+func Addresses() *AddrManager {
+	return addrManager
+}
+
+// Slots returns the connection slots of the node, taken and freed by the
+// relay as peers connect and disconnect
+func Slots() *ConnSlots {
+	return connSlots
+}
+
+// StartSync starts the download of the dir blocks from first to last from
+// several peers, when the processor starts the initial sync
+func StartSync(first, last uint32) *Downloader {
+	syncMutex.Lock()
+	defer syncMutex.Unlock()
+	downloader = NewDownloader(first, last, syncRangeSize, syncStallTimeout)
+	p2pLog.Info("syncing dir blocks ", first, " to ", last)
+	return downloader
+}
+
+// Syncing returns the download of the initial sync, or nil if there is none
+func Syncing() *Downloader {
+	syncMutex.Lock()
+	defer syncMutex.Unlock()
+	return downloader
+}
+
+// Peers returns the addresses of the known peers, the preferred ones first
+func Peers() []string {
+	return addrManager.Choose(0)
+}
+
+// setBanLimits sets the ban threshold and duration of the config
+func setBanLimits(cfg *util.FactomdConfig) {
+	banMutex.Lock()
+	defer banMutex.Unlock()
+	if cfg.P2p.BanThreshold > 0 {
+		banThreshold = float64(cfg.P2p.BanThreshold)
+	}
+	if cfg.P2p.BanDurationSeconds > 0 {
+		banDuration = time.Duration(cfg.P2p.BanDurationSeconds) * time.Second
+	}
+}
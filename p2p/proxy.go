@@ -0,0 +1,62 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/FactomProject/go-socks/socks"
+)
+
+// Outbound connections go through the SOCKS5 proxy of the config, such as
+// Tor, when one is set. Connections to loopback hosts are made directly,
+// since a proxy like Tor cannot reach them.
+
+// dialTimeout bounds connecting, through the proxy or not
+const dialTimeout = 30 * time.Second
+
+// Dial connects to the address, through the proxy of the config if the
+// address is proxied
+func Dial(network, addr string) (net.Conn, error) {
+	c := util.ReadConfig().P2p
+	if !Proxied(addr) {
+		return net.DialTimeout(network, addr, dialTimeout)
+	}
+	return DialSOCKS5(c.Proxy, c.ProxyUser, c.ProxyPass, addr)
+}
+
+// Proxied tells if a connection to the address goes through the proxy
+func Proxied(addr string) bool {
+	if util.ReadConfig().P2p.Proxy == "" {
+		return false
+	}
+	host := hostIP(addr)
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// HTTPClient returns an http client connecting with Dial
+func HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: &http.Transport{Dial: Dial}}
+}
+
+// DialSOCKS5 connects to the address through the SOCKS5 proxy, with the
+// user and password if the user is set. Host names are resolved by the
+// proxy, so .onion addresses can be reached through Tor.
+func DialSOCKS5(proxy, user, pass, addr string) (net.Conn, error) {
+	p := &socks.Proxy{Addr: proxy, Username: user, Password: pass}
+	conn, err := p.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 proxy %s: %s", proxy, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,77 @@
+package p2p_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/p2p"
+)
+
+// socks5Server accepts one connection with user and password auth and
+// echoes after the CONNECT, sending the requested address to got
+func socks5Server(t *testing.T, l net.Listener, got chan<- []byte) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	greeting := make([]byte, 4)
+	io.ReadFull(conn, greeting)
+	conn.Write([]byte{5, 2})
+
+	head := make([]byte, 2)
+	io.ReadFull(conn, head)
+	user := make([]byte, head[1]+1)
+	io.ReadFull(conn, user)
+	pass := make([]byte, user[len(user)-1])
+	io.ReadFull(conn, pass)
+	if string(user[:len(user)-1]) != "u" || string(pass) != "p" {
+		conn.Write([]byte{1, 1})
+		return
+	}
+	conn.Write([]byte{1, 0})
+
+	req := make([]byte, 5)
+	io.ReadFull(conn, req)
+	addr := make([]byte, int(req[4])+2)
+	io.ReadFull(conn, addr)
+	got <- append(req, addr...)
+	conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	io.Copy(conn, conn)
+}
+
+func TestDialSOCKS5(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	got := make(chan []byte, 1)
+	go socks5Server(t, l, got)
+
+	conn, err := DialSOCKS5(l.Addr().String(), "u", "p", "example.onion:8108")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	want := append([]byte{5, 1, 0, 3, 13}, "example.onion"...)
+	want = append(want, 0x1f, 0xac)
+	if req := <-got; !bytes.Equal(req, want) {
+		t.Errorf("CONNECT request is %x, not %x", req, want)
+	}
+
+	conn.Write([]byte("ping"))
+	p := make([]byte, 4)
+	if _, err := io.ReadFull(conn, p); err != nil || string(p) != "ping" {
+		t.Errorf("Read %q through the proxy: %v", p, err)
+	}
+
+	go socks5Server(t, l, got)
+	if _, err := DialSOCKS5(l.Addr().String(), "u", "wrong", "example.onion:8108"); err == nil {
+		t.Errorf("No error with a wrong password")
+	}
+}
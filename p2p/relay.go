@@ -0,0 +1,304 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// The btcd server talks to the peers only through this package. It listens
+// on loopback, behind the public listener of the node, and dials its peers
+// through the SOCKS5 gate of gate.go. Every connection is relayed message
+// by message, which lets the package take the connection slots, filter and
+// ban the peers, count their traffic and learn their addresses. The btcd
+// server talks the magic of its main network, which the relay swaps for the
+// magic of the network of the node, refusing the peers of other networks,
+// and drops the dir blocks contradicting the checkpoints of the network.
+
+const (
+	// headerSize is the size of the header of a p2p message: magic,
+	// command, payload length and checksum
+	headerSize = 24
+
+	// rotateBackoff is how long the gate refuses the peers dropped by
+	// RotatePeers, so the btcd server connects to others
+	rotateBackoff = 10 * time.Minute
+)
+
+// usefulCommands are the messages counted as useful relays of a peer
+var usefulCommands = map[string]bool{
+	wire.CmdDirBlock:    true,
+	wire.CmdFactoidTX:   true,
+	wire.CmdCommitChain: true,
+	wire.CmdCommitEntry: true,
+	wire.CmdRevealEntry: true,
+}
+
+var (
+	errOversized    = errors.New("oversized payload")
+	errBanned       = errors.New("banned")
+	errWrongNetwork = errors.New("peer of another network")
+
+	// networkMagic is the magic of the network of the node, and btcdMagic
+	// the one of the btcd server
+	networkMagic = uint32(wire.MainNet)
+	btcdMagic    = uint32(wire.MainNet)
+	networkID    = common.MainNetParams.NetworkID
+
+	connMutex sync.Mutex
+	conns     = make(map[string]net.Conn)  // peer connections by address
+	rotated   = make(map[string]time.Time) // peers dropped by RotatePeers
+)
+
+// listen accepts the peers on the port and relays them to the btcd server
+// listening at btcdAddr
+func listen(port int, btcdAddr string) error {
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			peer, err := l.Accept()
+			if err != nil {
+				p2pLog.Error("p2p listener stopped: ", err)
+				return
+			}
+			go accept(peer, btcdAddr)
+		}
+	}()
+	return nil
+}
+
+// accept relays an inbound peer to the btcd server if it gets a slot,
+// disconnecting the peer evicted for it
+func accept(peer net.Conn, btcdAddr string) {
+	addr := peer.RemoteAddr().String()
+	evict, ok := Slots().Accept(addr)
+	if !ok {
+		peer.Close()
+		return
+	}
+	if evict != "" {
+		disconnect(evict)
+	}
+	local, err := net.DialTimeout("tcp", btcdAddr, dialTimeout)
+	if err != nil {
+		p2pLog.Error("cannot reach the btcd server: ", err)
+		Slots().Disconnected(addr)
+		peer.Close()
+		return
+	}
+	relay(addr, peer, local)
+}
+
+// relay copies the messages between the peer at addr and the btcd server
+// until either side closes, and then frees the slot of the peer
+func relay(addr string, peer, local net.Conn) {
+	connMutex.Lock()
+	conns[addr] = peer
+	connMutex.Unlock()
+	p2pLog.Debug("relaying ", addr)
+
+	done := make(chan error, 1)
+	go func() { done <- copyMessages(peer, local, addr, false) }()
+	err := copyMessages(local, peer, addr, true)
+	peer.Close()
+	local.Close()
+	<-done
+	p2pLog.Debug("disconnected ", addr, ": ", err)
+
+	connMutex.Lock()
+	if conns[addr] == peer {
+		delete(conns, addr)
+	}
+	connMutex.Unlock()
+	Slots().Disconnected(addr)
+}
+
+// copyMessages copies the messages read from src to dst, counting them as
+// received from the peer at addr if fromPeer is set, or else as sent to it.
+// The messages of the peer are checked, and the peer scored for the ones
+// that are not valid p2p messages or carry a dir block contradicting the
+// checkpoints. The peer of the blocks and transactions is noted for the
+// processor. A peer whose messages do not start with the magic of the
+// network is disconnected.
+func copyMessages(dst, src net.Conn, addr string, fromPeer bool) error {
+	header := make([]byte, headerSize)
+	for {
+		if _, err := io.ReadFull(src, header); err != nil {
+			return err
+		}
+		if fromPeer {
+			if binary.LittleEndian.Uint32(header[0:4]) != networkMagic {
+				return errWrongNetwork
+			}
+			binary.LittleEndian.PutUint32(header[0:4], btcdMagic)
+		} else {
+			binary.LittleEndian.PutUint32(header[0:4], networkMagic)
+		}
+		command := string(bytes.TrimRight(header[4:16], "\x00"))
+		size := binary.LittleEndian.Uint32(header[16:20])
+		if fromPeer && size > wire.MaxMessagePayload {
+			misbehaving(addr, OversizedPayload)
+			return errOversized
+		}
+		msg := make([]byte, headerSize+int(size))
+		copy(msg, header)
+		if _, err := io.ReadFull(src, msg[headerSize:]); err != nil {
+			return err
+		}
+
+		if !fromPeer {
+			Slots().Sent(addr, command, len(msg))
+		} else {
+			Slots().Received(addr, command, len(msg))
+			sum := sha256.Sum256(msg[headerSize:])
+			sum = sha256.Sum256(sum[:])
+			if !bytes.Equal(sum[:4], header[20:24]) {
+				if misbehaving(addr, MalformedMessage) {
+					return errBanned
+				}
+				continue
+			}
+			if command == wire.CmdDirBlock && contradictsCheckpoint(msg[headerSize:]) {
+				if misbehaving(addr, ContradictsCheckpoint) {
+					return errBanned
+				}
+				continue
+			}
+			if usefulCommands[command] {
+				Slots().Useful(addr)
+				notePeerOrigin(sum, addr)
+			}
+			if command == wire.CmdAddr {
+				learnAddresses(msg[headerSize:])
+			}
+		}
+
+		if _, err := dst.Write(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// misbehaving scores the misbehavior of the peer at addr, disconnects the
+// IP if it is banned for it, and returns true if it is
+func misbehaving(addr string, m Misbehavior) bool {
+	if !Misbehaving(addr, m) {
+		return false
+	}
+	disconnectIP(hostIP(addr))
+	return true
+}
+
+// ReportMisbehavior scores the misbehavior of the peer at addr found by the
+// processor, and disconnects the IP if it is banned for it
+func ReportMisbehavior(addr string, m Misbehavior) {
+	misbehaving(addr, m)
+}
+
+// contradictsCheckpoint tells if the payload is a dir block whose hash is
+// not the checkpoint of the network at its height
+func contradictsCheckpoint(payload []byte) bool {
+	msg := new(wire.MsgDirBlock)
+	if err := msg.BtcDecode(bytes.NewReader(payload), wire.ProtocolVersion); err != nil || msg.DBlk == nil {
+		return false
+	}
+	expected, ok := common.CheckpointHash(networkID, msg.DBlk.Header.DBHeight)
+	if !ok {
+		return false
+	}
+	h, err := common.CreateHash(msg.DBlk)
+	return err == nil && h.String() != expected
+}
+
+// learnAddresses adds the peer addresses of an addr message to the known
+// addresses
+func learnAddresses(payload []byte) {
+	msg := new(wire.MsgAddr)
+	if err := msg.BtcDecode(bytes.NewReader(payload), wire.ProtocolVersion); err != nil {
+		return
+	}
+	addrs := make([]string, 0, len(msg.AddrList))
+	for _, na := range msg.AddrList {
+		addrs = append(addrs, net.JoinHostPort(na.IP.String(), strconv.Itoa(int(na.Port))))
+	}
+	addrManager.Add(addrs, "peer")
+}
+
+// disconnect closes the connection of the peer at addr, if any
+func disconnect(addr string) {
+	connMutex.Lock()
+	defer connMutex.Unlock()
+	if c := conns[addr]; c != nil {
+		c.Close()
+	}
+}
+
+// disconnectIP closes the connections of the peers at the IP
+func disconnectIP(ip string) {
+	connMutex.Lock()
+	defer connMutex.Unlock()
+	for addr, c := range conns {
+		if hostIP(addr) == ip {
+			c.Close()
+		}
+	}
+}
+
+// PeerCount returns the number of relayed peers
+func PeerCount() int {
+	connMutex.Lock()
+	defer connMutex.Unlock()
+	return len(conns)
+}
+
+// PeerAddresses returns the addresses of the relayed peers
+func PeerAddresses() []string {
+	connMutex.Lock()
+	defer connMutex.Unlock()
+	addrs := make([]string, 0, len(conns))
+	for addr := range conns {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// RotatePeers disconnects the relayed peers, and has the gate refuse them
+// for rotateBackoff so the btcd server connects to other peers
+func RotatePeers() {
+	connMutex.Lock()
+	defer connMutex.Unlock()
+	until := time.Now().Add(rotateBackoff)
+	for addr, c := range conns {
+		rotated[addr] = until
+		c.Close()
+	}
+}
+
+// isRotated tells if the peer at addr was dropped by RotatePeers less than
+// rotateBackoff ago
+func isRotated(addr string) bool {
+	connMutex.Lock()
+	defer connMutex.Unlock()
+	until, ok := rotated[addr]
+	if ok && time.Now().After(until) {
+		delete(rotated, addr)
+		return false
+	}
+	return ok
+}
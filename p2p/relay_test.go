@@ -0,0 +1,253 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// gateDial connects to addr through the gate like the btcd server
+func gateDial(gate, addr string) (net.Conn, error) {
+	c, err := net.Dial("tcp", gate)
+	if err != nil {
+		return nil, err
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	req := []byte{5, 1, 0, 5, 1, 0, 1}
+	req = append(req, net.ParseIP(host).To4()...)
+	var p uint16
+	for _, d := range port {
+		p = p*10 + uint16(d-'0')
+	}
+	req = append(req, byte(p>>8), byte(p))
+	c.Write(req)
+
+	reply := make([]byte, 12)
+	if _, err := io.ReadFull(c, reply); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if reply[3] != 0 {
+		c.Close()
+		return nil, io.ErrUnexpectedEOF
+	}
+	return c, nil
+}
+
+// message returns a p2p message of the network magic, command and payload,
+// with a valid checksum if valid is set
+func message(magic uint32, command string, payload []byte, valid bool) []byte {
+	msg := make([]byte, headerSize, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(msg[0:4], magic)
+	copy(msg[4:16], command)
+	binary.LittleEndian.PutUint32(msg[16:20], uint32(len(payload)))
+	sum := sha256.Sum256(payload)
+	sum = sha256.Sum256(sum[:])
+	if valid {
+		copy(msg[20:24], sum[:4])
+	}
+	return append(msg, payload...)
+}
+
+// relayTest is a peer connected through the gate, on a network whose magic
+// differs from the one of btcd
+type relayTest struct {
+	dir         string
+	gate        string
+	l           net.Listener
+	local, peer net.Conn
+}
+
+func newRelayTest(t *testing.T) *relayTest {
+	r := new(relayTest)
+	var err error
+	if r.dir, err = ioutil.TempDir("", "relay"); err != nil {
+		t.Fatal(err)
+	}
+	addrManager = NewAddrManager(filepath.Join(r.dir, "peers.json"))
+	connSlots = NewConnSlots(1, 1, 0, nil)
+	networkMagic = 0xFEFEFEFE
+
+	if r.l, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	if r.gate, err = startGate(); err != nil {
+		t.Fatal(err)
+	}
+	if r.local, err = gateDial(r.gate, r.l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if r.peer, err = r.l.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func (r *relayTest) close() {
+	r.local.Close()
+	r.peer.Close()
+	r.l.Close()
+	os.RemoveAll(r.dir)
+	networkMagic = btcdMagic
+}
+
+// waitSlotsFree waits for the relay to free the slots of the closed peers
+func waitSlotsFree(t *testing.T) {
+	for i := 0; len(Slots().Peers()) > 0; i++ {
+		if i == 100 {
+			t.Fatalf("Slots of the closed peers not freed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGateRelay(t *testing.T) {
+	r := newRelayTest(t)
+	defer r.close()
+	gate, l, local, peer := r.gate, r.l, r.local, r.peer
+
+	// the magic of btcd is swapped for the one of the network
+	local.Write(message(btcdMagic, "ping", []byte("12345678"), true))
+	ping := message(networkMagic, "ping", []byte("12345678"), true)
+	got := make([]byte, len(ping))
+	if _, err := io.ReadFull(peer, got); err != nil || !bytes.Equal(got, ping) {
+		t.Fatalf("Peer got %x: %v", got, err)
+	}
+
+	// a message with a wrong checksum is dropped
+	peer.Write(message(networkMagic, "pong", []byte("bad"), false))
+	peer.Write(message(networkMagic, "pong", []byte("87654321"), true))
+	pong := message(btcdMagic, "pong", []byte("87654321"), true)
+	if _, err := io.ReadFull(local, got); err != nil || !bytes.Equal(got, pong) {
+		t.Fatalf("btcd got %x: %v", got, err)
+	}
+	if s := BanScore("127.0.0.1"); s < 19 {
+		t.Errorf("Ban score is %d after a malformed message", s)
+	}
+
+	peers := Slots().Peers()
+	if len(peers) != 1 || peers[0].Inbound || peers[0].MsgsSent["ping"] != 1 || peers[0].MsgsRecv["pong"] != 2 {
+		t.Errorf("Peers are %+v", peers)
+	}
+	if k := addrManager.Addresses(); len(k) != 1 || k[0].LastSuccess.IsZero() {
+		t.Errorf("Known addresses are %+v", k)
+	}
+
+	if _, err := gateDial(gate, l.Addr().String()); err == nil {
+		t.Errorf("Connected with the outbound slots full")
+	}
+
+	Ban("127.0.0.1", time.Minute, "test")
+	defer Unban("127.0.0.1")
+	if _, err := io.ReadFull(local, got); err == nil {
+		t.Errorf("Banned peer still relayed")
+	}
+	waitSlotsFree(t)
+}
+
+func TestRelayWrongNetwork(t *testing.T) {
+	r := newRelayTest(t)
+	defer r.close()
+	local, peer := r.local, r.peer
+
+	peer.Write(message(btcdMagic, "version", []byte("12345678"), true))
+	got := make([]byte, headerSize)
+	if _, err := io.ReadFull(local, got); err == nil {
+		t.Errorf("Peer of another network relayed %x", got)
+	}
+	waitSlotsFree(t)
+}
+
+func TestRelayContradictingCheckpoint(t *testing.T) {
+	r := newRelayTest(t)
+	defer r.close()
+	local, peer := r.local, r.peer
+
+	hash := "1111111111111111111111111111111111111111111111111111111111111111"
+	if err := common.SetCheckpoints(networkID, []string{"1:" + hash}); err != nil {
+		t.Fatal(err)
+	}
+	defer common.SetCheckpoints(networkID, nil)
+
+	b := common.NewDBlock()
+	b.Header.DBHeight = 1
+	var buf bytes.Buffer
+	if err := (&wire.MsgDirBlock{DBlk: b}).BtcEncode(&buf, wire.ProtocolVersion); err != nil {
+		t.Fatal(err)
+	}
+	defer Unban("127.0.0.1")
+	peer.Write(message(networkMagic, wire.CmdDirBlock, buf.Bytes(), true))
+	got := make([]byte, headerSize)
+	if _, err := io.ReadFull(local, got); err == nil {
+		t.Errorf("Dir block contradicting a checkpoint relayed %x", got)
+	}
+	if !IsBanned("127.0.0.1") {
+		t.Errorf("Peer relaying a dir block contradicting a checkpoint not banned")
+	}
+	waitSlotsFree(t)
+}
+
+func TestRelayPeerOrigin(t *testing.T) {
+	r := newRelayTest(t)
+	defer r.close()
+	local, peer := r.local, r.peer
+
+	b := common.NewDBlock()
+	b.Header.DBHeight = 2
+	var buf bytes.Buffer
+	if err := (&wire.MsgDirBlock{DBlk: b}).BtcEncode(&buf, wire.ProtocolVersion); err != nil {
+		t.Fatal(err)
+	}
+	peer.Write(message(networkMagic, wire.CmdDirBlock, buf.Bytes(), true))
+	got := make([]byte, headerSize+buf.Len())
+	if _, err := io.ReadFull(local, got); err != nil {
+		t.Fatal(err)
+	}
+
+	// the processor gets the message decoded by btcd
+	addr := r.l.Addr().String()
+	if o := TakeOrigin(&wire.MsgDirBlock{DBlk: b}); o != addr {
+		t.Fatalf("Origin of the dir block is %s, expected %s", o, addr)
+	}
+	if o := TakeOrigin(&wire.MsgDirBlock{DBlk: b}); o != OriginNetwork {
+		t.Errorf("Origin %s taken twice", o)
+	}
+
+	score := BanScore(addr)
+	ReportMisbehavior(addr, StaleHeight)
+	if s := BanScore(addr); s < score+9 {
+		t.Errorf("Ban score is %d after a stale height, was %d", s, score)
+	}
+}
+
+func TestRotatePeers(t *testing.T) {
+	r := newRelayTest(t)
+	defer r.close()
+	defer func() { rotated = make(map[string]time.Time) }()
+
+	if n := PeerCount(); n != 1 {
+		t.Errorf("Peer count is %d", n)
+	}
+	RotatePeers()
+	got := make([]byte, headerSize)
+	if _, err := io.ReadFull(r.local, got); err == nil {
+		t.Errorf("Rotated peer still relayed")
+	}
+	waitSlotsFree(t)
+	if n := PeerCount(); n != 0 {
+		t.Errorf("Peer count is %d after the rotation", n)
+	}
+	if _, err := gateDial(r.gate, r.l.Addr().String()); err == nil {
+		t.Errorf("Connected to the rotated peer again")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// seedTimeout is how long a DNS seed is waited for
+const seedTimeout = 10 * time.Second
+
+// Resolver returns the addresses of a host name, like net.LookupHost
+type Resolver func(host string) ([]string, error)
+
+// SeedPeers resolves the DNS seeds, a host name with an optional port, into
+// peer addresses with the port of the seed or the default port. The seeds
+// are queried concurrently, and the static peers are returned when none of
+// them resolves.
+func SeedPeers(seeds []string, port int, static []string, lookup Resolver) []string {
+	results := make([][]string, len(seeds))
+	var wg sync.WaitGroup
+	for i, seed := range seeds {
+		if seed == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, seed string) {
+			defer wg.Done()
+			results[i] = resolveSeed(seed, port, lookup)
+		}(i, seed)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	addrs := make([]string, 0)
+	for _, r := range results {
+		for _, a := range r {
+			if !seen[a] {
+				seen[a] = true
+				addrs = append(addrs, a)
+			}
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs
+	}
+
+	if len(seeds) > 0 {
+		p2pLog.Warning("no DNS seed resolved, using the static peers")
+	}
+	for _, a := range static {
+		if a != "" && !seen[a] {
+			seen[a] = true
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// resolveSeed returns the peer addresses of a seed, or nil if it fails or
+// times out
+func resolveSeed(seed string, port int, lookup Resolver) []string {
+	host, p := seed, strconv.Itoa(port)
+	if h, sp, err := net.SplitHostPort(seed); err == nil {
+		host, p = h, sp
+	}
+
+	done := make(chan []string, 1)
+	go func() {
+		ips, err := lookup(host)
+		if err != nil {
+			p2pLog.Warning("DNS seed ", seed, " failed: ", err)
+			done <- nil
+			return
+		}
+		addrs := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip, p))
+		}
+		done <- addrs
+	}()
+
+	select {
+	case addrs := <-done:
+		return addrs
+	case <-time.After(seedTimeout):
+		p2pLog.Warning("DNS seed ", seed, " timed out")
+		return nil
+	}
+}
@@ -0,0 +1,33 @@
+package p2p_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/p2p"
+)
+
+func TestSeedPeers(t *testing.T) {
+	lookup := func(host string) ([]string, error) {
+		switch host {
+		case "seed1.example.com":
+			return []string{"10.0.0.1", "10.0.0.2"}, nil
+		case "seed2.example.com":
+			return []string{"10.0.0.2", "::1"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	static := []string{"10.1.1.1:8108"}
+
+	addrs := SeedPeers([]string{"seed1.example.com", "seed2.example.com:9000", "bad.example.com"}, 8108, static, lookup)
+	want := []string{"10.0.0.1:8108", "10.0.0.2:8108", "10.0.0.2:9000", "[::1]:9000"}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Seed peers are %v, not %v", addrs, want)
+	}
+
+	addrs = SeedPeers([]string{"bad.example.com"}, 8108, static, lookup)
+	if !reflect.DeepEqual(addrs, static) {
+		t.Errorf("Peers are %v when the seeds fail, not the static peers", addrs)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// evictProtection is how long a new inbound peer cannot be evicted, so it
+// has time to be useful
+const evictProtection = 5 * time.Minute
+
+// ConnectedPeer is a peer holding a connection slot, with its traffic
+type ConnectedPeer struct {
+	Addr       string
+	Inbound    bool
+	Federated  bool
+	Connected  time.Time
+	LastUseful time.Time
+	Useful     int // new blocks and transactions relayed
+
+	LastSend  time.Time
+	LastRecv  time.Time
+	BytesSent uint64
+	BytesRecv uint64
+	MsgsSent  map[string]uint64 // messages by command
+	MsgsRecv  map[string]uint64
+}
+
+// ConnSlots limits the inbound and outbound connections. Federated and
+// audit servers, known by their IP, get reserved slots over the limits, and
+// an ordinary inbound peer is evicted when they find the inbound slots full.
+type ConnSlots struct {
+	mutex       sync.Mutex
+	maxInbound  int
+	maxOutbound int
+	reserved    int
+	federated   map[string]bool
+	peers       map[string]*ConnectedPeer
+}
+
+// NewConnSlots returns the connection slots for the limits, with reserved
+// slots for the federated peers, given as IP or host:port
+func NewConnSlots(maxInbound, maxOutbound, reserved int, federated []string) *ConnSlots {
+	s := &ConnSlots{
+		maxInbound:  maxInbound,
+		maxOutbound: maxOutbound,
+		reserved:    reserved,
+		federated:   make(map[string]bool),
+		peers:       make(map[string]*ConnectedPeer),
+	}
+	for _, f := range federated {
+		if f != "" {
+			s.federated[hostIP(f)] = true
+		}
+	}
+	return s
+}
+
+// IsFederated tells if the peer at addr is a federated or audit server
+func (s *ConnSlots) IsFederated(addr string) bool {
+	return s.federated[hostIP(addr)]
+}
+
+// Accept takes a slot for an inbound peer. It returns false if the peer is
+// to be refused, or else the address of a peer to disconnect to free its
+// slot, if any.
+func (s *ConnSlots) Accept(addr string) (string, bool) {
+	if IsBanned(addr) || !Allowed(addr) {
+		return "", false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.peers[addr] != nil {
+		return "", false
+	}
+
+	federated := s.IsFederated(addr)
+	ordinary, fed := s.count(true)
+	evict := ""
+	switch {
+	case !federated && ordinary < s.maxInbound:
+	case federated && (fed < s.reserved || ordinary+fed < s.maxInbound+s.reserved):
+	default:
+		if evict = s.leastUseful(); evict == "" {
+			p2pLog.Debug("inbound slots full, refused ", addr)
+			return "", false
+		}
+		p2pLog.Info("inbound slots full, evicting ", evict, " for ", addr)
+		delete(s.peers, evict)
+	}
+	s.add(addr, true, federated)
+	return evict, true
+}
+
+// Connect takes a slot for an outbound connection to addr, and returns
+// false if none is free
+func (s *ConnSlots) Connect(addr string) bool {
+	if IsBanned(addr) || !Allowed(addr) {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.peers[addr] != nil {
+		return false
+	}
+
+	federated := s.IsFederated(addr)
+	ordinary, fed := s.count(false)
+	if ordinary >= s.maxOutbound && !(federated && fed < s.reserved) {
+		return false
+	}
+	s.add(addr, false, federated)
+	return true
+}
+
+// Disconnected frees the slot of the peer at addr
+func (s *ConnSlots) Disconnected(addr string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.peers, addr)
+}
+
+// Useful records that the peer at addr relayed a new block or transaction
+func (s *ConnSlots) Useful(addr string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if p := s.peers[addr]; p != nil {
+		p.Useful++
+		p.LastUseful = time.Now()
+	}
+}
+
+// Sent records a message of the command and size sent to the peer at addr
+func (s *ConnSlots) Sent(addr, command string, size int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if p := s.peers[addr]; p != nil {
+		p.LastSend = time.Now()
+		p.BytesSent += uint64(size)
+		p.MsgsSent[command]++
+	}
+	peerMessageCounter.Inc("sent", command)
+	peerBytesCounter.Add(float64(size), "sent")
+}
+
+// Received records a message of the command and size received from the
+// peer at addr
+func (s *ConnSlots) Received(addr, command string, size int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if p := s.peers[addr]; p != nil {
+		p.LastRecv = time.Now()
+		p.BytesRecv += uint64(size)
+		p.MsgsRecv[command]++
+	}
+	peerMessageCounter.Inc("received", command)
+	peerBytesCounter.Add(float64(size), "received")
+}
+
+// Peers returns the peers holding a slot, ordered by address
+func (s *ConnSlots) Peers() []ConnectedPeer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list := make([]ConnectedPeer, 0, len(s.peers))
+	for _, p := range s.peers {
+		c := *p
+		c.MsgsSent = copyCounts(p.MsgsSent)
+		c.MsgsRecv = copyCounts(p.MsgsRecv)
+		list = append(list, c)
+	}
+	sort.Sort(byAddr(list))
+	return list
+}
+
+func (s *ConnSlots) add(addr string, inbound, federated bool) {
+	s.peers[addr] = &ConnectedPeer{
+		Addr:      addr,
+		Inbound:   inbound,
+		Federated: federated,
+		Connected: time.Now(),
+		MsgsSent:  make(map[string]uint64),
+		MsgsRecv:  make(map[string]uint64),
+	}
+}
+
+// count returns the ordinary and federated peers, inbound or outbound
+func (s *ConnSlots) count(inbound bool) (int, int) {
+	var ordinary, federated int
+	for _, p := range s.peers {
+		if p.Inbound != inbound {
+			continue
+		}
+		if p.Federated {
+			federated++
+		} else {
+			ordinary++
+		}
+	}
+	return ordinary, federated
+}
+
+// leastUseful returns the ordinary inbound peer which relayed the fewest
+// new blocks and transactions, and the longest ago, among the ones past the
+// eviction protection, or "" if there is none
+func (s *ConnSlots) leastUseful() string {
+	now := time.Now()
+	var least *ConnectedPeer
+	for _, p := range s.peers {
+		if !p.Inbound || p.Federated || now.Sub(p.Connected) < evictProtection {
+			continue
+		}
+		if least == nil || p.Useful < least.Useful ||
+			p.Useful == least.Useful && p.LastUseful.Before(least.LastUseful) {
+			least = p
+		}
+	}
+	if least == nil {
+		return ""
+	}
+	return least.Addr
+}
+
+func copyCounts(m map[string]uint64) map[string]uint64 {
+	c := make(map[string]uint64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+type byAddr []ConnectedPeer
+
+func (b byAddr) Len() int           { return len(b) }
+func (b byAddr) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byAddr) Less(i, j int) bool { return b[i].Addr < b[j].Addr }
@@ -0,0 +1,60 @@
+package p2p_test
+
+import (
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/p2p"
+)
+
+func TestConnSlots(t *testing.T) {
+	s := NewConnSlots(2, 1, 1, []string{"10.0.0.9"})
+
+	for _, addr := range []string{"10.0.0.1:50001", "10.0.0.2:50002"} {
+		if _, ok := s.Accept(addr); !ok {
+			t.Errorf("Refused %s with a free inbound slot", addr)
+		}
+	}
+	if _, ok := s.Accept("10.0.0.3:50003"); ok {
+		t.Errorf("Accepted a peer with the inbound slots full of new peers")
+	}
+	if _, ok := s.Accept("10.0.0.9:50009"); !ok {
+		t.Errorf("Refused a federated peer with a reserved slot free")
+	}
+	if _, ok := s.Accept("10.0.0.9:50010"); ok {
+		t.Errorf("Accepted a federated peer over the reserved slots with no peer to evict")
+	}
+
+	if !s.Connect("10.0.0.4:8108") {
+		t.Errorf("No outbound slot for the first peer")
+	}
+	if s.Connect("10.0.0.5:8108") {
+		t.Errorf("Outbound slot over the limit")
+	}
+	if !s.Connect("10.0.0.9:8108") {
+		t.Errorf("No reserved outbound slot for a federated peer")
+	}
+
+	s.Disconnected("10.0.0.1:50001")
+	if _, ok := s.Accept("10.0.0.3:50003"); !ok {
+		t.Errorf("Refused a peer after a slot was freed")
+	}
+	if n := len(s.Peers()); n != 5 {
+		t.Errorf("%d peers hold a slot, not 5", n)
+	}
+
+	s.Sent("10.0.0.4:8108", "inv", 100)
+	s.Sent("10.0.0.4:8108", "inv", 50)
+	s.Received("10.0.0.4:8108", "dirblock", 1000)
+	s.Sent("10.0.0.8:8108", "inv", 10) // not connected
+	for _, p := range s.Peers() {
+		if p.Addr != "10.0.0.4:8108" {
+			continue
+		}
+		if p.BytesSent != 150 || p.MsgsSent["inv"] != 2 || p.BytesRecv != 1000 || p.MsgsRecv["dirblock"] != 1 {
+			t.Errorf("Traffic of %s is %+v", p.Addr, p)
+		}
+		if p.LastSend.IsZero() || p.LastRecv.IsZero() {
+			t.Errorf("No last send or receive time")
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ssdpAddr    = "239.255.255.250:1900"
+	ssdpTimeout = 3 * time.Second
+	soapTimeout = 10 * time.Second
+
+	// onlyPermanentLeases is the UPnP error of a router not supporting a
+	// lease other than 0
+	onlyPermanentLeases = "725"
+)
+
+var upnpServices = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// upnp is an internet gateway device controlled with UPnP
+type upnp struct {
+	controlURL  string
+	serviceType string
+	localIP     string
+}
+
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// DiscoverUPnP finds the internet gateway device of the network with SSDP
+func DiscoverUPnP() (NAT, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), dst); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(ssdpTimeout))
+	p := make([]byte, 2048)
+	for {
+		l, _, err := conn.ReadFrom(p)
+		if err != nil {
+			return nil, errors.New("no UPnP gateway device found")
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(p[:l])), nil)
+		if err != nil {
+			continue
+		}
+		location := resp.Header.Get("Location")
+		if location == "" {
+			continue
+		}
+		if nat, err := newUPnP(location); err == nil {
+			return nat, nil
+		} else {
+			p2pLog.Debug("UPnP device at ", location, ": ", err)
+		}
+	}
+}
+
+// newUPnP reads the description of the device at location and returns its
+// WAN connection service
+func newUPnP(location string) (*upnp, error) {
+	client := &http.Client{Timeout: soapTimeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	root := new(struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	})
+	if err := xml.NewDecoder(resp.Body).Decode(root); err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if root.URLBase != "" {
+		if base, err = url.Parse(root.URLBase); err != nil {
+			return nil, err
+		}
+	}
+
+	serviceType, control := findWANService(&root.Device)
+	if control == "" {
+		return nil, errors.New("no WAN connection service")
+	}
+	u, err := url.Parse(control)
+	if err != nil {
+		return nil, err
+	}
+
+	// the local IP is the one used to reach the device
+	host := base.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "80")
+	}
+	conn, err := net.Dial("udp4", host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP.String()
+
+	return &upnp{
+		controlURL:  base.ResolveReference(u).String(),
+		serviceType: serviceType,
+		localIP:     localIP,
+	}, nil
+}
+
+func findWANService(d *upnpDevice) (string, string) {
+	for _, s := range d.Services {
+		for _, t := range upnpServices {
+			if s.ServiceType == t {
+				return s.ServiceType, s.ControlURL
+			}
+		}
+	}
+	for i := range d.Devices {
+		if t, c := findWANService(&d.Devices[i]); c != "" {
+			return t, c
+		}
+	}
+	return "", ""
+}
+
+func (n *upnp) GetExternalAddress() (net.IP, error) {
+	r, err := n.soap("GetExternalIPAddress", "")
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(soapValue(r, "NewExternalIPAddress"))
+	if ip == nil {
+		return nil, errors.New("invalid external IP address")
+	}
+	return ip, nil
+}
+
+func (n *upnp) AddPortMapping(protocol string, externalPort, internalPort int, description string, lease time.Duration) (int, error) {
+	args := "<NewRemoteHost></NewRemoteHost>" +
+		"<NewExternalPort>" + strconv.Itoa(externalPort) + "</NewExternalPort>" +
+		"<NewProtocol>" + strings.ToUpper(protocol) + "</NewProtocol>" +
+		"<NewInternalPort>" + strconv.Itoa(internalPort) + "</NewInternalPort>" +
+		"<NewInternalClient>" + n.localIP + "</NewInternalClient>" +
+		"<NewEnabled>1</NewEnabled>" +
+		"<NewPortMappingDescription>" + description + "</NewPortMappingDescription>"
+	lifetime := "<NewLeaseDuration>" + strconv.Itoa(int(lease/time.Second)) + "</NewLeaseDuration>"
+	r, err := n.soap("AddPortMapping", args+lifetime)
+	if err != nil && soapValue(r, "errorCode") == onlyPermanentLeases {
+		_, err = n.soap("AddPortMapping", args+"<NewLeaseDuration>0</NewLeaseDuration>")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return externalPort, nil
+}
+
+func (n *upnp) DeletePortMapping(protocol string, externalPort, internalPort int) error {
+	_, err := n.soap("DeletePortMapping", "<NewRemoteHost></NewRemoteHost>"+
+		"<NewExternalPort>"+strconv.Itoa(externalPort)+"</NewExternalPort>"+
+		"<NewProtocol>"+strings.ToUpper(protocol)+"</NewProtocol>")
+	return err
+}
+
+// soap calls the action of the WAN connection service with the arguments,
+// and returns the body of the reply, also when it is a fault
+func (n *upnp) soap(action, args string) ([]byte, error) {
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:` + action + ` xmlns:u="` + n.serviceType + `">` + args + `</u:` + action + `></s:Body>` +
+		`</s:Envelope>`
+	req, err := http.NewRequest("POST", n.controlURL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+n.serviceType+"#"+action+`"`)
+
+	client := &http.Client{Timeout: soapTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	r, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return r, fmt.Errorf("UPnP %s failed: %s %s", action, resp.Status, soapValue(r, "errorDescription"))
+	}
+	return r, nil
+}
+
+// soapValue returns the text of the first element with the name in the
+// reply
+func soapValue(r []byte, name string) string {
+	d := xml.NewDecoder(bytes.NewReader(r))
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return ""
+		}
+		if s, ok := t.(xml.StartElement); ok && s.Name.Local == name {
+			var v string
+			if err := d.DecodeElement(&v, &s); err != nil {
+				return ""
+			}
+			return strings.TrimSpace(v)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"github.com/FactomProject/FactomCode/anchor"
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/database"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// anchorer places the dir blocks built by the server into bitcoin
+type anchorer interface {
+	init(ldb database.Db, q chan wire.FtmInternalMsg, serverKey common.PrivateKey)
+	updateDirBlockInfo(info *common.DirBlockInfo)
+	place(keyMR *common.Hash, height uint32)
+}
+
+// blockAnchorer anchors the dir blocks of the server. A simulated server
+// does not anchor them.
+var blockAnchorer anchorer = btcAnchorer{}
+
+// btcAnchorer anchors the dir blocks with the anchor package
+type btcAnchorer struct{}
+
+func (btcAnchorer) init(ldb database.Db, q chan wire.FtmInternalMsg, serverKey common.PrivateKey) {
+	anchor.InitAnchor(ldb, q, serverKey)
+}
+
+func (btcAnchorer) updateDirBlockInfo(info *common.DirBlockInfo) {
+	anchor.UpdateDirBlockInfoMap(info)
+}
+
+func (btcAnchorer) place(keyMR *common.Hash, height uint32) {
+	go anchor.PlaceAnchor(keyMR, height)
+}
+
+// noAnchorer does not anchor the dir blocks
+type noAnchorer struct{}
+
+func (noAnchorer) init(ldb database.Db, q chan wire.FtmInternalMsg, serverKey common.PrivateKey) {}
+
+func (noAnchorer) updateDirBlockInfo(info *common.DirBlockInfo) {}
+
+func (noAnchorer) place(keyMR *common.Hash, height uint32) {}
@@ -0,0 +1,90 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/hex"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/factomlog"
+	"github.com/FactomProject/FactomCode/p2p"
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// auditLog records every message served by the processor, except the
+// duplicates, when the audit is enabled
+var auditLog *factomlog.AuditLog
+
+// initAudit opens the audit log of the config
+func initAudit(cfg *util.FactomdConfig) {
+	if !cfg.Audit.Enabled {
+		return
+	}
+	l, err := factomlog.OpenAuditLog(cfg.Audit.Path, int64(cfg.Audit.MaxSizeMB)<<20)
+	if err != nil {
+		procLog.Error("Audit log disabled: ", err)
+		return
+	}
+	auditLog = l
+	p2p.TrackOrigins(true)
+	procLog.Info("Auditing the messages in ", cfg.Audit.Path)
+}
+
+// auditMessage records the message, its origin and the outcome of its
+// validation
+func auditMessage(msg wire.FtmInternalMsg, origin string, err error) {
+	if auditLog == nil {
+		return
+	}
+	r := &factomlog.AuditRecord{
+		Command: msg.Command(),
+		MsgHash: auditHash(msg),
+		Origin:  origin,
+		Outcome: factomlog.AuditAccepted,
+	}
+	if err != nil {
+		r.Outcome, r.Error = factomlog.AuditRejected, err.Error()
+	}
+	if err := auditLog.Append(r); err != nil {
+		procLog.Error("Cannot write the audit log: ", err)
+	}
+}
+
+// auditHash returns the entry hash of a commit or reveal, the id of a
+// factoid transaction or the hash of an ack, empty for other messages
+func auditHash(msg wire.FtmInternalMsg) string {
+	switch m := msg.(type) {
+	case *wire.MsgCommitEntry:
+		return m.CommitEntry.EntryHash.String()
+	case *wire.MsgCommitChain:
+		return m.CommitChain.EntryHash.String()
+	case *wire.MsgRevealEntry:
+		return m.Entry.Hash().String()
+	case *wire.MsgFactoidTX:
+		return hex.EncodeToString(m.Transaction.GetHash().Bytes())
+	case *wire.MsgAcknowledgement:
+		if p, err := m.GetBinaryForSignature(); err == nil {
+			return common.Sha(p).String()
+		}
+	}
+	return ""
+}
+
+// AuditRecords returns the recent audit records matching the filter, nil if
+// the audit is disabled
+func AuditRecords(f *factomlog.AuditFilter) []*factomlog.AuditRecord {
+	if auditLog == nil {
+		return nil
+	}
+	return auditLog.Recent(f)
+}
+
+// closeAudit closes the audit log when the node stops
+func closeAudit() {
+	if auditLog != nil {
+		auditLog.Close()
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"github.com/FactomProject/FactomCode/common"
+	fct "github.com/FactomProject/factoid"
+	"github.com/FactomProject/factoid/block"
+)
+
+var (
+	// fctBalances mirrors the factoid balances of every address seen in a
+	// factoid block so they can be saved in a balance snapshot
+	fctBalances = make(map[string]uint64)
+
+	// balanceSnapshot is the snapshot loaded from db at startup. Blocks at or
+	// below its height are not replayed.
+	balanceSnapshot *common.BalanceState
+)
+
+// loadBalanceState fetches the latest balance snapshot that is below the
+// highest block in db, so the last block is always replayed to restore the
+// current factoid block.
+func loadBalanceState() {
+	balanceSnapshot = nil
+	if dchain.NextDBHeight < 2 {
+		return
+	}
+
+	state, err := db.FetchBalanceState(dchain.NextDBHeight - 2)
+	if err != nil {
+		procLog.Error("Failed to load balance state: ", err)
+		return
+	}
+	if state == nil {
+		return
+	}
+
+	balanceSnapshot = state
+	procLog.Info("Loaded balance state at block height ", state.DBHeight)
+}
+
+// isInBalanceSnapshot returns true if the balances of the block at height are
+// already included in the loaded snapshot
+func isInBalanceSnapshot(height uint32) bool {
+	return balanceSnapshot != nil && height <= balanceSnapshot.DBHeight
+}
+
+// restoreFctBalances sets the factoid balances from the loaded snapshot
+func restoreFctBalances() {
+	if balanceSnapshot == nil {
+		return
+	}
+	for k, v := range balanceSnapshot.FCTBalances {
+		fctBalances[k] = v
+		common.FactoidState.UpdateBalance(fct.NewAddress([]byte(k)), int64(v))
+	}
+}
+
+// updateFctBalances refreshes the mirrored balances of the addresses used
+// in the factoid block
+func updateFctBalances(b block.IFBlock) {
+	for _, t := range b.GetTransactions() {
+		for _, in := range t.GetInputs() {
+			updateFctBalance(in.GetAddress())
+		}
+		for _, out := range t.GetOutputs() {
+			updateFctBalance(out.GetAddress())
+		}
+	}
+}
+
+func updateFctBalance(adr fct.IAddress) {
+	fctBalances[string(adr.Bytes())] = common.FactoidState.GetBalance(adr)
+}
+
+// currentBalanceState returns the current balances as the balance state of
+// the dir block at height
+func currentBalanceState(height uint32) *common.BalanceState {
+	state := common.NewBalanceState()
+	state.DBHeight = height
+	for k, v := range eCreditMap {
+		state.ECBalances[k] = v
+	}
+	for k, v := range fctBalances {
+		state.FCTBalances[k] = v
+	}
+	return state
+}
+
+// saveBalanceState stores the current balances as the snapshot for the dir
+// block at height
+func saveBalanceState(height uint32) {
+	state := currentBalanceState(height)
+	rememberBalanceState(state)
+
+	if err := db.InsertBalanceState(state); err != nil {
+		procLog.Error("Failed to save balance state: ", err)
+	}
+}
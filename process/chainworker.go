@@ -0,0 +1,75 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"hash/fnv"
+	"runtime"
+	"sync"
+
+	"github.com/FactomProject/FactomCode/common"
+)
+
+var (
+	// entryWorkers is the number of goroutines building entry blocks
+	entryWorkers int
+
+	workers *chainWorkers
+
+	// checkers validate the incoming reveals, the reveals of a chain in
+	// the order they arrived
+	checkers *chainWorkers
+)
+
+// chainWorkers runs the work of the entry chains in parallel.
+// All the jobs of a chain go to the same worker, so they run in the order
+// they were dispatched.
+type chainWorkers struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+func newChainWorkers(n int) *chainWorkers {
+	if n < 1 {
+		n = runtime.NumCPU()
+	}
+
+	w := new(chainWorkers)
+	w.queues = make([]chan func(), n)
+	for i := range w.queues {
+		w.queues[i] = make(chan func(), 1000)
+		go w.run(w.queues[i])
+	}
+	return w
+}
+
+func (w *chainWorkers) run(queue chan func()) {
+	for job := range queue {
+		job()
+		w.wg.Done()
+	}
+}
+
+// dispatch queues a job on the worker of the chain
+func (w *chainWorkers) dispatch(chainID *common.Hash, job func()) {
+	h := fnv.New32a()
+	h.Write(chainID.Bytes())
+
+	w.wg.Add(1)
+	w.queues[h.Sum32()%uint32(len(w.queues))] <- job
+}
+
+// wait blocks until all the dispatched jobs are done
+func (w *chainWorkers) wait() {
+	w.wg.Wait()
+}
+
+// stop waits for the dispatched jobs and stops the workers
+func (w *chainWorkers) stop() {
+	w.wait()
+	for _, queue := range w.queues {
+		close(queue)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/btcd/wire"
+)
+
+func TestChainWorkersOrder(t *testing.T) {
+	w := newChainWorkers(4)
+
+	chains := make([]*common.Hash, 16)
+	done := make([][]int, len(chains))
+	for i := range chains {
+		chains[i] = common.Sha([]byte(fmt.Sprint("chain", i)))
+	}
+	for n := 0; n < 200; n++ {
+		for i, chainID := range chains {
+			i, n := i, n
+			w.dispatch(chainID, func() { done[i] = append(done[i], n) })
+		}
+	}
+	w.wait()
+
+	for i := range chains {
+		if len(done[i]) != 200 {
+			t.Fatalf("chain %d ran %d jobs, expected 200", i, len(done[i]))
+		}
+		for n, v := range done[i] {
+			if v != n {
+				t.Fatalf("chain %d ran job %d at position %d", i, v, n)
+			}
+		}
+	}
+}
+
+func testRevealChains() {
+	zeroHash = common.NewHash()
+	dchain = &common.DChain{ChainID: common.Sha([]byte("dchain"))}
+	achain = &common.AdminChain{ChainID: common.Sha([]byte("achain"))}
+	ecchain = &common.ECChain{ChainID: common.Sha([]byte("ecchain"))}
+	fchain = &common.FctChain{ChainID: common.Sha([]byte("fchain"))}
+}
+
+func TestCheckReveal(t *testing.T) {
+	testRevealChains()
+
+	first := common.NewEntry()
+	first.ExtIDs = append(first.ExtIDs, []byte("checkreveal"))
+	first.ChainID = common.NewChainID(first)
+	first.Content = []byte("first entry")
+	r := checkReveal(&wire.MsgRevealEntry{Entry: first})
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if !r.chainIDOK {
+		t.Error("the chain id of the first entry is not recognized")
+	}
+	if !r.hash.IsSameAs(first.Hash()) || r.cred == 0 {
+		t.Error("wrong hash or cost of the reveal")
+	}
+
+	next := common.NewEntry()
+	next.ChainID = first.ChainID
+	next.Content = []byte("next entry")
+	if r := checkReveal(&wire.MsgRevealEntry{Entry: next}); r.err != nil || r.chainIDOK {
+		t.Error("wrong check of an entry of an existing chain: ", r.err)
+	}
+
+	reserved := common.NewEntry()
+	reserved.ChainID = dchain.ChainID
+	if r := checkReveal(&wire.MsgRevealEntry{Entry: reserved}); r.err == nil {
+		t.Error("reveal on the directory block chain accepted")
+	}
+
+	bad := common.NewEntry()
+	bad.Version = 1
+	if r := checkReveal(&wire.MsgRevealEntry{Entry: bad}); r.err == nil {
+		t.Error("reveal of an invalid entry accepted")
+	}
+}
+
+// the reveals checked by the workers come back to the processor in the
+// order of their chain
+func TestCheckedRevealsOrder(t *testing.T) {
+	testRevealChains()
+	checkers = newChainWorkers(4)
+
+	var chainIDs []*common.Hash
+	for i := 0; i < 8; i++ {
+		e := common.NewEntry()
+		e.ExtIDs = append(e.ExtIDs, []byte(fmt.Sprint("chain", i)))
+		chainIDs = append(chainIDs, common.NewChainID(e))
+	}
+	for n := 0; n < 50; n++ {
+		for _, chainID := range chainIDs {
+			e := common.NewEntry()
+			e.ChainID = chainID
+			e.Content = []byte(fmt.Sprint(n))
+			msg := &wire.MsgRevealEntry{Entry: e}
+			checkers.dispatch(chainID, func() { queueCheckedReveal(checkReveal(msg)) })
+		}
+	}
+	checkers.wait()
+
+	checkedRevealsMutex.Lock()
+	list := checkedReveals
+	checkedReveals = nil
+	checkedRevealsMutex.Unlock()
+
+	if len(list) != 50*len(chainIDs) {
+		t.Fatalf("%d reveals checked, expected %d", len(list), 50*len(chainIDs))
+	}
+	next := make(map[string]int)
+	for _, r := range list {
+		key := r.msg.Entry.ChainID.String()
+		if string(r.msg.Entry.Content) != fmt.Sprint(next[key]) {
+			t.Fatalf("reveal %s of chain %s out of order", r.msg.Entry.Content, key)
+		}
+		next[key]++
+	}
+}
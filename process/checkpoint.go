@@ -0,0 +1,95 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/common"
+	cp "github.com/FactomProject/FactomCode/controlpanel"
+	"github.com/FactomProject/FactomCode/database"
+)
+
+// netParams are the parameters of the network this node is on
+var netParams = &common.MainNetParams
+
+// networkID returns the id of the network this node is on
+func networkID() uint32 {
+	return netParams.NetworkID
+}
+
+// NetworkName returns the name of the network this node is on
+func NetworkName() string {
+	return netParams.Name
+}
+
+// isGenesisHash returns true if the hash is the genesis dir block of the
+// network, or if the network takes any genesis block
+func isGenesisHash(h string) bool {
+	return netParams.GenesisHash == "" || h == netParams.GenesisHash
+}
+
+// belowLastCheckpoint returns true if the block at height is covered by the
+// checkpoints, so its contents do not need to be re-validated
+func belowLastCheckpoint(height uint32) bool {
+	last := common.LastCheckpoint(networkID())
+	return last != nil && height <= last.DBHeight
+}
+
+// checkCheckpoint returns an error if the dir block contradicts the
+// checkpoint at its height
+func checkCheckpoint(b *common.DirectoryBlock) error {
+	expected, ok := common.CheckpointHash(networkID(), b.Header.DBHeight)
+	if !ok {
+		return nil
+	}
+	h, err := common.CreateHash(b)
+	if err != nil {
+		return err
+	}
+	if h.String() != expected {
+		return fmt.Errorf("Dir block at height %d is %s, checkpoint is %s",
+			b.Header.DBHeight, h.String(), expected)
+	}
+	return nil
+}
+
+// checkLinkage returns an error if the dir block does not link to the
+// previous dir block in db
+func checkLinkage(b *common.DirectoryBlock, db database.Db) error {
+	if b.Header.DBHeight == 0 {
+		return nil
+	}
+	prev, err := db.FetchDBlockByHeight(b.Header.DBHeight - 1)
+	if err != nil || prev == nil {
+		return fmt.Errorf("Previous dir block of height %d not found", b.Header.DBHeight)
+	}
+	h, err := common.CreateHash(prev)
+	if err != nil {
+		return err
+	}
+	if !h.IsSameAs(b.Header.PrevLedgerKeyMR) {
+		return fmt.Errorf("Dir block at height %d does not link to the previous dir block %s",
+			b.Header.DBHeight, h.String())
+	}
+	return nil
+}
+
+// reportCheckpointFailure logs and alerts a dir block refused by the
+// checkpoints or the linkage check, once per block height
+func reportCheckpointFailure(err error, height uint32) {
+	if lastInvalidDBHeight == int64(height) {
+		return
+	}
+	lastInvalidDBHeight = int64(height)
+
+	procLog.Error(err)
+	cp.CP.AddUpdate(
+		"Checkpoint",                  // tag
+		"warning",                     // Category
+		"Dir block contradicts chain", // Title
+		err.Error(),                   // Message
+		0)                             // Expire
+}
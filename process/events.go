@@ -0,0 +1,257 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sync"
+
+	"github.com/FactomProject/FactomCode/common"
+)
+
+// Types of the events pushed to the subscribers
+const (
+	EventDirBlock  = "dblock"
+	EventEntry     = "entry"
+	EventFactoidTx = "factoid-tx"
+	EventECBalance = "ec-balance"
+	EventMinute    = "minute"
+	EventStall     = "stall"
+)
+
+// eventQueueSize is the number of events buffered for a subscriber. Events
+// are dropped for a subscriber that does not keep up.
+const eventQueueSize = 1000
+
+// Event is pushed to the subscribers when a block is stored, a minute of
+// the open dir block ends or the node stalls
+type Event struct {
+	Type      string
+	DBHeight  uint32
+	KeyMR     string `json:",omitempty"`
+	ChainID   string `json:",omitempty"`
+	EntryHash string `json:",omitempty"`
+	TxID      string `json:",omitempty"`
+	Address   string `json:",omitempty"`
+	Balance   int64  `json:",omitempty"`
+	Minute    uint8  `json:",omitempty"` // the minute that ended, 1 to 10
+	Timestamp int64  `json:",omitempty"` // unix time the minute ended or the stall was detected
+	Cause     string `json:",omitempty"` // diagnosis of a stall
+}
+
+// Subscription receives the events matching its filters on C
+type Subscription struct {
+	C chan *Event
+
+	sync.Mutex
+	filters map[string]map[string]bool // event type -> keys, empty for any
+	Dropped int
+}
+
+var (
+	subscriptions     = make(map[*Subscription]bool)
+	subscriptionMutex sync.RWMutex
+)
+
+// Subscribe registers a new subscription without filters
+func Subscribe() *Subscription {
+	s := &Subscription{
+		C:       make(chan *Event, eventQueueSize),
+		filters: make(map[string]map[string]bool),
+	}
+
+	subscriptionMutex.Lock()
+	subscriptions[s] = true
+	subscriptionMutex.Unlock()
+	return s
+}
+
+// Unsubscribe removes the subscription. No more events are sent on C.
+func (s *Subscription) Unsubscribe() {
+	subscriptionMutex.Lock()
+	delete(subscriptions, s)
+	subscriptionMutex.Unlock()
+}
+
+// AddFilter subscribes to the events of the type for the key, which is a
+// chain id for entries, an address for factoid transactions and a public
+// key for entry credit balances, all in hex. An empty key matches any.
+func (s *Subscription) AddFilter(eventType, key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.filters[eventType] == nil {
+		s.filters[eventType] = make(map[string]bool)
+	}
+	s.filters[eventType][key] = true
+}
+
+// RemoveFilter reverses AddFilter
+func (s *Subscription) RemoveFilter(eventType, key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if keys, ok := s.filters[eventType]; ok {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(s.filters, eventType)
+		}
+	}
+}
+
+func (s *Subscription) matches(e *Event) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	keys, ok := s.filters[e.Type]
+	if !ok {
+		return false
+	}
+	if keys[""] {
+		return true
+	}
+	switch e.Type {
+	case EventEntry:
+		return keys[e.ChainID]
+	case EventFactoidTx, EventECBalance:
+		return keys[e.Address]
+	}
+	return true
+}
+
+func publishEvent(e *Event) {
+	subscriptionMutex.RLock()
+	defer subscriptionMutex.RUnlock()
+
+	for s := range subscriptions {
+		if !s.matches(e) {
+			continue
+		}
+		select {
+		case s.C <- e:
+		default:
+			s.Lock()
+			s.Dropped++
+			s.Unlock()
+		}
+	}
+}
+
+func hasSubscribers() bool {
+	subscriptionMutex.RLock()
+	defer subscriptionMutex.RUnlock()
+
+	return len(subscriptions) > 0
+}
+
+// publishBlockEvents pushes the events of a stored dir block and the blocks
+// it references
+func publishBlockEvents(b *common.DirectoryBlock) {
+	if !hasSubscribers() {
+		return
+	}
+
+	height := b.Header.DBHeight
+	keyMR := ""
+	if b.KeyMR != nil {
+		keyMR = b.KeyMR.String()
+	}
+	publishEvent(&Event{Type: EventDirBlock, DBHeight: height, KeyMR: keyMR})
+
+	for _, dbEntry := range b.DBEntries {
+		switch dbEntry.ChainID.String() {
+		case ecchain.ChainID.String():
+			publishECBalanceEvents(height)
+		case achain.ChainID.String():
+		case fchain.ChainID.String():
+			publishFactoidTxEvents(height)
+		default:
+			eb, err := db.FetchEBlockByMR(dbEntry.KeyMR)
+			if err != nil || eb == nil {
+				continue
+			}
+			for _, ebEntry := range eb.Body.EBEntries {
+				// skip the minute markers
+				if bytes.Equal(ebEntry.Bytes()[:31], common.ZERO_HASH[:31]) {
+					continue
+				}
+				publishEvent(&Event{
+					Type:      EventEntry,
+					DBHeight:  height,
+					KeyMR:     dbEntry.KeyMR.String(),
+					ChainID:   eb.Header.ChainID.String(),
+					EntryHash: ebEntry.String(),
+				})
+			}
+		}
+	}
+}
+
+func publishECBalanceEvents(height uint32) {
+	ecBlock, err := db.FetchECBlockByHeight(height)
+	if err != nil || ecBlock == nil {
+		return
+	}
+
+	changed := make(map[string]bool)
+	var keys []string
+	for _, entry := range ecBlock.Body.Entries {
+		var pub []byte
+		switch e := entry.(type) {
+		case *common.CommitChain:
+			pub = e.ECPubKey[:]
+		case *common.CommitEntry:
+			pub = e.ECPubKey[:]
+		case *common.IncreaseBalance:
+			pub = e.ECPubKey[:]
+		default:
+			continue
+		}
+		if !changed[string(pub)] {
+			changed[string(pub)] = true
+			keys = append(keys, string(pub))
+		}
+	}
+
+	for _, k := range keys {
+		publishEvent(&Event{
+			Type:     EventECBalance,
+			DBHeight: height,
+			Address:  hex.EncodeToString([]byte(k)),
+			Balance:  int64(eCreditMap[k]),
+		})
+	}
+}
+
+func publishFactoidTxEvents(height uint32) {
+	fBlock, err := db.FetchFBlockByHeight(height)
+	if err != nil || fBlock == nil {
+		return
+	}
+
+	for _, t := range fBlock.GetTransactions() {
+		txid := hex.EncodeToString(t.GetHash().Bytes())
+		seen := make(map[string]bool)
+		var addresses []string
+		for _, in := range t.GetInputs() {
+			addresses = append(addresses, hex.EncodeToString(in.GetAddress().Bytes()))
+		}
+		for _, out := range t.GetOutputs() {
+			addresses = append(addresses, hex.EncodeToString(out.GetAddress().Bytes()))
+		}
+		for _, ecout := range t.GetECOutputs() {
+			addresses = append(addresses, hex.EncodeToString(ecout.GetAddress().Bytes()))
+		}
+
+		for _, a := range addresses {
+			if seen[a] {
+				continue
+			}
+			seen[a] = true
+			publishEvent(&Event{Type: EventFactoidTx, DBHeight: height, TxID: txid, Address: a})
+		}
+	}
+}
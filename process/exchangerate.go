@@ -0,0 +1,192 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/util"
+)
+
+var (
+	// exchangeRateChainID is the well-known chain where signed exchange rate
+	// changes are recorded
+	exchangeRateChainID *common.Hash
+
+	// exchangeRateAuthority holds the hex encoded public keys that are
+	// allowed to sign exchange rate changes
+	exchangeRateAuthority = make(map[string]bool)
+
+	// pendingExchangeRates are the accepted rate changes that have not been
+	// activated yet, sorted by activation height
+	pendingExchangeRates []*common.ExchangeRateChange
+	exchangeRateMutex    sync.RWMutex
+)
+
+// loadExchangeRateConfig reads the exchange rate chain and the authorized
+// public keys from the config
+func loadExchangeRateConfig(cfg *util.FactomdConfig) {
+	exchangeRateChainID = common.NewHash()
+	if h, err := common.HexToHash(cfg.App.ExchangeRateChainID); err != nil {
+		procLog.Error("Invalid ExchangeRateChainID: ", err)
+	} else {
+		exchangeRateChainID = h
+	}
+
+	exchangeRateAuthority = make(map[string]bool)
+	for _, k := range cfg.App.ExchangeRateAuthority {
+		if p, err := hex.DecodeString(k); err != nil || len(p) != 32 {
+			procLog.Error("Invalid ExchangeRateAuthority: ", k)
+			continue
+		}
+		exchangeRateAuthority[k] = true
+	}
+}
+
+// isExchangeRateChain returns true if the chain is the exchange rate chain
+func isExchangeRateChain(chainID *common.Hash) bool {
+	return exchangeRateChainID != nil && !exchangeRateChainID.IsSameAs(zeroHash) &&
+		chainID.IsSameAs(exchangeRateChainID)
+}
+
+// validateExchangeRateEntry parses the entry content as a rate change and
+// checks that it is signed by an authorized key and takes effect after the
+// dir block at height
+func validateExchangeRateEntry(e *common.Entry, height uint32) (*common.ExchangeRateChange, error) {
+	c, err := parseExchangeRateEntry(e)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExchangeRateActivation(c, height); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseExchangeRateEntry parses the entry content as a rate change and
+// checks that it is signed by an authorized key
+func parseExchangeRateEntry(e *common.Entry) (*common.ExchangeRateChange, error) {
+	c := common.NewExchangeRateChange()
+	if err := c.UnmarshalBinary(e.Content); err != nil {
+		return nil, fmt.Errorf("Invalid exchange rate change: %s", err)
+	}
+
+	if !c.IsValid() {
+		return nil, fmt.Errorf("Exchange rate change has an invalid signature")
+	}
+
+	if !exchangeRateAuthority[hex.EncodeToString(c.PubKey[:])] {
+		return nil, fmt.Errorf("Exchange rate change is not signed by an authorized key: %x", c.PubKey[:])
+	}
+
+	return c, nil
+}
+
+// checkExchangeRateActivation checks that the rate change takes effect after
+// the dir block at height
+func checkExchangeRateActivation(c *common.ExchangeRateChange, height uint32) error {
+	if c.ActivationHeight <= height {
+		return fmt.Errorf("Exchange rate change activation height %d is not after %d", c.ActivationHeight, height)
+	}
+	return nil
+}
+
+// scheduleExchangeRateEntry adds the rate change carried by an entry of the
+// exchange rate chain, recorded in the dir block at height, to the pending
+// schedule
+func scheduleExchangeRateEntry(e *common.Entry, height uint32) {
+	if !isExchangeRateChain(e.ChainID) {
+		return
+	}
+
+	c, err := validateExchangeRateEntry(e, height)
+	if err != nil {
+		procLog.Debug("Ignoring exchange rate entry ", e.Hash().String(), ": ", err)
+		return
+	}
+
+	exchangeRateMutex.Lock()
+	defer exchangeRateMutex.Unlock()
+
+	for _, p := range pendingExchangeRates {
+		if p.ActivationHeight == c.ActivationHeight && bytes.Equal(p.Sig[:], c.Sig[:]) {
+			return
+		}
+	}
+
+	pendingExchangeRates = append(pendingExchangeRates, c)
+	sort.Stable(byActivationHeight(pendingExchangeRates))
+
+	procLog.Infof("Exchange rate change to %d scheduled at block height %d", c.Rate, c.ActivationHeight)
+}
+
+// activateExchangeRate returns the exchange rate in effect at the block height,
+// removing the pending changes that have been activated
+func activateExchangeRate(height uint32, rate uint64) uint64 {
+	exchangeRateMutex.Lock()
+	defer exchangeRateMutex.Unlock()
+
+	for len(pendingExchangeRates) > 0 && pendingExchangeRates[0].ActivationHeight <= height {
+		rate = pendingExchangeRates[0].Rate
+		pendingExchangeRates = pendingExchangeRates[1:]
+	}
+	return rate
+}
+
+// initExchangeRates rebuilds the pending rate changes from the exchange rate
+// chain in the database, each change checked against the height of its
+// entry block, and applies those already activated at the open block
+func initExchangeRates() {
+	if !isExchangeRateChain(exchangeRateChainID) {
+		return
+	}
+
+	eBlocks, err := db.FetchAllEBlocksByChain(exchangeRateChainID)
+	if err != nil || eBlocks == nil {
+		return
+	}
+	sort.Sort(util.ByEBlockIDAccending(*eBlocks))
+
+	for _, eb := range *eBlocks {
+		for _, h := range eb.Body.EBEntries {
+			if h.IsMinuteMarker() {
+				continue
+			}
+			if e, _ := db.FetchEntryByHash(h); e != nil {
+				scheduleExchangeRateEntry(e, eb.Header.EBHeight)
+			}
+		}
+	}
+
+	FactoshisPerCredit = activateExchangeRate(dchain.NextDBHeight, FactoshisPerCredit)
+	common.FactoidState.SetFactoshisPerEC(FactoshisPerCredit)
+}
+
+// GetExchangeRates returns the current exchange rate and the pending changes
+func GetExchangeRates() (uint64, []*common.ExchangeRateChange) {
+	exchangeRateMutex.RLock()
+	defer exchangeRateMutex.RUnlock()
+
+	pending := make([]*common.ExchangeRateChange, len(pendingExchangeRates))
+	copy(pending, pendingExchangeRates)
+	return FactoshisPerCredit, pending
+}
+
+type byActivationHeight []*common.ExchangeRateChange
+
+func (f byActivationHeight) Len() int {
+	return len(f)
+}
+func (f byActivationHeight) Less(i, j int) bool {
+	return f[i].ActivationHeight < f[j].ActivationHeight
+}
+func (f byActivationHeight) Swap(i, j int) {
+	f[i], f[j] = f[j], f[i]
+}
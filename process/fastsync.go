@@ -0,0 +1,289 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/factoid/block"
+)
+
+// With FastSync, a follower far behind the network stores the downloaded
+// blocks after checking only that they link to the previous dir block and
+// hash to the KeyMRs of their dir block, so it serves the read API hours
+// earlier. A background pass then checks the signatures and runs the full
+// validation on the stored blocks, from the last height it validated.
+
+const (
+	// fastSyncLag is how old a dir block must be for its validation to be
+	// deferred
+	fastSyncLag = time.Hour
+
+	// fastSyncSaveEvery is the blocks validated between saves of the
+	// progress
+	fastSyncSaveEvery = 1000
+
+	// fastSyncPoll is how long the background pass waits for new blocks
+	fastSyncPoll = 5 * time.Second
+)
+
+var (
+	// fastSync defers the validation of old blocks during the sync
+	fastSync bool
+
+	fastSyncMutex sync.Mutex
+	fastSyncState = FastSyncStatus{Deferring: true, DeferredHeight: -1, ValidatedHeight: -1}
+)
+
+// FastSyncStatus is the progress of the deferred validation
+type FastSyncStatus struct {
+	Enabled         bool
+	Deferring       bool   // old blocks are stored without validation
+	DeferredHeight  int64  // last dir block stored without validation, -1 if none
+	ValidatedHeight int64  // last dir block validated, -1 if none
+	Done            bool   // all the deferred blocks are validated
+	Error           string // validation failure stopping the pass
+}
+
+// GetFastSyncStatus returns the progress of the deferred validation
+func GetFastSyncStatus() FastSyncStatus {
+	fastSyncMutex.Lock()
+	defer fastSyncMutex.Unlock()
+	s := fastSyncState
+	s.Enabled = fastSync
+	return s
+}
+
+// deferValidation tells if the validation of the dir block is left to the
+// background pass. Deferring stops at the first block less than
+// fastSyncLag old.
+func deferValidation(b *common.DirectoryBlock) bool {
+	if !fastSync {
+		return false
+	}
+	fastSyncMutex.Lock()
+	defer fastSyncMutex.Unlock()
+	if !fastSyncState.Deferring {
+		return false
+	}
+	if time.Since(time.Unix(int64(b.Header.Timestamp)*60, 0)) < fastSyncLag {
+		fastSyncState.Deferring = false
+		procLog.Info("Fast sync reached recent blocks at height ", b.Header.DBHeight, ", validating them as they arrive")
+		return false
+	}
+	return true
+}
+
+func setDeferredHeight(height uint32) {
+	fastSyncMutex.Lock()
+	defer fastSyncMutex.Unlock()
+	if int64(height) > fastSyncState.DeferredHeight {
+		fastSyncState.DeferredHeight = int64(height)
+	}
+}
+
+// initFastSync loads the last height validated, and saves it before any
+// block is stored so the blocks stored without validation are never taken
+// for validated ones after a restart
+func initFastSync() error {
+	validated, err := loadFastSyncProgress(fastSyncPath())
+	if err != nil {
+		return err
+	}
+	setValidatedHeight(validated)
+	saveFastSyncProgress(fastSyncPath(), validated)
+	return nil
+}
+
+func fastSyncPath() string {
+	return filepath.Join(homeDir, "fastsync.json")
+}
+
+// runDeferredValidation validates the stored dir blocks after the last one
+// validated, until it passes the last one stored without validation
+func runDeferredValidation() {
+	path := fastSyncPath()
+	validated := GetFastSyncStatus().ValidatedHeight
+
+	// the balances before the next block are replayed from the start, the
+	// factoid state being ahead of the validated blocks
+	balances := make(map[string]int32)
+	factoids := make(map[string]int64)
+	for h := int64(0); h <= validated; h++ {
+		ecBlock, err := db.FetchECBlockByHeight(uint32(h))
+		if err != nil || ecBlock == nil {
+			stopFastSync(fmt.Errorf("Entry Credit Block %d not found: %v", h, err), path, validated)
+			return
+		}
+		applyECBlock(balances, ecBlock)
+		fBlock, err := db.FetchFBlockByHeight(uint32(h))
+		if err != nil || fBlock == nil {
+			stopFastSync(fmt.Errorf("Factoid Block %d not found: %v", h, err), path, validated)
+			return
+		}
+		applyFBlock(factoids, fBlock)
+	}
+
+	pool := new(ftmMemPool)
+	pool.init_ftmMemPool()
+	start, startHeight := time.Now(), validated
+	for {
+		_, stored, _ := db.FetchBlockHeightCache()
+		if validated >= stored {
+			s := GetFastSyncStatus()
+			if !s.Deferring && validated >= s.DeferredHeight {
+				saveFastSyncProgress(path, validated)
+				fastSyncMutex.Lock()
+				fastSyncState.Done = true
+				fastSyncMutex.Unlock()
+				procLog.Info("Fast sync validated the deferred blocks up to height ", validated)
+				return
+			}
+			time.Sleep(fastSyncPoll)
+			continue
+		}
+
+		h := uint32(validated + 1)
+		if err := validateStoredBlocks(h, balances, factoids, pool); err != nil {
+			reportInvalidBlock(err, h)
+			stopFastSync(err, path, validated)
+			return
+		}
+		validated = int64(h)
+		setValidatedHeight(validated)
+
+		if validated%fastSyncSaveEvery == 0 {
+			saveFastSyncProgress(path, validated)
+			rate := float64(validated-startHeight) / time.Since(start).Seconds()
+			procLog.Infof("Fast sync validated %d of %d dir blocks, %.1f blocks/s", validated+1, stored+1, rate)
+		}
+	}
+}
+
+// validateStoredBlocks checks the signature and the contents of the blocks
+// of the stored dir block at height. Blocks covered by the checkpoints only
+// update the entry credit and factoid balances.
+func validateStoredBlocks(height uint32, balances map[string]int32, factoids map[string]int64, pool *ftmMemPool) error {
+	b, err := db.FetchDBlockByHeight(height)
+	if err != nil || b == nil {
+		return newValidationError(height, "Directory Block", "not found")
+	}
+
+	var ecBlock *common.ECBlock
+	var fBlock block.IFBlock
+	var aBlock *common.AdminBlock
+	var eBlocks []*common.EBlock
+	for _, dbEntry := range b.DBEntries {
+		switch dbEntry.ChainID.String() {
+		case ecchain.ChainID.String():
+			ecBlock, _ = db.FetchECBlockByHeight(height)
+		case achain.ChainID.String():
+			aBlock, _ = db.FetchABlockByHeight(height)
+		case fchain.ChainID.String():
+			fBlock, _ = db.FetchFBlockByHeight(height)
+		default:
+			eb, _ := db.FetchEBlockByMR(dbEntry.KeyMR)
+			if eb == nil {
+				return newValidationError(height, "Entry Block "+dbEntry.KeyMR.String(), "not found")
+			}
+			eBlocks = append(eBlocks, eb)
+		}
+	}
+	if ecBlock == nil || fBlock == nil || aBlock == nil {
+		return newValidationError(height, "Directory Block", "missing Admin, Entry Credit or Factoid Block")
+	}
+
+	if !validateDBSignature(aBlock, dchain) {
+		return newValidationError(height, "Admin Block", "invalid signature of the previous dir block")
+	}
+	if belowLastCheckpoint(height) {
+		applyECBlock(balances, ecBlock)
+		applyFBlock(factoids, fBlock)
+		return nil
+	}
+
+	if err := validateFBlock(height, fBlock, factoids, nil); err != nil {
+		return err
+	}
+	commitEntries, commitChains, err := validateECBlock(height, ecBlock, fBlock, balances)
+	if err != nil {
+		return err
+	}
+	for _, eb := range eBlocks {
+		if err := validateEBlock(height, eb, commitEntries, commitChains, pool, db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyECBlock updates the balances with the commits and balance increases
+// of the block
+func applyECBlock(balances map[string]int32, ecBlock *common.ECBlock) {
+	for _, entry := range ecBlock.Body.Entries {
+		switch entry.ECID() {
+		case common.ECIDChainCommit:
+			c := entry.(*common.CommitChain)
+			balances[string(c.ECPubKey[:])] -= int32(c.Credits)
+		case common.ECIDEntryCommit:
+			c := entry.(*common.CommitEntry)
+			balances[string(c.ECPubKey[:])] -= int32(c.Credits)
+		case common.ECIDBalanceIncrease:
+			e := entry.(*common.IncreaseBalance)
+			balances[string(e.ECPubKey[:])] += int32(e.NumEC)
+		}
+	}
+}
+
+func setValidatedHeight(height int64) {
+	fastSyncMutex.Lock()
+	defer fastSyncMutex.Unlock()
+	fastSyncState.ValidatedHeight = height
+}
+
+// stopFastSync records the error stopping the background pass
+func stopFastSync(err error, path string, validated int64) {
+	procLog.Error("Fast sync stopped: ", err)
+	saveFastSyncProgress(path, validated)
+	fastSyncMutex.Lock()
+	defer fastSyncMutex.Unlock()
+	fastSyncState.Error = err.Error()
+}
+
+type fastSyncProgress struct {
+	ValidatedHeight int64
+}
+
+// loadFastSyncProgress returns the last height validated. Without a saved
+// progress, the blocks already stored were validated when they were stored.
+func loadFastSyncProgress(path string) (int64, error) {
+	p, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		_, height, err := db.FetchBlockHeightCache()
+		return height, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	progress := new(fastSyncProgress)
+	if err := json.Unmarshal(p, progress); err != nil {
+		return 0, err
+	}
+	return progress.ValidatedHeight, nil
+}
+
+func saveFastSyncProgress(path string, validated int64) {
+	p, _ := json.Marshal(fastSyncProgress{validated})
+	if err := ioutil.WriteFile(path, p, 0600); err != nil {
+		procLog.Error("Cannot save the fast sync progress: ", err)
+	}
+}
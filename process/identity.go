@@ -0,0 +1,378 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/util"
+)
+
+// The federated and audit servers are identities registered in the identity
+// chain by an identity authority key. Each identity has its own chain where
+// it announces the rotations of its signing key, signed by the identity key
+// given at the registration. The acks and dir block signatures are verified
+// with the signing keys in effect at their height. Without any federated
+// server registered, the ServerPubKey of the config is used.
+
+var (
+	// identityChainID is the well-known chain where the server identities
+	// are registered
+	identityChainID *common.Hash
+
+	// identityAuthority holds the hex encoded public keys that are allowed
+	// to register server identities
+	identityAuthority = make(map[string]bool)
+
+	// serverIdentityChainID is the identity of this server, zero if it has
+	// none
+	serverIdentityChainID = common.NewHash()
+
+	// identities are the registered server identities by chain ID
+	identities    = make(map[string]*serverIdentity)
+	identityMutex sync.RWMutex
+)
+
+// serverIdentity is a registered server identity with its signing keys
+type serverIdentity struct {
+	chainID          *common.Hash
+	role             uint8
+	identityKey      [32]byte
+	activationHeight uint32
+	keys             []signingKey // sorted by activation height
+}
+
+type signingKey struct {
+	key              [32]byte
+	activationHeight uint32
+}
+
+// ServerIdentity is a server identity with its signing key in effect at the
+// next dir block and the pending rotations
+type ServerIdentity struct {
+	ChainID     string
+	Role        string
+	IdentityKey string
+	SigningKey  string `json:",omitempty"` // empty until the first key is active
+	Pending     []PendingSigningKey
+}
+
+// PendingSigningKey is a signing key waiting for its activation height
+type PendingSigningKey struct {
+	SigningKey       string
+	ActivationHeight uint32
+}
+
+// loadIdentityConfig reads the identity chain and the authorized public keys
+// from the config
+func loadIdentityConfig(cfg *util.FactomdConfig) {
+	identityChainID = common.NewHash()
+	if cfg.App.IdentityChainID != "" {
+		if h, err := common.HexToHash(cfg.App.IdentityChainID); err != nil {
+			procLog.Error("Invalid IdentityChainID: ", err)
+		} else {
+			identityChainID = h
+		}
+	}
+
+	serverIdentityChainID = common.NewHash()
+	if cfg.App.ServerIdentityChainID != "" {
+		if h, err := common.HexToHash(cfg.App.ServerIdentityChainID); err != nil {
+			procLog.Error("Invalid ServerIdentityChainID: ", err)
+		} else {
+			serverIdentityChainID = h
+		}
+	}
+
+	identityAuthority = make(map[string]bool)
+	for _, k := range cfg.App.IdentityAuthority {
+		if k == "" {
+			continue
+		}
+		if p, err := hex.DecodeString(k); err != nil || len(p) != 32 {
+			procLog.Error("Invalid IdentityAuthority: ", k)
+			continue
+		}
+		identityAuthority[k] = true
+	}
+}
+
+// isIdentityChain returns true if the chain is the identity chain
+func isIdentityChain(chainID *common.Hash) bool {
+	return identityChainID != nil && !identityChainID.IsSameAs(zeroHash) &&
+		chainID.IsSameAs(identityChainID)
+}
+
+// validateIdentityEntry parses the entry content as an identity record of
+// the chain and checks its signature and that it takes effect after the
+// dir block at height
+func validateIdentityEntry(e *common.Entry, height uint32) (*common.IdentityRecord, error) {
+	r := common.NewIdentityRecord()
+	if err := r.UnmarshalBinary(e.Content); err != nil {
+		return nil, fmt.Errorf("Invalid identity record: %s", err)
+	}
+
+	if !r.IsValid() {
+		return nil, fmt.Errorf("Identity record has an invalid signature")
+	}
+
+	if r.ActivationHeight <= height {
+		return nil, fmt.Errorf("Identity record activation height %d is not after %d", r.ActivationHeight, height)
+	}
+
+	identityMutex.RLock()
+	defer identityMutex.RUnlock()
+	id := identities[r.IdentityChainID.String()]
+	switch {
+	case isIdentityChain(e.ChainID):
+		if r.Type != common.IdentityRegistration {
+			return nil, fmt.Errorf("Identity chain only takes registrations")
+		}
+		if !identityAuthority[hex.EncodeToString(r.PubKey[:])] {
+			return nil, fmt.Errorf("Identity registration is not signed by an authorized key: %x", r.PubKey[:])
+		}
+		if id != nil {
+			return nil, fmt.Errorf("Identity %s is already registered", r.IdentityChainID)
+		}
+	case id != nil && e.ChainID.IsSameAs(id.chainID):
+		if r.Type != common.IdentityKeyRotation {
+			return nil, fmt.Errorf("Identity %s chain only takes key rotations", id.chainID)
+		}
+		if !bytes.Equal(r.PubKey[:], id.identityKey[:]) {
+			return nil, fmt.Errorf("Key rotation is not signed by the identity key of %s", id.chainID)
+		}
+	default:
+		return nil, fmt.Errorf("Identity record for %s in chain %s", r.IdentityChainID, e.ChainID)
+	}
+
+	return r, nil
+}
+
+// scheduleIdentityEntry registers the identity or schedules the signing key
+// carried by an entry of the identity chain or of an identity's chain,
+// recorded in the dir block at height
+func scheduleIdentityEntry(e *common.Entry, height uint32) {
+	if !isIdentityChain(e.ChainID) && !isServerIdentityChain(e.ChainID) {
+		return
+	}
+
+	r, err := validateIdentityEntry(e, height)
+	if err != nil {
+		procLog.Debug("Ignoring identity entry ", e.Hash().String(), ": ", err)
+		return
+	}
+
+	identityMutex.Lock()
+	defer identityMutex.Unlock()
+
+	id := identities[r.IdentityChainID.String()]
+	if r.Type == common.IdentityRegistration {
+		if id != nil {
+			return
+		}
+		identities[r.IdentityChainID.String()] = &serverIdentity{
+			chainID:          r.IdentityChainID,
+			role:             r.Role,
+			identityKey:      *r.Key,
+			activationHeight: r.ActivationHeight,
+		}
+		procLog.Infof("Server identity %s registered from block height %d", r.IdentityChainID, r.ActivationHeight)
+		return
+	}
+
+	for _, k := range id.keys {
+		if k.activationHeight == r.ActivationHeight && k.key == *r.Key {
+			return
+		}
+	}
+	id.keys = append(id.keys, signingKey{key: *r.Key, activationHeight: r.ActivationHeight})
+	sort.Stable(bySigningKeyActivation(id.keys))
+
+	procLog.Infof("Server identity %s signs with %x from block height %d", id.chainID, r.Key[:], r.ActivationHeight)
+}
+
+// isServerIdentityChain returns true if the chain is the chain of a
+// registered server identity
+func isServerIdentityChain(chainID *common.Hash) bool {
+	identityMutex.RLock()
+	defer identityMutex.RUnlock()
+	_, ok := identities[chainID.String()]
+	return ok
+}
+
+// keyAt returns the signing key of the identity in effect at the height
+func (id *serverIdentity) keyAt(height uint32) ([32]byte, bool) {
+	var key [32]byte
+	found := false
+	if height < id.activationHeight {
+		return key, false
+	}
+	for _, k := range id.keys {
+		if k.activationHeight > height {
+			break
+		}
+		key, found = k.key, true
+	}
+	return key, found
+}
+
+// serverKeysAt returns the signing keys of the identities with the role in
+// effect at the height. Without any identity registered for the role, the
+// federated servers use the ServerPubKey of the config.
+func serverKeysAt(role uint8, height uint32) [][32]byte {
+	identityMutex.RLock()
+	defer identityMutex.RUnlock()
+
+	keys := make([][32]byte, 0)
+	registered := false
+	for _, id := range identities {
+		if id.role != role {
+			continue
+		}
+		registered = true
+		if key, ok := id.keyAt(height); ok {
+			keys = append(keys, key)
+		}
+	}
+	if !registered && role == common.FederatedServer && serverPubKey.Key != nil {
+		keys = append(keys, *serverPubKey.Key)
+	}
+	return keys
+}
+
+// isServerKey tells if the key is the signing key of a federated server at
+// the height
+func isServerKey(key *[32]byte, height uint32) bool {
+	for _, k := range serverKeysAt(common.FederatedServer, height) {
+		if k == *key {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyServerSignature tells if sig is the signature of msg by the signing
+// key of a federated server at the height, or of an audit server if audit
+// is true
+func VerifyServerSignature(height uint32, msg []byte, sig *[64]byte, audit bool) bool {
+	keys := serverKeysAt(common.FederatedServer, height)
+	if audit {
+		keys = append(keys, serverKeysAt(common.AuditServer, height)...)
+	}
+	for i := range keys {
+		pub := common.PublicKey{Key: &keys[i]}
+		if pub.Verify(msg, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// initIdentities rebuilds the server identities from the identity chain and
+// the identities' chains in the database
+func initIdentities() {
+	identityMutex.Lock()
+	identities = make(map[string]*serverIdentity)
+	identityMutex.Unlock()
+	if !isIdentityChain(identityChainID) {
+		return
+	}
+
+	replayIdentityChain(identityChainID)
+
+	identityMutex.RLock()
+	chainIDs := make([]*common.Hash, 0, len(identities))
+	for _, id := range identities {
+		chainIDs = append(chainIDs, id.chainID)
+	}
+	identityMutex.RUnlock()
+	for _, chainID := range chainIDs {
+		replayIdentityChain(chainID)
+	}
+}
+
+func replayIdentityChain(chainID *common.Hash) {
+	eBlocks, err := db.FetchAllEBlocksByChain(chainID)
+	if err != nil || eBlocks == nil {
+		return
+	}
+	sort.Sort(util.ByEBlockIDAccending(*eBlocks))
+
+	for _, eb := range *eBlocks {
+		for _, h := range eb.Body.EBEntries {
+			if h.IsMinuteMarker() {
+				continue
+			}
+			if e, _ := db.FetchEntryByHash(h); e != nil {
+				scheduleIdentityEntry(e, eb.Header.EBHeight)
+			}
+		}
+	}
+}
+
+// GetServerIdentities returns the registered server identities with their
+// signing keys
+func GetServerIdentities() []ServerIdentity {
+	height := dchain.NextDBHeight
+
+	identityMutex.RLock()
+	defer identityMutex.RUnlock()
+
+	list := make([]ServerIdentity, 0, len(identities))
+	for _, id := range identities {
+		s := ServerIdentity{
+			ChainID:     id.chainID.String(),
+			Role:        "federated",
+			IdentityKey: hex.EncodeToString(id.identityKey[:]),
+			Pending:     make([]PendingSigningKey, 0),
+		}
+		if id.role == common.AuditServer {
+			s.Role = "audit"
+		}
+		if key, ok := id.keyAt(height); ok {
+			s.SigningKey = hex.EncodeToString(key[:])
+		}
+		for _, k := range id.keys {
+			if k.activationHeight > height {
+				s.Pending = append(s.Pending, PendingSigningKey{
+					SigningKey:       hex.EncodeToString(k.key[:]),
+					ActivationHeight: k.activationHeight,
+				})
+			}
+		}
+		list = append(list, s)
+	}
+	sort.Sort(byIdentityChainID(list))
+	return list
+}
+
+type bySigningKeyActivation []signingKey
+
+func (f bySigningKeyActivation) Len() int {
+	return len(f)
+}
+func (f bySigningKeyActivation) Less(i, j int) bool {
+	return f[i].activationHeight < f[j].activationHeight
+}
+func (f bySigningKeyActivation) Swap(i, j int) {
+	f[i], f[j] = f[j], f[i]
+}
+
+type byIdentityChainID []ServerIdentity
+
+func (f byIdentityChainID) Len() int {
+	return len(f)
+}
+func (f byIdentityChainID) Less(i, j int) bool {
+	return f[i].ChainID < f[j].ChainID
+}
+func (f byIdentityChainID) Swap(i, j int) {
+	f[i], f[j] = f[j], f[i]
+}
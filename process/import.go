@@ -0,0 +1,184 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/database"
+	"github.com/FactomProject/btcd/wire"
+	fct "github.com/FactomProject/factoid"
+	"github.com/FactomProject/factoid/block"
+)
+
+// The db import subcommand of factomd stores a block stream while the node
+// is stopped. Each height of the stream is checked like the blocks
+// downloaded from the peers: the network, the genesis block, the
+// checkpoints and the signature of the previous dir block, and with
+// FullValidation the contents above the last checkpoint. The server
+// identities and the balances follow the stored blocks, and the balance
+// snapshot of every stored height is saved like a follower does.
+
+// BlockImport validates and follows the blocks imported into a stopped node
+type BlockImport struct {
+	prev     *common.DirectoryBlock // last stored dir block, nil if none
+	balances map[string]int32       // entry credit balances by public key
+	factoids map[string]int64       // factoid balances by address
+}
+
+// NewBlockImport loads the server identities and the balances of the
+// blocks stored in ldb, to import the blocks after them. The config must be
+// loaded with LoadConfigurations.
+func NewBlockImport(ldb database.Db) (*BlockImport, error) {
+	db = ldb
+	initServerKeys()
+	initChainIDs()
+	initIdentities()
+
+	im := &BlockImport{
+		balances: make(map[string]int32),
+		factoids: make(map[string]int64),
+	}
+	_, head, err := db.FetchBlockHeightCache()
+	if err != nil {
+		return nil, err
+	}
+	if head < 0 {
+		return im, nil
+	}
+	if im.prev, err = db.FetchDBlockByHeight(uint32(head)); err != nil || im.prev == nil {
+		return nil, fmt.Errorf("Last stored dir block %d not found", head)
+	}
+
+	// replay the balances from the last balance snapshot
+	from := int64(0)
+	if state, _ := db.FetchBalanceState(uint32(head)); state != nil {
+		for k, v := range state.ECBalances {
+			im.balances[k] = v
+		}
+		for k, v := range state.FCTBalances {
+			im.factoids[k] = int64(v)
+		}
+		from = int64(state.DBHeight) + 1
+	}
+	for h := from; h <= head; h++ {
+		ecBlock, err := db.FetchECBlockByHeight(uint32(h))
+		if err != nil || ecBlock == nil {
+			return nil, fmt.Errorf("Entry Credit Block %d not found: %v", h, err)
+		}
+		applyECBlock(im.balances, ecBlock)
+		fBlock, err := db.FetchFBlockByHeight(uint32(h))
+		if err != nil || fBlock == nil {
+			return nil, fmt.Errorf("Factoid Block %d not found: %v", h, err)
+		}
+		applyFBlock(im.factoids, fBlock)
+	}
+	return im, nil
+}
+
+// initChainIDs sets the ids of the dir, admin, entry credit and factoid
+// chains without loading their blocks
+func initChainIDs() {
+	dchain = new(common.DChain)
+	dchain.ChainID = new(common.Hash)
+	dchain.ChainID.SetBytes(common.D_CHAINID)
+	achain = new(common.AdminChain)
+	achain.ChainID = new(common.Hash)
+	achain.ChainID.SetBytes(common.ADMIN_CHAINID)
+	ecchain = common.NewECChain()
+	fchain = new(common.FctChain)
+	fchain.ChainID = new(common.Hash)
+	fchain.ChainID.SetBytes(fct.FACTOID_CHAINID)
+}
+
+// Validate checks the blocks of a height before they are stored after the
+// last stored dir block, and updates the balances with them
+func (im *BlockImport) Validate(b *common.DirectoryBlock, aBlock *common.AdminBlock, ecBlock *common.ECBlock,
+	fBlock block.IFBlock, eBlocks []*common.EBlock, entries []*common.Entry) error {
+
+	height := b.Header.DBHeight
+	if b.Header.NetworkID != networkID() {
+		return fmt.Errorf("Dir block %d is of network id %d, not of %s", height, b.Header.NetworkID, netParams.Name)
+	}
+	if height == 0 {
+		h, err := common.CreateHash(b)
+		if err != nil {
+			return err
+		}
+		if !isGenesisHash(h.String()) {
+			return fmt.Errorf("Genesis dir block is %s, expected %s", h.String(), netParams.GenesisHash)
+		}
+	}
+	if err := checkCheckpoint(b); err != nil {
+		return err
+	}
+	if aBlock == nil || ecBlock == nil || fBlock == nil {
+		return newValidationError(height, "Directory Block", "missing Admin, Entry Credit or Factoid Block")
+	}
+	if !verifyDBSignature(aBlock, im.prev) {
+		return newValidationError(height, "Admin Block", "invalid signature of the previous dir block")
+	}
+
+	if !fullValidation || belowLastCheckpoint(height) {
+		applyECBlock(im.balances, ecBlock)
+		applyFBlock(im.factoids, fBlock)
+		return nil
+	}
+
+	pool := new(ftmMemPool)
+	pool.init_ftmMemPool()
+	for _, e := range entries {
+		pool.blockpool[e.Hash().String()] = &wire.MsgEntry{Entry: e}
+	}
+	if err := validateFBlock(height, fBlock, im.factoids, nil); err != nil {
+		return err
+	}
+	commitEntries, commitChains, err := validateECBlock(height, ecBlock, fBlock, im.balances)
+	if err != nil {
+		return err
+	}
+	for _, eb := range eBlocks {
+		if err := validateEBlock(height, eb, commitEntries, commitChains, pool, db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stored follows the server identities registered or rotated by the stored
+// entry blocks of the dir block, and saves the balance snapshot of its
+// height
+func (im *BlockImport) Stored(b *common.DirectoryBlock, eBlocks []*common.EBlock) error {
+	for _, eb := range eBlocks {
+		scheduleEBlockEntries(eb, b.Header.DBHeight)
+	}
+	im.prev = b
+
+	state := common.NewBalanceState()
+	state.DBHeight = b.Header.DBHeight
+	for k, v := range im.balances {
+		state.ECBalances[k] = v
+	}
+	for k, v := range im.factoids {
+		state.FCTBalances[k] = uint64(v)
+	}
+	return db.InsertBalanceState(state)
+}
+
+// Finish indexes the ExtIDs, the entry credit addresses and the factoid
+// transactions of the stored blocks if their indexes are empty
+func (im *BlockImport) Finish() error {
+	if err := db.InitializeExtIDIndex(); err != nil {
+		return fmt.Errorf("Failed to index the entry ExtIDs: %v", err)
+	}
+	if err := db.InitializeECAddressIndex(); err != nil {
+		return fmt.Errorf("Failed to index the entry credit addresses: %v", err)
+	}
+	if err := db.InitializeFactoidIndex(); err != nil {
+		return fmt.Errorf("Failed to index the factoid transactions: %v", err)
+	}
+	return nil
+}
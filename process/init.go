@@ -88,8 +88,18 @@ func initECChain() {
 	ecBlocks, _ := db.FetchAllECBlocks()
 	sort.Sort(util.ByECBlockIDAccending(ecBlocks))
 
+	// Start from the balance snapshot if there is one
+	if balanceSnapshot != nil {
+		for k, v := range balanceSnapshot.ECBalances {
+			eCreditMap[k] = v
+		}
+	}
+
 	// Calculate the EC balance for each account
 	for _, v := range ecBlocks {
+		if isInBalanceSnapshot(v.Header.EBHeight) {
+			continue
+		}
 		initializeECreditMap(&v)
 	}
 
@@ -173,6 +183,9 @@ func initFctChain() {
 	fBlocks, _ := db.FetchAllFBlocks()
 	sort.Sort(util.ByFBlockIDAccending(fBlocks))
 
+	// Start from the balance snapshot if there is one
+	restoreFctBalances()
+
 	// double check the block ids
 	for i := 0; i < len(fBlocks); i = i + 1 {
 		if uint32(i) != fBlocks[i].GetDBHeight() {
@@ -182,11 +195,15 @@ func initFctChain() {
 		} else {
 			FactoshisPerCredit = fBlocks[i].GetExchRate()
 			common.FactoidState.SetFactoshisPerEC(FactoshisPerCredit)
+			if isInBalanceSnapshot(fBlocks[i].GetDBHeight()) {
+				continue
+			}
 			// initialize the FactoidState in sequence
 			err := common.FactoidState.AddTransactionBlock(fBlocks[i])
 			if err != nil {
 				panic("Failed to rebuild factoid state: " + err.Error())
 			}
+			updateFctBalances(fBlocks[i])
 		}
 	}
 
@@ -205,6 +222,7 @@ func initFctChain() {
 			if err != nil {
 				panic(err)
 			}
+			updateFctBalances(gb)
 		}
 
 	} else {
@@ -340,10 +358,10 @@ func validateDChain(c *common.DChain) error {
 
 	//validate the genesis block
 	//prevBlkHash is the block hash for c.Blocks[0]
-	if prevBlkHash == nil || prevBlkHash.String() != common.GENESIS_DIR_BLOCK_HASH {
+	if prevBlkHash == nil || !isGenesisHash(prevBlkHash.String()) {
 
 		str := fmt.Sprintf("<pre>" +
-			"Expected: " + common.GENESIS_DIR_BLOCK_HASH + "<br>" +
+			"Expected: " + netParams.GenesisHash + "<br>" +
 			"Found:    " + prevBlkHash.String() + "</pre><br><br>")
 		cp.CP.AddUpdate(
 			"GenHash",                    // tag
@@ -353,7 +371,7 @@ func validateDChain(c *common.DChain) error {
 			0)
 		// panic for Milestone 1
 		panic("Genesis Block wasn't as expected:\n" +
-			"    Expected: " + common.GENESIS_DIR_BLOCK_HASH + "\n" +
+			"    Expected: " + netParams.GenesisHash + "\n" +
 			"    Found:    " + prevBlkHash.String())
 
 	}
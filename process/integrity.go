@@ -0,0 +1,159 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/common"
+	cp "github.com/FactomProject/FactomCode/controlpanel"
+)
+
+// On startup, the last verifyBlocks dir blocks of the database are checked
+// against their stored hashes, with the blocks they reference. A follower
+// rolls the database back to the last dir block that verifies and downloads
+// the bad range again from its peers. A leader has nobody to get its blocks
+// from, and refuses to start.
+
+var (
+	// verifyBlocks is the number of dir blocks verified on startup, 0
+	// disables the verification
+	verifyBlocks int
+
+	// corruption is the bad range found on startup, nil if none
+	corruption *CorruptionStatus
+)
+
+// CorruptionStatus is the range of dir blocks found corrupted on startup and
+// downloaded again
+type CorruptionStatus struct {
+	FirstHeight uint32 // first dir block that failed the verification
+	LastHeight  uint32 // last dir block stored before the roll back
+	Reason      string
+	Resynced    bool // the range is stored again
+}
+
+// verifyRecentBlocks checks the last verifyBlocks dir blocks and rolls the
+// database back before the first corrupted one
+func verifyRecentBlocks() {
+	if verifyBlocks <= 0 {
+		return
+	}
+	top, err := db.FetchDBlockHeight()
+	if err != nil {
+		procLog.Error("Cannot read the dir block height index: ", err)
+		return
+	}
+	if top < 0 {
+		return
+	}
+
+	start := top - int64(verifyBlocks) + 1
+	if start < 0 {
+		start = 0
+	}
+	var prevHash *common.Hash
+	if start > 0 {
+		prevHash, _ = db.FetchDBHashByHeight(uint32(start - 1))
+	}
+	for h := start; h <= top; h++ {
+		hash, err := verifyDBlock(uint32(h), prevHash)
+		if err != nil {
+			rollBackCorruption(uint32(h), uint32(top), err)
+			return
+		}
+		prevHash = hash
+	}
+	procLog.Infof("Verified dir blocks %d to %d", start, top)
+}
+
+// verifyDBlock checks that the dir block at height matches its stored hash,
+// follows the dir block with prevHash, and that the blocks and entries it
+// references are stored. It returns the hash of the dir block.
+func verifyDBlock(height uint32, prevHash *common.Hash) (*common.Hash, error) {
+	hash, err := db.FetchDBHashByHeight(height)
+	if err != nil || hash == nil {
+		return nil, fmt.Errorf("dir block not found in the height index: %v", err)
+	}
+	b, err := db.FetchDBlockByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, fmt.Errorf("dir block %s not found", hash)
+	}
+	if b.Header.DBHeight != height {
+		return nil, fmt.Errorf("dir block %s has the height %d", hash, b.Header.DBHeight)
+	}
+	if h, err := common.CreateHash(b); err != nil || !h.IsSameAs(hash) {
+		return nil, fmt.Errorf("dir block does not match its stored hash %s", hash)
+	}
+	if prevHash != nil && !prevHash.IsSameAs(b.Header.PrevLedgerKeyMR) {
+		return nil, fmt.Errorf("dir block does not follow the dir block %s", prevHash)
+	}
+	if bodyMR, err := b.BuildBodyMR(); err != nil || !b.Header.BodyMR.IsSameAs(bodyMR) {
+		return nil, fmt.Errorf("dir block has an invalid body merkle root")
+	}
+
+	for _, dbEntry := range b.DBEntries {
+		chainID := dbEntry.ChainID.Bytes()
+		switch {
+		case bytes.Equal(chainID, common.EC_CHAINID):
+			err = validateCBlockByMR(dbEntry.KeyMR)
+		case bytes.Equal(chainID, common.ADMIN_CHAINID):
+			err = validateABlockByMR(dbEntry.KeyMR)
+		case bytes.Equal(chainID, common.FACTOID_CHAINID):
+			err = validateFBlockByMR(dbEntry.KeyMR)
+		default:
+			err = validateEBlockByMR(dbEntry.ChainID, dbEntry.KeyMR)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hash, nil
+}
+
+// rollBackCorruption deletes the dir blocks from first to last, so a follower
+// downloads them again
+func rollBackCorruption(first, last uint32, reason error) {
+	msg := fmt.Sprintf("Database corrupted at dir block %d of %d: %v", first, last, reason)
+	if nodeMode == common.SERVER_NODE {
+		panic(msg + "\nRestore the database from a backup or with factomd db import")
+	}
+	procLog.Critical(msg)
+
+	if err := db.DeleteDBlocksFrom(first); err != nil {
+		panic("Cannot roll back the corrupted dir blocks: " + err.Error())
+	}
+	corruption = &CorruptionStatus{
+		FirstHeight: first,
+		LastHeight:  last,
+		Reason:      reason.Error(),
+	}
+
+	msg = fmt.Sprintf("%s. Rolled back to dir block %d, dir blocks %d to %d are downloaded again from the peers",
+		msg, int64(first)-1, first, last)
+	procLog.Warning(msg)
+	cp.CP.AddUpdate(
+		"Corruption",         // tag
+		"warning",            // Category
+		"Database Corrupted", // Title
+		msg,                  // Message
+		0)                    // Expire
+}
+
+// GetCorruptionStatus returns the corrupted range found on startup, or nil
+func GetCorruptionStatus() *CorruptionStatus {
+	if corruption == nil {
+		return nil
+	}
+	s := *corruption
+	if _, height, err := db.FetchBlockHeightCache(); err == nil && height >= int64(s.LastHeight) {
+		s.Resynced = true
+	}
+	return &s
+}
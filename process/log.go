@@ -5,17 +5,15 @@
 package process
 
 import (
-	"os"
-
 	"github.com/FactomProject/FactomCode/factomlog"
 	"github.com/FactomProject/FactomCode/util"
 )
 
 var (
-	logcfg     = util.ReadConfig().Log
-	logPath    = logcfg.LogPath
-	logLevel   = logcfg.LogLevel
-	logfile, _ = os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0660)
+	logcfg   = util.ReadConfig().Log
+	logPath  = logcfg.LogPath
+	logLevel = logcfg.LogLevel
+	logfile  = factomlog.Open(logPath)
 )
 
 // setup subsystem loggers
@@ -6,12 +6,40 @@ package process
 
 import (
 	"errors"
+	"fmt"
 	"github.com/FactomProject/FactomCode/common"
+	cp "github.com/FactomProject/FactomCode/controlpanel"
 	"github.com/FactomProject/btcd/wire"
 	"sync"
 	"time"
 )
 
+var (
+	// limits of the mem pools, set from the config file
+	orphanTTL  time.Duration
+	commitTTL  time.Duration
+	maxOrphans = common.MAX_ORPHAN_SIZE
+	maxCommits = common.MAX_TX_POOL_SIZE
+
+	// lastExpiry is the last time the expired messages were removed
+	lastExpiry time.Time
+)
+
+// MemPoolStats has the current sizes of the mem pools and the number of
+// messages removed from them
+type MemPoolStats struct {
+	PoolSize       int
+	OrphanSize     int
+	BlockPoolSize  int
+	CommitEntries  int
+	CommitChains   int
+	PendingReveals int
+	ExpiredOrphans uint64
+	EvictedOrphans uint64
+	ExpiredCommits uint64
+	EvictedCommits uint64
+}
+
 // ftmMemPool is used as a source of factom transactions
 // (CommitChain, RevealChain, CommitEntry, RevealEntry)
 type ftmMemPool struct {
@@ -20,6 +48,13 @@ type ftmMemPool struct {
 	orphans     map[wire.ShaHash]wire.Message
 	blockpool   map[string]wire.Message // to hold the blocks or entries downloaded from peers
 	lastUpdated time.Time               // last time pool was updated
+
+	orphanTimes map[wire.ShaHash]time.Time // time each orphan was added
+
+	expiredOrphans uint64
+	evictedOrphans uint64
+	expiredCommits uint64
+	evictedCommits uint64
 }
 
 // Add a factom message to the orphan pool
@@ -28,6 +63,7 @@ func (mp *ftmMemPool) init_ftmMemPool() error {
 	mp.pool = make(map[wire.ShaHash]wire.Message)
 	mp.orphans = make(map[wire.ShaHash]wire.Message)
 	mp.blockpool = make(map[string]wire.Message)
+	mp.orphanTimes = make(map[wire.ShaHash]time.Time)
 
 	return nil
 }
@@ -51,15 +87,80 @@ func (mp *ftmMemPool) addOrphanMsg(msg wire.Message, hash *wire.ShaHash) error {
 	mp.Lock()
 	defer mp.Unlock()
 
-	if len(mp.orphans) > common.MAX_ORPHAN_SIZE {
-		return errors.New("Ophan mem pool exceeds the limit.")
+	if _, exists := mp.orphans[*hash]; !exists && len(mp.orphans) >= maxOrphans {
+		if !mp.evictOrphan() {
+			return errors.New("Ophan mem pool exceeds the limit.")
+		}
 	}
 
 	mp.orphans[*hash] = msg
+	if _, exists := mp.orphanTimes[*hash]; !exists {
+		mp.orphanTimes[*hash] = time.Now()
+	}
+	mp.lastUpdated = time.Now()
 
 	return nil
 }
 
+// Delete a factom message from the orphan pool
+func (mp *ftmMemPool) deleteOrphanMsg(hash wire.ShaHash) {
+	mp.Lock()
+	defer mp.Unlock()
+
+	delete(mp.orphans, hash)
+	delete(mp.orphanTimes, hash)
+}
+
+// evictOrphan removes the oldest orphan to make room for a new one.
+// If the oldest orphan is a factoid transaction, the factoid transaction
+// with the lowest fee is removed instead. The caller holds the lock.
+func (mp *ftmMemPool) evictOrphan() bool {
+	var oldest wire.ShaHash
+	var oldestTime time.Time
+	found := false
+	for h, t := range mp.orphanTimes {
+		if !found || t.Before(oldestTime) {
+			oldest, oldestTime, found = h, t, true
+		}
+	}
+	if !found {
+		return false
+	}
+
+	if tx, ok := mp.orphans[oldest].(*wire.MsgFactoidTX); ok {
+		lowestFee := factoidTxFee(tx)
+		for h, msg := range mp.orphans {
+			tx, ok := msg.(*wire.MsgFactoidTX)
+			if !ok {
+				continue
+			}
+			fee := factoidTxFee(tx)
+			if fee < lowestFee || (fee == lowestFee && mp.orphanTimes[h].Before(mp.orphanTimes[oldest])) {
+				oldest, lowestFee = h, fee
+			}
+		}
+	}
+
+	delete(mp.orphans, oldest)
+	delete(mp.orphanTimes, oldest)
+	mp.evictedOrphans++
+	return true
+}
+
+// expireOrphans removes the orphans older than the ttl
+func (mp *ftmMemPool) expireOrphans(ttl time.Duration) {
+	mp.Lock()
+	defer mp.Unlock()
+
+	for h, t := range mp.orphanTimes {
+		if time.Since(t) > ttl {
+			delete(mp.orphans, h)
+			delete(mp.orphanTimes, h)
+			mp.expiredOrphans++
+		}
+	}
+}
+
 // Add a factom block message to the  Mem pool
 func (mp *ftmMemPool) addBlockMsg(msg wire.Message, hash string) error {
 	mp.Lock()
@@ -84,3 +185,148 @@ func (mp *ftmMemPool) deleteBlockMsg(hash string) error {
 
 	return nil
 }
+
+// factoidTxFee returns the factoshis paid as fee by a factoid transaction
+func factoidTxFee(msg *wire.MsgFactoidTX) uint64 {
+	t := msg.Transaction
+	in, err := t.TotalInputs()
+	if err != nil {
+		return 0
+	}
+	out, err := t.TotalOutputs()
+	if err != nil {
+		return 0
+	}
+	ec, err := t.TotalECs()
+	if err != nil || in < out+ec {
+		return 0
+	}
+	return in - out - ec
+}
+
+// evictCommits removes the oldest commits until there is room for a new one
+func evictCommits() {
+	for len(commitEntryMap)+len(commitChainMap) >= maxCommits {
+		var oldest string
+		var oldestTime int64
+		isChain := false
+		for k, c := range commitEntryMap {
+			if oldest == "" || c.GetMilliTime() < oldestTime {
+				oldest, oldestTime, isChain = k, c.GetMilliTime(), false
+			}
+		}
+		for k, c := range commitChainMap {
+			if oldest == "" || c.GetMilliTime() < oldestTime {
+				oldest, oldestTime, isChain = k, c.GetMilliTime(), true
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		if isChain {
+			delete(commitChainMap, oldest)
+			deletePendingMatch(common.PENDING_COMMIT_CHAIN, oldest)
+		} else {
+			delete(commitEntryMap, oldest)
+			deletePendingMatch(common.PENDING_COMMIT_ENTRY, oldest)
+		}
+		fMemPool.Lock()
+		fMemPool.evictedCommits++
+		fMemPool.Unlock()
+	}
+}
+
+// expireCommits removes the unrevealed commits older than the ttl
+func expireCommits(ttl time.Duration) {
+	var expired uint64
+	limit := time.Now().Add(-ttl).UnixNano() / int64(time.Millisecond)
+	for k, c := range commitEntryMap {
+		if c.GetMilliTime() < limit {
+			delete(commitEntryMap, k)
+			deletePendingMatch(common.PENDING_COMMIT_ENTRY, k)
+			expired++
+		}
+	}
+	for k, c := range commitChainMap {
+		if c.GetMilliTime() < limit {
+			delete(commitChainMap, k)
+			deletePendingMatch(common.PENDING_COMMIT_CHAIN, k)
+			expired++
+		}
+	}
+
+	fMemPool.Lock()
+	fMemPool.expiredCommits += expired
+	fMemPool.Unlock()
+}
+
+// expireMemPool removes the expired orphans and commits once a minute
+func expireMemPool() {
+	if time.Since(lastExpiry) < time.Minute {
+		return
+	}
+	lastExpiry = time.Now()
+
+	if orphanTTL > 0 {
+		fMemPool.expireOrphans(orphanTTL)
+		expirePendingReveals(orphanTTL)
+	}
+	if commitTTL > 0 {
+		expireCommits(commitTTL)
+	}
+
+	s := memPoolStats()
+	procLog.Debugf("MemPool: %+v", s)
+	cp.CP.AddUpdate(
+		"MemPool",  // tag
+		"status",   // Category
+		"Mem Pool", // Title
+		fmt.Sprintf("Orphans %d, unrevealed commits %d\n", s.OrphanSize, s.CommitEntries+s.CommitChains)+
+			fmt.Sprintf("Expired orphans %d, evicted orphans %d\n", s.ExpiredOrphans, s.EvictedOrphans)+
+			fmt.Sprintf("Expired commits %d, evicted commits %d", s.ExpiredCommits, s.EvictedCommits),
+		0)
+}
+
+var (
+	// lastMemPoolStats are the last stats taken by the processor
+	lastMemPoolStats      MemPoolStats
+	lastMemPoolStatsMutex sync.Mutex
+)
+
+// GetMemPoolStats returns the current mem pool sizes and eviction counts.
+// They are taken on the processor goroutine; if it is too busy, the last
+// stats taken are returned.
+func GetMemPoolStats() MemPoolStats {
+	if !onProcessor(func() {
+		s := memPoolStats()
+		lastMemPoolStatsMutex.Lock()
+		lastMemPoolStats = s
+		lastMemPoolStatsMutex.Unlock()
+	}) {
+		procLog.Warning("The processor is busy, returning the last mem pool stats")
+	}
+
+	lastMemPoolStatsMutex.Lock()
+	defer lastMemPoolStatsMutex.Unlock()
+	return lastMemPoolStats
+}
+
+// memPoolStats returns the current mem pool sizes and eviction counts. It
+// must be called from the processor goroutine.
+func memPoolStats() MemPoolStats {
+	fMemPool.RLock()
+	defer fMemPool.RUnlock()
+
+	return MemPoolStats{
+		PoolSize:       len(fMemPool.pool),
+		OrphanSize:     len(fMemPool.orphans),
+		BlockPoolSize:  len(fMemPool.blockpool),
+		CommitEntries:  len(commitEntryMap),
+		CommitChains:   len(commitChainMap),
+		PendingReveals: len(pendingReveals),
+		ExpiredOrphans: fMemPool.expiredOrphans,
+		EvictedOrphans: fMemPool.evictedOrphans,
+		ExpiredCommits: fMemPool.expiredCommits,
+		EvictedCommits: fMemPool.evictedCommits,
+	}
+}
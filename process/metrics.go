@@ -0,0 +1,72 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"github.com/FactomProject/FactomCode/metrics"
+)
+
+var (
+	heightGauge        = metrics.NewGauge("factomd_dir_block_height", "Height of the last stored dir block.")
+	networkHeightGauge = metrics.NewGauge("factomd_network_height", "Highest dir block known from the network.")
+	syncedGauge        = metrics.NewGauge("factomd_synced", "1 if the node stored all the dir blocks known.")
+	minuteGauge        = metrics.NewGauge("factomd_minute", "Minutes ended in the open dir block.")
+	processListGauge   = metrics.NewGauge("factomd_process_list_size", "Items in the process list of the open dir block.")
+	memPoolGauge       = metrics.NewGauge("factomd_mempool_size", "Messages in each mem pool.", "pool")
+	anchorLagGauge     = metrics.NewGauge("factomd_anchor_lag_blocks", "Dir blocks stored after the last anchored one.")
+	fastSyncGauge      = metrics.NewGauge("factomd_fast_sync_validated_height", "Last dir block validated by the fast sync background pass.")
+	stalledGauge       = metrics.NewGauge("factomd_stalled", "1 if the node made no progress for the watchdog stall threshold.")
+
+	messageCounter   = metrics.NewCounter("factomd_messages_processed_total", "Messages served by the processor, by command.", "command")
+	duplicateCounter = metrics.NewCounter("factomd_seen_cache_lookups_total", "Lookups of received messages in the seen cache, by result.", "result")
+	stallCounter     = metrics.NewCounter("factomd_watchdog_stalls_total", "Stalls detected by the watchdog, by cause.", "cause")
+
+	blockStoreHistogram = metrics.NewHistogram("factomd_db_block_store_seconds", "Time to store a dir block and its blocks in the database.", metrics.DefaultBuckets)
+	blockBuildHistogram = metrics.NewHistogram("factomd_block_build_seconds", "Time for the leader to build and store the blocks of a dir block.", metrics.DefaultBuckets)
+)
+
+func init() {
+	metrics.OnScrape(updateMetrics)
+}
+
+// updateMetrics sets the gauges from the status of the node, whose state
+// of the processor is read on the processor goroutine
+func updateMetrics() {
+	if db == nil {
+		return
+	}
+	s := GetNodeStatus()
+	heightGauge.Set(float64(s.Height))
+	networkHeightGauge.Set(float64(s.NetworkHeight))
+	if s.Synced {
+		syncedGauge.Set(1)
+	} else {
+		syncedGauge.Set(0)
+	}
+	minuteGauge.Set(float64(s.Minute))
+	if s.FastSync.Enabled {
+		fastSyncGauge.Set(float64(s.FastSync.ValidatedHeight))
+	}
+	if s.Watchdog.Stalled {
+		stalledGauge.Set(1)
+	} else {
+		stalledGauge.Set(0)
+	}
+
+	memPoolGauge.Set(float64(s.MemPool.PoolSize), "pool")
+	memPoolGauge.Set(float64(s.MemPool.OrphanSize), "orphans")
+	memPoolGauge.Set(float64(s.MemPool.BlockPoolSize), "blocks")
+	memPoolGauge.Set(float64(s.MemPool.CommitEntries), "commit_entries")
+	memPoolGauge.Set(float64(s.MemPool.CommitChains), "commit_chains")
+	memPoolGauge.Set(float64(s.MemPool.PendingReveals), "pending_reveals")
+
+	if s.LastAnchoredHeight >= 0 {
+		anchorLagGauge.Set(float64(int64(s.Height) - s.LastAnchoredHeight))
+	} else {
+		anchorLagGauge.Set(float64(s.Height) + 1)
+	}
+
+	processListGauge.Set(float64(s.ProcessListSize))
+}
@@ -0,0 +1,51 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"github.com/FactomProject/FactomCode/p2p"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// staleHeightSlack is how far below the last stored dir block a peer may
+// send dir blocks again, as a peer answering late does, before they are
+// stale
+const staleHeightSlack = 10
+
+// misbehavior is the error of a message rejected for a misbehavior of the
+// peer which relayed it
+type misbehavior struct {
+	kind p2p.Misbehavior
+	err  error
+}
+
+func (m *misbehavior) Error() string {
+	return m.err.Error()
+}
+
+// peerMisbehaved returns the error of a message rejected for the
+// misbehavior, which is scored against the peer which relayed it
+func peerMisbehaved(kind p2p.Misbehavior, err error) error {
+	return &misbehavior{kind: kind, err: err}
+}
+
+// settleMessage takes the origin of the message served by the processor,
+// scores its rejection against the peer which relayed it if the peer
+// misbehaved, and audits it
+func settleMessage(msg wire.FtmInternalMsg, err error) {
+	origin := p2p.TakeOrigin(msg)
+	if m, ok := err.(*misbehavior); ok && p2p.IsPeerOrigin(origin) {
+		procLog.Debug("peer ", origin, " misbehaved: ", m.kind)
+		p2p.ReportMisbehavior(origin, m.kind)
+	}
+	auditMessage(msg, origin, err)
+}
+
+// isStaleHeight tells if a dir block at height, already stored, is more than
+// staleHeightSlack blocks below the last stored one
+func isStaleHeight(height uint32) bool {
+	_, head, err := db.FetchBlockHeightCache()
+	return err == nil && int64(height)+staleHeightSlack < head
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/FactomProject/btcd/wire"
+)
+
+// Ack status of the messages that are not in a block yet
+const (
+	PendingAcked         = "acked"          // in the process list of the next block
+	PendingUnacked       = "unacked"        // accepted but not acknowledged by the server
+	PendingOrphan        = "orphan"         // waiting in the orphan pool
+	PendingWaitingCommit = "waiting-commit" // reveal waiting for its commit
+)
+
+// PendingItem is a commit, reveal or factoid transaction that is not in a
+// block yet. Hash is the entry hash, or the transaction id of a factoid
+// transaction.
+type PendingItem struct {
+	Hash      string
+	ChainID   string `json:",omitempty"`
+	Status    string
+	AckHeight uint32 `json:",omitempty"`
+	AckIndex  uint32 `json:",omitempty"`
+}
+
+// PendingView has the messages submitted but not yet in a block
+type PendingView struct {
+	Commits    []*PendingItem
+	Reveals    []*PendingItem
+	FactoidTxs []*PendingItem
+}
+
+// add appends the item unless a message of the kind with the same hash was
+// already added with a better status
+func (v *PendingView) add(seen map[string]bool, msg wire.Message, item *PendingItem) {
+	var list *[]*PendingItem
+	switch m := msg.(type) {
+	case *wire.MsgCommitEntry:
+		list, item.Hash = &v.Commits, m.CommitEntry.EntryHash.String()
+	case *wire.MsgCommitChain:
+		list, item.Hash = &v.Commits, m.CommitChain.EntryHash.String()
+	case *wire.MsgRevealEntry:
+		list, item.Hash, item.ChainID = &v.Reveals, m.Entry.Hash().String(), m.Entry.ChainID.String()
+	case *wire.MsgFactoidTX:
+		list, item.Hash = &v.FactoidTxs, hex.EncodeToString(m.Transaction.GetHash().Bytes())
+	default:
+		return
+	}
+
+	key := msg.Command() + item.Hash
+	if seen[key] {
+		return
+	}
+	seen[key] = true
+	*list = append(*list, item)
+}
+
+var (
+	// lastPending is the last view built by the processor
+	lastPending = &PendingView{
+		Commits:    make([]*PendingItem, 0),
+		Reveals:    make([]*PendingItem, 0),
+		FactoidTxs: make([]*PendingItem, 0),
+	}
+	lastPendingMutex sync.Mutex
+)
+
+// GetPending returns the commits, reveals and factoid transactions that are
+// not in a block yet, with their ack status. Only the server acknowledges
+// messages, so on other nodes nothing is acked. The view is built on the
+// processor goroutine; if it is too busy, the last view built is returned.
+func GetPending() *PendingView {
+	if !onProcessor(func() {
+		v := pendingView()
+		lastPendingMutex.Lock()
+		lastPending = v
+		lastPendingMutex.Unlock()
+	}) {
+		procLog.Warning("The processor is busy, returning the last pending view")
+	}
+
+	lastPendingMutex.Lock()
+	defer lastPendingMutex.Unlock()
+	return lastPending
+}
+
+// pendingView builds the view of the pending messages. It must be called
+// from the processor goroutine.
+func pendingView() *PendingView {
+	v := &PendingView{
+		Commits:    make([]*PendingItem, 0),
+		Reveals:    make([]*PendingItem, 0),
+		FactoidTxs: make([]*PendingItem, 0),
+	}
+	seen := make(map[string]bool)
+
+	if plMgr != nil {
+		for _, pli := range plMgr.MyProcessList.GetPLItems() {
+			if pli == nil || pli.Ack == nil {
+				continue
+			}
+			msg, ok := pli.Msg.(wire.Message)
+			if !ok {
+				continue
+			}
+			v.add(seen, msg, &PendingItem{
+				Status:    PendingAcked,
+				AckHeight: pli.Ack.Height,
+				AckIndex:  pli.Ack.Index,
+			})
+		}
+	}
+
+	for _, c := range commitEntryMap {
+		m := wire.NewMsgCommitEntry()
+		m.CommitEntry = c
+		v.add(seen, m, &PendingItem{Status: PendingUnacked})
+	}
+	for _, c := range commitChainMap {
+		m := wire.NewMsgCommitChain()
+		m.CommitChain = c
+		v.add(seen, m, &PendingItem{Status: PendingUnacked})
+	}
+
+	fMemPool.RLock()
+	for _, msg := range fMemPool.orphans {
+		v.add(seen, msg, &PendingItem{Status: PendingOrphan})
+	}
+	fMemPool.RUnlock()
+
+	for _, msg := range pendingReveals {
+		v.add(seen, msg, &PendingItem{Status: PendingWaitingCommit})
+	}
+
+	return v
+}
@@ -0,0 +1,171 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/btcd/wire"
+)
+
+var (
+	// pendingReveals holds the reveals that arrived before their commits,
+	// keyed by entry hash
+	pendingReveals     = make(map[string]*wire.MsgRevealEntry)
+	pendingRevealTimes = make(map[string]time.Time)
+)
+
+// savePendingCommit stores an unrevealed commit in db so it survives a restart
+func savePendingCommit(pendingType uint8, entryHash *common.Hash, c common.BinaryMarshallable) {
+	data, err := c.MarshalBinary()
+	if err != nil {
+		procLog.Error(err)
+		return
+	}
+
+	p := common.NewPendingMatch()
+	p.Type = pendingType
+	p.EntryHash = entryHash
+	p.Timestamp = time.Now().Unix()
+	p.Data = data
+	if err := db.InsertPendingMatch(p); err != nil {
+		procLog.Error("Failed to save pending commit: ", err)
+	}
+}
+
+// deletePendingMatch removes a matched or expired commit or reveal from db
+func deletePendingMatch(pendingType uint8, entryHash string) {
+	h, err := common.HexToHash(entryHash)
+	if err != nil {
+		return
+	}
+	if err := db.DeletePendingMatch(pendingType, h); err != nil {
+		procLog.Error("Failed to delete pending match: ", err)
+	}
+}
+
+// addPendingReveal keeps a reveal without a commit until its commit arrives
+func addPendingReveal(msg *wire.MsgRevealEntry) error {
+	key := msg.Entry.Hash().String()
+	if _, exists := pendingReveals[key]; exists {
+		return nil
+	}
+	if len(pendingReveals) >= maxOrphans {
+		return fmt.Errorf("No commit for entry and the pending reveals exceed the limit")
+	}
+
+	data, err := msg.Entry.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	pendingReveals[key] = msg
+	pendingRevealTimes[key] = time.Now()
+
+	p := common.NewPendingMatch()
+	p.Type = common.PENDING_REVEAL
+	p.EntryHash = msg.Entry.Hash()
+	p.Timestamp = pendingRevealTimes[key].Unix()
+	p.Data = data
+	if err := db.InsertPendingMatch(p); err != nil {
+		procLog.Error("Failed to save pending reveal: ", err)
+	}
+
+	procLog.Info("No commit for entry yet, waiting for it: ", key)
+	return nil
+}
+
+// matchPendingReveal processes the pending reveal of a newly arrived commit
+func matchPendingReveal(entryHash *common.Hash) {
+	key := entryHash.String()
+	msg, ok := pendingReveals[key]
+	if !ok {
+		return
+	}
+
+	delete(pendingReveals, key)
+	delete(pendingRevealTimes, key)
+	deletePendingMatch(common.PENDING_REVEAL, key)
+
+	if err := processRevealEntry(checkReveal(msg)); err != nil {
+		procLog.Error("Failed to process the pending reveal ", key, ": ", err)
+	}
+}
+
+// expirePendingReveals drops the reveals whose commits did not arrive in time
+func expirePendingReveals(ttl time.Duration) {
+	for key, t := range pendingRevealTimes {
+		if time.Since(t) > ttl {
+			delete(pendingReveals, key)
+			delete(pendingRevealTimes, key)
+			deletePendingMatch(common.PENDING_REVEAL, key)
+		}
+	}
+}
+
+// initPendingMatches reloads the unmatched commits and reveals from db.
+// Only the commits that were already paid in a stored ECBlock are restored.
+func initPendingMatches() {
+	pendings, err := db.FetchAllPendingMatches()
+	if err != nil {
+		procLog.Error("Failed to load pending matches: ", err)
+		return
+	}
+
+	paid := make(map[string]bool)
+	for _, entry := range previousECEntries(dchain.NextDBHeight, db) {
+		switch c := entry.(type) {
+		case *common.CommitEntry:
+			paid[c.EntryHash.String()] = true
+		case *common.CommitChain:
+			paid[c.EntryHash.String()] = true
+		}
+	}
+
+	for _, p := range pendings {
+		key := p.EntryHash.String()
+		since := time.Unix(p.Timestamp, 0)
+
+		switch p.Type {
+		case common.PENDING_COMMIT_ENTRY:
+			c := common.NewCommitEntry()
+			if err := c.UnmarshalBinary(p.Data); err != nil || !paid[key] ||
+				(commitTTL > 0 && time.Since(since) > commitTTL) {
+				deletePendingMatch(p.Type, key)
+				continue
+			}
+			commitEntryMap[key] = c
+		case common.PENDING_COMMIT_CHAIN:
+			c := common.NewCommitChain()
+			if err := c.UnmarshalBinary(p.Data); err != nil || !paid[key] ||
+				(commitTTL > 0 && time.Since(since) > commitTTL) {
+				deletePendingMatch(p.Type, key)
+				continue
+			}
+			commitChainMap[key] = c
+		case common.PENDING_REVEAL:
+			e := common.NewEntry()
+			if err := e.UnmarshalBinary(p.Data); err != nil ||
+				(orphanTTL > 0 && time.Since(since) > orphanTTL) {
+				deletePendingMatch(p.Type, key)
+				continue
+			}
+			msg := wire.NewMsgRevealEntry()
+			msg.Entry = e
+			pendingReveals[key] = msg
+			pendingRevealTimes[key] = since
+		}
+	}
+
+	procLog.Info("Loaded ", len(commitEntryMap)+len(commitChainMap), " unrevealed commits and ",
+		len(pendingReveals), " reveals without commits")
+}
+
+// GetPendingMatches returns the unmatched commits and reveals
+func GetPendingMatches() ([]*common.PendingMatch, error) {
+	return db.FetchAllPendingMatches()
+}
@@ -19,13 +19,14 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/FactomProject/FactomCode/anchor"
 	"github.com/FactomProject/FactomCode/common"
 	"github.com/FactomProject/FactomCode/consensus"
 	cp "github.com/FactomProject/FactomCode/controlpanel"
 	"github.com/FactomProject/FactomCode/database"
+	"github.com/FactomProject/FactomCode/p2p"
 	"github.com/FactomProject/FactomCode/util"
 	"github.com/FactomProject/btcd/wire"
 	fct "github.com/FactomProject/factoid"
@@ -83,8 +84,8 @@ var (
 	directoryBlockInSeconds int
 	dataStorePath           string
 	ldbpath                 string
+	homeDir                 string
 	nodeMode                string
-	devNet                  bool
 	serverPrivKeyHex        string
 	serverIndex             = common.NewServerIndexNumber()
 )
@@ -96,9 +97,42 @@ func LoadConfigurations(cfg *util.FactomdConfig) {
 	logLevel = cfg.Log.LogLevel
 	dataStorePath = cfg.App.DataStorePath
 	ldbpath = cfg.App.LdbPath
+	homeDir = cfg.App.HomeDir
 	directoryBlockInSeconds = cfg.App.DirectoryBlockInSeconds
 	nodeMode = cfg.App.NodeMode
 	serverPrivKeyHex = cfg.App.ServerPrivKey
+	fullValidation = cfg.App.FullValidation
+	fastSync = cfg.App.FastSync
+	verifyBlocks = cfg.App.VerifyBlocks
+	entryWorkers = cfg.App.EntryWorkers
+
+	orphanTTL = time.Duration(cfg.Mempool.OrphanTTLInSeconds) * time.Second
+	commitTTL = time.Duration(cfg.Mempool.CommitTTLInSeconds) * time.Second
+	if cfg.Mempool.MaxOrphans > 0 {
+		maxOrphans = cfg.Mempool.MaxOrphans
+	}
+	if cfg.Mempool.MaxCommits > 0 {
+		maxCommits = cfg.Mempool.MaxCommits
+	}
+	if cfg.Mempool.SeenCacheSize > 0 {
+		seen = newSeenCache(cfg.Mempool.SeenCacheSize)
+	}
+	FactoshisPerCredit = cfg.App.ExchangeRate
+	if p, err := common.NetworkParamsByName(cfg.App.Network); err != nil {
+		panic("Cannot parse Network from configuration file: " + err.Error())
+	} else {
+		netParams = p
+	}
+	if err := common.SetCheckpoints(netParams.NetworkID, cfg.App.Checkpoints); err != nil {
+		panic("Cannot parse Checkpoints from configuration file: " + err.Error())
+	}
+	loadExchangeRateConfig(cfg)
+	loadIdentityConfig(cfg)
+	if err := loadWatchdogConfig(cfg); err != nil {
+		procLog.Error(err)
+	}
+	util.OnReload(loadWatchdogConfig, "Watchdog.StallSeconds", "Watchdog.Actions")
+	initAudit(cfg)
 
 	cp.CP.SetPort(cfg.Controlpanel.Port)
 
@@ -114,6 +148,14 @@ func initProcessor() {
 	// init server private key or pub key
 	initServerKeys()
 
+	// init the entry block and reveal workers, unless they are running
+	if workers == nil {
+		workers = newChainWorkers(entryWorkers)
+	}
+	if checkers == nil {
+		checkers = newChainWorkers(entryWorkers)
+	}
+
 	// init mem pools
 	fMemPool = new(ftmMemPool)
 	fMemPool.init_ftmMemPool()
@@ -122,28 +164,65 @@ func initProcessor() {
 	wire.FChainID = common.NewHash()
 	wire.FChainID.SetBytes(common.FACTOID_CHAINID)
 
-	FactoshisPerCredit = 666666 // .001 / .15 * 100000000 (assuming a Factoid is .15 cents, entry credit = .1 cents
+	if FactoshisPerCredit == 0 {
+		FactoshisPerCredit = 666666 // .001 / .15 * 100000000 (assuming a Factoid is .15 cents, entry credit = .1 cents
+	}
+
+	// roll back a corrupted range of the last dir blocks
+	verifyRecentBlocks()
 
 	// init Directory Block Chain
 	initDChain()
 
 	procLog.Info("Loaded ", dchain.NextDBHeight, " Directory blocks for chain: "+dchain.ChainID.String())
 
+	// refuse a database of another network
+	if len(dchain.Blocks) > 0 && dchain.Blocks[0].Header.NetworkID != networkID() {
+		panic(fmt.Sprintf("The database in %s is of network id %d, not of %s",
+			ldbpath, dchain.Blocks[0].Header.NetworkID, netParams.Name))
+	}
+
+	// resolve the signing keys of the server identities
+	initIdentities()
+	if nodeMode == common.SERVER_NODE && !isServerKey(serverPubKey.Key, dchain.NextDBHeight) {
+		procLog.Warning("The server key is not the signing key of a federated server identity")
+	}
+
+	// restore the state saved on a clean shutdown, or load the balance
+	// snapshot to skip replaying the older blocks
+	loadStateSnapshot()
+	if restored == nil {
+		loadBalanceState()
+	}
+
 	// init Entry Credit Chain
-	initECChain()
+	if restored != nil {
+		restoreECChain()
+	} else {
+		initECChain()
+	}
 	procLog.Info("Loaded ", ecchain.NextBlockHeight, " Entry Credit blocks for chain: "+ecchain.ChainID.String())
 
 	// init Admin Chain
-	initAChain()
+	if restored != nil {
+		restoreAChain()
+	} else {
+		initAChain()
+	}
 	procLog.Info("Loaded ", achain.NextBlockHeight, " Admin blocks for chain: "+achain.ChainID.String())
 
-	initFctChain()
+	if restored != nil {
+		restoreFctChain()
+	} else {
+		initFctChain()
+	}
+	initBoundaryStates()
 	//common.FactoidState.LoadState()
 	procLog.Info("Loaded ", fchain.NextBlockHeight, " factoid blocks for chain: "+fchain.ChainID.String())
 
 	//Init anchor for server
 	if nodeMode == common.SERVER_NODE {
-		anchor.InitAnchor(db, inMsgQueue, serverPrivKey)
+		blockAnchorer.init(db, inMsgQueue, serverPrivKey)
 	}
 	// build the Genesis blocks if the current height is 0
 	if dchain.NextDBHeight == 0 && nodeMode == common.SERVER_NODE {
@@ -159,20 +238,47 @@ func initProcessor() {
 	// init Entry Chains
 	initEChains()
 	for _, chain := range chainIDMap {
-		initEChainFromDB(chain)
+		if !restoreEChain(chain) {
+			initEChainFromDB(chain)
+		}
 
 		procLog.Info("Loaded ", chain.NextBlockHeight, " blocks for chain: "+chain.ChainID.String())
 	}
 
-	// Validate all dir blocks
-	err := validateDChain(dchain)
-	if err != nil {
-		if nodeMode == common.SERVER_NODE {
-			panic("Error found in validating directory blocks: " + err.Error())
+	// init the pending exchange rate changes
+	initExchangeRates()
+
+	// reload the unmatched commits and reveals
+	initPendingMatches()
+
+	// index the ExtIDs of the entries stored before the ExtID index
+	if err := db.InitializeExtIDIndex(); err != nil {
+		procLog.Error("Failed to index the entry ExtIDs: ", err)
+	}
+	if err := db.InitializeECAddressIndex(); err != nil {
+		procLog.Error("Failed to index the entry credit addresses: ", err)
+	}
+	if err := db.InitializeFactoidIndex(); err != nil {
+		procLog.Error("Failed to index the factoid transactions: ", err)
+	}
+
+	restoreSeenCache()
+
+	// Validate all dir blocks, unless they were before the snapshot
+	dchainValidated = restored != nil && restored.Validated
+	if !dchainValidated {
+		err := validateDChain(dchain)
+		if err != nil {
+			if nodeMode == common.SERVER_NODE {
+				panic("Error found in validating directory blocks: " + err.Error())
+			} else {
+				dchain.IsValidated = false
+			}
 		} else {
-			dchain.IsValidated = false
+			dchainValidated = true
 		}
 	}
+	restored = nil
 
 }
 
@@ -195,17 +301,26 @@ func Start_Processor(
 
 	// Initialize timer for the open dblock before processing messages
 	if nodeMode == common.SERVER_NODE {
-		timer := &BlockTimer{
-			nextDBlockHeight: dchain.NextDBHeight,
-			inCtlMsgQueue:    inCtlMsgQueue,
-		}
-		go timer.StartBlockTimer()
+		startTimer(dchain.NextDBHeight)
 	} else {
 		// start the go routine to process the blocks and entries downloaded
 		// from peers
 		time.Sleep(5 * time.Second)
+		if fastSync {
+			if err := initFastSync(); err != nil {
+				procLog.Error("Fast sync disabled, cannot read its progress: ", err)
+				fastSync = false
+			} else {
+				go runDeferredValidation()
+			}
+		}
 		go validateAndStoreBlocks(fMemPool, db, dchain, outCtlMsgQueue)
+		go startRangeSync(inMsgQueue)
+		if corruption != nil {
+			requestResync()
+		}
 	}
+	go runWatchdog()
 
 	// Process msg from the incoming queue one by one
 	for {
@@ -220,18 +335,26 @@ func Start_Processor(
 						procLog.Error(err)
 					}
 				}
+				finishReveals()
 			case ctlMsg, ok := <-inCtlMsgQueue:
 				if ok {
 					if err := serveMsgRequest(ctlMsg); err != nil {
 						procLog.Error(err)
 					}
 				}
+				finishReveals()
+			case f := <-processorCalls:
+				f()
 			default:
+				finishReveals()
+				expireMemPool()
 				time.Sleep(time.Duration(10) * time.Millisecond)
-				if SafeStop {
+				if SafeStop && !SafeStopDone {
+					saveStateSnapshot()
 					procLog.Info("Closing database")
 					db.Close()
 					procLog.Info("Database closed")
+					closeAudit()
 					SafeStopDone = true
 				}
 				break queueloop
@@ -240,6 +363,34 @@ func Start_Processor(
 	}
 }
 
+// processorCallTimeout bounds the wait of a reader for a busy processor
+const processorCallTimeout = 5 * time.Second
+
+// processorCalls are run on the processor goroutine, for the readers of its
+// state on other goroutines
+var processorCalls = make(chan func(), 100)
+
+// onProcessor runs f on the processor goroutine and waits until it is done.
+// It returns false if the processor did not run it in time, f may then still
+// run later.
+func onProcessor(f func()) bool {
+	done := make(chan struct{})
+	timeout := time.NewTimer(processorCallTimeout)
+	defer timeout.Stop()
+
+	select {
+	case processorCalls <- func() { f(); close(done) }:
+	case <-timeout.C:
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-timeout.C:
+		return false
+	}
+}
+
 // Serve the "fast lane" incoming control msg from inCtlMsgQueue
 func serveCtlMsgRequest(msg wire.FtmInternalMsg) error {
 
@@ -254,7 +405,33 @@ func serveCtlMsgRequest(msg wire.FtmInternalMsg) error {
 }
 
 // Serve incoming msg from inMsgQueue
-func serveMsgRequest(msg wire.FtmInternalMsg) error {
+func serveMsgRequest(msg wire.FtmInternalMsg) (err error) {
+
+	// drop a message already received from another peer before validating
+	// it again
+	if IsDuplicate(msg) {
+		procLog.Debug("duplicate ", msg.Command(), " dropped")
+		p2p.TakeOrigin(msg)
+		return nil
+	}
+	messageCounter.Inc(msg.Command())
+
+	// reveals are checked on the worker of their chain, and processed when
+	// they come back in finishReveals
+	if msg.Command() == wire.CmdRevealEntry {
+		if msgRevealEntry, ok := msg.(*wire.MsgRevealEntry); ok && msgRevealEntry.Entry != nil {
+			checkers.dispatch(msgRevealEntry.Entry.ChainID, func() {
+				queueCheckedReveal(checkReveal(msgRevealEntry))
+			})
+			return nil
+		}
+	}
+	defer func() {
+		if err != nil {
+			forgetSeen(msg)
+		}
+		settleMessage(msg, err)
+	}()
 
 	switch msg.Command() {
 	case wire.CmdCommitChain:
@@ -273,7 +450,7 @@ func serveMsgRequest(msg wire.FtmInternalMsg) error {
 				return err
 			}
 		} else {
-			return errors.New("Error in processing msg:" + spew.Sdump(msg))
+			return peerMisbehaved(p2p.InvalidSignature, errors.New("Error in processing msg:"+spew.Sdump(msg)))
 		}
 		// Broadcast the msg to the network if no errors
 		outMsgQueue <- msg
@@ -294,23 +471,13 @@ func serveMsgRequest(msg wire.FtmInternalMsg) error {
 				return err
 			}
 		} else {
-			return errors.New("Error in processing msg:" + spew.Sdump(msg))
+			return peerMisbehaved(p2p.InvalidSignature, errors.New("Error in processing msg:"+spew.Sdump(msg)))
 		}
 		// Broadcast the msg to the network if no errors
 		outMsgQueue <- msg
 
 	case wire.CmdRevealEntry:
-		msgRevealEntry, ok := msg.(*wire.MsgRevealEntry)
-		if ok && msgRevealEntry.IsValid() {
-			err := processRevealEntry(msgRevealEntry)
-			if err != nil {
-				return err
-			}
-		} else {
-			return errors.New("Error in processing msg:" + spew.Sdump(msg))
-		}
-		// Broadcast the msg to the network if no errors
-		outMsgQueue <- msg
+		return errors.New("Error in processing msg:" + spew.Sdump(msg))
 
 	case wire.CmdInt_EOM:
 
@@ -322,6 +489,7 @@ func serveMsgRequest(msg wire.FtmInternalMsg) error {
 			procLog.Infof("PROCESSOR: End of minute msg - wire.CmdInt_EOM:%+v\n", msg)
 
 			common.FactoidState.EndOfPeriod(int(msgEom.EOM_Type))
+			setMinute(msgEom.EOM_Type, dchain.NextDBHeight)
 
 			if msgEom.EOM_Type == wire.END_MINUTE_10 {
 
@@ -397,7 +565,7 @@ func serveMsgRequest(msg wire.FtmInternalMsg) error {
 		// continue processing commands.
 		msgFactoidTX, ok := msg.(*wire.MsgFactoidTX)
 		if !ok || !msgFactoidTX.IsValid() {
-			break
+			return peerMisbehaved(p2p.InvalidSignature, fmt.Errorf("Invalid factoid transaction"))
 		}
 		// prevent replay attacks
 		{
@@ -413,10 +581,11 @@ func serveMsgRequest(msg wire.FtmInternalMsg) error {
 		if nodeMode == common.SERVER_NODE {
 			t := msgFactoidTX.Transaction
 			txnum := len(common.FactoidState.GetCurrentBlock().GetTransactions())
-			if common.FactoidState.AddTransaction(txnum, t) == nil {
-				if err := processBuyEntryCredit(msgFactoidTX); err != nil {
-					return err
-				}
+			if err := common.FactoidState.AddTransaction(txnum, t); err != nil {
+				return err
+			}
+			if err := processBuyEntryCredit(msgFactoidTX); err != nil {
+				return err
 			}
 		} else {
 			// Handle the client case
@@ -502,7 +671,7 @@ func processAcknowledgement(msg *wire.MsgAcknowledgement) error {
 	if err != nil {
 		return err
 	}
-	if !serverPubKey.Verify(bytes, &msg.Signature) {
+	if !VerifyServerSignature(msg.Height, bytes, &msg.Signature, false) {
 		return errors.New(fmt.Sprintf("Invalid signature in Ack = %s\n", spew.Sdump(msg)))
 	}
 
@@ -516,35 +685,115 @@ func processAcknowledgement(msg *wire.MsgAcknowledgement) error {
 		db.UpdateNextBlockHeightCache(msg.Height)
 	}
 
+	setMinute(msg.Type, msg.Height)
+
 	return nil
 }
 
-// processRevealEntry validates the MsgRevealEntry and adds it to processlist
-func processRevealEntry(msg *wire.MsgRevealEntry) error {
+// revealCheck is the outcome of the checks of a reveal which do not depend
+// on the state of the processor
+type revealCheck struct {
+	msg       *wire.MsgRevealEntry
+	hash      *common.Hash
+	cred      uint8
+	chainIDOK bool                       // the chain id is the one of a first entry
+	rate      *common.ExchangeRateChange // carried by an entry of the exchange rate chain
+	err       error
+}
+
+// checkReveal runs the checks of the reveal which do not depend on the
+// state of the processor. It is called on the worker of the chain, so the
+// reveals of different chains are checked in parallel.
+func checkReveal(msg *wire.MsgRevealEntry) *revealCheck {
+	r := &revealCheck{msg: msg}
+	if !msg.IsValid() {
+		r.err = errors.New("Error in processing msg:" + spew.Sdump(msg))
+		return r
+	}
+
 	e := msg.Entry
-	bin, _ := e.MarshalBinary()
-	h, _ := wire.NewShaHash(e.Hash().Bytes())
+	r.hash = e.Hash()
 
 	// Check if the chain id is valid
 	if e.ChainID.IsSameAs(zeroHash) || e.ChainID.IsSameAs(dchain.ChainID) || e.ChainID.IsSameAs(achain.ChainID) ||
 		e.ChainID.IsSameAs(ecchain.ChainID) || e.ChainID.IsSameAs(fchain.ChainID) {
-		return fmt.Errorf("This entry chain is not supported: %s", e.ChainID.String())
+		r.err = fmt.Errorf("This entry chain is not supported: %s", e.ChainID.String())
+		return r
+	}
+
+	// Calculate the entry credits required for the entry
+	bin, _ := e.MarshalBinary()
+	if r.cred, r.err = util.EntryCost(bin); r.err != nil {
+		return r
 	}
 
-	if c, ok := commitEntryMap[e.Hash().String()]; ok {
+	r.chainIDOK = common.NewChainID(e).IsSameAs(e.ChainID)
+
+	// Only authorized rate changes can be added to the exchange rate chain
+	if isExchangeRateChain(e.ChainID) {
+		r.rate, r.err = parseExchangeRateEntry(e)
+	}
+	return r
+}
+
+var (
+	checkedReveals      []*revealCheck
+	checkedRevealsMutex sync.Mutex
+)
+
+// queueCheckedReveal hands a checked reveal back to the processor
+func queueCheckedReveal(r *revealCheck) {
+	checkedRevealsMutex.Lock()
+	checkedReveals = append(checkedReveals, r)
+	checkedRevealsMutex.Unlock()
+}
+
+// finishReveals processes the reveals checked by the workers, in the order
+// they were checked, and relays the accepted ones. It is called from the
+// processor goroutine.
+func finishReveals() {
+	checkedRevealsMutex.Lock()
+	list := checkedReveals
+	checkedReveals = nil
+	checkedRevealsMutex.Unlock()
+
+	for _, r := range list {
+		err := processRevealEntry(r)
+		settleMessage(r.msg, err)
+		if err != nil {
+			forgetSeen(r.msg)
+			procLog.Error(err)
+			continue
+		}
+		// Broadcast the msg to the network if no errors
+		outMsgQueue <- r.msg
+	}
+}
+
+// processRevealEntry validates the checked MsgRevealEntry against the
+// commits and adds it to processlist
+func processRevealEntry(r *revealCheck) error {
+	if r.err != nil {
+		return r.err
+	}
+	msg := r.msg
+	e := msg.Entry
+	h, _ := wire.NewShaHash(r.hash.Bytes())
+
+	if c, ok := commitEntryMap[r.hash.String()]; ok {
 		if chainIDMap[e.ChainID.String()] == nil {
 			fMemPool.addOrphanMsg(msg, h)
 			return fmt.Errorf("This chain is not supported: %s",
 				msg.Entry.ChainID.String())
 		}
 
-		// Calculate the entry credits required for the entry
-		cred, err := util.EntryCost(bin)
-		if err != nil {
-			return err
+		if r.rate != nil {
+			if err := checkExchangeRateActivation(r.rate, dchain.NextDBHeight); err != nil {
+				return err
+			}
 		}
 
-		if c.Credits < cred {
+		if c.Credits < r.cred {
 			fMemPool.addOrphanMsg(msg, h)
 			return fmt.Errorf("Credit needs to paid first before an entry is revealed: %s", e.Hash().String())
 		}
@@ -570,6 +819,7 @@ func processRevealEntry(msg *wire.MsgRevealEntry) error {
 		}
 
 		delete(commitEntryMap, e.Hash().String())
+		deletePendingMatch(common.PENDING_COMMIT_ENTRY, e.Hash().String())
 		return nil
 	} else if c, ok := commitChainMap[e.Hash().String()]; ok { //Reveal chain ---------------------------
 		if chainIDMap[e.ChainID.String()] != nil {
@@ -584,21 +834,14 @@ func processRevealEntry(msg *wire.MsgRevealEntry) error {
 		newChain.FirstEntry = e
 		chainIDMap[e.ChainID.String()] = newChain
 
-		// Calculate the entry credits required for the entry
-		cred, err := util.EntryCost(bin)
-		if err != nil {
-			return err
-		}
-
 		// 10 credit is additional for the chain creation
-		if c.Credits < cred+10 {
+		if c.Credits < r.cred+10 {
 			fMemPool.addOrphanMsg(msg, h)
 			return fmt.Errorf("Credit needs to paid first before an entry is revealed: %s", e.Hash().String())
 		}
 
 		//validate chain id for the first entry
-		expectedChainID := common.NewChainID(e)
-		if !expectedChainID.IsSameAs(e.ChainID) {
+		if !r.chainIDOK {
 			return fmt.Errorf("Invalid ChainID for entry: %s", e.Hash().String())
 		}
 
@@ -634,9 +877,11 @@ func processRevealEntry(msg *wire.MsgRevealEntry) error {
 		}
 
 		delete(commitChainMap, e.Hash().String())
+		deletePendingMatch(common.PENDING_COMMIT_CHAIN, e.Hash().String())
 		return nil
 	} else {
-		return fmt.Errorf("No commit for entry")
+		// keep the reveal until its commit arrives
+		return addPendingReveal(msg)
 	}
 
 	return nil
@@ -666,7 +911,9 @@ func processCommitEntry(msg *wire.MsgCommitEntry) error {
 	}
 
 	// add to the commitEntryMap
+	evictCommits()
 	commitEntryMap[c.EntryHash.String()] = c
+	savePendingCommit(common.PENDING_COMMIT_ENTRY, c.EntryHash, c)
 
 	// Server: add to MyPL
 	if nodeMode == common.SERVER_NODE {
@@ -689,6 +936,9 @@ func processCommitEntry(msg *wire.MsgCommitEntry) error {
 		}
 	}
 
+	// pair with the reveal that arrived first
+	matchPendingReveal(c.EntryHash)
+
 	return nil
 }
 
@@ -716,7 +966,9 @@ func processCommitChain(msg *wire.MsgCommitChain) error {
 	}
 
 	// add to the commitChainMap
+	evictCommits()
 	commitChainMap[c.EntryHash.String()] = c
+	savePendingCommit(common.PENDING_COMMIT_CHAIN, c.EntryHash, c)
 
 	// Server: add to MyPL
 	if nodeMode == common.SERVER_NODE {
@@ -739,6 +991,9 @@ func processCommitChain(msg *wire.MsgCommitChain) error {
 		}
 	}
 
+	// pair with the reveal that arrived first
+	matchPendingReveal(c.EntryHash)
+
 	return nil
 }
 
@@ -779,7 +1034,7 @@ func processFromOrphanPool() error {
 				procLog.Info("Error in processing orphan msgCommitChain:" + err.Error())
 				continue
 			}
-			delete(fMemPool.orphans, k)
+			fMemPool.deleteOrphanMsg(k)
 
 		case wire.CmdCommitEntry:
 			msgCommitEntry, _ := msg.(*wire.MsgCommitEntry)
@@ -788,16 +1043,16 @@ func processFromOrphanPool() error {
 				procLog.Info("Error in processing orphan msgCommitEntry:" + err.Error())
 				continue
 			}
-			delete(fMemPool.orphans, k)
+			fMemPool.deleteOrphanMsg(k)
 
 		case wire.CmdRevealEntry:
 			msgRevealEntry, _ := msg.(*wire.MsgRevealEntry)
-			err := processRevealEntry(msgRevealEntry)
+			err := processRevealEntry(checkReveal(msgRevealEntry))
 			if err != nil {
 				procLog.Info("Error in processing orphan msgRevealEntry:" + err.Error())
 				continue
 			}
-			delete(fMemPool.orphans, k)
+			fMemPool.deleteOrphanMsg(k)
 		}
 	}
 	return nil
@@ -814,7 +1069,25 @@ func buildRevealEntry(msg *wire.MsgRevealEntry) {
 	if err != nil {
 		panic("Error while adding Entity to Block:" + err.Error())
 	}
+}
 
+// scheduleEBlockEntries schedules the rate changes and identity updates
+// carried by the entries of the entry block, recorded in the dir block at
+// height
+func scheduleEBlockEntries(eb *common.EBlock, height uint32) {
+	chainID := eb.Header.ChainID
+	if !isExchangeRateChain(chainID) && !isIdentityChain(chainID) && !isServerIdentityChain(chainID) {
+		return
+	}
+	for _, h := range eb.Body.EBEntries {
+		if h.IsMinuteMarker() {
+			continue
+		}
+		if e, _ := db.FetchEntryByHash(h); e != nil {
+			scheduleExchangeRateEntry(e, height)
+			scheduleIdentityEntry(e, height)
+		}
+	}
 }
 
 func buildIncreaseBalance(msg *wire.MsgFactoidTX) {
@@ -882,7 +1155,8 @@ func buildEndOfMinute(pl *consensus.ProcessList, pli *consensus.ProcessListItem)
 		}
 	}
 	for _, v := range tmpChains {
-		v.NextBlock.AddEndOfMinuteMarker(pli.Ack.Type)
+		chain := v
+		workers.dispatch(chain.ChainID, func() { chain.NextBlock.AddEndOfMinuteMarker(pli.Ack.Type) })
 	}
 
 	// Add it to the entry credit chain
@@ -935,9 +1209,9 @@ func buildGenesisBlocks() error {
 	dbBlock := newDirectoryBlock(dchain)
 
 	// Check block hash if genesis block
-	if dbBlock.DBHash.String() != common.GENESIS_DIR_BLOCK_HASH {
+	if !isGenesisHash(dbBlock.DBHash.String()) {
 		//Panic for Milestone 1
-		panic("\nGenesis block hash expected: " + common.GENESIS_DIR_BLOCK_HASH +
+		panic("\nGenesis block hash expected: " + netParams.GenesisHash +
 			"\nGenesis block hash found:    " + dbBlock.DBHash.String() + "\n")
 	}
 
@@ -951,6 +1225,7 @@ func buildGenesisBlocks() error {
 
 // build blocks from all process lists
 func buildBlocks() error {
+	defer blockBuildHistogram.Since(time.Now())
 
 	// Allocate the first three dbentries for Admin block, ECBlock and Factoid block
 	dchain.AddDBEntry(&common.DBEntry{}) // AdminBlock
@@ -985,12 +1260,20 @@ func buildBlocks() error {
 	}
 	sort.Strings(keys)
 
-	// Entry Chains
-	for _, k := range keys {
-		chain := chainIDMap[k]
-		eblock := newEntryBlock(chain)
+	// Entry Chains are sealed in parallel and added in chain id order
+	eblocks := make([]*common.EBlock, len(keys))
+	for i, k := range keys {
+		i, chain := i, chainIDMap[k]
+		workers.dispatch(chain.ChainID, func() { eblocks[i] = newEntryBlock(chain) })
+	}
+	workers.wait()
+
+	// the rate changes and identity updates apply in the order of the dir
+	// block, as on the nodes which download it
+	for _, eblock := range eblocks {
 		if eblock != nil {
 			dchain.AddEBlockToDBEntry(eblock)
+			scheduleEBlockEntries(eblock, dchain.NextDBHeight)
 		}
 		exportEBlock(eblock)
 	}
@@ -1011,16 +1294,17 @@ func buildBlocks() error {
 
 	exportDBlock(dbBlock)
 
+	// Save the balances at the block boundary
+	saveBalanceState(dbBlock.Header.DBHeight)
+	recordStateHash(dbBlock.Header.DBHeight)
+	publishBlockEvents(dbBlock)
+
 	// re-initialize the process lit manager
 	initProcessListMgr()
 
 	// Initialize timer for the new dblock
 	if nodeMode == common.SERVER_NODE {
-		timer := &BlockTimer{
-			nextDBlockHeight: dchain.NextDBHeight,
-			inCtlMsgQueue:    inCtlMsgQueue,
-		}
-		go timer.StartBlockTimer()
+		startTimer(dchain.NextDBHeight)
 	}
 
 	// place an anchor into btc
@@ -1039,14 +1323,19 @@ func buildFromProcessList(pl *consensus.ProcessList) error {
 		} else if pli.Ack.Type == wire.ACK_COMMIT_ENTRY {
 			buildCommitEntry(pli.Msg.(*wire.MsgCommitEntry))
 		} else if pli.Ack.Type == wire.ACK_REVEAL_CHAIN {
-			buildRevealChain(pli.Msg.(*wire.MsgRevealEntry))
+			msg := pli.Msg.(*wire.MsgRevealEntry)
+			workers.dispatch(msg.Entry.ChainID, func() { buildRevealChain(msg) })
 		} else if pli.Ack.Type == wire.ACK_REVEAL_ENTRY {
-			buildRevealEntry(pli.Msg.(*wire.MsgRevealEntry))
+			msg := pli.Msg.(*wire.MsgRevealEntry)
+			workers.dispatch(msg.Entry.ChainID, func() { buildRevealEntry(msg) })
 		} else if wire.END_MINUTE_1 <= pli.Ack.Type && pli.Ack.Type <= wire.END_MINUTE_10 {
 			buildEndOfMinute(pl, pli)
 		}
 	}
 
+	// the entry blocks are complete once the workers are done
+	workers.wait()
+
 	return nil
 }
 
@@ -1075,7 +1364,10 @@ func newEntryBlock(chain *common.EChain) *common.EBlock {
 	}
 
 	//Store the block in db
-	db.ProcessEBlockBatch(block)
+	if err := db.ProcessEBlockBatch(block); err != nil {
+		procLog.Error(err)
+		recordDBError(err)
+	}
 	procLog.Infof("EntryBlock: block" + strconv.FormatUint(uint64(block.Header.EBSequence), 10) + " created for chain: " + chain.ChainID.String())
 	return block
 }
@@ -1101,7 +1393,10 @@ func newEntryCreditBlock(chain *common.ECChain) *common.ECBlock {
 	chain.BlockMutex.Unlock()
 
 	//Store the block in db
-	db.ProcessECBlockBatch(block)
+	if err := db.ProcessECBlockBatch(block); err != nil {
+		procLog.Error(err)
+		recordDBError(err)
+	}
 	procLog.Infof("EntryCreditBlock: block" + strconv.FormatUint(uint64(block.Header.EBHeight), 10) + " created for chain: " + chain.ChainID.String())
 
 	return block
@@ -1138,7 +1433,10 @@ func newAdminBlock(chain *common.AdminChain) *common.AdminBlock {
 	chain.BlockMutex.Unlock()
 
 	//Store the block in db
-	db.ProcessABlockBatch(block)
+	if err := db.ProcessABlockBatch(block); err != nil {
+		procLog.Error(err)
+		recordDBError(err)
+	}
 	procLog.Infof("Admin Block: block " + strconv.FormatUint(uint64(block.Header.DBHeight), 10) + " created for chain: " + chain.ChainID.String())
 
 	return block
@@ -1149,8 +1447,8 @@ func newFactoidBlock(chain *common.FctChain) block.IFBlock {
 
 	older := FactoshisPerCredit
 
-	cfg := util.ReReadConfig()
-	FactoshisPerCredit = cfg.App.ExchangeRate
+	// apply the exchange rate changes scheduled for the next block
+	FactoshisPerCredit = activateExchangeRate(dchain.NextDBHeight+1, FactoshisPerCredit)
 
 	rate := fmt.Sprintf("Current Exchange rate is %v",
 		strings.TrimSpace(fct.ConvertDecimal(FactoshisPerCredit)))
@@ -1188,8 +1486,13 @@ func newFactoidBlock(chain *common.FctChain) block.IFBlock {
 	chain.NextBlock = common.FactoidState.GetCurrentBlock()
 	chain.BlockMutex.Unlock()
 
+	updateFctBalances(currentBlock)
+
 	//Store the block in db
-	db.ProcessFBlockBatch(currentBlock)
+	if err := db.ProcessFBlockBatch(currentBlock); err != nil {
+		procLog.Error(err)
+		recordDBError(err)
+	}
 	procLog.Infof("Factoid chain: block " + strconv.FormatUint(uint64(currentBlock.GetDBHeight()), 10) + " created for chain: " + chain.ChainID.String())
 
 	return currentBlock
@@ -1201,11 +1504,7 @@ func newDirectoryBlock(chain *common.DChain) *common.DirectoryBlock {
 	// acquire the last block
 	block := chain.NextBlock
 
-	if devNet {
-		block.Header.NetworkID = common.NETWORK_ID_TEST
-	} else {
-		block.Header.NetworkID = common.NETWORK_ID_EB
-	}
+	block.Header.NetworkID = networkID()
 
 	// Create the block add a new block for new coming entries
 	chain.BlockMutex.Lock()
@@ -1225,11 +1524,14 @@ func newDirectoryBlock(chain *common.DChain) *common.DirectoryBlock {
 	block.BuildKeyMerkleRoot()
 
 	//Store the block in db
-	db.ProcessDBlockBatch(block)
+	if err := db.ProcessDBlockBatch(block); err != nil {
+		procLog.Error(err)
+		recordDBError(err)
+	}
 
 	// Initialize the dirBlockInfo obj in db
 	db.InsertDirBlockInfo(common.NewDirBlockInfoFromDBlock(block))
-	anchor.UpdateDirBlockInfoMap(common.NewDirBlockInfoFromDBlock(block))
+	blockAnchorer.updateDirBlockInfo(common.NewDirBlockInfoFromDBlock(block))
 
 	procLog.Info("DirectoryBlock: block" + strconv.FormatUint(uint64(block.Header.DBHeight), 10) + " created for directory block chain: " + chain.ChainID.String())
 
@@ -1246,9 +1548,8 @@ func SignDirectoryBlock() error {
 		// get the previous directory block from db
 		dbBlock, _ := db.FetchDBlockByHeight(dchain.NextDBHeight - 1)
 		dbHeaderBytes, _ := dbBlock.Header.MarshalBinary()
-		identityChainID := common.NewHash() // 0 ID for milestone 1
 		sig := serverPrivKey.Sign(dbHeaderBytes)
-		achain.NextBlock.AddABEntry(common.NewDBSignatureEntry(identityChainID, sig))
+		achain.NextBlock.AddABEntry(common.NewDBSignatureEntry(serverIdentityChainID, sig))
 	}
 	return nil
 }
@@ -1259,7 +1560,7 @@ func placeAnchor(dbBlock *common.DirectoryBlock) error {
 	if nodeMode == common.SERVER_NODE && dbBlock != nil {
 		// todo: need to make anchor as a go routine, independent of factomd
 		// same as blockmanager to btcd
-		go anchor.SendRawTransactionToBTC(dbBlock.KeyMR, dbBlock.Header.DBHeight)
+		blockAnchorer.place(dbBlock.KeyMR, dbBlock.Header.DBHeight)
 
 	}
 	return nil
@@ -0,0 +1,221 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/p2p"
+	"github.com/FactomProject/btcd/wire"
+	"github.com/FactomProject/factoid/block"
+)
+
+// A follower far behind the network downloads the dir blocks it misses in
+// ranges from several peers at once, through the blocks API of the peers
+// (see wsapi handleBlocks). p2p.Downloader gives the ranges to the fastest
+// peers and takes back the stalled ones. The blocks go through the processor
+// queue like the ones relayed by btcd, and validateAndStoreBlocks validates
+// and stores them in height order.
+
+const (
+	// rangeSyncEvery is how often the ranges are scheduled
+	rangeSyncEvery = time.Second
+
+	// peersHeadEvery is how often the peers are asked for their height
+	peersHeadEvery = time.Minute
+
+	// rangeSyncWait is how long the sync waits for a peer to answer
+	rangeSyncWait = 5 * time.Minute
+)
+
+// startRangeSync downloads the dir blocks from the stored ones up to the
+// height of the peers, if the node is behind. It gives up if no peer
+// answers in rangeSyncWait, and the blocks then come from btcd alone.
+func startRangeSync(queue chan<- wire.FtmInternalMsg) {
+	stateHashMutex.RLock()
+	lister := peerLister
+	stateHashMutex.RUnlock()
+	if lister == nil {
+		return
+	}
+
+	for start := time.Now(); time.Since(start) < rangeSyncWait; time.Sleep(rangeSyncEvery) {
+		_, height, err := db.FetchBlockHeightCache()
+		if err != nil {
+			procLog.Error("Range sync not started: ", err)
+			return
+		}
+		head, ok := peersHead(lister())
+		if !ok {
+			continue
+		}
+		if int64(head) > height {
+			runRangeSync(p2p.StartSync(uint32(height+1), head), lister, queue)
+		}
+		return
+	}
+}
+
+// runRangeSync schedules the ranges of the download on the connected peers
+// until all the dir blocks are received
+func runRangeSync(d *p2p.Downloader, lister func() []string, queue chan<- wire.FtmInternalMsg) {
+	lastHead := time.Now()
+	for !d.Done() {
+		peers := lister()
+		if time.Since(lastHead) > peersHeadEvery {
+			if head, ok := peersHead(peers); ok {
+				d.Extend(head)
+			}
+			lastHead = time.Now()
+		}
+		for _, r := range d.Schedule(peers) {
+			go fetchRange(d, r, queue)
+		}
+		time.Sleep(rangeSyncEvery)
+	}
+	procLog.Info("Range sync done at dir block height ", d.Completed())
+	for _, t := range d.Throughput() {
+		procLog.Infof("Range sync peer %s: %d blocks, %.1f blocks/s, %d stalls",
+			t.Addr, t.Blocks, t.BlocksPerSecond, t.Stalls)
+	}
+}
+
+// peersHead returns the highest dir block height of the peers, and false if
+// none of them answers
+func peersHead(peers []string) (uint32, bool) {
+	type dbheight struct {
+		Height int
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	head, ok := uint32(0), false
+	for _, peer := range peers {
+		api, err := peerAPI(peer)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Get(fmt.Sprintf("http://%s/v1/directory-block-height/", api))
+		if err != nil {
+			procLog.Debug("Height request to ", api, " failed: ", err)
+			continue
+		}
+		h := new(dbheight)
+		err = json.NewDecoder(resp.Body).Decode(h)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK || h.Height < 0 {
+			continue
+		}
+		if !ok || uint32(h.Height) > head {
+			head, ok = uint32(h.Height), true
+		}
+	}
+	return head, ok
+}
+
+// fetchRange downloads the block stream of the range from the peer and
+// queues its blocks to the processor. A dir block is received once the
+// blocks of its height are queued. It stops when the range is given to
+// another peer.
+func fetchRange(d *p2p.Downloader, r p2p.RangeRequest, queue chan<- wire.FtmInternalMsg) {
+	api, err := peerAPI(r.Peer)
+	if err != nil {
+		return
+	}
+	resp, err := http.Get(fmt.Sprintf("http://%s/v1/blocks/?from=%d&to=%d&format=binary", api, r.First, r.Last))
+	if err != nil {
+		procLog.Debug("Range request to ", api, " failed: ", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		procLog.Debug("Range request to ", api, " failed: ", resp.Status)
+		return
+	}
+
+	in := bufio.NewReader(resp.Body)
+	var height uint32
+	queued := false // blocks of height queued
+	for {
+		blockType, data, err := common.ReadStreamBlock(in)
+		if err == io.EOF {
+			if queued {
+				d.Received(r.Peer, height)
+			}
+			return
+		}
+		if err != nil {
+			procLog.Debug("Range from ", api, " broken: ", err)
+			return
+		}
+		msg, err := streamBlockMsg(blockType, data)
+		if err != nil {
+			procLog.Warning("Range from ", api, " has an invalid block: ", err)
+			return
+		}
+
+		if m, ok := msg.(*wire.MsgDirBlock); ok {
+			if queued {
+				d.Received(r.Peer, height)
+			}
+			height, queued = m.DBlk.Header.DBHeight, true
+			if !d.Assigned(r.Peer, height) {
+				return
+			}
+		} else if !queued {
+			procLog.Warning("Range from ", api, " does not start with a dir block")
+			return
+		}
+		queue <- msg
+	}
+}
+
+// streamBlockMsg returns the message of a block read from a block stream
+func streamBlockMsg(blockType byte, data []byte) (wire.FtmInternalMsg, error) {
+	switch blockType {
+	case common.StreamDBlock:
+		b := common.NewDirectoryBlock()
+		if err := b.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return &wire.MsgDirBlock{DBlk: b}, nil
+	case common.StreamABlock:
+		b := new(common.AdminBlock)
+		if err := b.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return &wire.MsgABlock{ABlk: b}, nil
+	case common.StreamECBlock:
+		b := common.NewECBlock()
+		if err := b.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return &wire.MsgECBlock{ECBlock: b}, nil
+	case common.StreamFBlock:
+		b := new(block.FBlock)
+		if err := b.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return &wire.MsgFBlock{SC: b}, nil
+	case common.StreamEBlock:
+		b := common.NewEBlock()
+		if err := b.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return &wire.MsgEBlock{EBlk: b}, nil
+	case common.StreamEntry:
+		e := common.NewEntry()
+		if err := e.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return &wire.MsgEntry{Entry: e}, nil
+	}
+	return nil, fmt.Errorf("Unknown block type %d", blockType)
+}
@@ -0,0 +1,184 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"container/list"
+	"encoding/hex"
+	"sync"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// defaultSeenCacheSize is the number of message hashes remembered when the
+// config does not set it
+const defaultSeenCacheSize = 20000
+
+// seen remembers the hashes of the last messages received, so a message
+// broadcast by several peers is validated only once
+var seen = newSeenCache(defaultSeenCacheSize)
+
+// SeenCacheStats has the size of the seen message cache and how often it
+// found a duplicate
+type SeenCacheStats struct {
+	Size     int
+	Capacity int
+	Hits     uint64
+	Misses   uint64
+}
+
+// seenCache is a bounded set of message hashes, dropping the least
+// recently seen one when full
+type seenCache struct {
+	sync.Mutex
+	capacity int
+	order    *list.List // most recently seen first
+	hashes   map[[common.HASH_LENGTH]byte]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+func newSeenCache(capacity int) *seenCache {
+	return &seenCache{
+		capacity: capacity,
+		order:    list.New(),
+		hashes:   make(map[[common.HASH_LENGTH]byte]*list.Element),
+	}
+}
+
+// add adds the hash, and returns true if it was already in the cache
+func (c *seenCache) add(h *common.Hash) bool {
+	var key [common.HASH_LENGTH]byte
+	copy(key[:], h.Bytes())
+
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.hashes[key]; ok {
+		c.order.MoveToFront(e)
+		c.hits++
+		return true
+	}
+	c.misses++
+	c.hashes[key] = c.order.PushFront(key)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.hashes, oldest.Value.([common.HASH_LENGTH]byte))
+	}
+	return false
+}
+
+// remove removes the hash from the cache
+func (c *seenCache) remove(h *common.Hash) {
+	var key [common.HASH_LENGTH]byte
+	copy(key[:], h.Bytes())
+
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.hashes[key]; ok {
+		c.order.Remove(e)
+		delete(c.hashes, key)
+	}
+}
+
+// keys returns the hashes in the cache as hex, the least recently seen
+// first
+func (c *seenCache) keys() []string {
+	c.Lock()
+	defer c.Unlock()
+	keys := make([]string, 0, c.order.Len())
+	for e := c.order.Back(); e != nil; e = e.Prev() {
+		key := e.Value.([common.HASH_LENGTH]byte)
+		keys = append(keys, hex.EncodeToString(key[:]))
+	}
+	return keys
+}
+
+// restore adds the hashes, the least recently seen first, without counting
+// them as hits or misses
+func (c *seenCache) restore(keys [][common.HASH_LENGTH]byte) {
+	c.Lock()
+	defer c.Unlock()
+	for _, key := range keys {
+		if e, ok := c.hashes[key]; ok {
+			c.order.MoveToFront(e)
+			continue
+		}
+		c.hashes[key] = c.order.PushFront(key)
+		if c.order.Len() > c.capacity {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.hashes, oldest.Value.([common.HASH_LENGTH]byte))
+		}
+	}
+}
+
+func (c *seenCache) stats() SeenCacheStats {
+	c.Lock()
+	defer c.Unlock()
+	return SeenCacheStats{
+		Size:     c.order.Len(),
+		Capacity: c.capacity,
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+}
+
+// IsDuplicate tells if the commit, reveal, factoid transaction or ack was
+// already received, and remembers it if not. Other messages are never
+// duplicates. A message rejected by the processor is forgotten with
+// forgetSeen, so it can be submitted again.
+func IsDuplicate(msg wire.FtmInternalMsg) bool {
+	h := seenHash(msg)
+	if h == nil {
+		return false
+	}
+	if seen.add(h) {
+		duplicateCounter.Inc("hit")
+		return true
+	}
+	duplicateCounter.Inc("miss")
+	return false
+}
+
+// forgetSeen removes the message from the seen cache
+func forgetSeen(msg wire.FtmInternalMsg) {
+	if h := seenHash(msg); h != nil {
+		seen.remove(h)
+	}
+}
+
+// seenHash returns the hash of the message in the seen cache, or nil if it
+// is not a commit, reveal, factoid transaction or ack
+func seenHash(msg wire.FtmInternalMsg) *common.Hash {
+	var p []byte
+	var err error
+	switch m := msg.(type) {
+	case *wire.MsgCommitChain:
+		p, err = m.CommitChain.MarshalBinary()
+	case *wire.MsgCommitEntry:
+		p, err = m.CommitEntry.MarshalBinary()
+	case *wire.MsgRevealEntry:
+		p, err = m.Entry.MarshalBinary()
+	case *wire.MsgFactoidTX:
+		p, err = m.Transaction.MarshalBinary()
+	case *wire.MsgAcknowledgement:
+		p, err = m.GetBinaryForSignature()
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	// the command keeps apart messages with the same content
+	return common.Sha(append([]byte(msg.Command()), p...))
+}
+
+// GetSeenCacheStats returns the size and hit counts of the seen message
+// cache
+func GetSeenCacheStats() SeenCacheStats {
+	return seen.stats()
+}
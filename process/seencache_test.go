@@ -0,0 +1,35 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"testing"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/btcd/wire"
+)
+
+func TestForgetSeen(t *testing.T) {
+	seen = newSeenCache(2)
+
+	e := common.NewEntry()
+	e.Content = []byte("seen")
+	msg := &wire.MsgRevealEntry{Entry: e}
+	if IsDuplicate(msg) {
+		t.Fatal("first reveal is a duplicate")
+	}
+	if !IsDuplicate(msg) {
+		t.Fatal("second reveal is not a duplicate")
+	}
+
+	// a rejected message can be submitted again
+	forgetSeen(msg)
+	if IsDuplicate(msg) {
+		t.Error("forgotten reveal is still a duplicate")
+	}
+	if s := GetSeenCacheStats(); s.Size != 1 || s.Hits != 1 || s.Misses != 2 {
+		t.Errorf("wrong seen cache stats %+v", s)
+	}
+}
@@ -0,0 +1,452 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/consensus"
+	"github.com/FactomProject/FactomCode/database"
+	"github.com/FactomProject/btcd/wire"
+	"github.com/FactomProject/factoid/state"
+	"github.com/FactomProject/factoid/state/stateinit"
+)
+
+// The simulation package runs a leader and its followers inside one process
+// on the code of the processor. The processor keeps its state in package
+// variables, so a simulated node holds its own copy of that state and swaps
+// it in while it handles a message. The nodes of a simulation share the
+// config and run on one goroutine. The leader builds its blocks on the end
+// of minute messages of the simulation instead of a timer, and does not
+// anchor them, as its state holds no timer and no anchorer; the followers
+// store the blocks served by the leader like the ones downloaded from peers.
+
+// simQueueSize is the size of the queue of the messages a simulated node
+// sends while it handles one message
+const simQueueSize = 10000
+
+// nodeState is the state of the processor of one simulated node
+type nodeState struct {
+	db                    database.Db
+	nodeMode              string
+	dataStorePath         string
+	ldbpath               string
+	homeDir               string
+	outMsgQueue           chan wire.FtmInternalMsg
+	factoidState          state.IFactoidState
+	dchain                *common.DChain
+	ecchain               *common.ECChain
+	achain                *common.AdminChain
+	fchain                *common.FctChain
+	chainIDMap            map[string]*common.EChain
+	commitChainMap        map[string]*common.CommitChain
+	commitEntryMap        map[string]*common.CommitEntry
+	eCreditMap            map[string]int32
+	chainIDMapBackup      map[string]*common.EChain
+	eCreditMapBackup      map[string]int32
+	fMemPool              *ftmMemPool
+	plMgr                 *consensus.ProcessListMgr
+	lastDirBlockTimestamp uint32
+	serverPrivKey         common.PrivateKey
+	serverPubKey          common.PublicKey
+	factoshisPerCredit    uint64
+	workers               *chainWorkers
+	checkers              *chainWorkers
+	checkedReveals        []*revealCheck
+	fctBalances           map[string]uint64
+	balanceSnapshot       *common.BalanceState
+	identities            map[string]*serverIdentity
+	pendingExchangeRates  []*common.ExchangeRateChange
+	pendingReveals        map[string]*wire.MsgRevealEntry
+	pendingRevealTimes    map[string]time.Time
+	buckets               [numBuckets]map[[32]byte]int64
+	lasttime              int64
+	seen                  *seenCache
+	restored              *stateSnapshot
+	boundaryStates        [2]*common.BalanceState
+	dchainValidated       bool
+	stateHashes           map[uint32]*common.Hash
+	lastStateHeight       uint32
+	lastInvalidDBHeight   int64
+	corruption            *CorruptionStatus
+	fastSyncState         FastSyncStatus
+	lastPending           *PendingView
+	lastExpiry            time.Time
+	lastMemPoolStats      MemPoolStats
+	exchangeRateChainID   *common.Hash
+	exchangeRateAuthority map[string]bool
+	identityChainID       *common.Hash
+	identityAuthority     map[string]bool
+	serverIdentityChainID *common.Hash
+	subscriptions         map[*Subscription]bool
+	currentMinute         uint8
+	lastMinute            uint8
+	lastMinuteHeight      uint32
+	lastProcessorState    processorState
+	dbSize                int64
+	dbSizeMeasured        time.Time
+	lastDBError           error
+	lastDBErrorTime       time.Time
+	lastProgress          time.Time
+	lastProgressHeight    int64
+	lastProgressMinute    uint8
+	lastRecovery          time.Time
+	stallCause            string
+	stallCount            int
+	blockAnchorer         anchorer
+	startTimer            func(height uint32)
+}
+
+// swap exchanges the state with the one of the processor, so swapping twice
+// restores both
+func (s *nodeState) swap() {
+	s.db, db = db, s.db
+	s.nodeMode, nodeMode = nodeMode, s.nodeMode
+	s.dataStorePath, dataStorePath = dataStorePath, s.dataStorePath
+	s.ldbpath, ldbpath = ldbpath, s.ldbpath
+	s.homeDir, homeDir = homeDir, s.homeDir
+	s.outMsgQueue, outMsgQueue = outMsgQueue, s.outMsgQueue
+	s.factoidState, common.FactoidState = common.FactoidState, s.factoidState
+	s.dchain, dchain = dchain, s.dchain
+	s.ecchain, ecchain = ecchain, s.ecchain
+	s.achain, achain = achain, s.achain
+	s.fchain, fchain = fchain, s.fchain
+	s.chainIDMap, chainIDMap = chainIDMap, s.chainIDMap
+	s.commitChainMap, commitChainMap = commitChainMap, s.commitChainMap
+	s.commitEntryMap, commitEntryMap = commitEntryMap, s.commitEntryMap
+	s.eCreditMap, eCreditMap = eCreditMap, s.eCreditMap
+	s.chainIDMapBackup, chainIDMapBackup = chainIDMapBackup, s.chainIDMapBackup
+	s.eCreditMapBackup, eCreditMapBackup = eCreditMapBackup, s.eCreditMapBackup
+	s.fMemPool, fMemPool = fMemPool, s.fMemPool
+	s.plMgr, plMgr = plMgr, s.plMgr
+	s.lastDirBlockTimestamp, lastDirBlockTimestamp = lastDirBlockTimestamp, s.lastDirBlockTimestamp
+	s.serverPrivKey, serverPrivKey = serverPrivKey, s.serverPrivKey
+	s.serverPubKey, serverPubKey = serverPubKey, s.serverPubKey
+	s.factoshisPerCredit, FactoshisPerCredit = FactoshisPerCredit, s.factoshisPerCredit
+	s.workers, workers = workers, s.workers
+	s.checkers, checkers = checkers, s.checkers
+	s.checkedReveals, checkedReveals = checkedReveals, s.checkedReveals
+	s.fctBalances, fctBalances = fctBalances, s.fctBalances
+	s.balanceSnapshot, balanceSnapshot = balanceSnapshot, s.balanceSnapshot
+	s.identities, identities = identities, s.identities
+	s.pendingExchangeRates, pendingExchangeRates = pendingExchangeRates, s.pendingExchangeRates
+	s.pendingReveals, pendingReveals = pendingReveals, s.pendingReveals
+	s.pendingRevealTimes, pendingRevealTimes = pendingRevealTimes, s.pendingRevealTimes
+	s.buckets, buckets = buckets, s.buckets
+	s.lasttime, lasttime = lasttime, s.lasttime
+	s.seen, seen = seen, s.seen
+	s.restored, restored = restored, s.restored
+	s.boundaryStates, boundaryStates = boundaryStates, s.boundaryStates
+	s.dchainValidated, dchainValidated = dchainValidated, s.dchainValidated
+	s.stateHashes, stateHashes = stateHashes, s.stateHashes
+	s.lastStateHeight, lastStateHeight = lastStateHeight, s.lastStateHeight
+	s.lastInvalidDBHeight, lastInvalidDBHeight = lastInvalidDBHeight, s.lastInvalidDBHeight
+	s.corruption, corruption = corruption, s.corruption
+	s.fastSyncState, fastSyncState = fastSyncState, s.fastSyncState
+	s.lastPending, lastPending = lastPending, s.lastPending
+	s.lastExpiry, lastExpiry = lastExpiry, s.lastExpiry
+	s.lastMemPoolStats, lastMemPoolStats = lastMemPoolStats, s.lastMemPoolStats
+	s.exchangeRateChainID, exchangeRateChainID = exchangeRateChainID, s.exchangeRateChainID
+	s.exchangeRateAuthority, exchangeRateAuthority = exchangeRateAuthority, s.exchangeRateAuthority
+	s.identityChainID, identityChainID = identityChainID, s.identityChainID
+	s.identityAuthority, identityAuthority = identityAuthority, s.identityAuthority
+	s.serverIdentityChainID, serverIdentityChainID = serverIdentityChainID, s.serverIdentityChainID
+	s.subscriptions, subscriptions = subscriptions, s.subscriptions
+	s.currentMinute, currentMinute = currentMinute, s.currentMinute
+	s.lastMinute, lastMinute = lastMinute, s.lastMinute
+	s.lastMinuteHeight, lastMinuteHeight = lastMinuteHeight, s.lastMinuteHeight
+	s.lastProcessorState, lastProcessorState = lastProcessorState, s.lastProcessorState
+	s.dbSize, dbSize = dbSize, s.dbSize
+	s.dbSizeMeasured, dbSizeMeasured = dbSizeMeasured, s.dbSizeMeasured
+	s.lastDBError, lastDBError = lastDBError, s.lastDBError
+	s.lastDBErrorTime, lastDBErrorTime = lastDBErrorTime, s.lastDBErrorTime
+	s.lastProgress, lastProgress = lastProgress, s.lastProgress
+	s.lastProgressHeight, lastProgressHeight = lastProgressHeight, s.lastProgressHeight
+	s.lastProgressMinute, lastProgressMinute = lastProgressMinute, s.lastProgressMinute
+	s.lastRecovery, lastRecovery = lastRecovery, s.lastRecovery
+	s.stallCause, stallCause = stallCause, s.stallCause
+	s.stallCount, stallCount = stallCount, s.stallCount
+	s.blockAnchorer, blockAnchorer = blockAnchorer, s.blockAnchorer
+	s.startTimer, startTimer = startTimer, s.startTimer
+}
+
+// SimNode is a leader or follower of a simulation
+type SimNode struct {
+	name   string
+	dir    string
+	starts int // times the processor of the node was started
+	state  nodeState
+}
+
+// NewSimNode starts a node of a simulation on the database ldb, with its
+// files in dir, as the leader if leader is set or else as a follower. The
+// config of the simulation must be loaded with LoadConfigurations.
+func NewSimNode(name, dir string, ldb database.Db, leader bool) (*SimNode, error) {
+	n := &SimNode{name: name, dir: dir}
+	mode := common.FULL_NODE
+	if leader {
+		mode = common.SERVER_NODE
+	}
+	if err := n.start(ldb, mode); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// start starts the processor of the node from its database, like factomd
+// restarted in the mode. The state of the processor is new but for the
+// config and the chain workers, which keep running.
+func (n *SimNode) start(ldb database.Db, mode string) error {
+	n.starts++
+	n.state = nodeState{
+		db:                  ldb,
+		nodeMode:            mode,
+		dataStorePath:       filepath.Join(n.dir, "export") + "/",
+		ldbpath:             filepath.Join(n.dir, "ldb"),
+		homeDir:             n.dir,
+		outMsgQueue:         make(chan wire.FtmInternalMsg, simQueueSize),
+		factoidState:        stateinit.NewFactoidState(filepath.Join(n.dir, fmt.Sprintf("factoid_bolt.%d.db", n.starts))),
+		commitChainMap:      make(map[string]*common.CommitChain),
+		commitEntryMap:      make(map[string]*common.CommitEntry),
+		factoshisPerCredit:  FactoshisPerCredit,
+		fctBalances:         make(map[string]uint64),
+		identities:          make(map[string]*serverIdentity),
+		pendingReveals:      make(map[string]*wire.MsgRevealEntry),
+		pendingRevealTimes:  make(map[string]time.Time),
+		seen:                newSeenCache(defaultSeenCacheSize),
+		stateHashes:         make(map[uint32]*common.Hash),
+		lastInvalidDBHeight: -1,
+		fastSyncState:       FastSyncStatus{Deferring: true, DeferredHeight: -1, ValidatedHeight: -1},
+		lastPending:         &PendingView{Commits: make([]*PendingItem, 0), Reveals: make([]*PendingItem, 0), FactoidTxs: make([]*PendingItem, 0)},
+		subscriptions:       make(map[*Subscription]bool),
+		lastProgress:        time.Now(),
+		blockAnchorer:       noAnchorer{},
+		startTimer:          func(height uint32) {},
+
+		// kept from the config and the previous start
+		workers:               n.state.workers,
+		checkers:              n.state.checkers,
+		exchangeRateChainID:   exchangeRateChainID,
+		exchangeRateAuthority: exchangeRateAuthority,
+		identityChainID:       identityChainID,
+		identityAuthority:     identityAuthority,
+		serverIdentityChainID: serverIdentityChainID,
+	}
+	return n.run(func() error {
+		initProcessor()
+		return nil
+	})
+}
+
+// run runs f with the state of the node swapped in. A panic of the
+// processor is returned as an error.
+func (n *SimNode) run(f func() error) (err error) {
+	n.state.swap()
+	defer n.state.swap()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", n.name, r)
+		}
+	}()
+	return f()
+}
+
+// drain returns the messages the node sent
+func (n *SimNode) drain() []wire.FtmInternalMsg {
+	var msgs []wire.FtmInternalMsg
+	for {
+		select {
+		case msg := <-n.state.outMsgQueue:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func (n *SimNode) Name() string {
+	return n.name
+}
+
+// IsLeader returns true if the node is the leader
+func (n *SimNode) IsLeader() bool {
+	return n.state.nodeMode == common.SERVER_NODE
+}
+
+// Height returns the number of dir blocks stored by the node
+func (n *SimNode) Height() uint32 {
+	_, head, _ := n.state.db.FetchBlockHeightCache()
+	return uint32(head + 1)
+}
+
+// DBlock returns the stored dir block at height
+func (n *SimNode) DBlock(height uint32) (*common.DirectoryBlock, error) {
+	return n.state.db.FetchDBlockByHeight(height)
+}
+
+// EBlock returns the entry block stored by the node with the key merkle
+// root
+func (n *SimNode) EBlock(keyMR *common.Hash) (*common.EBlock, error) {
+	return n.state.db.FetchEBlockByMR(keyMR)
+}
+
+// Fund gives credits to the entry credit key on the leader, like a purchase
+// sealed in its next entry credit block. No factoid transaction pays them,
+// so the followers must not run with FullValidation.
+func (n *SimNode) Fund(key *[32]byte, credits uint32) error {
+	return n.run(func() error {
+		if nodeMode != common.SERVER_NODE {
+			return fmt.Errorf("%s is not the leader", n.name)
+		}
+		ib := common.NewIncreaseBalance()
+		ib.ECPubKey = key
+		ib.NumEC = uint64(credits)
+		ecchain.NextBlock.AddEntry(ib)
+		eCreditMap[string(key[:])] += int32(credits)
+		return nil
+	})
+}
+
+// Deliver makes the node process a message like one received from a peer.
+// A follower then stores the dir blocks it has all the blocks of. It
+// returns the messages the node sent to its peers.
+func (n *SimNode) Deliver(msg wire.FtmInternalMsg) ([]wire.FtmInternalMsg, error) {
+	err := n.run(func() error {
+		err := serveMsgRequest(msg)
+		checkers.wait()
+		finishReveals()
+		if nodeMode != common.SERVER_NODE {
+			storeDBlocks()
+		}
+		return err
+	})
+	return n.drain(), err
+}
+
+// storeDBlocks stores the downloaded dir blocks after the last stored one,
+// as long as they are complete and valid
+func storeDBlocks() {
+	for {
+		_, head, _ := db.FetchBlockHeightCache()
+		next := head + 1
+		if int64(len(dchain.Blocks)) <= next || dchain.Blocks[next] == nil ||
+			!storeDBlock(dchain.Blocks[next], fMemPool, db) {
+			return
+		}
+	}
+}
+
+// EndMinute makes the leader end the minute of its open dir block, like its
+// timer. The tenth minute builds the blocks. It returns the messages the
+// leader sent to its peers.
+func (n *SimNode) EndMinute(minute byte) ([]wire.FtmInternalMsg, error) {
+	err := n.run(func() error {
+		if nodeMode != common.SERVER_NODE {
+			return fmt.Errorf("%s is not the leader", n.name)
+		}
+		if minute == wire.END_MINUTE_1 {
+			// the dir blocks are ten minutes apart from the genesis block
+			t, _ := time.Parse(time.RFC3339, common.GENESIS_BLK_TIMESTAMP)
+			dchain.NextBlock.Header.Timestamp = uint32(t.Unix()/60) + 10*dchain.NextDBHeight
+		}
+		return serveMsgRequest(&wire.MsgInt_EOM{
+			EOM_Type:         minute,
+			NextDBlockHeight: dchain.NextDBHeight,
+		})
+	})
+	return n.drain(), err
+}
+
+// Serve returns the messages of the dir blocks stored from height on and
+// of their blocks and entries, as a peer sends them to a node syncing up
+func (n *SimNode) Serve(height uint32) ([]wire.FtmInternalMsg, error) {
+	var msgs []wire.FtmInternalMsg
+	err := n.run(func() error {
+		_, head, err := db.FetchBlockHeightCache()
+		if err != nil {
+			return err
+		}
+		for h := int64(height); h <= head; h++ {
+			b, err := db.FetchDBlockByHeight(uint32(h))
+			if err != nil || b == nil {
+				return fmt.Errorf("Dir block %d not found: %v", h, err)
+			}
+			blocks, err := blockMessages(b)
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, &wire.MsgDirBlock{DBlk: b})
+			msgs = append(msgs, blocks...)
+		}
+		return nil
+	})
+	return msgs, err
+}
+
+// blockMessages returns the messages of the blocks and entries of the dir
+// block stored in db
+func blockMessages(b *common.DirectoryBlock) ([]wire.FtmInternalMsg, error) {
+	var msgs []wire.FtmInternalMsg
+	for _, dbEntry := range b.DBEntries {
+		switch dbEntry.ChainID.String() {
+		case ecchain.ChainID.String():
+			ecBlock, err := db.FetchECBlockByHash(dbEntry.KeyMR)
+			if err != nil || ecBlock == nil {
+				return nil, fmt.Errorf("Entry Credit Block %s not found: %v", dbEntry.KeyMR, err)
+			}
+			msgs = append(msgs, &wire.MsgECBlock{ECBlock: ecBlock})
+		case achain.ChainID.String():
+			aBlock, err := db.FetchABlockByHash(dbEntry.KeyMR)
+			if err != nil || aBlock == nil {
+				return nil, fmt.Errorf("Admin Block %s not found: %v", dbEntry.KeyMR, err)
+			}
+			msgs = append(msgs, &wire.MsgABlock{ABlk: aBlock})
+		case fchain.ChainID.String():
+			fBlock, err := db.FetchFBlockByHash(dbEntry.KeyMR)
+			if err != nil || fBlock == nil {
+				return nil, fmt.Errorf("Factoid Block %s not found: %v", dbEntry.KeyMR, err)
+			}
+			msgs = append(msgs, &wire.MsgFBlock{SC: fBlock})
+		default:
+			eBlock, err := db.FetchEBlockByMR(dbEntry.KeyMR)
+			if err != nil || eBlock == nil {
+				return nil, fmt.Errorf("Entry Block %s not found: %v", dbEntry.KeyMR, err)
+			}
+			msgs = append(msgs, &wire.MsgEBlock{EBlk: eBlock})
+			for _, h := range eBlock.Body.EBEntries {
+				if h.IsMinuteMarker() {
+					continue
+				}
+				entry, err := db.FetchEntryByHash(h)
+				if err != nil || entry == nil {
+					return nil, fmt.Errorf("Entry %s not found: %v", h, err)
+				}
+				msgs = append(msgs, &wire.MsgEntry{Entry: entry})
+			}
+		}
+	}
+	return msgs, nil
+}
+
+// Promote restarts a follower as the leader on its database, which is how
+// a follower takes over from a dead leader
+func (n *SimNode) Promote() error {
+	if n.IsLeader() {
+		return nil
+	}
+	return n.start(n.state.db, common.SERVER_NODE)
+}
+
+// Close stops the chain workers of the node and closes its database
+func (n *SimNode) Close() error {
+	if n.state.workers != nil {
+		n.state.workers.stop()
+		n.state.checkers.stop()
+	}
+	return n.state.db.Close()
+}
@@ -0,0 +1,301 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/factoid/block"
+)
+
+// On a clean shutdown the processor saves its runtime state to a snapshot
+// file, so the next start restores the chain heads, the balances and the
+// seen message cache instead of replaying every block. The snapshot is used
+// only if it was saved at the dir block stored last in the database, and it
+// is removed once read so a crash never restores a stale one.
+
+const snapshotFile = "state.snapshot"
+
+// stateSnapshot is the runtime state at the last stored dir block
+type stateSnapshot struct {
+	DBHeight  uint32
+	DBHash    string
+	Validated bool // the dir chain was validated
+
+	// Balances is the balance state at the dir block before DBHeight. The
+	// blocks at DBHeight are replayed on it to restore the open factoid
+	// block, like with the balance states in the database.
+	Balances []byte
+
+	Chains []chainHead
+	Seen   []string // message hashes, the oldest first
+
+	// the blocks at DBHeight, fetched when the snapshot is loaded
+	ecBlock *common.ECBlock
+	aBlock  *common.AdminBlock
+	fBlock  block.IFBlock
+	heads   map[string]chainHead
+}
+
+// chainHead is the height and the last block of an entry chain
+type chainHead struct {
+	ChainID         string
+	NextBlockHeight uint32
+	KeyMR           string // last entry block, empty if none
+}
+
+var (
+	// restored is the snapshot restored at startup, nil if none
+	restored *stateSnapshot
+
+	// boundaryStates are the balance states of the last two dir blocks,
+	// the older first
+	boundaryStates [2]*common.BalanceState
+
+	// dchainValidated tells if the dir chain was validated at startup
+	dchainValidated bool
+)
+
+func snapshotPath() string {
+	return filepath.Join(homeDir, snapshotFile)
+}
+
+// rememberBalanceState keeps the balance state of the last dir block
+func rememberBalanceState(state *common.BalanceState) {
+	boundaryStates[0], boundaryStates[1] = boundaryStates[1], state
+}
+
+// initBoundaryStates sets the balance states of the last two dir blocks
+// once the chains are loaded
+func initBoundaryStates() {
+	boundaryStates = [2]*common.BalanceState{}
+	if dchain.NextDBHeight == 0 {
+		return
+	}
+	head := dchain.NextDBHeight - 1
+	if balanceSnapshot != nil && balanceSnapshot.DBHeight+1 == head {
+		boundaryStates[0] = balanceSnapshot
+	}
+	boundaryStates[1] = currentBalanceState(head)
+}
+
+// saveStateSnapshot writes the runtime state to the snapshot file. Nothing
+// is saved if the state is not at the last stored dir block.
+func saveStateSnapshot() {
+	if dchain == nil || dchain.NextDBHeight < 2 {
+		return
+	}
+	hash, head, err := db.FetchBlockHeightCache()
+	if err != nil || hash == nil || head != int64(dchain.NextDBHeight-1) {
+		procLog.Warning("State snapshot not saved, the dir chain is not at the last stored dir block")
+		return
+	}
+	prev, last := boundaryStates[0], boundaryStates[1]
+	if prev == nil || last == nil || int64(last.DBHeight) != head || prev.DBHeight+1 != last.DBHeight {
+		procLog.Warning("State snapshot not saved, the balances are not at the last stored dir block")
+		return
+	}
+	balances, err := prev.MarshalBinary()
+	if err != nil {
+		procLog.Error("State snapshot not saved: ", err)
+		return
+	}
+
+	s := &stateSnapshot{
+		DBHeight:  uint32(head),
+		DBHash:    hash.String(),
+		Validated: dchainValidated,
+		Balances:  balances,
+		Chains:    make([]chainHead, 0, len(chainIDMap)),
+		Seen:      seen.keys(),
+	}
+	for _, chain := range chainIDMap {
+		chain.BlockMutex.Lock()
+		h := chainHead{ChainID: chain.ChainID.String(), NextBlockHeight: chain.NextBlockHeight}
+		if chain.NextBlockHeight > 0 && chain.NextBlock != nil {
+			h.KeyMR = chain.NextBlock.Header.PrevKeyMR.String()
+		}
+		chain.BlockMutex.Unlock()
+		s.Chains = append(s.Chains, h)
+	}
+
+	p, err := json.Marshal(s)
+	if err != nil {
+		procLog.Error("State snapshot not saved: ", err)
+		return
+	}
+	path := snapshotPath()
+	if err := ioutil.WriteFile(path+".tmp", p, 0600); err != nil {
+		procLog.Error("State snapshot not saved: ", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		procLog.Error("State snapshot not saved: ", err)
+		return
+	}
+	procLog.Info("Saved the state snapshot at height ", head)
+}
+
+// loadStateSnapshot reads and removes the snapshot file, and keeps it in
+// restored if it matches the last stored dir block
+func loadStateSnapshot() {
+	restored = nil
+	path := snapshotPath()
+	p, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	os.Remove(path)
+	if err != nil {
+		procLog.Error("Cannot read the state snapshot: ", err)
+		return
+	}
+
+	s := new(stateSnapshot)
+	if err := json.Unmarshal(p, s); err != nil {
+		procLog.Error("Invalid state snapshot: ", err)
+		return
+	}
+	hash, head, err := db.FetchBlockHeightCache()
+	if err != nil || hash == nil || head != int64(s.DBHeight) || hash.String() != s.DBHash ||
+		dchain.NextDBHeight != s.DBHeight+1 {
+		procLog.Warning("State snapshot at height ", s.DBHeight, " does not match the database, replaying the blocks")
+		return
+	}
+
+	balances := common.NewBalanceState()
+	if err := balances.UnmarshalBinary(s.Balances); err != nil || balances.DBHeight+1 != s.DBHeight {
+		procLog.Error("Invalid balances in the state snapshot: ", err)
+		return
+	}
+	s.ecBlock, _ = db.FetchECBlockByHeight(s.DBHeight)
+	s.aBlock, _ = db.FetchABlockByHeight(s.DBHeight)
+	s.fBlock, _ = db.FetchFBlockByHeight(s.DBHeight)
+	if s.ecBlock == nil || s.aBlock == nil || s.fBlock == nil {
+		procLog.Warning("Blocks at height ", s.DBHeight, " of the state snapshot not found, replaying the blocks")
+		return
+	}
+	s.heads = make(map[string]chainHead, len(s.Chains))
+	for _, h := range s.Chains {
+		s.heads[h.ChainID] = h
+	}
+
+	balanceSnapshot = balances
+	restored = s
+	procLog.Info("Restoring the state snapshot at height ", s.DBHeight)
+}
+
+// restoreECChain initializes the Entry Credit Chain from the restored
+// snapshot, replaying only the last block
+func restoreECChain() {
+	eCreditMap = make(map[string]int32)
+	ecchain = common.NewECChain()
+
+	for k, v := range balanceSnapshot.ECBalances {
+		eCreditMap[k] = v
+	}
+	initializeECreditMap(restored.ecBlock)
+
+	ecchain.NextBlockHeight = dchain.NextDBHeight
+	var err error
+	ecchain.NextBlock, err = common.NextECBlock(restored.ecBlock)
+	if err != nil {
+		panic(err)
+	}
+	exportECChain(ecchain)
+}
+
+// restoreAChain initializes the Admin Chain from the restored snapshot. The
+// signatures were checked before the snapshot was saved.
+func restoreAChain() {
+	achain = new(common.AdminChain)
+	achain.ChainID = new(common.Hash)
+	achain.ChainID.SetBytes(common.ADMIN_CHAINID)
+
+	achain.NextBlockHeight = dchain.NextDBHeight
+	achain.NextBlock, _ = common.CreateAdminBlock(achain, restored.aBlock, 10)
+	exportAChain(achain)
+}
+
+// restoreFctChain initializes the Factoid Chain from the restored snapshot,
+// replaying only the last block
+func restoreFctChain() {
+	fchain = new(common.FctChain)
+	fchain.ChainID = new(common.Hash)
+	fchain.ChainID.SetBytes(common.FACTOID_CHAINID)
+
+	restoreFctBalances()
+	FactoshisPerCredit = restored.fBlock.GetExchRate()
+	common.FactoidState.SetFactoshisPerEC(FactoshisPerCredit)
+	if err := common.FactoidState.AddTransactionBlock(restored.fBlock); err != nil {
+		panic("Failed to rebuild factoid state: " + err.Error())
+	}
+	updateFctBalances(restored.fBlock)
+
+	fchain.NextBlockHeight = dchain.NextDBHeight
+	common.FactoidState.ProcessEndOfBlock2(dchain.NextDBHeight)
+	fchain.NextBlock = common.FactoidState.GetCurrentBlock()
+	exportFctChain(fchain)
+}
+
+// restoreEChain initializes the entry chain from its head in the restored
+// snapshot, and returns false if it has to be loaded from db
+func restoreEChain(chain *common.EChain) bool {
+	if restored == nil {
+		return false
+	}
+	h, ok := restored.heads[chain.ChainID.String()]
+	if !ok {
+		return false
+	}
+	// a follower fetches the missing first entry in initEChainFromDB
+	if nodeMode != common.SERVER_NODE && chain.FirstEntry == nil && h.NextBlockHeight > 0 {
+		return false
+	}
+
+	var last *common.EBlock
+	if h.NextBlockHeight > 0 {
+		mr, err := common.HexToHash(h.KeyMR)
+		if err != nil {
+			return false
+		}
+		last, _ = db.FetchEBlockByMR(mr)
+		if last == nil || last.Header.EBSequence != h.NextBlockHeight-1 {
+			return false
+		}
+	}
+
+	chain.NextBlockHeight = h.NextBlockHeight
+	next, err := common.MakeEBlock(chain, last)
+	if err != nil {
+		return false
+	}
+	chain.NextBlock = next
+	return true
+}
+
+// restoreSeenCache adds the message hashes of the restored snapshot to the
+// seen message cache
+func restoreSeenCache() {
+	if restored == nil {
+		return
+	}
+	keys := make([][common.HASH_LENGTH]byte, 0, len(restored.Seen))
+	for _, s := range restored.Seen {
+		p, err := hex.DecodeString(s)
+		if err != nil || len(p) != common.HASH_LENGTH {
+			continue
+		}
+		var key [common.HASH_LENGTH]byte
+		copy(key[:], p)
+		keys = append(keys, key)
+	}
+	seen.restore(keys)
+}
@@ -0,0 +1,195 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	cp "github.com/FactomProject/FactomCode/controlpanel"
+)
+
+// stateHashHistory is the number of state hashes kept in memory
+const stateHashHistory = 1000
+
+var (
+	// peerLister returns the addresses of the connected peers, whose state
+	// hashes are compared with ours, set by factomd
+	peerLister func() []string
+
+	stateHashes     = make(map[uint32]*common.Hash)
+	lastStateHeight uint32
+	stateHashMutex  sync.RWMutex
+)
+
+// computeStateHash returns a hash of the balances, the chain heads and the
+// entry credit ledger after the dir block at height. Every map is walked in
+// sorted key order, so the same state always produces the same hash.
+func computeStateHash(height uint32) *common.Hash {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, height)
+
+	// entry credit balances sorted by public key
+	keys := make([]string, 0, len(eCreditMap))
+	for k := range eCreditMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	binary.Write(buf, binary.BigEndian, uint32(len(keys)))
+	for _, k := range keys {
+		buf.WriteString(k)
+		binary.Write(buf, binary.BigEndian, eCreditMap[k])
+	}
+
+	// factoid balances sorted by address
+	keys = make([]string, 0, len(fctBalances))
+	for k := range fctBalances {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	binary.Write(buf, binary.BigEndian, uint32(len(keys)))
+	for _, k := range keys {
+		buf.WriteString(k)
+		binary.Write(buf, binary.BigEndian, fctBalances[k])
+	}
+
+	// heads of the chains with a block, sorted by chain id. The chains
+	// revealed since the last block have none yet.
+	keys = make([]string, 0, len(chainIDMap))
+	for k := range chainIDMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		chainID := chainIDMap[k].ChainID
+		if head, _ := db.FetchHeadMRByChainID(chainID); head != nil {
+			buf.Write(chainID.Bytes())
+			buf.Write(head.Bytes())
+		}
+	}
+
+	// entry credit ledger
+	if ecBlock, _ := db.FetchECBlockByHeight(height); ecBlock != nil {
+		if h, err := ecBlock.HeaderHash(); err == nil {
+			buf.Write(h.Bytes())
+		}
+	}
+
+	return common.Sha(buf.Bytes())
+}
+
+// recordStateHash computes and keeps the state hash of the dir block at
+// height, and compares the previous one with the peers
+func recordStateHash(height uint32) {
+	h := computeStateHash(height)
+
+	stateHashMutex.Lock()
+	stateHashes[height] = h
+	lastStateHeight = height
+	if height >= stateHashHistory {
+		delete(stateHashes, height-stateHashHistory)
+	}
+	lister := peerLister
+	stateHashMutex.Unlock()
+
+	procLog.Debugf("State hash at block height %d: %s", height, h.String())
+
+	// peers may not have the newest block yet
+	if height > 0 && lister != nil {
+		if prev, ok := GetStateHash(height - 1); ok {
+			go compareStateHash(height-1, prev, lister())
+		}
+	}
+}
+
+// SetPeerLister sets the function returning the addresses of the connected
+// peers
+func SetPeerLister(f func() []string) {
+	stateHashMutex.Lock()
+	defer stateHashMutex.Unlock()
+	peerLister = f
+}
+
+// GetStateHash returns the state hash of the dir block at height
+func GetStateHash(height uint32) (*common.Hash, bool) {
+	stateHashMutex.RLock()
+	defer stateHashMutex.RUnlock()
+
+	h, ok := stateHashes[height]
+	return h, ok
+}
+
+// GetLatestStateHash returns the state hash of the last dir block
+func GetLatestStateHash() (uint32, *common.Hash) {
+	stateHashMutex.RLock()
+	defer stateHashMutex.RUnlock()
+
+	return lastStateHeight, stateHashes[lastStateHeight]
+}
+
+// peerAPI returns the address of the API of the peer, at the api port of the
+// network
+func peerAPI(peer string) (string, error) {
+	host, _, err := net.SplitHostPort(peer)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(netParams.APIPort)), nil
+}
+
+// compareStateHash asks the peers for their state hash at height through
+// their state-hash API, and raises an alert on any mismatch. The peers which
+// do not answer are skipped.
+func compareStateHash(height uint32, h *common.Hash, peers []string) {
+	type stateHash struct {
+		Height    uint32
+		StateHash string
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, peer := range peers {
+		api, err := peerAPI(peer)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Get(fmt.Sprintf("http://%s/v1/state-hash/%d", api, height))
+		if err != nil {
+			procLog.Debug("State hash request to ", api, " failed: ", err)
+			continue
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+
+		s := new(stateHash)
+		if err := json.Unmarshal(body, s); err != nil || s.Height != height {
+			continue
+		}
+
+		if s.StateHash != h.String() {
+			msg := fmt.Sprintf("State hash at block height %d is %s, peer %s has %s",
+				height, h.String(), api, s.StateHash)
+			procLog.Error(msg)
+			cp.CP.AddUpdate(
+				"StateHash",        // tag
+				"warning",          // Category
+				"State Divergence", // Title
+				msg,                // Message
+				0)                  // Expire
+		}
+	}
+}
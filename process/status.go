@@ -0,0 +1,219 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/anchor"
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// dbSizeInterval is how long the measured database size is reused
+const dbSizeInterval = time.Minute
+
+var (
+	startTime = time.Now()
+
+	// currentMinute is the number of minutes ended in the open dir block
+	currentMinute uint8
+
+	// lastMinuteHeight and lastMinute are the last end of minute published,
+	// read by the watchdog
+	minuteMutex      sync.Mutex
+	lastMinuteHeight uint32
+	lastMinute       uint8
+
+	// peerCount returns the number of connected peers, set by factomd with
+	// watchdogMutex held
+	peerCount func() int
+
+	dbSizeLock     sync.Mutex
+	dbSize         int64
+	dbSizeMeasured time.Time
+
+	lastProcessorState      processorState
+	lastProcessorStateMutex sync.Mutex
+)
+
+// processorState is the part of the node status owned by the processor
+// goroutine
+type processorState struct {
+	nextDBHeight    uint32
+	minute          uint8
+	processListSize int
+	memPool         MemPoolStats
+}
+
+// NodeStatus is the state of the node for monitoring
+type NodeStatus struct {
+	Network            string // mainnet, testnet or localnet
+	Role               string // leader or follower
+	Height             uint32 // last stored dir block
+	NetworkHeight      uint32 // highest dir block known from the network
+	Synced             bool
+	Minute             uint8
+	ProcessListSize    int   // items in the process list of the open dir block
+	PeerCount          int   // -1 if unknown
+	LastAnchoredHeight int64 // -1 if no dir block is anchored
+	DatabaseSize       int64 // bytes
+	UptimeSeconds      int64
+	MemPool            MemPoolStats
+	SeenCache          SeenCacheStats
+	FastSync           FastSyncStatus
+	Watchdog           WatchdogStatus
+	Corruption         *CorruptionStatus `json:",omitempty"` // found on startup
+}
+
+// SetPeerCounter sets the function returning the number of connected peers
+func SetPeerCounter(f func() int) {
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+	peerCount = f
+}
+
+// setMinute records the end of a minute of the open dir block at height and
+// publishes it to the subscribers
+func setMinute(eomType byte, height uint32) {
+	if eomType < wire.END_MINUTE_1 || eomType > wire.END_MINUTE_10 {
+		return
+	}
+	minute := eomType - wire.END_MINUTE_1 + 1
+	currentMinute = minute % 10
+
+	// a follower may see the end of a minute more than once
+	minuteMutex.Lock()
+	seen := height == lastMinuteHeight && minute == lastMinute
+	lastMinuteHeight, lastMinute = height, minute
+	minuteMutex.Unlock()
+	if seen {
+		return
+	}
+	publishEvent(&Event{
+		Type:      EventMinute,
+		DBHeight:  height,
+		Minute:    minute,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// publishedMinute returns the last end of minute published
+func publishedMinute() uint8 {
+	minuteMutex.Lock()
+	defer minuteMutex.Unlock()
+	return lastMinute
+}
+
+// IsLeader tells if the node is the server acknowledging the messages
+func IsLeader() bool {
+	return nodeMode == common.SERVER_NODE
+}
+
+// GetNodeStatus returns the current state of the node
+func GetNodeStatus() *NodeStatus {
+	s := new(NodeStatus)
+	s.Network = netParams.Name
+	if IsLeader() {
+		s.Role = "leader"
+	} else {
+		s.Role = "follower"
+	}
+
+	if _, height, err := db.FetchBlockHeightCache(); err == nil && height >= 0 {
+		s.Height = uint32(height)
+	}
+	p := getProcessorState()
+	s.NetworkHeight = s.Height
+	if p.nextDBHeight > 0 && p.nextDBHeight-1 > s.NetworkHeight {
+		s.NetworkHeight = p.nextDBHeight - 1
+	}
+	if next := db.FetchNextBlockHeightCache(); next > 0 && uint32(next-1) > s.NetworkHeight {
+		s.NetworkHeight = uint32(next - 1)
+	}
+	s.Synced = s.Height >= s.NetworkHeight
+
+	s.Minute = p.minute
+	s.ProcessListSize = p.processListSize
+	s.PeerCount = -1
+	watchdogMutex.Lock()
+	count := peerCount
+	watchdogMutex.Unlock()
+	if count != nil {
+		s.PeerCount = count()
+	}
+	s.LastAnchoredHeight = anchor.LastAnchoredHeight()
+	s.DatabaseSize = databaseSize()
+	s.UptimeSeconds = int64(time.Since(startTime).Seconds())
+	s.MemPool = p.memPool
+	s.SeenCache = GetSeenCacheStats()
+	s.FastSync = GetFastSyncStatus()
+	s.Watchdog = GetWatchdogStatus()
+	s.Corruption = GetCorruptionStatus()
+	return s
+}
+
+// getProcessorState returns the state of the processor, read on its
+// goroutine, or the last one read if it is busy
+func getProcessorState() processorState {
+	if !onProcessor(func() {
+		p := processorState{
+			minute:          currentMinute,
+			processListSize: processListSize(),
+			memPool:         memPoolStats(),
+		}
+		if dchain != nil {
+			p.nextDBHeight = dchain.NextDBHeight
+		}
+		lastProcessorStateMutex.Lock()
+		lastProcessorState = p
+		lastProcessorStateMutex.Unlock()
+	}) {
+		procLog.Warning("The processor is busy, returning its last state")
+	}
+
+	lastProcessorStateMutex.Lock()
+	defer lastProcessorStateMutex.Unlock()
+	return lastProcessorState
+}
+
+// processListSize returns the number of items in the process list. It must
+// be called from the processor goroutine.
+func processListSize() int {
+	items := 0
+	if plMgr != nil && plMgr.MyProcessList != nil {
+		for _, item := range plMgr.MyProcessList.GetPLItems() {
+			if item != nil {
+				items++
+			}
+		}
+	}
+	return items
+}
+
+// databaseSize returns the size of the files of the database, measured at
+// most once every dbSizeInterval
+func databaseSize() int64 {
+	dbSizeLock.Lock()
+	defer dbSizeLock.Unlock()
+
+	if time.Since(dbSizeMeasured) < dbSizeInterval {
+		return dbSize
+	}
+
+	var size int64
+	filepath.Walk(ldbpath, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	dbSize = size
+	dbSizeMeasured = time.Now()
+	return dbSize
+}
@@ -8,9 +8,11 @@ import (
 	"bytes"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/FactomProject/FactomCode/common"
 	cp "github.com/FactomProject/FactomCode/controlpanel"
 	"github.com/FactomProject/FactomCode/database"
+	"github.com/FactomProject/FactomCode/p2p"
 	"github.com/FactomProject/btcd/wire"
 	"github.com/FactomProject/go-spew/spew"
 	"strconv"
@@ -35,9 +37,18 @@ func processDirBlock(msg *wire.MsgDirBlock) error {
 			"Directory Block Overlap",                                            // Title
 			"DBlock already exists for height:"+string(msg.DBlk.Header.DBHeight), // Message
 			0) // Expire
+		if isStaleHeight(msg.DBlk.Header.DBHeight) {
+			return peerMisbehaved(p2p.StaleHeight, fmt.Errorf("Dir block %d is far below the stored ones", msg.DBlk.Header.DBHeight))
+		}
 		return nil
 	}
 
+	// refuse a peer serving a chain that contradicts the checkpoints
+	if err := checkCheckpoint(msg.DBlk); err != nil {
+		reportCheckpointFailure(err, msg.DBlk.Header.DBHeight)
+		return err
+	}
+
 	msg.DBlk.IsSealed = true
 	dchain.AddDBlockToDChain(msg.DBlk)
 
@@ -196,14 +207,7 @@ func validateAndStoreBlocks(fMemPool *ftmMemPool, db database.Db, dchain *common
 			dblk = dchain.Blocks[myDBHeight+1]
 		}
 		if dblk != nil {
-			if validateBlocksFromMemPool(dblk, fMemPool, db) {
-				err := storeBlocksFromMemPool(dblk, fMemPool, db)
-				if err == nil {
-					deleteBlocksFromMemPool(dblk, fMemPool)
-				} else {
-					panic("error in storeBlocksFromMemPool. " + err.Error())
-				}
-			} else {
+			if !storeDBlock(dblk, fMemPool, db) {
 				time.Sleep(time.Duration(sleeptime * 1000000)) // Nanoseconds for duration
 			}
 		} else {
@@ -216,8 +220,9 @@ func validateAndStoreBlocks(fMemPool *ftmMemPool, db database.Db, dchain *common
 			} else {
 				time.Sleep(time.Duration(sleeptime * 1000000)) // Nanoseconds for duration
 				// this means, there could be a syncup breakage happened, and let's renew syncup.
+				// A range sync reassigns the missing blocks itself.
 				//startHash, _ := wire.NewShaHash(dbhash.Bytes())
-				if dbhash != nil {
+				if d := p2p.Syncing(); dbhash != nil && (d == nil || d.Done()) {
 					outMsgQueue <- &wire.MsgInt_ReSyncup{
 						StartHash: dbhash,
 					}
@@ -229,20 +234,55 @@ func validateAndStoreBlocks(fMemPool *ftmMemPool, db database.Db, dchain *common
 
 }
 
+// storeDBlock validates the dir block and its blocks in mem pool, and
+// stores them in db. It returns false if they are not all downloaded yet or
+// not valid.
+func storeDBlock(dblk *common.DirectoryBlock, fMemPool *ftmMemPool, db database.Db) bool {
+	if !validateBlocksFromMemPool(dblk, fMemPool, db) {
+		return false
+	}
+	if err := storeBlocksFromMemPool(dblk, fMemPool, db); err != nil {
+		panic("error in storeBlocksFromMemPool. " + err.Error())
+	}
+	deleteBlocksFromMemPool(dblk, fMemPool)
+	return true
+}
+
 // Validate the new blocks in mem pool and store them in db
 func validateBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool, db database.Db) bool {
 
+	// Refuse the dir blocks of another network
+	if b.Header.NetworkID != networkID() {
+		procLog.Errorf("Dir block %d is of network id %d, not of %s", b.Header.DBHeight, b.Header.NetworkID, netParams.Name)
+		return false
+	}
+
 	// Validate the genesis block
 	if b.Header.DBHeight == 0 {
 		h, _ := common.CreateHash(b)
-		if h.String() != common.GENESIS_DIR_BLOCK_HASH {
+		if !isGenesisHash(h.String()) {
 			// panic for milestone 1
-			panic("\nGenesis block hash expected: " + common.GENESIS_DIR_BLOCK_HASH +
+			panic("\nGenesis block hash expected: " + netParams.GenesisHash +
 				"\nGenesis block hash found:    " + h.String() + "\n")
 			//procLog.Errorf("Genesis dir block is not as expected: " + h.String())
 		}
 	}
 
+	if err := checkCheckpoint(b); err != nil {
+		reportCheckpointFailure(err, b.Header.DBHeight)
+		return false
+	}
+	if err := checkLinkage(b, db); err != nil {
+		reportCheckpointFailure(err, b.Header.DBHeight)
+		return false
+	}
+
+	// fast sync checks the signature and the contents later, and the
+	// blocks covered by the checkpoints are trusted. The linkage is still
+	// checked, as it ties the blocks between the checkpoints to them.
+	deferred := deferValidation(b)
+	trusted := belowLastCheckpoint(b.Header.DBHeight)
+
 	fMemPool.RLock()
 	defer fMemPool.RUnlock()
 
@@ -258,7 +298,7 @@ func validateBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool, d
 			} else {
 				// validate signature of the previous dir block
 				aBlkMsg, _ := msg.(*wire.MsgABlock)
-				if !validateDBSignature(aBlkMsg.ABlk, dchain) {
+				if !deferred && !trusted && !validateDBSignature(aBlkMsg.ABlk, dchain) {
 					return false
 				}
 			}
@@ -287,12 +327,24 @@ func validateBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool, d
 		}
 	}
 
+	// Auditors re-validate every object in the blocks before storing them
+	if fullValidation && !deferred && !trusted {
+		if err := fullyValidateBlocks(b, fMemPool, db); err != nil {
+			reportInvalidBlock(err, b.Header.DBHeight)
+			return false
+		}
+	}
+
+	if deferred {
+		setDeferredHeight(b.Header.DBHeight)
+	}
 	return true
 }
 
 // Validate the new blocks in mem pool and store them in db
 // Need to make a batch insert in db in milestone 2
 func storeBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool, db database.Db) error {
+	defer blockStoreHistogram.Since(time.Now())
 	fMemPool.RLock()
 	defer fMemPool.RUnlock()
 
@@ -328,6 +380,7 @@ func storeBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool, db d
 			if err != nil {
 				return err
 			}
+			updateFctBalances(fBlkMsg.SC)
 
 			// for debugging
 			exportFctBlock(fBlkMsg.SC)
@@ -343,6 +396,7 @@ func storeBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool, db d
 					}
 				}
 			}
+			scheduleEBlockEntries(eBlkMsg.EBlk, b.Header.DBHeight)
 			// Store Entry Block in db
 			err := db.ProcessEBlockBatch(eBlkMsg.EBlk)
 			if err != nil {
@@ -382,6 +436,11 @@ func storeBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool, db d
 	commonHash, _ := common.CreateHash(b)
 	db.UpdateBlockHeightCache(b.Header.DBHeight, commonHash)
 
+	// Save the balances at the block boundary
+	saveBalanceState(b.Header.DBHeight)
+	recordStateHash(b.Header.DBHeight)
+	publishBlockEvents(b)
+
 	// for debugging
 	exportDBlock(b)
 
@@ -398,6 +457,21 @@ func storeBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool, db d
 	return nil
 }
 
+// requestResync asks the peers for the dir blocks after the last stored
+// one. It does not wait for a full queue.
+func requestResync() {
+	dbhash, _, _ := db.FetchBlockHeightCache()
+	if dbhash == nil {
+		return
+	}
+	select {
+	case outMsgQueue <- &wire.MsgInt_ReSyncup{StartHash: dbhash}:
+		procLog.Info("Requesting the dir blocks after ", dbhash.String())
+	default:
+		procLog.Debug("Cannot request the dir blocks, the queue is full")
+	}
+}
+
 // Validate the new blocks in mem pool and store them in db
 func deleteBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool) error {
 
@@ -425,6 +499,16 @@ func deleteBlocksFromMemPool(b *common.DirectoryBlock, fMemPool *ftmMemPool) err
 }
 
 func validateDBSignature(aBlock *common.AdminBlock, dchain *common.DChain) bool {
+	var prev *common.DirectoryBlock
+	if h := aBlock.Header.DBHeight; h > 0 && int(h) <= len(dchain.Blocks) {
+		prev = dchain.Blocks[h-1]
+	}
+	return verifyDBSignature(aBlock, prev)
+}
+
+// verifyDBSignature tells if the admin block holds the signature of the
+// previous dir block prev by a federated server, or is the genesis one
+func verifyDBSignature(aBlock *common.AdminBlock, prev *common.DirectoryBlock) bool {
 
 	dbSigEntry := aBlock.GetDBSignature()
 	if dbSigEntry == nil {
@@ -435,17 +519,15 @@ func validateDBSignature(aBlock *common.AdminBlock, dchain *common.DChain) bool
 		}
 	} else {
 		dbSig := dbSigEntry.(*common.DBSignatureEntry)
-		if serverPubKey.String() != dbSig.PubKey.String() {
+		if dbSig.PubKey.Key == nil || !isServerKey(dbSig.PubKey.Key, aBlock.Header.DBHeight) {
 			return false
 		} else {
-			// obtain the previous directory block
-			dblk := dchain.Blocks[aBlock.Header.DBHeight-1]
-			if dblk == nil {
+			if prev == nil {
 				return false
 			} else {
 				// validatet the signature
-				bHeader, _ := dblk.Header.MarshalBinary()
-				if !serverPubKey.Verify(bHeader, (*[64]byte)(dbSig.PrevDBSig)) {
+				bHeader, _ := prev.Header.MarshalBinary()
+				if !dbSig.PubKey.Verify(bHeader, (*[64]byte)(dbSig.PrevDBSig)) {
 					procLog.Infof("No valid signature found in Admin Block = %s\n", spew.Sdump(aBlock))
 					return false
 				}
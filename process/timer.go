@@ -5,10 +5,22 @@
 package process
 
 import (
+	"github.com/FactomProject/FactomCode/p2p"
 	"github.com/FactomProject/btcd/wire"
 	"time"
 )
 
+// startTimer starts the timer of the server ending the minutes of the open
+// dir block at height. A simulated server ends its minutes on request
+// instead.
+var startTimer = func(height uint32) {
+	timer := &BlockTimer{
+		nextDBlockHeight: height,
+		inCtlMsgQueue:    inCtlMsgQueue,
+	}
+	go timer.StartBlockTimer()
+}
+
 // BlockTimer is set to sent End-Of-Minute messages to processor
 type BlockTimer struct {
 	nextDBlockHeight uint32
@@ -37,6 +49,7 @@ func (bt *BlockTimer) StartBlockTimer() {
 			}
 
 			//send the end-of-minute message to processor
+			p2p.NoteOrigin(eomMsg, p2p.OriginTimer)
 			bt.inCtlMsgQueue <- eomMsg
 
 			time.Sleep(time.Duration(sleeptime * 1000000000))
@@ -67,6 +80,7 @@ func (bt *BlockTimer) StartBlockTimer() {
 		}
 
 		//send the end-of-minute message to processor
+		p2p.NoteOrigin(eomMsg, p2p.OriginTimer)
 		bt.inCtlMsgQueue <- eomMsg
 
 		minutesPassed++
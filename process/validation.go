@@ -0,0 +1,374 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/common"
+	cp "github.com/FactomProject/FactomCode/controlpanel"
+	"github.com/FactomProject/FactomCode/database"
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/FactomProject/btcd/wire"
+	fct "github.com/FactomProject/factoid"
+	"github.com/FactomProject/factoid/block"
+)
+
+// commitLookBack is the number of previous ECBlocks searched for the commit
+// of a revealed entry
+const commitLookBack = 144
+
+var (
+	// fullValidation makes a follower re-validate every factoid transaction,
+	// commit, reveal and factoid purchase before it stores a block
+	fullValidation bool
+
+	// lastInvalidDBHeight keeps the height of the last block that failed the
+	// full validation so the failure is only reported once
+	lastInvalidDBHeight = int64(-1)
+)
+
+// ValidationError describes which object in a block broke which rule
+type ValidationError struct {
+	DBHeight uint32
+	Object   string
+	Rule     string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("Block %d: %s failed rule: %s", e.DBHeight, e.Object, e.Rule)
+}
+
+func newValidationError(height uint32, object string, format string, a ...interface{}) error {
+	return &ValidationError{
+		DBHeight: height,
+		Object:   object,
+		Rule:     fmt.Sprintf(format, a...),
+	}
+}
+
+// fullyValidateBlocks independently re-validates all the blocks referenced by
+// the dir block against the local state before they can be stored.
+// The caller holds the read lock of the mem pool.
+func fullyValidateBlocks(b *common.DirectoryBlock, fMemPool *ftmMemPool, db database.Db) error {
+	height := b.Header.DBHeight
+
+	var ecBlock *common.ECBlock
+	var fBlock block.IFBlock
+	var eBlocks []*common.EBlock
+
+	for _, dbEntry := range b.DBEntries {
+		msg := fMemPool.blockpool[dbEntry.KeyMR.String()]
+		switch dbEntry.ChainID.String() {
+		case ecchain.ChainID.String():
+			ecBlock = msg.(*wire.MsgECBlock).ECBlock
+		case achain.ChainID.String():
+		case fchain.ChainID.String():
+			fBlock = msg.(*wire.MsgFBlock).SC
+		default:
+			eBlocks = append(eBlocks, msg.(*wire.MsgEBlock).EBlk)
+		}
+	}
+
+	if ecBlock == nil {
+		return newValidationError(height, "Directory Block", "missing Entry Credit Block")
+	}
+	if fBlock == nil {
+		return newValidationError(height, "Directory Block", "missing Factoid Block")
+	}
+
+	// the factoid state holds the balances before the block
+	if err := validateFBlock(height, fBlock, make(map[string]int64), factoidStateBalance); err != nil {
+		return err
+	}
+
+	balances := make(map[string]int32)
+	for k, v := range eCreditMap {
+		balances[k] = v
+	}
+	commitEntries, commitChains, err := validateECBlock(height, ecBlock, fBlock, balances)
+	if err != nil {
+		return err
+	}
+
+	for _, eb := range eBlocks {
+		if err := validateEBlock(height, eb, commitEntries, commitChains, fMemPool, db); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateFBlock checks the signatures, the amounts and the fee of every
+// factoid transaction and that its inputs are funded, spending the balances
+// keyed by address. The balance of an address missing from balances is
+// taken from stateBalance, or is 0 if it is nil.
+func validateFBlock(height uint32, fBlock block.IFBlock, balances map[string]int64, stateBalance func(fct.IAddress) int64) error {
+	balance := func(adr fct.IAddress) int64 {
+		k := string(adr.Bytes())
+		if _, ok := balances[k]; !ok && stateBalance != nil {
+			balances[k] = stateBalance(adr)
+		}
+		return balances[k]
+	}
+
+	rate := fBlock.GetExchRate()
+	for i, t := range fBlock.GetTransactions() {
+		object := fmt.Sprintf("Factoid Transaction %x", t.GetHash().Bytes())
+		if err := t.Validate(i); err != nil {
+			return newValidationError(height, object, "%s", err.Error())
+		}
+		// the coinbase transaction has no inputs to sign
+		if i > 0 {
+			if err := t.ValidateSignatures(); err != nil {
+				return newValidationError(height, object, "%s", err.Error())
+			}
+			fee, err := t.CalculateFee(rate)
+			if err != nil {
+				return newValidationError(height, object, "%s", err.Error())
+			}
+			in, _ := t.TotalInputs()
+			out, _ := t.TotalOutputs()
+			ecs, _ := t.TotalECs()
+			if in < out+ecs+fee {
+				return newValidationError(height, object, "inputs of %d do not pay the outputs of %d and the fee of %d", in, out+ecs, fee)
+			}
+		}
+
+		spent := make(map[string]int64)
+		for _, input := range t.GetInputs() {
+			adr := input.GetAddress()
+			spent[string(adr.Bytes())] += int64(input.GetAmount())
+			if need := spent[string(adr.Bytes())]; balance(adr) < need {
+				return newValidationError(height, object, "balance %d of %x is less than %d", balance(adr), adr.Bytes(), need)
+			}
+		}
+		for k, v := range spent {
+			balances[k] -= v
+		}
+		for _, output := range t.GetOutputs() {
+			balances[string(output.GetAddress().Bytes())] = balance(output.GetAddress()) + int64(output.GetAmount())
+		}
+	}
+	return nil
+}
+
+// factoidStateBalance returns the balance of the address in the factoid state
+func factoidStateBalance(adr fct.IAddress) int64 {
+	return int64(common.FactoidState.GetBalance(adr))
+}
+
+// applyFBlock updates the factoid balances with the transactions of the
+// block
+func applyFBlock(balances map[string]int64, fBlock block.IFBlock) {
+	for _, t := range fBlock.GetTransactions() {
+		for _, input := range t.GetInputs() {
+			balances[string(input.GetAddress().Bytes())] -= int64(input.GetAmount())
+		}
+		for _, output := range t.GetOutputs() {
+			balances[string(output.GetAddress().Bytes())] += int64(output.GetAmount())
+		}
+	}
+}
+
+// validateECBlock checks the signature and the balance of every commit and
+// that every balance increase is paid by the factoid block, updating the
+// balances before the block. It returns the commits found in the block
+// keyed by entry hash.
+func validateECBlock(height uint32, ecBlock *common.ECBlock, fBlock block.IFBlock, balances map[string]int32) (map[string]*common.CommitEntry, map[string]*common.CommitChain, error) {
+	commitEntries := make(map[string]*common.CommitEntry)
+	commitChains := make(map[string]*common.CommitChain)
+
+	// EC outputs of the factoid block keyed by txid and output index
+	purchases := make(map[string]uint64)
+	for _, t := range fBlock.GetTransactions() {
+		for i, ecout := range t.GetECOutputs() {
+			purchases[fmt.Sprintf("%x:%d", t.GetHash().Bytes(), i)] = ecout.GetAmount()
+		}
+	}
+
+	for _, entry := range ecBlock.Body.Entries {
+		switch entry.ECID() {
+		case common.ECIDChainCommit:
+			c := entry.(*common.CommitChain)
+			object := "CommitChain " + c.EntryHash.String()
+			if !c.IsValid() {
+				return nil, nil, newValidationError(height, object, "invalid signature or credits")
+			}
+			if c.Credits > common.MAX_CHAIN_CREDITS {
+				return nil, nil, newValidationError(height, object, "%d credits exceed the limit of %d", c.Credits, common.MAX_CHAIN_CREDITS)
+			}
+			if balances[string(c.ECPubKey[:])] < int32(c.Credits) {
+				return nil, nil, newValidationError(height, object, "balance %d of %x is less than %d credits",
+					balances[string(c.ECPubKey[:])], c.ECPubKey[:], c.Credits)
+			}
+			balances[string(c.ECPubKey[:])] -= int32(c.Credits)
+			commitChains[c.EntryHash.String()] = c
+		case common.ECIDEntryCommit:
+			c := entry.(*common.CommitEntry)
+			object := "CommitEntry " + c.EntryHash.String()
+			if !c.IsValid() {
+				return nil, nil, newValidationError(height, object, "invalid signature or credits")
+			}
+			if c.Credits > common.MAX_ENTRY_CREDITS {
+				return nil, nil, newValidationError(height, object, "%d credits exceed the limit of %d", c.Credits, common.MAX_ENTRY_CREDITS)
+			}
+			if balances[string(c.ECPubKey[:])] < int32(c.Credits) {
+				return nil, nil, newValidationError(height, object, "balance %d of %x is less than %d credits",
+					balances[string(c.ECPubKey[:])], c.ECPubKey[:], c.Credits)
+			}
+			balances[string(c.ECPubKey[:])] -= int32(c.Credits)
+			commitEntries[c.EntryHash.String()] = c
+		case common.ECIDBalanceIncrease:
+			e := entry.(*common.IncreaseBalance)
+			object := fmt.Sprintf("IncreaseBalance %s:%d", e.TXID.String(), e.Index)
+			amount, ok := purchases[fmt.Sprintf("%x:%d", e.TXID.Bytes(), e.Index)]
+			if !ok {
+				return nil, nil, newValidationError(height, object, "no matching EC output in the Factoid Block")
+			}
+			if rate := fBlock.GetExchRate(); rate == 0 || e.NumEC != amount/rate {
+				return nil, nil, newValidationError(height, object, "%d credits do not match %d factoshis at rate %d", e.NumEC, amount, rate)
+			}
+			balances[string(e.ECPubKey[:])] += int32(e.NumEC)
+		}
+	}
+
+	return commitEntries, commitChains, nil
+}
+
+// validateEBlock checks the chain rules and that every entry in the block
+// was paid for by a commit
+func validateEBlock(height uint32, eb *common.EBlock, commitEntries map[string]*common.CommitEntry,
+	commitChains map[string]*common.CommitChain, fMemPool *ftmMemPool, db database.Db) error {
+
+	chainID := eb.Header.ChainID
+	if chainID.IsSameAs(zeroHash) || chainID.IsSameAs(dchain.ChainID) || chainID.IsSameAs(achain.ChainID) ||
+		chainID.IsSameAs(ecchain.ChainID) || chainID.IsSameAs(fchain.ChainID) {
+		return newValidationError(height, "Entry Block "+chainID.String(), "reserved chain id")
+	}
+
+	first := true
+	for _, ebEntry := range eb.Body.EBEntries {
+		// skip the minute markers
+		if bytes.Equal(ebEntry.Bytes()[:31], common.ZERO_HASH[:31]) {
+			continue
+		}
+
+		var entry *common.Entry
+		if msg, ok := fMemPool.blockpool[ebEntry.String()]; ok {
+			entry = msg.(*wire.MsgEntry).Entry
+		} else {
+			entry, _ = db.FetchEntryByHash(ebEntry)
+		}
+
+		object := "Entry " + ebEntry.String()
+		if entry == nil {
+			return newValidationError(height, object, "entry not found")
+		}
+		if !entry.Hash().IsSameAs(ebEntry) {
+			return newValidationError(height, object, "entry hash does not match")
+		}
+		if !entry.ChainID.IsSameAs(chainID) {
+			return newValidationError(height, object, "entry belongs to chain %s", entry.ChainID.String())
+		}
+
+		bin, _ := entry.MarshalBinary()
+		cost, err := util.EntryCost(bin)
+		if err != nil {
+			return newValidationError(height, object, "%s", err.Error())
+		}
+
+		// the first entry of a new chain is paid by a chain commit
+		if first && eb.Header.EBSequence == 0 {
+			first = false
+			if !common.NewChainID(entry).IsSameAs(chainID) {
+				return newValidationError(height, object, "chain id does not match the first entry")
+			}
+			c, ok := commitChains[ebEntry.String()]
+			if !ok {
+				c = findCommitChain(height, ebEntry, db)
+			}
+			if c == nil {
+				return newValidationError(height, object, "no CommitChain for the first entry")
+			}
+			chainIDHash := common.DoubleSha(chainID.Bytes())
+			if !bytes.Equal(c.ChainIDHash.Bytes(), chainIDHash) {
+				return newValidationError(height, object, "chain id hash does not match the CommitChain")
+			}
+			weld := common.DoubleSha(append(c.EntryHash.Bytes(), chainID.Bytes()...))
+			if !bytes.Equal(c.Weld.Bytes(), weld) {
+				return newValidationError(height, object, "weld does not match the CommitChain")
+			}
+			if c.Credits < cost+10 {
+				return newValidationError(height, object, "%d committed credits are less than the cost of %d", c.Credits, cost+10)
+			}
+			continue
+		}
+		first = false
+
+		c, ok := commitEntries[ebEntry.String()]
+		if !ok {
+			c = findCommitEntry(height, ebEntry, db)
+		}
+		if c == nil {
+			return newValidationError(height, object, "no CommitEntry for the entry")
+		}
+		if c.Credits < cost {
+			return newValidationError(height, object, "%d committed credits are less than the cost of %d", c.Credits, cost)
+		}
+	}
+
+	return nil
+}
+
+// findCommitEntry looks for the commit of an entry in the previous ECBlocks
+func findCommitEntry(height uint32, entryHash *common.Hash, db database.Db) *common.CommitEntry {
+	for _, entry := range previousECEntries(height, db) {
+		if c, ok := entry.(*common.CommitEntry); ok && c.EntryHash.IsSameAs(entryHash) {
+			return c
+		}
+	}
+	return nil
+}
+
+// findCommitChain looks for the commit of a chain in the previous ECBlocks
+func findCommitChain(height uint32, entryHash *common.Hash, db database.Db) *common.CommitChain {
+	for _, entry := range previousECEntries(height, db) {
+		if c, ok := entry.(*common.CommitChain); ok && c.EntryHash.IsSameAs(entryHash) {
+			return c
+		}
+	}
+	return nil
+}
+
+func previousECEntries(height uint32, db database.Db) []common.ECBlockEntry {
+	var entries []common.ECBlockEntry
+	for i := uint32(1); i <= commitLookBack && i <= height; i++ {
+		ecBlock, err := db.FetchECBlockByHeight(height - i)
+		if err != nil || ecBlock == nil {
+			continue
+		}
+		entries = append(entries, ecBlock.Body.Entries...)
+	}
+	return entries
+}
+
+// reportInvalidBlock logs the failed rule once per block height
+func reportInvalidBlock(err error, height uint32) {
+	if lastInvalidDBHeight == int64(height) {
+		return
+	}
+	lastInvalidDBHeight = int64(height)
+
+	procLog.Error("Full validation refused block: ", err)
+	cp.CP.AddUpdate(
+		"FullValidation",                   // tag
+		"warning",                          // Category
+		"Block refused by full validation", // Title
+		err.Error(),                        // Message
+		0)                                  // Expire
+}
@@ -0,0 +1,214 @@
+// Copyright 2015 FactomProject Authors. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package process
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	cp "github.com/FactomProject/FactomCode/controlpanel"
+	"github.com/FactomProject/FactomCode/util"
+)
+
+// The watchdog checks that the node progresses: a dir block is stored or a
+// minute of the open dir block ends. When nothing happened for StallSeconds,
+// it logs a diagnosis of the stall and takes the recovery actions of the
+// config, again every StallSeconds until the node progresses.
+
+// Causes of a stall
+const (
+	StallNoPeers      = "no-peers"
+	StallLeaderSilent = "leader-silent"
+	StallDBWrite      = "db-write"
+	StallUnknown      = "unknown"
+)
+
+// Recovery actions
+const (
+	watchdogRotatePeers = "rotate-peers"
+	watchdogResync      = "resync"
+	watchdogAlert       = "alert"
+)
+
+var (
+	// watchdogStall is how long the node may not progress, 0 disables the
+	// watchdog
+	watchdogStall   time.Duration
+	watchdogActions = make(map[string]bool)
+
+	// peerRotator drops the connected peers for others, set by factomd
+	peerRotator func()
+
+	watchdogMutex      sync.Mutex
+	lastProgress       = time.Now()
+	lastProgressHeight int64
+	lastProgressMinute uint8
+	lastRecovery       time.Time
+	stallCause         string
+	stallCount         int
+
+	// lastDBError is the last failed write of a block to the database
+	lastDBError     error
+	lastDBErrorTime time.Time
+)
+
+// WatchdogStatus is the state of the stalled sync watchdog
+type WatchdogStatus struct {
+	Enabled      bool
+	Stalled      bool
+	Cause        string `json:",omitempty"` // diagnosis of the current stall
+	Stalls       int    // stalls detected since the start
+	LastProgress int64  // unix time of the last dir block or minute
+}
+
+// SetPeerRotator sets the function dropping the connected peers for others
+func SetPeerRotator(f func()) {
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+	peerRotator = f
+}
+
+// loadWatchdogConfig reads the stall threshold and the recovery actions from
+// the config
+func loadWatchdogConfig(cfg *util.FactomdConfig) error {
+	actions := make(map[string]bool)
+	for _, a := range cfg.Watchdog.Actions {
+		switch a = strings.ToLower(a); a {
+		case "":
+		case watchdogRotatePeers, watchdogResync, watchdogAlert:
+			actions[a] = true
+		default:
+			return fmt.Errorf("Unknown watchdog action %s, expected rotate-peers, resync or alert", a)
+		}
+	}
+
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+	watchdogStall = time.Duration(cfg.Watchdog.StallSeconds) * time.Second
+	watchdogActions = actions
+	return nil
+}
+
+// recordDBError records a failed write of a block, for the diagnosis of a
+// stall
+func recordDBError(err error) {
+	if err == nil {
+		return
+	}
+	watchdogMutex.Lock()
+	lastDBError, lastDBErrorTime = err, time.Now()
+	watchdogMutex.Unlock()
+}
+
+// runWatchdog checks the progress of the node until it stops
+func runWatchdog() {
+	for !SafeStop {
+		time.Sleep(time.Second)
+		checkProgress(time.Now())
+	}
+}
+
+// checkProgress records the progress of the node, and diagnoses and recovers
+// from a stall
+func checkProgress(now time.Time) {
+	height := int64(-1)
+	if _, h, err := db.FetchBlockHeightCache(); err == nil {
+		height = h
+	}
+	minute := publishedMinute()
+
+	watchdogMutex.Lock()
+	if height != lastProgressHeight || minute != lastProgressMinute {
+		if stallCause != "" {
+			procLog.Infof("Node progresses again after a stall of %s (%s)", now.Sub(lastProgress), stallCause)
+			stallCause = ""
+		}
+		lastProgress, lastProgressHeight, lastProgressMinute = now, height, minute
+	}
+	if watchdogStall <= 0 || now.Sub(lastProgress) < watchdogStall || now.Sub(lastRecovery) < watchdogStall {
+		watchdogMutex.Unlock()
+		return
+	}
+	cause, diagnosis := diagnoseStall(now)
+	if stallCause == "" {
+		stallCount++
+		stallCounter.Inc(cause)
+	}
+	stallCause = cause
+	lastRecovery = now
+	stalled := now.Sub(lastProgress)
+	actions, rotate := watchdogActions, peerRotator
+	watchdogMutex.Unlock()
+
+	msg := fmt.Sprintf("No dir block or minute for %s at block height %d: %s", stalled, height, diagnosis)
+	procLog.Warning(msg)
+	recoverStall(cause, msg, uint32(height+1), actions, rotate)
+}
+
+// diagnoseStall returns the likely cause of the stall and its description.
+// It is called with watchdogMutex held.
+func diagnoseStall(now time.Time) (string, string) {
+	if lastDBError != nil && now.Sub(lastDBErrorTime) < 2*watchdogStall {
+		return StallDBWrite, "the last write to the database failed: " + lastDBError.Error()
+	}
+	if peerCount != nil && peerCount() == 0 {
+		return StallNoPeers, "no peer is connected"
+	}
+	if nodeMode != common.SERVER_NODE {
+		return StallLeaderSilent, "no ack or dir block received from the leader"
+	}
+	return StallUnknown, "the leader is not closing its blocks"
+}
+
+// recoverStall takes the recovery actions of the config for the stall of
+// the open dir block at height, rotating the peers with rotate
+func recoverStall(cause, msg string, height uint32, actions map[string]bool, rotate func()) {
+	if actions[watchdogAlert] {
+		procLog.Alert(msg)
+		cp.CP.AddUpdate(
+			"Watchdog",     // tag
+			"warning",      // Category
+			"Stalled Sync", // Title
+			msg,            // Message
+			0)              // Expire
+		publishEvent(&Event{
+			Type:      EventStall,
+			DBHeight:  height,
+			Cause:     cause,
+			Timestamp: time.Now().Unix(),
+		})
+	}
+
+	if actions[watchdogRotatePeers] && cause != StallDBWrite {
+		if rotate != nil {
+			procLog.Info("Watchdog rotating the peers")
+			rotate()
+		} else {
+			procLog.Debug("Watchdog cannot rotate the peers without a p2p server")
+		}
+	}
+
+	// only a follower gets the blocks from its peers
+	if actions[watchdogResync] && nodeMode != common.SERVER_NODE && cause != StallDBWrite {
+		requestResync()
+	}
+}
+
+// GetWatchdogStatus returns the state of the stalled sync watchdog
+func GetWatchdogStatus() WatchdogStatus {
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+
+	return WatchdogStatus{
+		Enabled:      watchdogStall > 0,
+		Stalled:      stallCause != "",
+		Cause:        stallCause,
+		Stalls:       stallCount,
+		LastProgress: lastProgress.Unix(),
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package simulation
+
+import (
+	"sort"
+
+	"github.com/FactomProject/btcd/wire"
+)
+
+// Node is a simulated server attached to the in-memory network
+type Node interface {
+	Name() string
+	Deliver(from string, msg wire.FtmInternalMsg)
+}
+
+// Fault drops or delays the messages matching it. Empty fields match any
+// node or command.
+type Fault struct {
+	From    string
+	To      string
+	Command string
+	Drop    bool
+	Delay   uint64 // in ticks
+}
+
+func (f *Fault) matches(from, to string, msg wire.FtmInternalMsg) bool {
+	return (f.From == "" || f.From == from) &&
+		(f.To == "" || f.To == to) &&
+		(f.Command == "" || f.Command == msg.Command())
+}
+
+type envelope struct {
+	from string
+	to   string
+	msg  wire.FtmInternalMsg
+	due  uint64
+	seq  uint64
+}
+
+// Network is an in-memory network delivering messages between nodes on a
+// logical clock, so a run is always deterministic
+type Network struct {
+	tick   uint64
+	seq    uint64
+	nodes  map[string]Node
+	names  []string
+	dead   map[string]bool
+	queue  []*envelope
+	faults []*Fault
+
+	Dropped   int
+	Delivered int
+}
+
+func NewNetwork() *Network {
+	n := new(Network)
+	n.nodes = make(map[string]Node)
+	n.dead = make(map[string]bool)
+	return n
+}
+
+// AddNode attaches a node to the network
+func (n *Network) AddNode(node Node) {
+	n.nodes[node.Name()] = node
+	n.names = append(n.names, node.Name())
+	sort.Strings(n.names)
+}
+
+// AddFault installs a fault for the following messages
+func (n *Network) AddFault(f *Fault) {
+	n.faults = append(n.faults, f)
+}
+
+// ClearFaults removes all the faults
+func (n *Network) ClearFaults() {
+	n.faults = nil
+}
+
+// Kill disconnects a node. Messages from and to it are dropped.
+func (n *Network) Kill(name string) {
+	n.dead[name] = true
+}
+
+// IsAlive returns true if the node has not been killed
+func (n *Network) IsAlive(name string) bool {
+	_, ok := n.nodes[name]
+	return ok && !n.dead[name]
+}
+
+// Tick returns the current logical time
+func (n *Network) Tick() uint64 {
+	return n.tick
+}
+
+// Send queues a message for one node
+func (n *Network) Send(from, to string, msg wire.FtmInternalMsg) {
+	if n.dead[from] || n.dead[to] {
+		n.Dropped++
+		return
+	}
+
+	e := &envelope{from: from, to: to, msg: msg, due: n.tick + 1, seq: n.seq}
+	n.seq++
+	for _, f := range n.faults {
+		if !f.matches(from, to, msg) {
+			continue
+		}
+		if f.Drop {
+			n.Dropped++
+			return
+		}
+		e.due += f.Delay
+	}
+	n.queue = append(n.queue, e)
+}
+
+// Broadcast queues a message for every other node
+func (n *Network) Broadcast(from string, msg wire.FtmInternalMsg) {
+	for _, name := range n.names {
+		if name != from {
+			n.Send(from, name, msg)
+		}
+	}
+}
+
+// Step advances the clock by one tick and delivers the messages that are
+// due, in the order they were sent. It returns the number delivered.
+func (n *Network) Step() int {
+	n.tick++
+
+	var due, later []*envelope
+	for _, e := range n.queue {
+		if e.due <= n.tick {
+			due = append(due, e)
+		} else {
+			later = append(later, e)
+		}
+	}
+	n.queue = later
+
+	sort.Sort(bySeq(due))
+	for _, e := range due {
+		if n.dead[e.to] {
+			n.Dropped++
+			continue
+		}
+		n.nodes[e.to].Deliver(e.from, e.msg)
+		n.Delivered++
+	}
+	return len(due)
+}
+
+// Run steps the network for the number of ticks
+func (n *Network) Run(ticks int) {
+	for i := 0; i < ticks; i++ {
+		n.Step()
+	}
+}
+
+// Settle steps the network until no message is in flight
+func (n *Network) Settle() {
+	for len(n.queue) > 0 {
+		n.Step()
+	}
+}
+
+type bySeq []*envelope
+
+func (s bySeq) Len() int           { return len(s) }
+func (s bySeq) Less(i, j int) bool { return s[i].seq < s[j].seq }
+func (s bySeq) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
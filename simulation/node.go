@@ -0,0 +1,112 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package simulation
+
+import (
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/process"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// Commands of the simulation messages
+const (
+	CmdInv       = "siminv"
+	CmdGetBlocks = "simgetblocks"
+)
+
+// MsgInv announces a new dir block of the leader to its peers, like the
+// inventory btcd relays for the processor
+type MsgInv struct {
+	Height uint32
+}
+
+func (m *MsgInv) Command() string { return CmdInv }
+
+// MsgGetBlocks asks the leader for its dir blocks from Height on, with
+// their blocks and entries
+type MsgGetBlocks struct {
+	Height uint32
+}
+
+func (m *MsgGetBlocks) Command() string { return CmdGetBlocks }
+
+// ServerNode is a leader or follower attached to the in-memory network. It
+// hands the messages of its peers to its processor, and sends the ones the
+// processor relays.
+type ServerNode struct {
+	node *process.SimNode
+	sim  *Simulation
+
+	// Rejected counts the messages the processor of the node refused
+	Rejected int
+}
+
+var _ Node = (*ServerNode)(nil)
+
+func (s *ServerNode) Name() string {
+	return s.node.Name()
+}
+
+// IsLeader returns true if the node is a leader, dead or alive
+func (s *ServerNode) IsLeader() bool {
+	return s.node.IsLeader()
+}
+
+// Height returns the number of dir blocks stored by the node
+func (s *ServerNode) Height() uint32 {
+	return s.node.Height()
+}
+
+// DBlock returns the dir block stored by the node at height
+func (s *ServerNode) DBlock(height uint32) (*common.DirectoryBlock, error) {
+	return s.node.DBlock(height)
+}
+
+// EBlock returns the entry block stored by the node with the key merkle
+// root
+func (s *ServerNode) EBlock(keyMR *common.Hash) (*common.EBlock, error) {
+	return s.node.EBlock(keyMR)
+}
+
+func (s *ServerNode) Deliver(from string, msg wire.FtmInternalMsg) {
+	switch m := msg.(type) {
+	case *MsgInv:
+		if !s.IsLeader() && m.Height >= s.Height() {
+			s.sim.Net.Send(s.Name(), from, &MsgGetBlocks{Height: s.Height()})
+		}
+	case *MsgGetBlocks:
+		msgs, err := s.node.Serve(m.Height)
+		if err != nil {
+			s.Rejected++
+		}
+		for _, b := range msgs {
+			s.sim.Net.Send(s.Name(), from, b)
+		}
+	default:
+		out, err := s.node.Deliver(msg)
+		if err != nil {
+			s.Rejected++
+		}
+		s.send(out)
+	}
+}
+
+// send sends the messages the processor relays to the peers: the commits,
+// reveals and transactions, the inventory of a new dir block, and the
+// request of the missing dir blocks
+func (s *ServerNode) send(out []wire.FtmInternalMsg) {
+	for _, msg := range out {
+		switch msg.(type) {
+		case *wire.MsgCommitChain, *wire.MsgCommitEntry, *wire.MsgRevealEntry, *wire.MsgFactoidTX:
+			s.sim.Net.Broadcast(s.Name(), msg)
+		case *wire.MsgInt_DirBlock:
+			s.sim.Net.Broadcast(s.Name(), &MsgInv{Height: s.Height() - 1})
+		case *wire.MsgInt_ReSyncup:
+			if leader := s.sim.Leader(); leader != nil && leader != s {
+				s.sim.Net.Send(s.Name(), leader.Name(), &MsgGetBlocks{Height: s.Height()})
+			}
+		}
+	}
+}
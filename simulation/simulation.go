@@ -0,0 +1,246 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package simulation runs a leader and its followers inside one process over
+// an in-memory network. Every node runs the processor of factomd on its own
+// database: the leader builds the blocks, the followers download, validate
+// and store them. Faults such as dropped or delayed messages or a killed
+// leader can be scripted, and the dir blocks stored by every node checked,
+// so consensus changes can be tested deterministically.
+package simulation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/database/ldb"
+	"github.com/FactomProject/FactomCode/process"
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/FactomProject/btcd/wire"
+)
+
+// client is the sender name of the submitted messages
+const client = "client"
+
+// Simulation is a group of nodes on one in-memory network
+type Simulation struct {
+	Net   *Network
+	Nodes []*ServerNode
+}
+
+// NewSimulation starts n nodes named node0 to node(n-1) on the localnet,
+// with their files in dir. node0 is the leader, the followers sync up its
+// genesis block.
+func NewSimulation(dir string, n int) (*Simulation, error) {
+	cfg := *util.ReadConfig()
+	cfg.App.Network = common.LocalNetParams.Name
+	cfg.App.FastSync = false
+	cfg.App.VerifyBlocks = 0
+	cfg.App.Checkpoints = nil
+	cfg.Audit.Enabled = false
+	process.LoadConfigurations(&cfg)
+
+	sim := new(Simulation)
+	sim.Net = NewNetwork()
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("node%d", i)
+		node, err := newServerNode(sim, name, filepath.Join(dir, name), i == 0)
+		if err != nil {
+			sim.Close()
+			return nil, err
+		}
+		sim.Nodes = append(sim.Nodes, node)
+		sim.Net.AddNode(node)
+	}
+	sim.Resync()
+	sim.Net.Settle()
+	return sim, nil
+}
+
+func newServerNode(sim *Simulation, name, dir string, leader bool) (*ServerNode, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	db, err := ldb.OpenLevelDB(filepath.Join(dir, "ldb"), true)
+	if err != nil {
+		return nil, err
+	}
+	node, err := process.NewSimNode(name, dir, db, leader)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &ServerNode{node: node, sim: sim}, nil
+}
+
+// Close closes the databases of the nodes
+func (sim *Simulation) Close() {
+	for _, node := range sim.Nodes {
+		node.node.Close()
+	}
+}
+
+// Leader returns the live leader, or nil if there is none
+func (sim *Simulation) Leader() *ServerNode {
+	for _, node := range sim.Nodes {
+		if node.IsLeader() && sim.Net.IsAlive(node.Name()) {
+			return node
+		}
+	}
+	return nil
+}
+
+// Alive returns the nodes that have not been killed
+func (sim *Simulation) Alive() []*ServerNode {
+	var alive []*ServerNode
+	for _, node := range sim.Nodes {
+		if sim.Net.IsAlive(node.Name()) {
+			alive = append(alive, node)
+		}
+	}
+	return alive
+}
+
+// Submit sends a commit, reveal or factoid transaction to the leader
+func (sim *Simulation) Submit(msg wire.FtmInternalMsg) error {
+	leader := sim.Leader()
+	if leader == nil {
+		return fmt.Errorf("No leader")
+	}
+	sim.Net.Send(client, leader.Name(), msg)
+	return nil
+}
+
+// Fund gives credits to the entry credit key, paid by nothing else than the
+// simulation. The followers learn them from the next entry credit block.
+func (sim *Simulation) Fund(key *[32]byte, credits uint32) error {
+	leader := sim.Leader()
+	if leader == nil {
+		return fmt.Errorf("No leader")
+	}
+	return leader.node.Fund(key, credits)
+}
+
+// SealBlock ends the ten minutes of the open dir block of the leader, which
+// builds the blocks and announces them to the followers
+func (sim *Simulation) SealBlock() error {
+	leader := sim.Leader()
+	if leader == nil {
+		return fmt.Errorf("No leader")
+	}
+	for m := byte(wire.END_MINUTE_1); m <= wire.END_MINUTE_10; m++ {
+		out, err := leader.node.EndMinute(m)
+		leader.send(out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resync makes every live follower ask the leader for the dir blocks it has
+// not stored yet
+func (sim *Simulation) Resync() {
+	leader := sim.Leader()
+	if leader == nil {
+		return
+	}
+	for _, node := range sim.Alive() {
+		if node != leader {
+			sim.Net.Send(node.Name(), leader.Name(), &MsgGetBlocks{Height: node.Height()})
+		}
+	}
+}
+
+// KillLeader kills the current leader and promotes the first live follower
+func (sim *Simulation) KillLeader() error {
+	leader := sim.Leader()
+	if leader == nil {
+		return fmt.Errorf("No leader")
+	}
+	sim.Net.Kill(leader.Name())
+	return sim.Failover()
+}
+
+// Failover restarts the first live follower as the leader if there is no
+// live leader
+func (sim *Simulation) Failover() error {
+	if sim.Leader() != nil {
+		return nil
+	}
+	for _, node := range sim.Nodes {
+		if sim.Net.IsAlive(node.Name()) {
+			return node.node.Promote()
+		}
+	}
+	return fmt.Errorf("No live node to promote")
+}
+
+// AssertConsistent returns an error if two live nodes stored different dir
+// blocks at the same height
+func (sim *Simulation) AssertConsistent() error {
+	alive := sim.Alive()
+	for i, node := range alive {
+		for _, other := range alive[i+1:] {
+			for h := uint32(0); h < node.Height() && h < other.Height(); h++ {
+				if err := sameBlock(node, other, h); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// AssertHeight returns an error if a live node did not store height dir
+// blocks
+func (sim *Simulation) AssertHeight(height uint32) error {
+	for _, node := range sim.Alive() {
+		if node.Height() != height {
+			return fmt.Errorf("%s has %d dir blocks, expected %d", node.Name(), node.Height(), height)
+		}
+	}
+	return nil
+}
+
+// AssertBlockEntries returns an error if the dir block at height of a live
+// node does not have n entries
+func (sim *Simulation) AssertBlockEntries(height uint32, n int) error {
+	for _, node := range sim.Alive() {
+		b, err := node.DBlock(height)
+		if err != nil || b == nil {
+			return fmt.Errorf("%s has no dir block at height %d", node.Name(), height)
+		}
+		if len(b.DBEntries) != n {
+			return fmt.Errorf("%s has %d entries at height %d, expected %d",
+				node.Name(), len(b.DBEntries), height, n)
+		}
+	}
+	return nil
+}
+
+func sameBlock(a, b *ServerNode, height uint32) error {
+	ha, err := blockHash(a, height)
+	if err != nil {
+		return err
+	}
+	hb, err := blockHash(b, height)
+	if err != nil {
+		return err
+	}
+	if !ha.IsSameAs(hb) {
+		return fmt.Errorf("%s and %s have different dir blocks at height %d", a.Name(), b.Name(), height)
+	}
+	return nil
+}
+
+func blockHash(node *ServerNode, height uint32) (*common.Hash, error) {
+	b, err := node.DBlock(height)
+	if err != nil || b == nil {
+		return nil, fmt.Errorf("%s has no dir block at height %d", node.Name(), height)
+	}
+	return common.CreateHash(b)
+}
@@ -0,0 +1,134 @@
+package simulation_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/simulation"
+	"github.com/FactomProject/btcd/wire"
+)
+
+func newSimulation(t *testing.T, n int) (*Simulation, func()) {
+	dir, err := ioutil.TempDir("", "simulation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, err := NewSimulation(dir, n)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Error: %v", err)
+	}
+	return sim, func() {
+		sim.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func seal(t *testing.T, sim *Simulation) {
+	if err := sim.SealBlock(); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	sim.Net.Settle()
+}
+
+func TestSimulationBlocks(t *testing.T) {
+	sim, done := newSimulation(t, 4)
+	defer done()
+
+	// the followers stored the genesis block of the leader
+	if err := sim.AssertHeight(1); err != nil {
+		t.Error(err)
+	}
+
+	seal(t, sim)
+	seal(t, sim)
+
+	if err := sim.AssertHeight(3); err != nil {
+		t.Error(err)
+	}
+	// admin, entry credit and factoid blocks
+	if err := sim.AssertBlockEntries(2, 3); err != nil {
+		t.Error(err)
+	}
+	if err := sim.AssertConsistent(); err != nil {
+		t.Error(err)
+	}
+	for _, node := range sim.Nodes {
+		if node.Rejected != 0 {
+			t.Errorf("%s rejected %d messages", node.Name(), node.Rejected)
+		}
+	}
+}
+
+func TestSimulationDelayedMessages(t *testing.T) {
+	sim, done := newSimulation(t, 3)
+	defer done()
+	sim.Net.AddFault(&Fault{To: "node2", Delay: 5})
+
+	seal(t, sim)
+
+	if err := sim.AssertHeight(2); err != nil {
+		t.Error(err)
+	}
+	if err := sim.AssertConsistent(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimulationDroppedBlocks(t *testing.T) {
+	sim, done := newSimulation(t, 3)
+	defer done()
+	sim.Net.AddFault(&Fault{To: "node2", Command: wire.CmdABlock, Drop: true})
+
+	// node2 cannot store a dir block without its admin block
+	seal(t, sim)
+	if h := sim.Nodes[2].Height(); h != 1 {
+		t.Errorf("node2 stored %d dir blocks without the admin block", h)
+	}
+	if h := sim.Nodes[1].Height(); h != 2 {
+		t.Errorf("node1 stored %d dir blocks, expected 2", h)
+	}
+
+	// and syncs up once the admin blocks get through
+	sim.Net.ClearFaults()
+	sim.Resync()
+	sim.Net.Settle()
+
+	if err := sim.AssertHeight(2); err != nil {
+		t.Error(err)
+	}
+	if err := sim.AssertConsistent(); err != nil {
+		t.Error(err)
+	}
+	if sim.Net.Dropped == 0 {
+		t.Errorf("No message dropped")
+	}
+}
+
+func TestSimulationFailover(t *testing.T) {
+	sim, done := newSimulation(t, 3)
+	defer done()
+
+	seal(t, sim)
+
+	if err := sim.KillLeader(); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if leader := sim.Leader(); leader == nil || leader.Name() != "node1" {
+		t.Fatalf("node1 was not promoted")
+	}
+
+	// node2 validates the blocks of the new leader
+	seal(t, sim)
+
+	if err := sim.AssertHeight(3); err != nil {
+		t.Error(err)
+	}
+	if err := sim.AssertConsistent(); err != nil {
+		t.Error(err)
+	}
+	if n := sim.Nodes[2].Rejected; n != 0 {
+		t.Errorf("node2 rejected %d messages", n)
+	}
+}
@@ -114,12 +114,19 @@ checkout btclog       $branch $default
 checkout dynrsrc      $branch $default
 checkout ed25519      $branch $default
 checkout fastsha256   $branch $default 
+checkout go-bip32     $branch $default
+checkout go-bip39     $branch $default
 checkout go-flags     $branch $default
 checkout go-socks     $branch $default
 checkout seelog       $branch $default
 checkout snappy-go    $branch $default
 checkout websocket    $branch $default
 
+# dependencies outside FactomProject, fetched by go get
+go get -u github.com/golang/protobuf/proto
+go get -u google.golang.org/grpc
+go get -u golang.org/x/crypto/pbkdf2
+
 echo "
 ******************************************************** 
 *     Compiling fctwallet, the cli, and factomd
@@ -128,6 +135,8 @@ echo "
 compile factoid/fctwallet 
 compile factom-cli  
 compile FactomCode/factomd 
+compile FactomCode/factomsign
+compile FactomCode/factomload
 echo ""
 echo "
 *******************************************************
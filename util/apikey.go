@@ -0,0 +1,85 @@
+package util
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+)
+
+// Permissions granted to an API key
+const (
+	PermRead   uint8 = 1 << iota // query blocks, entries and balances
+	PermSubmit                   // submit commits, reveals and transactions
+	PermAdmin                    // node administration
+)
+
+var permNames = map[string]uint8{
+	"read":   PermRead,
+	"submit": PermSubmit,
+	"admin":  PermAdmin,
+}
+
+// APIKeys maps the configured API keys to their permissions
+type APIKeys map[string]uint8
+
+// ParseAPIKeys reads the API keys from the config. Each key is configured as
+// key:perm,perm with the permissions read, submit and admin.
+func ParseAPIKeys(lines []string) (APIKeys, error) {
+	keys := make(APIKeys)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("API key must be in the form key:perm,perm")
+		}
+
+		var perms uint8
+		for _, name := range strings.Split(parts[1], ",") {
+			p, ok := permNames[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("Unknown API key permission: %s", name)
+			}
+			perms |= p
+		}
+		keys[parts[0]] |= perms
+	}
+	return keys, nil
+}
+
+// Allows returns true if the key exists and has the permission
+func (k APIKeys) Allows(key string, perm uint8) bool {
+	perms, ok := k.lookup(key)
+	return ok && perms&perm != 0
+}
+
+// Has returns true if the key exists
+func (k APIKeys) Has(key string) bool {
+	_, ok := k.lookup(key)
+	return ok
+}
+
+func (k APIKeys) lookup(key string) (uint8, bool) {
+	if key == "" {
+		return 0, false
+	}
+	for known, perms := range k {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(key)) == 1 {
+			return perms, true
+		}
+	}
+	return 0, false
+}
+
+// PermissionName returns the config name of a permission
+func PermissionName(perm uint8) string {
+	for name, p := range permNames {
+		if p == perm {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package util_test
+
+import (
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/util"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys([]string{"", "reader:read", "writer:read,submit", "root:read, submit, admin"})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		perm uint8
+		ok   bool
+	}{
+		{"reader", PermRead, true},
+		{"reader", PermSubmit, false},
+		{"writer", PermSubmit, true},
+		{"writer", PermAdmin, false},
+		{"root", PermAdmin, true},
+		{"unknown", PermRead, false},
+		{"", PermRead, false},
+	}
+	for _, tt := range tests {
+		if keys.Allows(tt.key, tt.perm) != tt.ok {
+			t.Errorf("Allows(%q, %s) should be %v", tt.key, PermissionName(tt.perm), tt.ok)
+		}
+	}
+
+	if !keys.Has("reader") || keys.Has("unknown") || keys.Has("") {
+		t.Error("Has should only find the configured keys")
+	}
+
+	for _, bad := range []string{"nokey", ":read", "key:write"} {
+		if _, err := ParseAPIKeys([]string{bad}); err == nil {
+			t.Errorf("ParseAPIKeys(%q) should fail", bad)
+		}
+	}
+}
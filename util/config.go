@@ -4,14 +4,17 @@ import (
 	"log"
 	"os"
 	"os/user"
+	"strings"
 	"sync"
 
+	"github.com/FactomProject/FactomCode/common"
 	"gopkg.in/gcfg.v1"
 )
 
 type FactomdConfig struct {
 	App struct {
 		PortNumber              int
+		Network                 string
 		HomeDir                 string
 		LdbPath                 string
 		BoltDBPath              string
@@ -21,6 +24,16 @@ type FactomdConfig struct {
 		ServerPrivKey           string
 		ServerPubKey            string
 		ExchangeRate            uint64
+		ExchangeRateChainID     string
+		ExchangeRateAuthority   []string
+		IdentityChainID         string
+		IdentityAuthority       []string
+		ServerIdentityChainID   string
+		Checkpoints             []string
+		FullValidation          bool
+		FastSync                bool
+		VerifyBlocks            int
+		EntryWorkers            int
 	}
 	Anchor struct {
 		ServerECKey         string
@@ -28,6 +41,8 @@ type FactomdConfig struct {
 		ConfirmationsNeeded int
 	}
 	Btc struct {
+		Enabled            bool
+		AnchorEvery        int
 		BTCPubAddr         string
 		SendToBTCinSeconds int
 		WalletPassphrase   string
@@ -37,23 +52,106 @@ type FactomdConfig struct {
 		RpcClientUser      string
 		RpcClientPass      string
 		BtcTransFee        float64
+		MaxBtcTransFee     float64
+		FeeTargetBlocks    int
+		MinUTXOs           int
+		LowBalanceAlert    float64
 		CertHomePathBtcd   string
 		RpcBtcdHost        string
 		RpcUser            string
 		RpcPass            string
 	}
+	Eth struct {
+		Enabled             bool
+		RpcHost             string
+		Account             string
+		PassphraseFile      string
+		PendingFile         string
+		ContractAddress     string
+		GasLimit            uint64
+		MaxGasPriceGwei     uint64
+		ConfirmationsNeeded int
+	}
 	Rpc struct {
 		PortNumber       int
 		ApplicationName  string
 		RefreshInSeconds int
 	}
+	Mempool struct {
+		OrphanTTLInSeconds int
+		CommitTTLInSeconds int
+		MaxOrphans         int
+		MaxCommits         int
+		SeenCacheSize      int
+	}
 	Wsapi struct {
 		PortNumber      int
 		ApplicationName string
+		RequireAPIKey   bool
+		APIKey          []string
+
+		ReadRequestsPerMinute   int
+		SubmitRequestsPerMinute int
+		AdminRequestsPerMinute  int
+
+		MaxBatchSize        int
+		MaxBatchConcurrency int
+
+		TLSEnabled        bool
+		TLSCertFile       string
+		TLSKeyFile        string
+		CORSAllowedOrigin []string
+
+		EnableDiagnostics bool
+	}
+	Grpc struct {
+		Enabled    bool
+		PortNumber int
+	}
+	P2p struct {
+		DefaultPort int
+		DNSSeeds    []string
+		StaticPeers []string
+		PeersFile   string
+
+		Proxy              string
+		ProxyUser          string
+		ProxyPass          string
+		ProxyDisableListen bool
+
+		PortMapping bool
+
+		MaxInbound     int
+		MaxOutbound    int
+		ReservedSlots  int
+		FederatedPeers []string
+
+		SyncRangeSize    int
+		SyncStallSeconds int
+
+		BanThreshold       int
+		BanDurationSeconds int
+
+		Whitelist     []string
+		Blacklist     []string
+		WhitelistOnly bool
+	}
+	Watchdog struct {
+		StallSeconds int
+		Actions      []string
+	}
+	Audit struct {
+		Enabled   bool
+		Path      string
+		MaxSizeMB int
 	}
 	Log struct {
-		LogPath  string
-		LogLevel string
+		LogPath        string
+		LogLevel       string
+		LogFormat      string
+		SubsystemLevel []string
+		MaxSizeMB      int
+		MaxBackups     int
 	}
 	Wallet struct {
 		Address          string
@@ -63,60 +161,226 @@ type FactomdConfig struct {
 		BoltDBPath       string
 		FactomdAddress   string
 		FactomdPort      int
+
+		KeyStore      string
+		RelockSeconds int
 	}
 	Controlpanel struct {
 		Port string
 	}
 
-	//	AddPeers     []string `short:"a" long:"addpeer" description:"Add a peer to connect with at startup"`
+	// the btcd flags AddPeers, Proxy, Listeners, ExternalIPs, MaxPeers and
+	// DisableBanning are set by p2p.Start, which relays the btcd connections
+	AddPeers []string `short:"a" long:"addpeer" description:"Add a peer to connect with at startup"`
 	//	ConnectPeers []string `long:"connect" description:"Connect only to the specified peers at startup"`
 
-	Proxy          string `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
-	DisableListen  bool   `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect or --proxy options are used without also specifying listen interfaces via --listen"`
-	DisableRPC     bool   `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass is specified"`
-	DisableTLS     bool   `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
-	DisableDNSSeed bool   `long:"nodnsseed" description:"Disable DNS seeding for peers"`
+	Proxy          string   `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
+	Listeners      []string `long:"listen" description:"Add an interface/port to listen for connections"`
+	ExternalIPs    []string `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
+	MaxPeers       int      `long:"maxpeers" description:"Max number of inbound and outbound peers"`
+	DisableBanning bool     `long:"nobanning" description:"Disable banning of misbehaving peers"`
+	DisableListen  bool     `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect or --proxy options are used without also specifying listen interfaces via --listen"`
+	DisableRPC     bool     `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass is specified"`
+	DisableTLS     bool     `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
+	DisableDNSSeed bool     `long:"nodnsseed" description:"Disable DNS seeding for peers"`
 }
 
 // defaultConfig
 const defaultConfig = `
+; Every setting can be overridden by the environment variable
+; FACTOMD_SECTION_SETTING, or the higher precedence flag --section.setting=value
 ; ------------------------------------------------------------------------------
 ; App settings
 ; ------------------------------------------------------------------------------
 [app]
 PortNumber				      		= 8088
+; --------------- Network: mainnet | testnet | localnet, sets the magic bytes, genesis block and the defaults of the settings left 0 or empty ----------------
+Network								= mainnet
+; --------------- HomeDir: empty for ~/.factom/, or ~/.factom/testnet/ and ~/.factom/localnet/ ----------------
 HomeDir								= ""
 LdbPath					        	= "ldb"
 BoltDBPath							= ""
 DataStorePath			      		= "data/export/"
-DirectoryBlockInSeconds				= 60
+; --------------- DirectoryBlockInSeconds: 0 for the block time of the network ----------------
+DirectoryBlockInSeconds				= 0
 ; --------------- NodeMode: FULL | SERVER | LIGHT ----------------
 NodeMode                            = FULL
+; --------------- Checkpoints: known good dir blocks of the network as height:hash, added to the built-in ones (may be repeated) ----------------
+Checkpoints                         = ""
+; --------------- FullValidation: followers re-validate every commit, reveal and purchase ----------------
+FullValidation                      = false
+; --------------- FastSync: followers store old blocks checking only their linkage, and validate them in the background ----------------
+FastSync                            = false
+; --------------- VerifyBlocks: the last dir blocks checked against their stored hashes on startup, a follower downloads a corrupted range again, 0 disables ----------------
+VerifyBlocks                        = 100
+; --------------- EntryWorkers: goroutines building entry blocks, 0 uses one per CPU ----------------
+EntryWorkers                        = 0
 ServerPrivKey                       = 07c0d52cb74f4ca3106d80c4a70488426886bccc6ebc10c6bafb37bf8a65f4c38cee85c62a9e48039d4ac294da97943c2001be1539809ea5f54721f0c5477a0a
 ServerPubKey                        = "0426a802617848d4d16d87830fc521f4d136bb2d0c352850919c2679f189613a"
 ExchangeRate                        = 00666600
+; --------------- Signed exchange rate changes are recorded in this chain ----------------
+ExchangeRateChainID                 = e7bcdb1ebebe34064828cb9707843c8310c5fa0726342ce3d05ef1f76af8e6e5
+; --------------- Public keys allowed to sign exchange rate changes (may be repeated) ----------------
+ExchangeRateAuthority               = 0426a802617848d4d16d87830fc521f4d136bb2d0c352850919c2679f189613a
+; --------------- Federated and audit server identities are registered in this chain, empty uses ServerPubKey ----------------
+IdentityChainID                     = ""
+; --------------- Public keys allowed to register server identities (may be repeated) ----------------
+IdentityAuthority                   = ""
+; --------------- ServerIdentityChainID: the identity of this server, named in its dir block signatures ----------------
+ServerIdentityChainID               = ""
 
 [anchor]
 ServerECKey							= 397c49e182caa97737c6b394591c614156fbe7998d7bf5d76273961e9fa1edd406ed9e69bfdf85db8aa69820f348d096985bc0b11cc9fc9dcee3b8c68b41dfd5
 AnchorChainID						= df3ade9eec4b08d5379cc64270c30ea7315d8a8a1a69efe2b98a60ecdd69e604
 ConfirmationsNeeded					= 20
 
+; --------------- Anchors are written to each enabled chain: btc, eth or both ----------------
 [btc]
+Enabled								= true
+; --------------- AnchorEvery: one anchor writes the merkle root of this many dir blocks, 1 anchors every dir block ----------------
+AnchorEvery							= 1
 WalletPassphrase 	  				= "lindasilva"
 CertHomePath			  			= "btcwallet"
 RpcClientHost			  			= "localhost:18332"
 RpcClientEndpoint					= "ws"
 RpcClientUser			  			= "testuser"
 RpcClientPass 						= "notarychain"
+; --------------- BtcTransFee: the lowest fee of an anchor, used when btcd has no fee estimate ----------------
 BtcTransFee				  			= 0.0001
+; --------------- MaxBtcTransFee: anchors wait while the estimated fee is higher ----------------
+MaxBtcTransFee						= 0.001
+; --------------- FeeTargetBlocks: fees are estimated to get an anchor in a block within this many blocks ----------------
+FeeTargetBlocks						= 6
+; --------------- MinUTXOs: a large output is split when the anchor wallet has fewer outputs ----------------
+MinUTXOs							= 10
+; --------------- LowBalanceAlert: alert when the anchor wallet has less bitcoin ----------------
+LowBalanceAlert						= 0.01
 CertHomePathBtcd					= "btcd"
 RpcBtcdHost 			  			= "localhost:18334"
 RpcUser								= testuser
 RpcPass								= notarychain
 
+; ------------------------------------------------------------------------------
+; Ethereum anchoring - the anchor is sent from an account of the node at RpcHost,
+; in the data of a transaction to ContractAddress, or to the account itself
+; if no contract is set. The node signs each anchor with the passphrase read
+; from PassphraseFile, which only the user running factomd may read.
+; ------------------------------------------------------------------------------
+[eth]
+Enabled								= false
+RpcHost								= "http://localhost:8545"
+Account								= ""
+PassphraseFile						= "eth-passphrase"
+; --------------- PendingFile: the anchors waiting for confirmations are kept in this file ----------------
+PendingFile							= "eth-anchors.json"
+ContractAddress						= ""
+; --------------- GasLimit: gas of an anchor transaction, 0 to estimate it ----------------
+GasLimit							= 0
+; --------------- MaxGasPriceGwei: anchors wait while the gas price is higher, 0 for no limit ----------------
+MaxGasPriceGwei						= 0
+ConfirmationsNeeded					= 12
+
+; ------------------------------------------------------------------------------
+; Mem pool limits - unrevealed commits and orphans are dropped after their TTL
+; or when the pool is full, oldest first
+; ------------------------------------------------------------------------------
+[mempool]
+OrphanTTLInSeconds                  = 3600
+CommitTTLInSeconds                  = 43200
+MaxOrphans                          = 5000
+MaxCommits                          = 50000
+; --------------- SeenCacheSize: hashes of the last messages received, duplicates are dropped before validation ----------------
+SeenCacheSize                       = 20000
+
 [wsapi]
 ApplicationName						= "Factom/wsapi"
-PortNumber				  			= 8088
+; --------------- PortNumber: 0 for the api port of the network ----------------
+PortNumber				  			= 0
+; --------------- RequireAPIKey: refuse requests without a key sent in X-Factom-Key or Authorization: Bearer ----------------
+RequireAPIKey						= false
+; --------------- APIKey: key:permissions with read, submit and admin (may be repeated). Without an admin key, the admin endpoints are only served to localhost requests without a Forwarded, X-Forwarded-For or X-Real-IP header, so a node behind a reverse proxy needs an admin key ----------------
+APIKey								= ""
+; --------------- Requests per minute allowed to each API key, or IP address without a key, 0 for no limit ----------------
+ReadRequestsPerMinute				= 0
+SubmitRequestsPerMinute				= 0
+AdminRequestsPerMinute				= 0
+; --------------- JSON-RPC batches: max calls per batch and calls run at the same time ----------------
+MaxBatchSize						= 1000
+MaxBatchConcurrency					= 8
+; --------------- TLSEnabled: serve https, with a self signed certificate for development if no cert and key files are set ----------------
+TLSEnabled							= false
+TLSCertFile							= ""
+TLSKeyFile							= ""
+; --------------- CORSAllowedOrigin: origin allowed to call from a browser, * for any (may be repeated) ----------------
+CORSAllowedOrigin					= ""
+; --------------- EnableDiagnostics: serve pprof profiles at /debug/pprof and goroutine and heap dumps to admin keys ----------------
+EnableDiagnostics					= false
+
+; ------------------------------------------------------------------------------
+; gRPC api serving the same operations as the wsapi
+; ------------------------------------------------------------------------------
+[grpc]
+Enabled								= false
+PortNumber							= 8091
+
+; ------------------------------------------------------------------------------
+; Peer discovery - the DNS seeds are resolved on startup, and the static peers
+; are used when no seed resolves
+; ------------------------------------------------------------------------------
+[p2p]
+; --------------- DefaultPort: 0 for the p2p port of the network ----------------
+DefaultPort							= 0
+; --------------- DNSSeeds: host names resolving to the addresses of nodes, with an optional :port (may be repeated), empty for the seeds of the network ----------------
+DNSSeeds							= ""
+; --------------- StaticPeers: host:port of nodes used when no seed resolves (may be repeated) ----------------
+StaticPeers							= ""
+; --------------- PeersFile: the known peer addresses are kept in this file ----------------
+PeersFile							= "peers.json"
+; --------------- Proxy: host:port of a SOCKS5 proxy, such as Tor, for the outbound p2p and anchor connections ----------------
+Proxy								= ""
+ProxyUser							= ""
+ProxyPass							= ""
+; --------------- ProxyDisableListen: do not listen for incoming connections when a proxy is set ----------------
+ProxyDisableListen					= true
+; --------------- PortMapping: map DefaultPort on the router with NAT-PMP or UPnP ----------------
+PortMapping							= true
+; --------------- Connection slots: FederatedPeers (IP, may be repeated) get ReservedSlots over the limits ----------------
+MaxInbound							= 100
+MaxOutbound							= 8
+ReservedSlots						= 8
+FederatedPeers						= ""
+; --------------- The initial sync downloads SyncRangeSize dir blocks at a time from each peer, and a range getting no block for SyncStallSeconds goes to another peer ----------------
+SyncRangeSize						= 500
+SyncStallSeconds					= 30
+; --------------- A peer IP is banned for BanDurationSeconds when its misbehavior scores reach BanThreshold ----------------
+BanThreshold						= 100
+BanDurationSeconds					= 86400
+; --------------- Whitelist and Blacklist: CIDR ranges or IPs (may be repeated), whitelisted peers are never banned ----------------
+; --------------- WhitelistOnly: refuse the peers not whitelisted, for a private federation ----------------
+Whitelist							= ""
+Blacklist							= ""
+WhitelistOnly						= false
+
+; ------------------------------------------------------------------------------
+; Stalled sync watchdog
+; ------------------------------------------------------------------------------
+[watchdog]
+; --------------- StallSeconds: no dir block stored and no minute ended for this long is a stall, 0 disables the watchdog ----------------
+StallSeconds						= 300
+; --------------- Actions: rotate-peers, resync and alert, taken on a stall and again every StallSeconds until the node progresses (may be repeated) ----------------
+Actions								= rotate-peers
+Actions								= resync
+Actions								= alert
+
+; ------------------------------------------------------------------------------
+; Audit log of the messages processed, accepted or rejected, in hash chained
+; JSON records
+; ------------------------------------------------------------------------------
+[audit]
+Enabled								= false
+Path								= "audit.log"
+; --------------- The file is renamed to Path.N at MaxSizeMB, N being its first record, and never deleted, 0 never rotates ----------------
+MaxSizeMB							= 100
 
 ; ------------------------------------------------------------------------------
 ; logLevel - allowed values are: debug, info, notice, warning, error, critical, alert, emergency and none
@@ -124,6 +388,14 @@ PortNumber				  			= 8088
 [log]
 logLevel 							= info
 LogPath								= "factom-d.log"
+; --------------- LogFormat: console | json ----------------
+LogFormat							= console
+; --------------- SubsystemLevel: level of a subsystem, such as PROC=debug (may be repeated) ----------------
+; --------------- subsystems: FTMD, PROC, DB, ANCH, P2P, WSAPI, RPC, SERV, GRPC ----------------
+SubsystemLevel						= ""
+; --------------- The log file is rotated at MaxSizeMB, keeping MaxBackups rotated files, 0 never rotates ----------------
+MaxSizeMB							= 100
+MaxBackups							= 5
 
 ; ------------------------------------------------------------------------------
 ; Configurations for fctwallet
@@ -136,6 +408,10 @@ RefreshInSeconds 					= 60
 BoltDBPath 							= ""
 FactomdAddress                      = localhost
 FactomdPort                         = 8088
+; --------------- KeyStore: encrypted key store unlocked through the API, created with factomsign, empty for none ----------------
+KeyStore							= ""
+; --------------- RelockSeconds: the key store is locked again after at most this, 0 for no limit ----------------
+RelockSeconds						= 300
 
 ; ------------------------------------------------------------------------------
 ; Configurations for controlpanel
@@ -145,6 +421,7 @@ Port             					= 8090
 `
 
 var cfg *FactomdConfig
+var cfgMutex sync.RWMutex
 var once sync.Once
 var filename = getHomeDir() + "/.factom/factomd.conf"
 
@@ -152,21 +429,64 @@ var filename = getHomeDir() + "/.factom/factomd.conf"
 // object corresponding to the state of the file.
 func ReadConfig() *FactomdConfig {
 	once.Do(func() {
-		log.Println("read factom config file: ", filename)
-		cfg = readConfig()
+		log.Println("read factom config file: ", configFile())
+		setConfig(readConfig())
 	})
+	cfgMutex.RLock()
+	defer cfgMutex.RUnlock()
 	return cfg
 }
 
 func ReReadConfig() *FactomdConfig {
-	cfg = readConfig()
+	c := readConfig()
+	setConfig(c)
+	return c
+}
 
-	return cfg
+// setConfig replaces the config returned by ReadConfig
+func setConfig(c *FactomdConfig) {
+	cfgMutex.Lock()
+	defer cfgMutex.Unlock()
+	cfg = c
 }
 
+// readConfig reads the config, with the default settings if the default
+// config file cannot be read. A config file named by the --config flag or
+// FACTOMD_CONFIG, or an override, which cannot be read stops the node.
 func readConfig() *FactomdConfig {
 	cfg := new(FactomdConfig)
+	if err := readConfigFile(cfg); err != nil {
+		if configFile() != filename {
+			log.Fatalln("ERROR Reading config file: ", err)
+		}
+		log.Println("ERROR Reading config file!\nServer starting with default settings...\n", err)
+		cfg = new(FactomdConfig)
+		if err := gcfg.ReadStringInto(cfg, defaultConfig); err != nil {
+			panic(err)
+		}
+	}
+	if err := ApplyOverrides(cfg, os.Getenv, configArgs); err != nil {
+		log.Fatalln("ERROR Overriding settings: ", err)
+	}
+	completeConfig(cfg)
+	return cfg
+}
+
+// loadConfig reads the config file over the default settings, then the
+// environment variables and flags overriding them
+func loadConfig() (*FactomdConfig, error) {
+	cfg := new(FactomdConfig)
+	if err := readConfigFile(cfg); err != nil {
+		return nil, err
+	}
+	if err := ApplyOverrides(cfg, os.Getenv, configArgs); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
 
+// readConfigFile reads the config file into cfg over the default settings
+func readConfigFile(cfg *FactomdConfig) error {
 	// This makes factom config file located at
 	//   POSIX (Linux/BSD): ~/.factom/factom.conf
 	//   Mac OS: $HOME/Library/Application Support/Factom/factom.conf
@@ -180,23 +500,39 @@ func readConfig() *FactomdConfig {
 	//LdbPath					 = filepath.Join(defaultDataDir, "ldb9")
 	//DataStorePath		 = filepath.Join(defaultDataDir, "store/seed/")
 
-	err := gcfg.ReadStringInto(cfg, defaultConfig)
-	if err != nil {
+	if err := gcfg.ReadStringInto(cfg, defaultConfig); err != nil {
 		panic(err)
 	}
+	return gcfg.ReadFileInto(cfg, configFile())
+}
 
-	err = gcfg.ReadFileInto(cfg, filename)
+// completeConfig sets the settings derived from the ones read
+func completeConfig(cfg *FactomdConfig) {
+	// the settings left empty take the defaults of the network, whose name
+	// is checked by the processor
+	network, err := common.NetworkParamsByName(cfg.App.Network)
 	if err != nil {
-		log.Println("ERROR Reading config file!\nServer starting with default settings...\n", err)
-		err = gcfg.ReadStringInto(cfg, defaultConfig)
-		if err != nil {
-			panic(err)
-		}
+		network = &common.MainNetParams
+	}
+	if cfg.App.DirectoryBlockInSeconds == 0 {
+		cfg.App.DirectoryBlockInSeconds = network.DirectoryBlockInSeconds
+	}
+	if cfg.Wsapi.PortNumber == 0 {
+		cfg.Wsapi.PortNumber = network.APIPort
+	}
+	if cfg.P2p.DefaultPort == 0 {
+		cfg.P2p.DefaultPort = network.DefaultPort
+	}
+	if strings.TrimSpace(strings.Join(cfg.P2p.DNSSeeds, "")) == "" {
+		cfg.P2p.DNSSeeds = append([]string(nil), network.DNSSeeds...)
 	}
 
-	// Default to home directory if not set
+	// Default to home directory if not set, apart for each network
 	if len(cfg.App.HomeDir) < 1 {
 		cfg.App.HomeDir = getHomeDir() + "/.factom/"
+		if network != &common.MainNetParams {
+			cfg.App.HomeDir += network.Name + "/"
+		}
 	}
 
 	// TODO: improve the paths after milestone 1
@@ -204,9 +540,28 @@ func readConfig() *FactomdConfig {
 	cfg.App.BoltDBPath = cfg.App.HomeDir + cfg.App.BoltDBPath
 	cfg.App.DataStorePath = cfg.App.HomeDir + cfg.App.DataStorePath
 	cfg.Log.LogPath = cfg.App.HomeDir + cfg.Log.LogPath
+	if !strings.HasPrefix(cfg.Audit.Path, "/") {
+		cfg.Audit.Path = cfg.App.HomeDir + cfg.Audit.Path
+	}
 	cfg.Wallet.BoltDBPath = cfg.App.HomeDir + cfg.Wallet.BoltDBPath
-
-	return cfg
+	if cfg.Wallet.KeyStore != "" && !strings.HasPrefix(cfg.Wallet.KeyStore, "/") {
+		cfg.Wallet.KeyStore = cfg.App.HomeDir + cfg.Wallet.KeyStore
+	}
+	cfg.P2p.PeersFile = cfg.App.HomeDir + cfg.P2p.PeersFile
+	if cfg.Eth.PassphraseFile != "" && !strings.HasPrefix(cfg.Eth.PassphraseFile, "/") {
+		cfg.Eth.PassphraseFile = cfg.App.HomeDir + cfg.Eth.PassphraseFile
+	}
+	if cfg.Eth.PendingFile != "" && !strings.HasPrefix(cfg.Eth.PendingFile, "/") {
+		cfg.Eth.PendingFile = cfg.App.HomeDir + cfg.Eth.PendingFile
+	}
+	// the proxy of the btcd flag is the proxy of the p2p section, which
+	// the p2p package dials the peers through
+	if cfg.P2p.Proxy == "" {
+		cfg.P2p.Proxy = cfg.Proxy
+	}
+	if cfg.P2p.Proxy != "" {
+		cfg.DisableListen = cfg.DisableListen || cfg.P2p.ProxyDisableListen
+	}
 }
 
 func getHomeDir() string {
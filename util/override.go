@@ -0,0 +1,189 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/gcfg.v1"
+)
+
+// Every setting of the config file can be overridden, so containers can be
+// configured without templating the file. From the lowest precedence to the
+// highest, a setting is taken from:
+//
+//   1. the default config
+//   2. the config file, factomd.conf in the home directory, or the file of
+//      the FACTOMD_CONFIG environment variable or of the --config flag
+//   3. the environment variable FACTOMD_SECTION_SETTING, such as
+//      FACTOMD_WSAPI_PORTNUMBER=8089. A setting which may be repeated takes
+//      a list separated by spaces.
+//   4. the flag --section.setting=value, such as --wsapi.portnumber=8089.
+//      A setting which may be repeated takes the flag repeated, and a bool
+//      setting is true with the flag alone.
+//
+// An environment variable or flag replaces all the values of a setting
+// which may be repeated.
+
+const envPrefix = "FACTOMD_"
+
+// configArgs are the command line arguments, kept before the config flags
+// are stripped for the other components
+var configArgs = append([]string(nil), os.Args[1:]...)
+
+// setting is a setting of the config, with its section
+type setting struct {
+	section string
+	name    string
+	field   reflect.Value
+}
+
+// override is the values given to a setting
+type override struct {
+	setting
+	values []string
+}
+
+// configFile returns the path of the config file, from the --config flag,
+// the FACTOMD_CONFIG environment variable or the home directory
+func configFile() string {
+	for _, arg := range configArgs {
+		if name, value, ok := parseFlag(arg); ok && name == "config" {
+			return value
+		}
+	}
+	if path := os.Getenv(envPrefix + "CONFIG"); path != "" {
+		return path
+	}
+	return filename
+}
+
+// ApplyOverrides sets the settings of the cfg given by the environment
+// variables, read with getenv, and then by the flags in args
+func ApplyOverrides(cfg *FactomdConfig, getenv func(string) string, args []string) error {
+	settings := configSettings(cfg)
+
+	env := make([]override, 0)
+	for _, s := range settings {
+		v := getenv(envPrefix + strings.ToUpper(s.section+"_"+s.name))
+		if v == "" {
+			continue
+		}
+		if s.field.Kind() == reflect.Slice {
+			env = append(env, override{s, strings.Fields(v)})
+		} else {
+			env = append(env, override{s, []string{v}})
+		}
+	}
+	if err := applyOverrides(cfg, env); err != nil {
+		return err
+	}
+
+	byFlag := make(map[string]setting)
+	for _, s := range settings {
+		byFlag[strings.ToLower(s.section+"."+s.name)] = s
+	}
+	flags := make([]override, 0)
+	index := make(map[string]int)
+	for _, arg := range args {
+		name, value, ok := parseFlag(arg)
+		if !ok || name == "config" {
+			continue
+		}
+		s, found := byFlag[name]
+		if !found {
+			return fmt.Errorf("Unknown setting in flag %s", arg)
+		}
+		if i, seen := index[name]; seen {
+			flags[i].values = append(flags[i].values, value)
+			continue
+		}
+		index[name] = len(flags)
+		flags = append(flags, override{s, []string{value}})
+	}
+	return applyOverrides(cfg, flags)
+}
+
+// StripConfigFlags returns the args without the config flags, for the
+// components parsing the other flags
+func StripConfigFlags(args []string) []string {
+	stripped := make([]string, 0, len(args))
+	for _, arg := range args {
+		if _, _, ok := parseFlag(arg); !ok {
+			stripped = append(stripped, arg)
+		}
+	}
+	return stripped
+}
+
+// applyOverrides sets the values of the settings of the cfg, replacing the
+// values of the settings which may be repeated
+func applyOverrides(cfg *FactomdConfig, overrides []override) error {
+	for _, o := range overrides {
+		if o.field.Kind() == reflect.Slice {
+			o.field.Set(reflect.Zero(o.field.Type()))
+		}
+		// gcfg parses the values into the type of the setting
+		ini := "[" + o.section + "]\n"
+		for _, v := range o.values {
+			ini += o.name + " = " + quoteValue(v) + "\n"
+		}
+		if err := gcfg.ReadStringInto(cfg, ini); err != nil {
+			return fmt.Errorf("Invalid value for %s.%s: %s", o.section, o.name, err)
+		}
+	}
+	return nil
+}
+
+// configSettings returns the settings of the sections of the cfg
+func configSettings(cfg *FactomdConfig) []setting {
+	settings := make([]setting, 0)
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() != reflect.Struct {
+			continue
+		}
+		section := t.Field(i).Type
+		for j := 0; j < section.NumField(); j++ {
+			settings = append(settings, setting{
+				section: t.Field(i).Name,
+				name:    section.Field(j).Name,
+				field:   v.Field(i).Field(j),
+			})
+		}
+	}
+	return settings
+}
+
+// parseFlag returns the lower case name and the value of a --section.setting
+// or --config flag, with the value true if it has none
+func parseFlag(arg string) (name, value string, ok bool) {
+	if !strings.HasPrefix(arg, "-") {
+		return "", "", false
+	}
+	arg = strings.TrimLeft(arg, "-")
+	name, value = arg, "true"
+	if i := strings.Index(arg, "="); i >= 0 {
+		name, value = arg[:i], arg[i+1:]
+	}
+	name = strings.ToLower(name)
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		return "", "", false
+	}
+	if name != "config" && !strings.Contains(name, ".") {
+		return "", "", false
+	}
+	return name, value, true
+}
+
+// quoteValue quotes a value for gcfg
+func quoteValue(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(v) + `"`
+}
@@ -0,0 +1,65 @@
+package util_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/util"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	cfg := new(FactomdConfig)
+	cfg.Wsapi.PortNumber = 8088
+	cfg.Wsapi.ApplicationName = "Factom/wsapi"
+	cfg.P2p.StaticPeers = []string{"file:8108"}
+	cfg.P2p.DNSSeeds = []string{"seed.factom.org"}
+
+	env := map[string]string{
+		"FACTOMD_WSAPI_PORTNUMBER":      "9000",
+		"FACTOMD_WSAPI_APPLICATIONNAME": `Factom "test"`,
+		"FACTOMD_P2P_STATICPEERS":       "env1:8108  env2:8108",
+		"FACTOMD_P2P_DNSSEEDS":          "env.factom.org",
+	}
+	args := []string{
+		"initializeonly",
+		"--wsapi.portnumber=9100",
+		"--p2p.dnsseeds=flag1.factom.org",
+		"-P2p.DNSSeeds=flag2.factom.org",
+		"--p2p.whitelistonly",
+		"--nolisten",
+	}
+	getenv := func(k string) string { return env[k] }
+	if err := ApplyOverrides(cfg, getenv, args); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	if cfg.Wsapi.PortNumber != 9100 {
+		t.Errorf("flag should override the environment, got port %d", cfg.Wsapi.PortNumber)
+	}
+	if cfg.Wsapi.ApplicationName != `Factom "test"` {
+		t.Errorf("Wrong application name %q", cfg.Wsapi.ApplicationName)
+	}
+	if !reflect.DeepEqual(cfg.P2p.StaticPeers, []string{"env1:8108", "env2:8108"}) {
+		t.Errorf("Wrong static peers %v", cfg.P2p.StaticPeers)
+	}
+	if !reflect.DeepEqual(cfg.P2p.DNSSeeds, []string{"flag1.factom.org", "flag2.factom.org"}) {
+		t.Errorf("Wrong DNS seeds %v", cfg.P2p.DNSSeeds)
+	}
+	if !cfg.P2p.WhitelistOnly {
+		t.Errorf("bool flag without a value should be true")
+	}
+
+	if err := ApplyOverrides(cfg, getenv, []string{"--wsapi.nosuchsetting=1"}); err == nil {
+		t.Errorf("unknown setting should fail")
+	}
+	if err := ApplyOverrides(cfg, getenv, []string{"--wsapi.portnumber=abc"}); err == nil {
+		t.Errorf("invalid value should fail")
+	}
+}
+
+func TestStripConfigFlags(t *testing.T) {
+	args := StripConfigFlags([]string{"initializeonly", "--config=/etc/factomd.conf", "--app.homedir=/data", "--nolisten", "-1.5"})
+	if !reflect.DeepEqual(args, []string{"initializeonly", "--nolisten", "-1.5"}) {
+		t.Errorf("Wrong args %v", args)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Some settings can be reloaded from the config file while the node runs.
+// The packages using them register which ones with OnReload, and the
+// function applying them. A changed setting no package registered needs a
+// restart, and keeps its old value until then. A reload never changes the
+// config returned by ReadConfig: it makes a copy with the new values, and
+// swaps it in, so a package holding the config keeps consistent values.
+
+// ReloadReport tells which changed settings, named Section.Setting, were
+// applied and which need a restart
+type ReloadReport struct {
+	Applied         []string
+	RestartRequired []string
+	Errors          []string
+}
+
+type reloader struct {
+	settings []string
+	apply    func(cfg *FactomdConfig) error
+}
+
+var (
+	reloadMutex sync.Mutex
+	reloaders   []reloader
+)
+
+// OnReload registers the function applying the settings, named
+// Section.Setting such as Wsapi.APIKey, when they change in a reload. The
+// function gets the config with the new values.
+func OnReload(apply func(cfg *FactomdConfig) error, settings ...string) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	reloaders = append(reloaders, reloader{settings: settings, apply: apply})
+}
+
+// ReloadConfig reads the config file again, and applies the changed
+// settings which can be reloaded
+func ReloadConfig() (*ReloadReport, error) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	newCfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	completeConfig(newCfg)
+	cur := ReadConfig()
+	next := *cur
+
+	changed := make(map[string]bool)
+	for _, name := range changedSettings(cur, newCfg) {
+		changed[name] = true
+	}
+
+	r := new(ReloadReport)
+	handled := make(map[string]bool)
+	for _, rl := range reloaders {
+		for _, name := range rl.changed(changed) {
+			handled[name] = true
+			copySetting(&next, newCfg, name)
+		}
+	}
+	setConfig(&next)
+
+	for _, rl := range reloaders {
+		names := rl.changed(changed)
+		if len(names) == 0 {
+			continue
+		}
+		if err := rl.apply(&next); err != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("%v: %s", names, err))
+			continue
+		}
+		r.Applied = append(r.Applied, names...)
+	}
+	for name := range changed {
+		if !handled[name] {
+			r.RestartRequired = append(r.RestartRequired, name)
+		}
+	}
+	sort.Strings(r.Applied)
+	sort.Strings(r.RestartRequired)
+	return r, nil
+}
+
+// changed returns the settings of the reloader which are changed
+func (rl reloader) changed(changed map[string]bool) []string {
+	var names []string
+	for _, name := range rl.settings {
+		if changed[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// changedSettings returns the names of the settings which differ
+func changedSettings(a, b *FactomdConfig) []string {
+	names := make([]string, 0)
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() != reflect.Struct {
+			if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+				names = append(names, f.Name)
+			}
+			continue
+		}
+		for j := 0; j < f.Type.NumField(); j++ {
+			if !reflect.DeepEqual(va.Field(i).Field(j).Interface(), vb.Field(i).Field(j).Interface()) {
+				names = append(names, f.Name+"."+f.Type.Field(j).Name)
+			}
+		}
+	}
+	return names
+}
+
+// copySetting sets the named setting of dst to its value in src
+func copySetting(dst, src *FactomdConfig, name string) {
+	vd, vs := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for _, field := range splitSetting(name) {
+		vd, vs = vd.FieldByName(field), vs.FieldByName(field)
+	}
+	vd.Set(vs)
+}
+
+func splitSetting(name string) []string {
+	for i := 0; i < len(name); i++ {
+		if name[i] == '.' {
+			return []string{name[:i], name[i+1:]}
+		}
+	}
+	return []string{name}
+}
@@ -0,0 +1,49 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/go-bip32"
+)
+
+// The keys are derived from the seed of a phrase with BIP32, on the paths of
+// the Factom wallets: m/44'/131'/0'/0/i for the factoid keys and
+// m/44'/132'/0'/0/i for the EC keys. The 32 bytes of a derived key are the
+// seed of an ed25519 key. A Koinify key is m/7'.
+
+// HD paths without their last index
+var (
+	factoidPath = []uint32{bip32.FirstHardenedChild + 44, bip32.FirstHardenedChild + 131, bip32.FirstHardenedChild, 0}
+	ecPath      = []uint32{bip32.FirstHardenedChild + 44, bip32.FirstHardenedChild + 132, bip32.FirstHardenedChild, 0}
+	koinifyPath = []uint32{bip32.FirstHardenedChild + 7}
+)
+
+// deriveKey returns the ed25519 key at the path and the index from the seed
+func deriveKey(seed []byte, path []uint32, index ...uint32) (*common.PrivateKey, error) {
+	k, err := bip32.NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range append(append([]uint32{}, path...), index...) {
+		if k, err = k.NewChildKey(i); err != nil {
+			return nil, err
+		}
+	}
+	priv, err := common.NewPrivateKeyFromSeed(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &priv, nil
+}
+
+// KoinifyKey returns the factoid key of a Koinify phrase
+func KoinifyKey(mnemonic string) (*common.PrivateKey, error) {
+	seed, err := MnemonicToSeed(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	return deriveKey(seed, koinifyPath)
+}
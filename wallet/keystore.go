@@ -0,0 +1,552 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Types of the stored keys
+const (
+	KeyFactoid = "fct"
+	KeyEC      = "ec"
+)
+
+const (
+	keyStoreVersion = 1
+	// keyStoreIterations of PBKDF2-SHA256 derive the encryption key from
+	// the passphrase
+	keyStoreIterations = 100000
+	// keyStoreCheck is sealed with the encryption key to detect a wrong
+	// passphrase, even in a store without keys
+	keyStoreCheck = "factom key store"
+	// keyStoreSeed authenticates the sealed phrase
+	keyStoreSeed = "seed"
+)
+
+// ErrLocked is returned when a private key is needed while the store is
+// locked
+var ErrLocked = errors.New("The key store is locked")
+
+// KeyStore keeps factoid and EC private keys encrypted at rest with
+// AES-256-GCM, under a key derived from a passphrase. The keys are
+// decrypted in memory by Unlock, and wiped by Lock or once the unlock
+// timeout expires.
+type KeyStore struct {
+	mutex sync.Mutex
+	path  string
+	file  keyStoreFile
+	key   []byte                        // encryption key while unlocked
+	keys  map[string]*common.PrivateKey // by hex public key while unlocked
+	until time.Time                     // zero if unlocked until Lock
+	timer *time.Timer
+	// unlocks counts the unlocks, so the timer of an earlier unlock firing
+	// late does not lock a later one
+	unlocks uint64
+}
+
+type keyStoreFile struct {
+	Version    int
+	Salt       string // hex
+	Iterations int
+	Check      string // hex of the nonce and the sealed keyStoreCheck
+	Keys       []*StoredKey
+
+	// hex of the nonce and the sealed mnemonic phrase the keys are derived
+	// from, with the indexes of the next derived keys
+	Seed        string `json:",omitempty"`
+	NextFactoid uint32 `json:",omitempty"`
+	NextEC      uint32 `json:",omitempty"`
+}
+
+// StoredKey is a private key encrypted in the key store
+type StoredKey struct {
+	Name   string
+	Type   string
+	PubKey string
+	Secret string // hex of the nonce and the sealed private key
+}
+
+// KeyInfo describes a stored key without its secret
+type KeyInfo struct {
+	Name   string
+	Type   string
+	PubKey string
+}
+
+// KeyStoreStatus tells if the store is locked, and until when it is
+// unlocked
+type KeyStoreStatus struct {
+	Locked        bool
+	UnlockedUntil int64 `json:",omitempty"` // unix time, 0 if unlocked until locked
+	HasSeed       bool  // new keys are derived from a phrase
+	Keys          []KeyInfo
+}
+
+// CreateKeyStore creates an empty key store file protected by the passphrase
+func CreateKeyStore(path, passphrase string) (*KeyStore, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("The key store %s already exists", path)
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("The passphrase is empty")
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	s := &KeyStore{
+		path: path,
+		file: keyStoreFile{
+			Version:    keyStoreVersion,
+			Salt:       hex.EncodeToString(salt),
+			Iterations: keyStoreIterations,
+			Keys:       make([]*StoredKey, 0),
+		},
+	}
+	key := pbkdf2.Key([]byte(passphrase), salt, keyStoreIterations, 32, sha256.New)
+	check, err := seal(key, []byte(keyStoreCheck), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.file.Check = hex.EncodeToString(check)
+	return s, s.save()
+}
+
+// OpenKeyStore opens the key store file, locked
+func OpenKeyStore(path string) (*KeyStore, error) {
+	p, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &KeyStore{path: path}
+	if err := json.Unmarshal(p, &s.file); err != nil {
+		return nil, err
+	}
+	if s.file.Version != keyStoreVersion {
+		return nil, fmt.Errorf("Unsupported key store version %d", s.file.Version)
+	}
+	return s, nil
+}
+
+// Unlock decrypts the keys with the passphrase. They are locked again after
+// the timeout, or only by Lock if it is 0.
+func (s *KeyStore) Unlock(passphrase string, timeout time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	salt, err := hex.DecodeString(s.file.Salt)
+	if err != nil {
+		return err
+	}
+	key := pbkdf2.Key([]byte(passphrase), salt, s.file.Iterations, 32, sha256.New)
+	if err := checkSealed(key, s.file.Check, []byte(keyStoreCheck), nil); err != nil {
+		return fmt.Errorf("Wrong passphrase")
+	}
+
+	keys := make(map[string]*common.PrivateKey)
+	for _, k := range s.file.Keys {
+		priv, err := openKey(key, k)
+		if err != nil {
+			return err
+		}
+		keys[k.PubKey] = priv
+	}
+
+	s.lock()
+	s.unlocks++
+	s.key, s.keys = key, keys
+	if timeout > 0 {
+		unlocks := s.unlocks
+		s.until = time.Now().Add(timeout)
+		s.timer = time.AfterFunc(timeout, func() { s.expire(unlocks) })
+	}
+	return nil
+}
+
+// expire locks the store at the timeout of the unlock, unless it was
+// unlocked again since
+func (s *KeyStore) expire(unlocks uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.unlocks == unlocks {
+		s.lock()
+	}
+}
+
+// Lock wipes the decrypted keys
+func (s *KeyStore) Lock() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lock()
+}
+
+func (s *KeyStore) lock() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	for _, k := range s.keys {
+		wipe(k.Key[:])
+	}
+	wipe(s.key)
+	s.key, s.keys, s.until = nil, nil, time.Time{}
+}
+
+// Status returns the lock state and the stored keys
+func (s *KeyStore) Status() KeyStoreStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	st := KeyStoreStatus{
+		Locked:  s.key == nil,
+		HasSeed: s.file.Seed != "",
+		Keys:    make([]KeyInfo, 0, len(s.file.Keys)),
+	}
+	if !st.Locked && !s.until.IsZero() {
+		st.UnlockedUntil = s.until.Unix()
+	}
+	for _, k := range s.file.Keys {
+		st.Keys = append(st.Keys, KeyInfo{k.Name, k.Type, k.PubKey})
+	}
+	return st
+}
+
+// GenerateKey adds a new key of the type, derived from the phrase of the
+// store if it has one, and returns its hex public key
+func (s *KeyStore) GenerateKey(name, typ string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.key == nil {
+		return "", ErrLocked
+	}
+	if s.file.Seed == "" {
+		priv := new(common.PrivateKey)
+		if err := priv.GenerateKey(); err != nil {
+			return "", err
+		}
+		if err := s.importKey(name, typ, priv); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(priv.Public()), nil
+	}
+
+	next := &s.file.NextFactoid
+	if typ == KeyEC {
+		next = &s.file.NextEC
+	}
+	priv, err := s.deriveKey(typ, *next)
+	if err != nil {
+		return "", err
+	}
+	// the index is saved with the key
+	*next++
+	if err := s.importKey(name, typ, priv); err != nil {
+		*next--
+		return "", err
+	}
+	return hex.EncodeToString(priv.Public()), nil
+}
+
+// ImportKey adds the private key of the type under the name
+func (s *KeyStore) ImportKey(name, typ string, priv *common.PrivateKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.key == nil {
+		return ErrLocked
+	}
+	return s.importKey(name, typ, priv)
+}
+
+func (s *KeyStore) importKey(name, typ string, priv *common.PrivateKey) error {
+	if typ != KeyFactoid && typ != KeyEC {
+		return fmt.Errorf("Unknown key type %s", typ)
+	}
+	pub := hex.EncodeToString(priv.Public())
+	for _, k := range s.file.Keys {
+		if k.Name == name {
+			return fmt.Errorf("A key is already named %s", name)
+		}
+		if k.PubKey == pub {
+			return fmt.Errorf("The key %s is already stored as %s", pub, k.Name)
+		}
+	}
+
+	secret, err := seal(s.key, priv.Key[:], priv.Public())
+	if err != nil {
+		return err
+	}
+	s.file.Keys = append(s.file.Keys, &StoredKey{
+		Name:   name,
+		Type:   typ,
+		PubKey: pub,
+		Secret: hex.EncodeToString(secret),
+	})
+	if err := s.save(); err != nil {
+		s.file.Keys = s.file.Keys[:len(s.file.Keys)-1]
+		return err
+	}
+
+	s.keys[pub] = copyKey(priv)
+	return nil
+}
+
+// ImportKoinify adds the factoid key of a Koinify phrase under the name
+func (s *KeyStore) ImportKoinify(name, mnemonic string) error {
+	priv, err := KoinifyKey(mnemonic)
+	if err != nil {
+		return err
+	}
+	return s.ImportKey(name, KeyFactoid, priv)
+}
+
+// SetMnemonic sets the phrase the new keys are derived from, in a store
+// without one
+func (s *KeyStore) SetMnemonic(mnemonic string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.key == nil {
+		return ErrLocked
+	}
+	if s.file.Seed != "" {
+		return fmt.Errorf("The key store already has a phrase")
+	}
+	if err := CheckMnemonic(mnemonic); err != nil {
+		return err
+	}
+	phrase := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	sealed, err := seal(s.key, []byte(phrase), []byte(keyStoreSeed))
+	if err != nil {
+		return err
+	}
+	s.file.Seed, s.file.NextFactoid, s.file.NextEC = hex.EncodeToString(sealed), 0, 0
+	if err := s.save(); err != nil {
+		s.file.Seed = ""
+		return err
+	}
+	return nil
+}
+
+// Mnemonic returns the phrase of the store, to back it up
+func (s *KeyStore) Mnemonic() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.key == nil {
+		return "", ErrLocked
+	}
+	return s.mnemonic()
+}
+
+// Restore sets the phrase of a store without one, and derives count keys
+// of each type from it, named fct-0, ec-0 and so on
+func (s *KeyStore) Restore(mnemonic string, count int) error {
+	if err := s.SetMnemonic(mnemonic); err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		for _, typ := range []string{KeyFactoid, KeyEC} {
+			if _, err := s.GenerateKey(fmt.Sprintf("%s-%d", typ, i), typ); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *KeyStore) mnemonic() (string, error) {
+	if s.file.Seed == "" {
+		return "", fmt.Errorf("The key store has no phrase")
+	}
+	sealed, err := hex.DecodeString(s.file.Seed)
+	if err != nil {
+		return "", err
+	}
+	p, err := open(s.key, sealed, []byte(keyStoreSeed))
+	if err != nil {
+		return "", fmt.Errorf("The phrase of the key store is corrupted")
+	}
+	return string(p), nil
+}
+
+// deriveKey derives the key of the type at the index from the phrase
+func (s *KeyStore) deriveKey(typ string, index uint32) (*common.PrivateKey, error) {
+	path := factoidPath
+	switch typ {
+	case KeyFactoid:
+	case KeyEC:
+		path = ecPath
+	default:
+		return nil, fmt.Errorf("Unknown key type %s", typ)
+	}
+	mnemonic, err := s.mnemonic()
+	if err != nil {
+		return nil, err
+	}
+	seed, err := MnemonicToSeed(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	return deriveKey(seed, path, index)
+}
+
+// Key returns a copy of the private key of the hex public key or name
+func (s *KeyStore) Key(id string) (*common.PrivateKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.key == nil {
+		return nil, ErrLocked
+	}
+	for _, k := range s.file.Keys {
+		if k.PubKey == id || k.Name == id {
+			return copyKey(s.keys[k.PubKey]), nil
+		}
+	}
+	return nil, fmt.Errorf("No key %s in the key store", id)
+}
+
+// Sign signs the request with the stored keys, and returns the number of
+// signers signed for
+func (s *KeyStore) Sign(r *common.SigningRequest) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.key == nil {
+		return 0, ErrLocked
+	}
+	if err := r.Check(); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, signer := range r.Signers {
+		if k, ok := s.keys[signer.PubKey]; ok {
+			m, err := r.Sign(*k)
+			if err != nil {
+				return n, err
+			}
+			n += m
+		}
+	}
+	return n, nil
+}
+
+// save writes the store file, readable by its owner only
+func (s *KeyStore) save() error {
+	p, err := json.MarshalIndent(&s.file, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, p, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// openKey decrypts the stored key and checks it against its public key
+func openKey(key []byte, k *StoredKey) (*common.PrivateKey, error) {
+	pub, err := hex.DecodeString(k.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(k.Secret)
+	if err != nil {
+		return nil, err
+	}
+	p, err := open(key, secret, pub)
+	if err != nil || len(p) != 64 || !bytes.Equal(p[32:], pub) {
+		return nil, fmt.Errorf("The key %s is corrupted", k.Name)
+	}
+	priv := new(common.PrivateKey)
+	priv.AllocateNew()
+	copy(priv.Key[:], p)
+	copy(priv.Pub.Key[:], pub)
+	wipe(p)
+	return priv, nil
+}
+
+func checkSealed(key []byte, sealed string, expected, data []byte) error {
+	p, err := hex.DecodeString(sealed)
+	if err != nil {
+		return err
+	}
+	if p, err = open(key, p, data); err != nil {
+		return err
+	}
+	if !bytes.Equal(p, expected) {
+		return fmt.Errorf("Unexpected sealed data")
+	}
+	return nil
+}
+
+// seal encrypts and authenticates the plaintext and the additional data,
+// and returns the nonce followed by the ciphertext
+func seal(key, plaintext, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, data), nil
+}
+
+// open decrypts what seal returns
+func open(key, sealed, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("The sealed data is too short")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, sealed[:n], sealed[n:], data)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func copyKey(k *common.PrivateKey) *common.PrivateKey {
+	c := new(common.PrivateKey)
+	c.AllocateNew()
+	copy(c.Key[:], k.Key[:])
+	copy(c.Pub.Key[:], k.Public())
+	return c
+}
+
+func wipe(p []byte) {
+	for i := range p {
+		p[i] = 0
+	}
+}
@@ -0,0 +1,78 @@
+package wallet_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/FactomProject/FactomCode/wallet"
+)
+
+func TestKeyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keystore.json")
+
+	s, err := CreateKeyStore(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GenerateKey("fct1", KeyFactoid); err != ErrLocked {
+		t.Errorf("GenerateKey on a locked store returned %v", err)
+	}
+	if err := s.Unlock("wrong", 0); err == nil {
+		t.Errorf("Unlock succeeded with a wrong passphrase")
+	}
+	if err := s.Unlock("passphrase", 0); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := s.GenerateKey("fct1", KeyFactoid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GenerateKey("fct1", KeyEC); err == nil {
+		t.Errorf("GenerateKey accepted a duplicate name")
+	}
+	k, err := s.Key("fct1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Lock()
+	if _, err := s.Key(pub); err != ErrLocked {
+		t.Errorf("Key on a locked store returned %v", err)
+	}
+
+	// the key is encrypted in the file
+	p, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+32 <= len(p); i++ {
+		if string(p[i:i+32]) == string(k.Key[:32]) {
+			t.Fatalf("The private key is stored in plaintext")
+		}
+	}
+
+	s, err = OpenKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Status(); !st.Locked || len(st.Keys) != 1 || st.Keys[0].PubKey != pub {
+		t.Errorf("Unexpected status of the opened store: %+v", st)
+	}
+	if err := s.Unlock("passphrase", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := s.Key(pub); err != nil || *opened.Key != *k.Key {
+		t.Errorf("The opened key differs from the generated one: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !s.Status().Locked {
+		t.Errorf("The store is not locked again after the timeout")
+	}
+}
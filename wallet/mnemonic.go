@@ -0,0 +1,47 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"strings"
+
+	"github.com/FactomProject/go-bip39"
+)
+
+// Mnemonic phrases follow BIP39 with the English word list. The seed of a
+// phrase is the root of the HD derivation of the keys.
+
+// NewMnemonic returns a new 12 words phrase, like the Koinify wallets
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(128)
+	if err != nil {
+		return "", err
+	}
+	return MnemonicFromEntropy(entropy)
+}
+
+// MnemonicFromEntropy returns the phrase of 16 to 32 bytes of entropy, with
+// its checksum
+func MnemonicFromEntropy(entropy []byte) (string, error) {
+	return bip39.NewMnemonic(entropy)
+}
+
+// CheckMnemonic verifies the words and the checksum of the phrase
+func CheckMnemonic(mnemonic string) error {
+	_, err := bip39.MnemonicToByteArray(normalizeMnemonic(mnemonic))
+	return err
+}
+
+// MnemonicToSeed returns the 64 bytes seed of the phrase and the optional
+// passphrase
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	return bip39.NewSeedWithErrorChecking(normalizeMnemonic(mnemonic), passphrase)
+}
+
+// normalizeMnemonic lowers the words of the phrase and separates them by
+// single spaces, as in the word list
+func normalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+}
@@ -0,0 +1,87 @@
+package wallet_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/FactomProject/FactomCode/wallet"
+)
+
+const yellow = "yellow yellow yellow yellow yellow yellow yellow yellow yellow yellow yellow yellow"
+
+func TestMnemonic(t *testing.T) {
+	// BIP39 test vectors
+	e, _ := hex.DecodeString("7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f")
+	if m, err := MnemonicFromEntropy(e); err != nil || m != "legal winner thank year wave sausage worth useful legal winner thank yellow" {
+		t.Errorf("Unexpected phrase %s: %v", m, err)
+	}
+	seed, err := MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := hex.EncodeToString(seed); s != "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04" {
+		t.Errorf("Unexpected seed %s", s)
+	}
+	if err := CheckMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"); err == nil {
+		t.Errorf("A phrase with a wrong checksum is accepted")
+	}
+
+	m, err := NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckMnemonic(m); err != nil {
+		t.Errorf("The new phrase %s is invalid: %v", m, err)
+	}
+}
+
+func TestKoinifyKey(t *testing.T) {
+	// FA3cih2o2tjEUsnnFR4jX1tQXPpSXFwsp3rhVp6odL5PNCHWvZV1
+	k, err := KoinifyKey(yellow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub := hex.EncodeToString(k.Public()); pub != "e5a19e6235f55719d07520455a5f6a05ffc6c88ea3b08a58ad90a56a25a5c13e" {
+		t.Errorf("Unexpected Koinify key %s", pub)
+	}
+}
+
+func TestKeyStoreRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := CreateKeyStore(filepath.Join(dir, "keystore.json"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unlock("passphrase", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Restore(yellow, 2); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := s.Mnemonic(); err != nil || m != yellow {
+		t.Errorf("Unexpected phrase %s: %v", m, err)
+	}
+
+	// m/44'/131'/0'/0/0 is FA22de5NSG2FA2HmMaD4h8qSAZAJyztmmnwgLPghCQKoSekwYYct
+	expected := map[string]string{
+		"fct-0": "4429b79161e22e9392caf03a9790c7c99d49c5f5377559db5316ad948fa4260a",
+		"ec-0":  "4a1704c7bde0710953c18db8d4f7c900026eac040ea52b660880aee9a2d9faa1",
+	}
+	st := s.Status()
+	if !st.HasSeed || len(st.Keys) != 4 {
+		t.Fatalf("Unexpected status %+v", st)
+	}
+	for _, k := range st.Keys {
+		if pub, ok := expected[k.Name]; ok && pub != k.PubKey {
+			t.Errorf("%s is %s, expected %s", k.Name, k.PubKey, pub)
+		}
+	}
+}
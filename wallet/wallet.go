@@ -3,6 +3,7 @@ package wallet
 import (
 	"log"
 	"os"
+	"sync"
 	//"fmt"
 
 	"github.com/FactomProject/FactomCode/common"
@@ -18,18 +19,19 @@ var (
 	keyManager KeyManager
 )
 
-func init() {
-	util.Trace()
-	loadConfigurations()
-	loadKeys()
-}
+// the client key is loaded on first use, so importing the package for the
+// key store does not create the plaintext wallet file
+var loadOnce sync.Once
 
 func loadKeys() {
-	err := keyManager.InitKeyManager(walletStorePath, walletFile)
-	if err != nil {
-		panic(err)
-	}
-
+	loadOnce.Do(func() {
+		util.Trace()
+		loadConfigurations()
+		err := keyManager.InitKeyManager(walletStorePath, walletFile)
+		if err != nil {
+			panic(err)
+		}
+	})
 }
 
 func loadConfigurations() {
@@ -61,6 +63,7 @@ func loadConfigurations() {
 }
 
 func SignData(data []byte) common.Signature {
+	loadKeys()
 	return keyManager.keyPair.Sign(data)
 }
 
@@ -68,10 +71,12 @@ func SignData(data []byte) common.Signature {
 func Sign(d []byte) common.Signature { return SignData(d) }
 
 func ClientPublicKey() common.PublicKey {
+	loadKeys()
 	return keyManager.keyPair.Pub
 }
 
 func MarshalSign(msg common.BinaryMarshallable) common.Signature {
+	loadKeys()
 	return keyManager.keyPair.MarshalSign(msg)
 }
 
@@ -0,0 +1,203 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/factomapi"
+	"github.com/FactomProject/web"
+)
+
+// anchorNet is the Bitcoin network the anchor package writes the anchors to
+var anchorNet = common.BitcoinTestNet3
+
+// anchorProof is a common.BitcoinAnchorProof of a dir block in hex, with
+// the hashes in the order they are hashed in. AnchorRange is set when the
+// anchor is aggregated.
+type anchorProof struct {
+	KeyMR        string
+	DBHeight     uint32
+	AnchorRange  *common.AnchorRange `json:",omitempty"`
+	RawTx        string
+	TxIndex      uint32
+	MerkleBranch []string
+	Headers      []string
+}
+
+// anchorVerification is the result of the verification of an anchorProof.
+// TxID and BlockHash are in the byte order Bitcoin displays them.
+type anchorVerification struct {
+	Valid         bool
+	TxID          string `json:",omitempty"`
+	BlockHash     string `json:",omitempty"`
+	Confirmations int    `json:",omitempty"`
+	Error         string `json:",omitempty"`
+}
+
+func newAnchorProof(keymr string, height uint32, r *common.AnchorRange, p *common.BitcoinAnchorProof) *anchorProof {
+	a := &anchorProof{
+		KeyMR:        keymr,
+		DBHeight:     height,
+		AnchorRange:  r,
+		RawTx:        hex.EncodeToString(p.RawTx),
+		TxIndex:      p.TxIndex,
+		MerkleBranch: make([]string, 0, len(p.MerkleBranch)),
+		Headers:      make([]string, 0, len(p.Headers)),
+	}
+	for _, v := range p.MerkleBranch {
+		a.MerkleBranch = append(a.MerkleBranch, hex.EncodeToString(v))
+	}
+	for _, v := range p.Headers {
+		a.Headers = append(a.Headers, hex.EncodeToString(v))
+	}
+	return a
+}
+
+// verify checks the proof with common.VerifyBitcoinAnchor
+func (a *anchorProof) verify() *anchorVerification {
+	fail := func(err error) *anchorVerification {
+		return &anchorVerification{Valid: false, Error: err.Error()}
+	}
+
+	keyMR, err := common.HexToHash(a.KeyMR)
+	if err != nil {
+		return fail(err)
+	}
+	p := &common.BitcoinAnchorProof{TxIndex: a.TxIndex}
+	if p.RawTx, err = hex.DecodeString(a.RawTx); err != nil {
+		return fail(err)
+	}
+	for _, v := range a.MerkleBranch {
+		h, err := hex.DecodeString(v)
+		if err != nil || len(h) != common.HASH_LENGTH {
+			return fail(fmt.Errorf("Invalid merkle branch hash: %s", v))
+		}
+		p.MerkleBranch = append(p.MerkleBranch, h)
+	}
+	for _, v := range a.Headers {
+		h, err := hex.DecodeString(v)
+		if err != nil {
+			return fail(err)
+		}
+		p.Headers = append(p.Headers, h)
+	}
+
+	var anchor *common.BitcoinAnchor
+	if a.AnchorRange != nil {
+		if err := a.AnchorRange.Verify(keyMR, a.DBHeight); err != nil {
+			return fail(err)
+		}
+		anchor, err = common.VerifyBitcoinAnchorPayload(anchorNet, a.AnchorRange.Payload(), p)
+	} else {
+		anchor, err = common.VerifyBitcoinAnchor(anchorNet, keyMR, a.DBHeight, p)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return &anchorVerification{
+		Valid:         true,
+		TxID:          hex.EncodeToString(reverse(anchor.TxID)),
+		BlockHash:     hex.EncodeToString(reverse(anchor.BlockHash)),
+		Confirmations: anchor.Confirmations,
+	}
+}
+
+func reverse(p []byte) []byte {
+	r := make([]byte, len(p))
+	for i, b := range p {
+		r[len(p)-1-i] = b
+	}
+	return r
+}
+
+// handleAnchorProof returns the SPV proof of the bitcoin anchor of the dir
+// block, fetched from the btcd of the node, with its verification
+func handleAnchorProof(ctx *web.Context, keymr string) {
+	type proof struct {
+		Proof        *anchorProof
+		Verification *anchorVerification
+	}
+
+	height, r, p, err := factomapi.BitcoinAnchorProof(keymr)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	a := newAnchorProof(keymr, height, r, p)
+
+	if p, err := json.Marshal(&proof{a, a.verify()}); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// handleVerifyAnchor verifies a proof sent by the client, who may have got
+// the headers from any bitcoin node
+func handleVerifyAnchor(ctx *web.Context) {
+	a := new(anchorProof)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, a); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	if p, err := json.Marshal(a.verify()); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// handleAnchorRecord returns the anchor status of the dir block at the
+// height, with its records in the anchor chain
+func handleAnchorRecord(ctx *web.Context, height string) {
+	n, err := strconv.ParseUint(height, 10, 32)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	a, err := factomapi.AnchorRecords(uint32(n))
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	if p, err := json.Marshal(a); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
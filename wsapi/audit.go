@@ -0,0 +1,65 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/FactomProject/FactomCode/factomapi"
+	"github.com/FactomProject/FactomCode/factomlog"
+	"github.com/FactomProject/web"
+)
+
+// handleAudit returns the recent records of the audit log, oldest first,
+// filtered by the command, origin and outcome query params. after returns
+// the records following a Seq, and limit the most recent ones only.
+func handleAudit(ctx *web.Context) {
+	query := ctx.Request.URL.Query()
+	f := &factomlog.AuditFilter{
+		Command: query.Get("command"),
+		Origin:  query.Get("origin"),
+		Outcome: query.Get("outcome"),
+	}
+	if v := query.Get("after"); v != "" {
+		after, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			err := fmt.Errorf("Invalid after: %s", v)
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+		f.After = after
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			err := fmt.Errorf("Invalid limit: %s", v)
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+		f.Limit = limit
+	}
+
+	records, err := factomapi.AuditRecords(f)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	if p, err := json.Marshal(records); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
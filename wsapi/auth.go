@@ -0,0 +1,174 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/FactomProject/web"
+)
+
+const httpUnauthorized = 401
+const httpForbidden = 403
+
+var (
+	authMutex     sync.RWMutex
+	requireAPIKey = cfg.RequireAPIKey
+	apiKeys       util.APIKeys
+)
+
+func initAuth() error {
+	if err := setAPIKeys(cfg.APIKey, cfg.RequireAPIKey); err != nil {
+		return err
+	}
+	util.OnReload(func(c *util.FactomdConfig) error {
+		return setAPIKeys(c.Wsapi.APIKey, c.Wsapi.RequireAPIKey)
+	}, "Wsapi.APIKey", "Wsapi.RequireAPIKey")
+	return nil
+}
+
+// setAPIKeys sets the API keys, and if they are required
+func setAPIKeys(spec []string, require bool) error {
+	keys, err := util.ParseAPIKeys(spec)
+	if err != nil {
+		return err
+	}
+	authMutex.Lock()
+	defer authMutex.Unlock()
+	apiKeys = keys
+	requireAPIKey = require
+	if requireAPIKey && len(apiKeys) == 0 {
+		wsLog.Warning("API keys are required but none is configured")
+	}
+	return nil
+}
+
+// requestAPIKey returns the key of the X-Factom-Key header or of a bearer
+// Authorization header
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-Factom-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// isLoopback returns true if the request comes from the node's host. A
+// request forwarded by a reverse proxy on the host comes from its client,
+// so it is never loopback.
+func isLoopback(r *http.Request) bool {
+	if isForwarded(r) {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isForwarded returns true if a proxy added a forwarding header to the
+// request
+func isForwarded(r *http.Request) bool {
+	for _, h := range []string{"Forwarded", "X-Forwarded-For", "X-Real-Ip"} {
+		if r.Header.Get(h) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAdminKey returns true if a configured key has the admin permission
+func hasAdminKey() bool {
+	for _, perms := range apiKeys {
+		if perms&util.PermAdmin != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize returns the http status and an error if the request may not
+// use an endpoint needing the permission. The admin endpoints always need
+// a key with the admin permission, or without any configured come only
+// from the loopback interface and not through a proxy, whether or not the
+// keys are required.
+func authorize(r *http.Request, perm uint8) (int, error) {
+	authMutex.RLock()
+	defer authMutex.RUnlock()
+	if perm == util.PermAdmin && !hasAdminKey() {
+		if !isLoopback(r) {
+			return httpForbidden, fmt.Errorf("Admin endpoints are only served to localhost, not through a proxy, without an admin API key")
+		}
+		return httpOK, nil
+	}
+	if !requireAPIKey && perm != util.PermAdmin {
+		return httpOK, nil
+	}
+	key := requestAPIKey(r)
+	if key == "" {
+		return httpUnauthorized, fmt.Errorf("API key required")
+	}
+	if !apiKeys.Allows(key, perm) {
+		return httpForbidden, fmt.Errorf("API key does not have the %s permission", util.PermissionName(perm))
+	}
+	return httpOK, nil
+}
+
+func refuse(ctx *web.Context, status int, err error) {
+	wsLog.Warning(ctx.Request.RemoteAddr, " ", ctx.Request.URL.Path, ": ", err)
+	ctx.WriteHeader(status)
+	ctx.Write([]byte(err.Error()))
+}
+
+func refuseTooMany(ctx *web.Context, wait time.Duration) {
+	secs := int(math.Ceil(wait.Seconds()))
+	ctx.SetHeader("Retry-After", strconv.Itoa(secs), true)
+	ctx.WriteHeader(httpTooManyRequests)
+	ctx.Write([]byte(fmt.Sprintf("Too many requests, retry after %d seconds", secs)))
+}
+
+// protect wraps a handler so it is only served to keys with the permission,
+// within the rate limit of the endpoint class
+func protect(perm uint8, handler interface{}) interface{} {
+	switch h := handler.(type) {
+	case func(*web.Context):
+		return func(ctx *web.Context) {
+			if status, err := authorize(ctx.Request, perm); err != nil {
+				refuse(ctx, status, err)
+				return
+			}
+			if ok, wait := rateLimit(ctx.Request, perm); !ok {
+				refuseTooMany(ctx, wait)
+				return
+			}
+			h(ctx)
+		}
+	case func(*web.Context, string):
+		return func(ctx *web.Context, arg string) {
+			if status, err := authorize(ctx.Request, perm); err != nil {
+				refuse(ctx, status, err)
+				return
+			}
+			if ok, wait := rateLimit(ctx.Request, perm); !ok {
+				refuseTooMany(ctx, wait)
+				return
+			}
+			h(ctx, arg)
+		}
+	}
+	panic(fmt.Sprintf("protect: unsupported handler type %T", handler))
+}
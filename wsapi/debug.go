@@ -0,0 +1,243 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/FactomProject/web"
+)
+
+// The profiling and diagnostics endpoints are served only with
+// EnableDiagnostics, and only to admin keys. They answer like
+// net/http/pprof, so go tool pprof can read them, but that package is not
+// imported as it serves the profiles to anyone on the default mux, which
+// the control panel uses. The dumps are written to the diagnostics
+// directory of the home directory, as they can be large.
+
+// maxProfileSeconds bounds a CPU profile
+const maxProfileSeconds = 300
+
+// registerDiagnostics sets the handlers of the diagnostics endpoints
+func registerDiagnostics() {
+	if !cfg.EnableDiagnostics {
+		return
+	}
+	wsLog.Warning("diagnostics endpoints enabled")
+	server.Get("/debug/pprof/?", protect(util.PermAdmin, handlePprofIndex))
+	server.Get("/debug/pprof/cmdline", protect(util.PermAdmin, handlePprofCmdline))
+	server.Get("/debug/pprof/profile", protect(util.PermAdmin, handlePprofProfile))
+	server.Get("/debug/pprof/symbol", protect(util.PermAdmin, handlePprofSymbol))
+	server.Post("/debug/pprof/symbol", protect(util.PermAdmin, handlePprofSymbol))
+	server.Get("/debug/pprof/([^/]+)", protect(util.PermAdmin, handlePprofLookup))
+	server.Get("/v1/debug/runtime/?", protect(util.PermAdmin, handleRuntime))
+	server.Post("/v1/debug/goroutine-dump/?", protect(util.PermAdmin, handleGoroutineDump))
+	server.Post("/v1/debug/heap-dump/?", protect(util.PermAdmin, handleHeapDump))
+}
+
+// handlePprofIndex lists the profiles with their counts
+func handlePprofIndex(ctx *web.Context) {
+	ctx.SetHeader("Content-Type", "text/plain; charset=utf-8", true)
+	for _, p := range pprof.Profiles() {
+		fmt.Fprintf(ctx, "%d\t%s\n", p.Count(), p.Name())
+	}
+	fmt.Fprint(ctx, "\tprofile (CPU, ?seconds=30)\n")
+}
+
+func handlePprofCmdline(ctx *web.Context) {
+	ctx.SetHeader("Content-Type", "text/plain; charset=utf-8", true)
+	ctx.Write([]byte(strings.Join(os.Args, "\x00")))
+}
+
+// handlePprofProfile profiles the CPU for 30 seconds, or the seconds
+// parameter
+func handlePprofProfile(ctx *web.Context) {
+	secs, _ := strconv.Atoi(ctx.Request.URL.Query().Get("seconds"))
+	if secs <= 0 {
+		secs = 30
+	}
+	if secs > maxProfileSeconds {
+		secs = maxProfileSeconds
+	}
+	if err := pprof.StartCPUProfile(ctx); err != nil {
+		// a profile is already running
+		wsLog.Error(err)
+		ctx.WriteHeader(httpServiceUnavailable)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	ctx.SetHeader("Content-Type", "application/octet-stream", true)
+	time.Sleep(time.Duration(secs) * time.Second)
+	pprof.StopCPUProfile()
+}
+
+// handlePprofSymbol returns the function names of the program counters
+// posted, like 0x4a1b2c+0x4a1c00, as go tool pprof asks for them
+func handlePprofSymbol(ctx *web.Context) {
+	ctx.SetHeader("Content-Type", "text/plain; charset=utf-8", true)
+	var buf bytes.Buffer
+	buf.WriteString("num_symbols: 1\n")
+	if ctx.Request.Method == "POST" {
+		body, _ := ioutil.ReadAll(ctx.Request.Body)
+		for _, word := range strings.FieldsFunc(string(body), func(r rune) bool { return r == '+' || r == '\n' }) {
+			pc, err := strconv.ParseUint(strings.TrimSpace(word), 0, 64)
+			if err != nil {
+				continue
+			}
+			if f := runtime.FuncForPC(uintptr(pc)); f != nil {
+				fmt.Fprintf(&buf, "%#x %s\n", pc, f.Name())
+			}
+		}
+	}
+	ctx.Write(buf.Bytes())
+}
+
+// handlePprofLookup serves a profile by name, such as goroutine or heap, as
+// text with debug=1 or 2
+func handlePprofLookup(ctx *web.Context, name string) {
+	p := pprof.Lookup(name)
+	if p == nil {
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte("Unknown profile " + name))
+		return
+	}
+	debugLevel, _ := strconv.Atoi(ctx.Request.URL.Query().Get("debug"))
+	if debugLevel > 0 {
+		ctx.SetHeader("Content-Type", "text/plain; charset=utf-8", true)
+	} else {
+		ctx.SetHeader("Content-Type", "application/octet-stream", true)
+	}
+	if name == "heap" && ctx.Request.URL.Query().Get("gc") != "" {
+		runtime.GC()
+	}
+	if err := p.WriteTo(ctx, debugLevel); err != nil {
+		wsLog.Error(err)
+	}
+}
+
+// runtimeInfo is a summary of the go runtime of the node
+type runtimeInfo struct {
+	GoVersion     string
+	NumCPU        int
+	GOMAXPROCS    int
+	NumGoroutine  int
+	NumCgoCall    int64
+	HeapAlloc     uint64
+	HeapSys       uint64
+	HeapObjects   uint64
+	StackInuse    uint64
+	Sys           uint64
+	TotalAlloc    uint64
+	NumGC         uint32
+	LastGC        int64 // unix time
+	PauseTotalNs  uint64
+	NextGC        uint64
+	UptimeSeconds int64
+}
+
+var startTime = time.Now()
+
+func handleRuntime(ctx *web.Context) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	info := runtimeInfo{
+		GoVersion:     runtime.Version(),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		NumGoroutine:  runtime.NumGoroutine(),
+		NumCgoCall:    runtime.NumCgoCall(),
+		HeapAlloc:     m.HeapAlloc,
+		HeapSys:       m.HeapSys,
+		HeapObjects:   m.HeapObjects,
+		StackInuse:    m.StackInuse,
+		Sys:           m.Sys,
+		TotalAlloc:    m.TotalAlloc,
+		NumGC:         m.NumGC,
+		LastGC:        int64(m.LastGC / uint64(time.Second)),
+		PauseTotalNs:  m.PauseTotalNs,
+		NextGC:        m.NextGC,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+	}
+
+	if p, err := json.Marshal(info); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// handleGoroutineDump writes the stacks of all the goroutines to a file
+func handleGoroutineDump(ctx *web.Context) {
+	writeDump(ctx, "goroutines", func(f *os.File) error {
+		return pprof.Lookup("goroutine").WriteTo(f, 2)
+	})
+}
+
+// handleHeapDump writes a heap dump, which stops the node while it is
+// written
+func handleHeapDump(ctx *web.Context) {
+	writeDump(ctx, "heap", func(f *os.File) error {
+		debug.WriteHeapDump(f.Fd())
+		return nil
+	})
+}
+
+// writeDump creates a dump file named after the kind and the time, writes
+// it and returns its path
+func writeDump(ctx *web.Context, kind string, write func(f *os.File) error) {
+	type dumpFile struct {
+		File string
+	}
+
+	dir := filepath.Join(util.ReadConfig().App.HomeDir, "diagnostics")
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.dump", kind, time.Now().UTC().Format("20060102-150405")))
+	err := os.MkdirAll(dir, 0700)
+	if err == nil {
+		err = createDump(path, write)
+	}
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	wsLog.Info(kind, " dump written to ", path)
+
+	if p, err := json.Marshal(dumpFile{path}); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func createDump(path string, write func(f *os.File) error) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
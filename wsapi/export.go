@@ -0,0 +1,100 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/FactomProject/FactomCode/factomapi"
+	"github.com/FactomProject/web"
+)
+
+// handleBlocks streams the dir blocks from the from to the to height, with
+// their child blocks and entries unless children is false. The blocks of a
+// height are a line of JSON, or with format=binary records of a block stream
+// (see common.WriteStreamBlock). The response is flushed after each height.
+func handleBlocks(ctx *web.Context) {
+	query := ctx.Request.URL.Query()
+
+	_, head, err := dbase.FetchBlockHeightCache()
+	if err != nil || head < 0 {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpServiceUnavailable)
+		ctx.Write([]byte("No directory block stored"))
+		return
+	}
+	from, to := uint64(0), uint64(head)
+	if v := query.Get("from"); v != "" {
+		if from, err = strconv.ParseUint(v, 10, 32); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(fmt.Sprintf("Invalid from height: %s", v)))
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = strconv.ParseUint(v, 10, 32); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(fmt.Sprintf("Invalid to height: %s", v)))
+			return
+		}
+	}
+	if to > uint64(head) {
+		to = uint64(head)
+	}
+	if from > to {
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(fmt.Sprintf("No directory block from height %d to %d", from, to)))
+		return
+	}
+
+	children := true
+	if v := query.Get("children"); v != "" {
+		if children, err = strconv.ParseBool(v); err != nil {
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(fmt.Sprintf("Invalid children: %s", v)))
+			return
+		}
+	}
+
+	var write func(io.Writer, *factomapi.HeightBlocks) error
+	switch query.Get("format") {
+	case "", "json":
+		ctx.SetHeader("Content-Type", "application/x-ndjson", true)
+		write = func(w io.Writer, b *factomapi.HeightBlocks) error {
+			return json.NewEncoder(w).Encode(b)
+		}
+	case "binary":
+		ctx.SetHeader("Content-Type", "application/octet-stream", true)
+		write = factomapi.WriteStreamBlocks
+	default:
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(fmt.Sprintf("Invalid format: %s", query.Get("format"))))
+		return
+	}
+
+	flusher, _ := ctx.ResponseWriter.(http.Flusher)
+	ctx.WriteHeader(httpOK)
+	for h := from; h <= to; h++ {
+		b, err := factomapi.BlocksAt(uint32(h), children)
+		if err != nil {
+			// the status is sent already, so the stream just ends early
+			wsLog.Error(err)
+			return
+		}
+		if err := write(ctx, b); err != nil {
+			wsLog.Error(err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
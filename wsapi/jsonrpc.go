@@ -0,0 +1,505 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/factomapi"
+	"github.com/FactomProject/FactomCode/util"
+	fct "github.com/FactomProject/factoid"
+	"github.com/FactomProject/web"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcUnauthorized   = -32001
+	rpcRateLimited    = -32002
+)
+
+// maxRPCBody bounds the body of a JSON-RPC request, above a full batch of
+// the largest hex encoded reveals
+const maxRPCBody = 32 << 20
+
+var (
+	maxBatchSize        = cfg.MaxBatchSize
+	maxBatchConcurrency = cfg.MaxBatchConcurrency
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func newRPCError(code int, format string, a ...interface{}) *rpcError {
+	return &rpcError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// rpcMethod runs a call with its params and returns the result
+type rpcMethod struct {
+	perm uint8
+	call func(params json.RawMessage) (interface{}, *rpcError)
+}
+
+var rpcMethods = map[string]rpcMethod{
+	"directory-block-head": {util.PermRead, rpcDirectoryBlockHead},
+	"directory-block":      {util.PermRead, rpcDirectoryBlock},
+	"entry-block":          {util.PermRead, rpcEntryBlock},
+	"entry":                {util.PermRead, rpcEntry},
+	"chain-head":           {util.PermRead, rpcChainHead},
+	"chain-entries":        {util.PermRead, rpcChainEntries},
+	"entry-credit-balance": {util.PermRead, rpcEntryCreditBalance},
+	"factoid-balance":      {util.PermRead, rpcFactoidBalance},
+	"balances":             {util.PermRead, rpcBalances},
+	"anchor-record":        {util.PermRead, rpcAnchorRecord},
+	"commit-chain":         {util.PermSubmit, rpcCommitChain},
+	"commit-entry":         {util.PermSubmit, rpcCommitEntry},
+	"reveal-entry":         {util.PermSubmit, rpcRevealEntry},
+	"factoid-submit":       {util.PermSubmit, rpcFactoidSubmit},
+}
+
+// handleJSONRPC serves a JSON-RPC 2.0 call or a batch of calls. The calls of
+// a batch run concurrently, at most maxBatchConcurrency at a time, and the
+// responses are returned in the order of the calls.
+func handleJSONRPC(ctx *web.Context) {
+	p, err := ioutil.ReadAll(http.MaxBytesReader(ctx.ResponseWriter, ctx.Request.Body, maxRPCBody))
+	if err != nil {
+		writeRPC(ctx, &rpcResponse{JSONRPC: "2.0", Error: newRPCError(rpcParseError, err.Error())})
+		return
+	}
+
+	p = bytes.TrimSpace(p)
+	if len(p) == 0 || p[0] != '[' {
+		if r := runRPC(ctx.Request, p); r != nil {
+			writeRPC(ctx, r)
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(p, &batch); err != nil {
+		writeRPC(ctx, &rpcResponse{JSONRPC: "2.0", Error: newRPCError(rpcParseError, err.Error())})
+		return
+	}
+	if len(batch) == 0 {
+		writeRPC(ctx, &rpcResponse{JSONRPC: "2.0", Error: newRPCError(rpcInvalidRequest, "Empty batch")})
+		return
+	}
+	if maxBatchSize > 0 && len(batch) > maxBatchSize {
+		writeRPC(ctx, &rpcResponse{JSONRPC: "2.0", Error: newRPCError(rpcInvalidRequest,
+			"Batch of %d calls exceeds the limit of %d", len(batch), maxBatchSize)})
+		return
+	}
+
+	concurrency := maxBatchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	results := make([]*rpcResponse, len(batch))
+
+	var wg sync.WaitGroup
+	for i, call := range batch {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, call json.RawMessage) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = runRPC(ctx.Request, call)
+		}(i, call)
+	}
+	wg.Wait()
+
+	// notifications have no response
+	responses := make([]*rpcResponse, 0, len(results))
+	for _, r := range results {
+		if r != nil {
+			responses = append(responses, r)
+		}
+	}
+	if len(responses) > 0 {
+		writeRPC(ctx, responses)
+	}
+}
+
+// runRPC runs one call. It returns nil for a notification, a valid request
+// without id, even if the call fails. An invalid request gets an error with
+// a null id as JSON-RPC 2.0 asks.
+func runRPC(r *http.Request, p []byte) *rpcResponse {
+	req := new(rpcRequest)
+	if err := json.Unmarshal(p, req); err != nil {
+		return &rpcResponse{JSONRPC: "2.0", Error: newRPCError(rpcParseError, err.Error())}
+	}
+
+	resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if resp.ID == nil {
+		resp.ID = json.RawMessage("null")
+	}
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = newRPCError(rpcInvalidRequest, "Invalid Request")
+	} else if m, ok := rpcMethods[req.Method]; !ok {
+		resp.Error = newRPCError(rpcMethodNotFound, "Method not found: %s", req.Method)
+	} else if _, err := authorize(r, m.perm); err != nil {
+		resp.Error = newRPCError(rpcUnauthorized, err.Error())
+	} else if ok, wait := rateLimit(r, m.perm); !ok {
+		resp.Error = newRPCError(rpcRateLimited, "Too many requests, retry after %.0f seconds", wait.Seconds()+0.5)
+	} else {
+		resp.Result, resp.Error = m.call(req.Params)
+	}
+
+	if req.ID == nil && req.JSONRPC == "2.0" && req.Method != "" {
+		return nil
+	}
+	return resp
+}
+
+func writeRPC(ctx *web.Context, v interface{}) {
+	p, err := json.Marshal(v)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	ctx.SetHeader("Content-Type", "application/json", true)
+	ctx.Write(p)
+}
+
+// rpcParams decodes the params object of a call
+func rpcParams(params json.RawMessage, v interface{}) *rpcError {
+	if len(params) == 0 {
+		return newRPCError(rpcInvalidParams, "Missing params")
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return newRPCError(rpcInvalidParams, err.Error())
+	}
+	return nil
+}
+
+type rpcDBlock struct {
+	KeyMR          string
+	PrevBlockKeyMR string
+	SequenceNumber uint32
+	Timestamp      uint32
+	EntryBlockList []rpcEBlockAddr
+}
+
+type rpcEBlockAddr struct {
+	ChainID string
+	KeyMR   string
+}
+
+func newRPCDBlock(block *common.DirectoryBlock) *rpcDBlock {
+	d := new(rpcDBlock)
+	if block.KeyMR != nil {
+		d.KeyMR = block.KeyMR.String()
+	}
+	d.PrevBlockKeyMR = block.Header.PrevKeyMR.String()
+	d.SequenceNumber = block.Header.DBHeight
+	d.Timestamp = block.Header.Timestamp * 60
+	d.EntryBlockList = make([]rpcEBlockAddr, 0, len(block.DBEntries))
+	for _, v := range block.DBEntries {
+		d.EntryBlockList = append(d.EntryBlockList, rpcEBlockAddr{v.ChainID.String(), v.KeyMR.String()})
+	}
+	return d
+}
+
+func rpcDirectoryBlockHead(params json.RawMessage) (interface{}, *rpcError) {
+	block, err := factomapi.DBlockHead()
+	if err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+	return newRPCDBlock(block), nil
+}
+
+func rpcDirectoryBlock(params json.RawMessage) (interface{}, *rpcError) {
+	p := new(struct{ KeyMR string })
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	block, err := factomapi.DBlockByKeyMR(p.KeyMR)
+	if err != nil || block == nil {
+		return nil, newRPCError(rpcInternalError, "DBlock not found")
+	}
+	block.BuildKeyMerkleRoot()
+	return newRPCDBlock(block), nil
+}
+
+func rpcEntryBlock(params json.RawMessage) (interface{}, *rpcError) {
+	p := new(struct{ KeyMR string })
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	block, err := factomapi.EBlockByKeyMR(p.KeyMR)
+	if err != nil || block == nil {
+		return nil, newRPCError(rpcInternalError, "EBlock not found")
+	}
+
+	type eblock struct {
+		BlockSequenceNumber uint32
+		ChainID             string
+		PrevKeyMR           string
+		DBHeight            uint32
+		EntryList           []string
+	}
+	e := &eblock{
+		BlockSequenceNumber: block.Header.EBSequence,
+		ChainID:             block.Header.ChainID.String(),
+		PrevKeyMR:           block.Header.PrevKeyMR.String(),
+		DBHeight:            block.Header.EBHeight,
+		EntryList:           make([]string, 0, len(block.Body.EBEntries)),
+	}
+	for _, v := range block.Body.EBEntries {
+		e.EntryList = append(e.EntryList, v.String())
+	}
+	return e, nil
+}
+
+func rpcEntry(params json.RawMessage) (interface{}, *rpcError) {
+	p := new(struct{ Hash string })
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	entry, err := factomapi.EntryByHash(p.Hash)
+	if err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+
+	type rpcEntry struct {
+		ChainID string
+		Content string
+		ExtIDs  []string
+	}
+	e := &rpcEntry{ChainID: entry.ChainID.String(), Content: hex.EncodeToString(entry.Content)}
+	for _, v := range entry.ExtIDs {
+		e.ExtIDs = append(e.ExtIDs, hex.EncodeToString(v))
+	}
+	return e, nil
+}
+
+func rpcChainHead(params json.RawMessage) (interface{}, *rpcError) {
+	p := new(struct{ ChainID string })
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	mr, err := factomapi.ChainHead(p.ChainID)
+	if err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+	return struct{ ChainHead string }{mr.String()}, nil
+}
+
+func rpcChainEntries(params json.RawMessage) (interface{}, *rpcError) {
+	p := new(struct {
+		ChainID    string
+		EBSequence *uint32
+		Offset     int
+		Limit      int
+		Order      string
+	})
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	if p.Offset < 0 || p.Limit < 0 || (p.Order != "" && p.Order != "asc" && p.Order != "desc") {
+		return nil, newRPCError(rpcInvalidParams, "Invalid offset, limit or order")
+	}
+	if p.EBSequence == nil && p.Offset != 0 {
+		return nil, newRPCError(rpcInvalidParams, "The offset needs an EBSequence")
+	}
+	if p.Limit == 0 {
+		p.Limit = defaultPageLimit
+	}
+	if p.Limit > maxPageLimit {
+		p.Limit = maxPageLimit
+	}
+
+	var cursor *factomapi.ChainCursor
+	if p.EBSequence != nil {
+		cursor = &factomapi.ChainCursor{EBSequence: *p.EBSequence, Offset: p.Offset}
+	}
+	entries, next, count, err := factomapi.ChainEntries(p.ChainID, cursor, p.Limit, p.Order == "desc")
+	if err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+
+	type chainEntry struct {
+		EntryHash  string
+		EBSequence uint32
+		DBHeight   uint32
+	}
+	type chainEntries struct {
+		ChainID string
+		EBlocks uint32
+		Limit   int
+		Entries []chainEntry
+		Next    *factomapi.ChainCursor
+	}
+	c := &chainEntries{ChainID: p.ChainID, EBlocks: count, Limit: p.Limit, Next: next}
+	c.Entries = make([]chainEntry, 0, len(entries))
+	for _, v := range entries {
+		c.Entries = append(c.Entries, chainEntry{v.EntryHash.String(), v.EBSequence, v.DBHeight})
+	}
+	return c, nil
+}
+
+func rpcEntryCreditBalance(params json.RawMessage) (interface{}, *rpcError) {
+	p := new(struct{ Address string })
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	if adr, err := hex.DecodeString(p.Address); err != nil || len(adr) != common.HASH_LENGTH {
+		return nil, newRPCError(rpcInvalidParams, "Invalid Address")
+	}
+	bal, err := factomapi.ECBalance(p.Address)
+	if err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+	return struct{ Balance uint32 }{bal}, nil
+}
+
+func rpcFactoidBalance(params json.RawMessage) (interface{}, *rpcError) {
+	p := new(struct{ Address string })
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	adr, err := hex.DecodeString(p.Address)
+	if err != nil || len(adr) != common.HASH_LENGTH {
+		return nil, newRPCError(rpcInvalidParams, "Invalid Address")
+	}
+	return struct{ Balance int64 }{int64(common.FactoidState.GetBalance(fct.NewAddress(adr)))}, nil
+}
+
+func rpcBalances(params json.RawMessage) (interface{}, *rpcError) {
+	p := new(struct {
+		FactoidAddresses []string
+		ECAddresses      []string
+	})
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	if len(p.FactoidAddresses)+len(p.ECAddresses) > maxPageLimit {
+		return nil, newRPCError(rpcInvalidParams, "More than %d addresses", maxPageLimit)
+	}
+	b, err := factomapi.Balances(p.FactoidAddresses, p.ECAddresses)
+	if err != nil {
+		return nil, newRPCError(rpcInvalidParams, err.Error())
+	}
+	return b, nil
+}
+
+func rpcAnchorRecord(params json.RawMessage) (interface{}, *rpcError) {
+	p := new(struct{ Height uint32 })
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	a, err := factomapi.AnchorRecords(p.Height)
+	if err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+	return a, nil
+}
+
+// rpcMessage decodes the hex message param of the submit calls
+func rpcMessage(params json.RawMessage) ([]byte, *rpcError) {
+	p := new(struct{ Message string })
+	if e := rpcParams(params, p); e != nil {
+		return nil, e
+	}
+	data, err := hex.DecodeString(p.Message)
+	if err != nil {
+		return nil, newRPCError(rpcInvalidParams, err.Error())
+	}
+	return data, nil
+}
+
+func rpcCommitChain(params json.RawMessage) (interface{}, *rpcError) {
+	data, e := rpcMessage(params)
+	if e != nil {
+		return nil, e
+	}
+	commit := common.NewCommitChain()
+	if _, err := commit.UnmarshalBinaryData(data); err != nil {
+		return nil, newRPCError(rpcInvalidParams, err.Error())
+	}
+	if err := factomapi.CommitChain(commit); err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+	return struct{ EntryHash string }{commit.EntryHash.String()}, nil
+}
+
+func rpcCommitEntry(params json.RawMessage) (interface{}, *rpcError) {
+	data, e := rpcMessage(params)
+	if e != nil {
+		return nil, e
+	}
+	commit := common.NewCommitEntry()
+	if _, err := commit.UnmarshalBinaryData(data); err != nil {
+		return nil, newRPCError(rpcInvalidParams, err.Error())
+	}
+	if err := factomapi.CommitEntry(commit); err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+	return struct{ EntryHash string }{commit.EntryHash.String()}, nil
+}
+
+func rpcRevealEntry(params json.RawMessage) (interface{}, *rpcError) {
+	data, e := rpcMessage(params)
+	if e != nil {
+		return nil, e
+	}
+	entry := common.NewEntry()
+	if _, err := entry.UnmarshalBinaryData(data); err != nil {
+		return nil, newRPCError(rpcInvalidParams, err.Error())
+	}
+	if err := factomapi.RevealEntry(entry); err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+	return struct{ EntryHash string }{entry.Hash().String()}, nil
+}
+
+func rpcFactoidSubmit(params json.RawMessage) (interface{}, *rpcError) {
+	data, e := rpcMessage(params)
+	if e != nil {
+		return nil, e
+	}
+	t := new(fct.Transaction)
+	if _, err := t.UnmarshalBinaryData(data); err != nil {
+		return nil, newRPCError(rpcInvalidParams, err.Error())
+	}
+	if err := common.FactoidState.Validate(1, t); err != nil {
+		return nil, newRPCError(rpcInvalidParams, err.Error())
+	}
+	if err := factomapi.FactoidTX(t); err != nil {
+		return nil, newRPCError(rpcInternalError, err.Error())
+	}
+	return struct{ TxID string }{hex.EncodeToString(t.GetHash().Bytes())}, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	tlsEnabled  = cfg.TLSEnabled
+	tlsCertFile = cfg.TLSCertFile
+	tlsKeyFile  = cfg.TLSKeyFile
+	corsOrigins = allowedOrigins(cfg.CORSAllowedOrigin)
+
+	listener net.Listener
+)
+
+// listen returns the listener of the server, serving https if TLS is enabled
+func listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if !tlsEnabled {
+		return l, nil
+	}
+
+	cert, err := serverCertificate()
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	return tls.NewListener(l, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// serverCertificate loads the configured certificate, or creates a self
+// signed one for development when no certificate is configured
+func serverCertificate() (tls.Certificate, error) {
+	if tlsCertFile != "" || tlsKeyFile != "" {
+		return tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+	}
+
+	cert, err := selfSignedCertificate()
+	if err != nil {
+		return cert, err
+	}
+	fp := sha256.Sum256(cert.Certificate[0])
+	wsLog.Warning("No TLS certificate configured, using a self signed one with SHA-256 fingerprint ",
+		hex.EncodeToString(fp[:]))
+	return cert, nil
+}
+
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{applicationName}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// allowedOrigins returns the configured CORS origins without the empty ones
+func allowedOrigins(origins []string) []string {
+	allowed := make([]string, 0, len(origins))
+	for _, o := range origins {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			allowed = append(allowed, o)
+		}
+	}
+	return allowed
+}
+
+func corsAllowed(origin string) bool {
+	for _, o := range corsOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// withCORS lets browser apps of the allowed origins call the handler, and
+// answers their preflight requests
+func withCORS(h http.Handler) http.Handler {
+	if len(corsOrigins) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !corsAllowed(origin) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
+
+		if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Factom-Key")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(204)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,194 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/factomapi"
+	"github.com/FactomProject/web"
+)
+
+// The unsigned commits and factoid transactions are returned as a
+// common.SigningRequest, signed offline with factom-sign, and posted back
+// to /v1/submit-signed.
+
+func handleUnsignedCommitChain(ctx *web.Context) {
+	handleUnsignedCommit(ctx, factomapi.UnsignedCommitChain)
+}
+
+func handleUnsignedCommitEntry(ctx *web.Context) {
+	handleUnsignedCommit(ctx, factomapi.UnsignedCommitEntry)
+}
+
+func handleUnsignedCommit(ctx *web.Context, unsigned func(e *common.Entry, ecPubKey string) (*common.SigningRequest, error)) {
+	type commit struct {
+		Entry    string
+		ECPubKey string
+	}
+
+	c := new(commit)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, c); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	entry := common.NewEntry()
+	if p, err := hex.DecodeString(c.Entry); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else if _, err := entry.UnmarshalBinaryData(p); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	r, err := unsigned(entry, c.ECPubKey)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	if p, err := json.Marshal(r); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleUnsignedFactoidTx(ctx *web.Context) {
+	type factoidTx struct {
+		Inputs    []factomapi.FactoidInput
+		Outputs   []factomapi.FactoidIO
+		ECOutputs []factomapi.FactoidIO
+	}
+
+	t := new(factoidTx)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, t); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	r, err := factomapi.UnsignedFactoidTx(t.Inputs, t.Outputs, t.ECOutputs)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	if p, err := json.Marshal(r); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// handleBuildFactoidTx returns the request to sign the transaction paying
+// the outputs from inputs selected among the public keys, with the fee and
+// the change
+func handleBuildFactoidTx(ctx *web.Context) {
+	b := factomapi.NewTxBuilder()
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, b); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	r, err := b.Build()
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	if p, err := json.Marshal(r); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// handleSubmitSigned submits a signed common.SigningRequest, and returns the
+// entry hash of a commit or the txid of a factoid transaction
+func handleSubmitSigned(ctx *web.Context) {
+	r := new(common.SigningRequest)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, r); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	type submitted struct {
+		Response string
+		Success  bool
+		ID       string `json:",omitempty"`
+	}
+	s := submitted{Response: "Successfully submitted the " + r.Type, Success: true}
+	id, err := factomapi.SubmitSigned(r)
+	if err != nil {
+		wsLog.Error(err)
+		s = submitted{Response: err.Error(), Success: false}
+	} else {
+		s.ID = id
+	}
+	if p, err := json.Marshal(s); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
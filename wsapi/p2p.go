@@ -0,0 +1,133 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/FactomProject/FactomCode/p2p"
+	"github.com/FactomProject/web"
+)
+
+func handleBans(ctx *web.Context) {
+	if p, err := json.Marshal(p2p.Bans()); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// handleBan bans an IP for Seconds, or the default ban duration if 0
+func handleBan(ctx *web.Context) {
+	type ban struct {
+		IP      string
+		Seconds int
+		Reason  string
+	}
+
+	b := new(ban)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, b); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+	if net.ParseIP(b.IP) == nil || b.Seconds < 0 {
+		err := fmt.Errorf("Invalid IP or ban duration")
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	if b.Reason == "" {
+		b.Reason = "banned by the admin"
+	}
+
+	p2p.Ban(b.IP, time.Duration(b.Seconds)*time.Second, b.Reason)
+	handleBans(ctx)
+}
+
+func handleUnban(ctx *web.Context, ip string) {
+	if !p2p.Unban(ip) {
+		err := fmt.Errorf("%s is not banned", ip)
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	handleBans(ctx)
+}
+
+// handlePeers returns the connected peers with their traffic, like the
+// getpeerinfo of bitcoind
+func handlePeers(ctx *web.Context) {
+	type peerInfo struct {
+		Addr          string
+		Inbound       bool
+		Federated     bool
+		ConnTime      int64
+		UptimeSeconds int64
+		LastSend      int64
+		LastRecv      int64
+		BytesSent     uint64
+		BytesRecv     uint64
+		MsgsSent      map[string]uint64
+		MsgsRecv      map[string]uint64
+		Useful        int
+		BanScore      int
+	}
+
+	now := time.Now()
+	peers := p2p.Slots().Peers()
+	info := make([]peerInfo, 0, len(peers))
+	for _, p := range peers {
+		info = append(info, peerInfo{
+			Addr:          p.Addr,
+			Inbound:       p.Inbound,
+			Federated:     p.Federated,
+			ConnTime:      p.Connected.Unix(),
+			UptimeSeconds: int64(now.Sub(p.Connected) / time.Second),
+			LastSend:      unixOrZero(p.LastSend),
+			LastRecv:      unixOrZero(p.LastRecv),
+			BytesSent:     p.BytesSent,
+			BytesRecv:     p.BytesRecv,
+			MsgsSent:      p.MsgsSent,
+			MsgsRecv:      p.MsgsRecv,
+			Useful:        p.Useful,
+			BanScore:      p2p.BanScore(p.Addr),
+		})
+	}
+
+	if p, err := json.Marshal(info); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// unixOrZero returns the unix time, or 0 for the zero time
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
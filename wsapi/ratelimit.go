@@ -0,0 +1,198 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/FactomCode/util"
+)
+
+const httpTooManyRequests = 429
+
+// idleBucketSweep is the number of requests after which the full buckets
+// of idle clients are swept, if they were not within the last minute
+const idleBucketSweep = 10000
+
+// bucket holds the requests a client may still make
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket per client allowing perMinute requests a
+// minute, with bursts of up to a minute's worth
+type rateLimiter struct {
+	sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*bucket
+	calls   int
+	swept   time.Time
+
+	Allowed uint64
+	Limited uint64
+}
+
+// newRateLimiter returns nil, meaning no limit, if perMinute is below 1
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute < 1 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(perMinute),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token for the client. If there is none it returns false and
+// the time until the next one.
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		l.Limited++
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	l.Allowed++
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled, so idle clients do not use
+// memory forever. It runs every minute, or sooner under many requests.
+func (l *rateLimiter) sweep(now time.Time) {
+	l.calls++
+	if l.calls < idleBucketSweep && now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.calls = 0
+	l.swept = now
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+func (l *rateLimiter) stats() (allowed, limited uint64, clients int) {
+	if l == nil {
+		return 0, 0, 0
+	}
+	l.Lock()
+	defer l.Unlock()
+	return l.Allowed, l.Limited, len(l.buckets)
+}
+
+var (
+	rateLimitMutex sync.RWMutex
+
+	// rateLimiters are the limits of each endpoint class, keyed by permission
+	rateLimiters = map[uint8]*rateLimiter{
+		util.PermRead:   newRateLimiter(cfg.ReadRequestsPerMinute),
+		util.PermSubmit: newRateLimiter(cfg.SubmitRequestsPerMinute),
+		util.PermAdmin:  newRateLimiter(cfg.AdminRequestsPerMinute),
+	}
+)
+
+// initRateLimits has the limits set again when the config is reloaded
+func initRateLimits() {
+	util.OnReload(func(c *util.FactomdConfig) error {
+		setRateLimits(c.Wsapi.ReadRequestsPerMinute, c.Wsapi.SubmitRequestsPerMinute, c.Wsapi.AdminRequestsPerMinute)
+		return nil
+	}, "Wsapi.ReadRequestsPerMinute", "Wsapi.SubmitRequestsPerMinute", "Wsapi.AdminRequestsPerMinute")
+}
+
+// setRateLimits replaces the limits of the endpoint classes. The clients
+// start again with full buckets.
+func setRateLimits(read, submit, admin int) {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	rateLimiters = map[uint8]*rateLimiter{
+		util.PermRead:   newRateLimiter(read),
+		util.PermSubmit: newRateLimiter(submit),
+		util.PermAdmin:  newRateLimiter(admin),
+	}
+}
+
+// rateLimiterOf returns the limit of the endpoint class
+func rateLimiterOf(perm uint8) *rateLimiter {
+	rateLimitMutex.RLock()
+	defer rateLimitMutex.RUnlock()
+	return rateLimiters[perm]
+}
+
+// rateLimitClient identifies the client of a request by its API key, or by
+// its IP address without a configured key, so made up keys do not get
+// buckets of their own
+func rateLimitClient(r *http.Request) string {
+	if key := requestAPIKey(r); key != "" {
+		authMutex.RLock()
+		known := apiKeys.Has(key)
+		authMutex.RUnlock()
+		if known {
+			return "key:" + key
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// rateLimit returns false and the time to wait if the client made too many
+// requests to the endpoint class
+func rateLimit(r *http.Request, perm uint8) (bool, time.Duration) {
+	return rateLimiterOf(perm).allow(rateLimitClient(r))
+}
+
+// RateLimitStats are the rate limiting counters of an endpoint class
+type RateLimitStats struct {
+	Class   string
+	Allowed uint64
+	Limited uint64
+	Clients int
+}
+
+// GetRateLimitStats returns the rate limiting counters of each endpoint class
+func GetRateLimitStats() []RateLimitStats {
+	stats := make([]RateLimitStats, 0, 3)
+	for _, perm := range []uint8{util.PermRead, util.PermSubmit, util.PermAdmin} {
+		allowed, limited, clients := rateLimiterOf(perm).stats()
+		stats = append(stats, RateLimitStats{
+			Class:   util.PermissionName(perm),
+			Allowed: allowed,
+			Limited: limited,
+			Clients: clients,
+		})
+	}
+	return stats
+}
@@ -0,0 +1,118 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"fmt"
+
+	"github.com/FactomProject/FactomCode/factomapi"
+	"github.com/FactomProject/FactomCode/process"
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/FactomProject/web"
+	"github.com/FactomProject/websocket"
+)
+
+// Buffer sizes of the subscription websockets
+const (
+	subscribeReadBuffer  = 1024
+	subscribeWriteBuffer = 4096
+)
+
+// subscribeRequest is sent by a client to change its subscriptions. Type is
+// one of dblock, entry, factoid-tx, ec-balance or minute. Key is the chain id,
+// address or entry credit public key in hex, or empty for any. The minute
+// events have no key.
+type subscribeRequest struct {
+	Action string // subscribe or unsubscribe
+	Type   string
+	Key    string
+}
+
+type subscribeResponse struct {
+	Response string
+	Success  bool
+}
+
+// handleSubscribe upgrades the request to a websocket and sends the events
+// the client subscribes to until it disconnects
+func handleSubscribe(ctx *web.Context) {
+	ws, err := websocket.Upgrade(ctx.ResponseWriter, ctx.Request, nil, subscribeReadBuffer, subscribeWriteBuffer)
+	if err != nil {
+		wsLog.Warning(ctx.Request.RemoteAddr, " subscribe: ", err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	defer ws.Close()
+
+	if _, err := authorize(ctx.Request, util.PermRead); err != nil {
+		wsLog.Warning(ctx.Request.RemoteAddr, " subscribe: ", err)
+		ws.WriteJSON(&subscribeResponse{Response: err.Error(), Success: false})
+		return
+	}
+	if ok, _ := rateLimit(ctx.Request, util.PermRead); !ok {
+		ws.WriteJSON(&subscribeResponse{Response: "Too many requests", Success: false})
+		return
+	}
+
+	sub := factomapi.Subscribe()
+	defer sub.Unsubscribe()
+
+	// responses and events are written by this goroutine only
+	responses := make(chan *subscribeResponse, 10)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		defer close(done)
+		for {
+			req := new(subscribeRequest)
+			if err := ws.ReadJSON(req); err != nil {
+				return
+			}
+			select {
+			case responses <- applySubscribeRequest(sub, req):
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case r := <-responses:
+			err = ws.WriteJSON(r)
+		case e := <-sub.C:
+			err = ws.WriteJSON(e)
+		}
+		if err != nil {
+			wsLog.Debug("Subscriber disconnected: ", err)
+			return
+		}
+	}
+}
+
+func applySubscribeRequest(sub *process.Subscription, req *subscribeRequest) *subscribeResponse {
+	switch req.Type {
+	case process.EventDirBlock, process.EventEntry, process.EventFactoidTx, process.EventECBalance,
+		process.EventMinute, process.EventStall:
+	default:
+		return &subscribeResponse{Response: fmt.Sprintf("Unknown event type: %s", req.Type), Success: false}
+	}
+
+	switch req.Action {
+	case "subscribe":
+		sub.AddFilter(req.Type, req.Key)
+	case "unsubscribe":
+		sub.RemoveFilter(req.Type, req.Key)
+	default:
+		return &subscribeResponse{Response: fmt.Sprintf("Unknown action: %s", req.Action), Success: false}
+	}
+	return &subscribeResponse{Response: req.Action + " " + req.Type + " " + req.Key, Success: true}
+}
@@ -0,0 +1,209 @@
+// Copyright 2015 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/FactomProject/FactomCode/common"
+	"github.com/FactomProject/FactomCode/util"
+	"github.com/FactomProject/FactomCode/wallet"
+	"github.com/FactomProject/web"
+)
+
+// The keys of the key store are encrypted at rest. They sign only once the
+// store is unlocked with its passphrase, and are wiped when it is locked
+// again, at most RelockSeconds later.
+
+var (
+	keyStore *wallet.KeyStore
+	// maxUnlock bounds the unlock timeout, 0 for no limit
+	maxUnlock time.Duration
+)
+
+// initKeyStore opens the configured key store, locked
+func initKeyStore() error {
+	c := util.ReadConfig().Wallet
+	maxUnlock = time.Duration(c.RelockSeconds) * time.Second
+	if c.KeyStore == "" {
+		return nil
+	}
+	s, err := wallet.OpenKeyStore(c.KeyStore)
+	if err != nil {
+		return err
+	}
+	keyStore = s
+	return nil
+}
+
+// unlockTimeout returns the timeout of an unlock requested for seconds,
+// bounded by maxUnlock
+func unlockTimeout(seconds int) time.Duration {
+	t := time.Duration(seconds) * time.Second
+	if maxUnlock > 0 && (t <= 0 || t > maxUnlock) {
+		t = maxUnlock
+	}
+	return t
+}
+
+// walletKeyStore writes an error if no key store is configured
+func walletKeyStore(ctx *web.Context) *wallet.KeyStore {
+	if keyStore == nil {
+		err := fmt.Errorf("No key store is configured")
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+	}
+	return keyStore
+}
+
+func handleWalletStatus(ctx *web.Context) {
+	s := walletKeyStore(ctx)
+	if s == nil {
+		return
+	}
+	if p, err := json.Marshal(s.Status()); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleWalletUnlock(ctx *web.Context) {
+	type unlock struct {
+		Passphrase     string
+		TimeoutSeconds int
+	}
+
+	s := walletKeyStore(ctx)
+	if s == nil {
+		return
+	}
+	u := new(unlock)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, u); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	if err := s.Unlock(u.Passphrase, unlockTimeout(u.TimeoutSeconds)); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	wsLog.Info("key store unlocked")
+	handleWalletStatus(ctx)
+}
+
+func handleWalletLock(ctx *web.Context) {
+	s := walletKeyStore(ctx)
+	if s == nil {
+		return
+	}
+	s.Lock()
+	wsLog.Info("key store locked")
+	handleWalletStatus(ctx)
+}
+
+func handleWalletGenerateKey(ctx *web.Context) {
+	type generateKey struct {
+		Name string
+		Type string
+	}
+
+	s := walletKeyStore(ctx)
+	if s == nil {
+		return
+	}
+	g := new(generateKey)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, g); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	pub, err := s.GenerateKey(g.Name, g.Type)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	if p, err := json.Marshal(wallet.KeyInfo{Name: g.Name, Type: g.Type, PubKey: pub}); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// handleWalletSign signs a common.SigningRequest with the keys of the
+// unlocked key store, and returns it to be posted to /v1/submit-signed
+func handleWalletSign(ctx *web.Context) {
+	s := walletKeyStore(ctx)
+	if s == nil {
+		return
+	}
+	r := new(common.SigningRequest)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, r); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	if n, err := s.Sign(r); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else if n == 0 {
+		err := fmt.Errorf("None of the stored keys signs this request")
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	if p, err := json.Marshal(r); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
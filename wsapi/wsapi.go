@@ -5,14 +5,21 @@
 package wsapi
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
 
 	"github.com/FactomProject/FactomCode/common"
 	"github.com/FactomProject/FactomCode/database"
 	"github.com/FactomProject/FactomCode/factomapi"
+	"github.com/FactomProject/FactomCode/factomlog"
+	"github.com/FactomProject/FactomCode/metrics"
+	"github.com/FactomProject/FactomCode/process"
 	"github.com/FactomProject/FactomCode/util"
 	"github.com/FactomProject/btcd"
 	"github.com/FactomProject/btcd/wire"
@@ -21,8 +28,13 @@ import (
 )
 
 const (
-	httpOK  = 200
-	httpBad = 400
+	httpOK                 = 200
+	httpBad                = 400
+	httpServiceUnavailable = 503
+
+	// defaultPageLimit and maxPageLimit bound the entries returned in a page
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
 )
 
 var (
@@ -47,30 +59,95 @@ func Start(db database.Db, inMsgQ chan wire.FtmInternalMsg) {
 	factomapi.SetInMsgQueue(inMsgQ)
 	inMessageQ = inMsgQ
 
+	if err := initAuth(); err != nil {
+		wsLog.Error(err)
+		panic(err)
+	}
+	initRateLimits()
+	if err := initKeyStore(); err != nil {
+		wsLog.Error("key store not opened: ", err)
+	}
+
 	wsLog.Debug("Setting Handlers")
-	server.Post("/v1/commit-chain/?", handleCommitChain)
-	server.Post("/v1/reveal-chain/?", handleRevealChain)
-	server.Post("/v1/commit-entry/?", handleCommitEntry)
-	server.Post("/v1/reveal-entry/?", handleRevealEntry)
-	server.Post("/v1/factoid-submit/?", handleFactoidSubmit)
-	server.Get("/v1/directory-block-head/?", handleDirectoryBlockHead)
-	server.Get("/v1/get-raw-data/([^/]+)", handleGetRaw)
-	server.Get("/v1/directory-block-by-keymr/([^/]+)", handleDirectoryBlock)
-	server.Get("/v1/directory-block-height/?", handleDirectoryBlockHeight)
-	server.Get("/v1/entry-block-by-keymr/([^/]+)", handleEntryBlock)
-	server.Get("/v1/entry-by-hash/([^/]+)", handleEntry)
-	server.Get("/v1/chain-head/([^/]+)", handleChainHead)
-	server.Get("/v1/entry-credit-balance/([^/]+)", handleEntryCreditBalance)
-	server.Get("/v1/factoid-balance/([^/]+)", handleFactoidBalance)
-	server.Get("/v1/factoid-get-fee/", handleGetFee)
-	server.Get("/v1/properties/", handleProperties)
+	server.Post("/v1/commit-chain/?", protect(util.PermSubmit, handleCommitChain))
+	server.Post("/v1/reveal-chain/?", protect(util.PermSubmit, handleRevealChain))
+	server.Post("/v1/commit-entry/?", protect(util.PermSubmit, handleCommitEntry))
+	server.Post("/v1/reveal-entry/?", protect(util.PermSubmit, handleRevealEntry))
+	server.Post("/v1/factoid-submit/?", protect(util.PermSubmit, handleFactoidSubmit))
+	server.Post("/v1/unsigned-commit-chain/?", protect(util.PermRead, handleUnsignedCommitChain))
+	server.Post("/v1/unsigned-commit-entry/?", protect(util.PermRead, handleUnsignedCommitEntry))
+	server.Post("/v1/unsigned-factoid-tx/?", protect(util.PermRead, handleUnsignedFactoidTx))
+	server.Post("/v1/build-factoid-tx/?", protect(util.PermRead, handleBuildFactoidTx))
+	server.Post("/v1/submit-signed/?", protect(util.PermSubmit, handleSubmitSigned))
+	server.Get("/v1/directory-block-head/?", protect(util.PermRead, handleDirectoryBlockHead))
+	server.Get("/v1/get-raw-data/([^/]+)", protect(util.PermRead, handleGetRaw))
+	server.Get("/v1/directory-block-by-keymr/([^/]+)", protect(util.PermRead, handleDirectoryBlock))
+	server.Get("/v1/directory-block-height/?", protect(util.PermRead, handleDirectoryBlockHeight))
+	server.Get("/v1/entry-block-by-keymr/([^/]+)", protect(util.PermRead, handleEntryBlock))
+	server.Get("/v1/chain-entries/([^/]+)", protect(util.PermRead, handleChainEntries))
+	server.Get("/v1/entries-by-extid/?", protect(util.PermRead, handleEntriesByExtID))
+	server.Get("/v1/blocks/?", protect(util.PermRead, handleBlocks))
+	server.Get("/v1/entry-by-hash/([^/]+)", protect(util.PermRead, handleEntry))
+	server.Get("/v1/chain-head/([^/]+)", protect(util.PermRead, handleChainHead))
+	server.Get("/v1/receipt/([^/]+)", protect(util.PermRead, handleReceipt))
+	server.Get("/v1/anchor-proof/([^/]+)", protect(util.PermRead, handleAnchorProof))
+	server.Post("/v1/verify-anchor/?", protect(util.PermRead, handleVerifyAnchor))
+	server.Get("/v1/anchor-record/([^/]+)", protect(util.PermRead, handleAnchorRecord))
+	server.Get("/v1/entry-credit-balance/([^/]+)", protect(util.PermRead, handleEntryCreditBalance))
+	server.Get("/v1/entry-credit-history/([^/]+)", protect(util.PermRead, handleEntryCreditHistory))
+	server.Get("/v1/factoid-balance/([^/]+)", protect(util.PermRead, handleFactoidBalance))
+	server.Post("/v1/balances/?", protect(util.PermRead, handleBalances))
+	server.Get("/v1/factoid-get-fee/", protect(util.PermRead, handleGetFee))
+	server.Get("/v1/factoid-transaction/([^/]+)", protect(util.PermRead, handleFactoidTransaction))
+	server.Get("/v1/factoid-transaction-status/([^/]+)", protect(util.PermRead, handleFactoidTransactionStatus))
+	server.Get("/v1/factoid-transactions/([^/]+)", protect(util.PermRead, handleFactoidTransactions))
+	server.Get("/v1/exchange-rate/?", protect(util.PermRead, handleExchangeRate))
+	server.Get("/v1/server-identities/?", protect(util.PermRead, handleServerIdentities))
+	server.Get("/v1/pending-matches/?", protect(util.PermRead, handlePendingMatches))
+	server.Get("/v1/pending-commits/?", protect(util.PermRead, handlePendingCommits))
+	server.Get("/v1/pending-reveals/?", protect(util.PermRead, handlePendingReveals))
+	server.Get("/v1/pending-factoid-transactions/?", protect(util.PermRead, handlePendingFactoidTxs))
+	server.Get("/v1/state-hash/?", protect(util.PermRead, handleStateHash))
+	server.Get("/v1/state-hash/([^/]+)", protect(util.PermRead, handleStateHashByHeight))
+	server.Get("/v1/properties/", protect(util.PermRead, handleProperties))
+	// load balancers check the status without keys
+	server.Get("/v1/status/?", handleStatus)
+	// Prometheus scrapes the metrics without keys
+	server.Get("/metrics/?", handleMetrics)
+	server.Get("/v1/rate-limits/?", protect(util.PermAdmin, handleRateLimits))
+	server.Get("/v1/log-levels/?", protect(util.PermAdmin, handleLogLevels))
+	server.Post("/v1/log-level/?", protect(util.PermAdmin, handleLogLevel))
+	server.Post("/v1/reload-config/?", protect(util.PermAdmin, handleReloadConfig))
+	server.Get("/v1/peers/?", protect(util.PermAdmin, handlePeers))
+	server.Get("/v1/bans/?", protect(util.PermAdmin, handleBans))
+	server.Get("/v1/audit/?", protect(util.PermAdmin, handleAudit))
+	server.Post("/v1/ban/?", protect(util.PermAdmin, handleBan))
+	server.Post("/v1/unban/([^/]+)", protect(util.PermAdmin, handleUnban))
+	server.Get("/v1/wallet/status/?", protect(util.PermAdmin, handleWalletStatus))
+	server.Post("/v1/wallet/unlock/?", protect(util.PermAdmin, handleWalletUnlock))
+	server.Post("/v1/wallet/lock/?", protect(util.PermAdmin, handleWalletLock))
+	server.Post("/v1/wallet/generate-key/?", protect(util.PermAdmin, handleWalletGenerateKey))
+	server.Post("/v1/wallet/sign/?", protect(util.PermAdmin, handleWalletSign))
+	registerDiagnostics()
+	server.Get("/v1/subscribe/?", handleSubscribe)
+	// JSON-RPC 2.0 calls and batches check the permission of each call
+	server.Post("/v2/?", handleJSONRPC)
+
+	l, err := listen(fmt.Sprintf(":%d", portNumber))
+	if err != nil {
+		wsLog.Error(err)
+		panic(err)
+	}
+	listener = l
 
 	wsLog.Info("Starting server")
-	go server.Run(fmt.Sprintf(":%d", portNumber))
+	go http.Serve(listener, withCORS(server))
 }
 
 func Stop() {
-	server.Close()
+	if listener != nil {
+		listener.Close()
+	}
 }
 
 func handleProperties(ctx *web.Context) {
@@ -89,6 +166,38 @@ func handleProperties(ctx *web.Context) {
 	}
 }
 
+// handleStatus returns the node status, with 503 while the node is behind
+// the network
+func handleStatus(ctx *web.Context) {
+	s := factomapi.NodeStatus()
+
+	if p, err := json.Marshal(s); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.SetHeader("Content-Type", "application/json", true)
+		if !s.Synced {
+			ctx.WriteHeader(httpServiceUnavailable)
+		}
+		ctx.Write(p)
+	}
+}
+
+// handleMetrics writes the metrics of the node in the Prometheus text format
+func handleMetrics(ctx *web.Context) {
+	var buf bytes.Buffer
+	if err := metrics.WriteText(&buf); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	ctx.SetHeader("Content-Type", "text/plain; version=0.0.4", true)
+	ctx.Write(buf.Bytes())
+}
+
 func handleCommitChain(ctx *web.Context) {
 	type commitchain struct {
 		CommitChainMsg string
@@ -336,7 +445,16 @@ func handleEntryBlock(ctx *web.Context, keymr string) {
 			PrevKeyMR           string
 			Timestamp           uint32
 		}
-		EntryList []entryaddr
+		EntryList    []entryaddr
+		TotalEntries int
+	}
+
+	offset, limit, descending, err := pageParams(ctx, 0)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
 	}
 
 	e := new(eblock)
@@ -382,6 +500,285 @@ func handleEntryBlock(ctx *web.Context, keymr string) {
 		}
 	}
 
+	// page through the entries
+	e.TotalEntries = len(e.EntryList)
+	if descending {
+		for i, j := 0, len(e.EntryList)-1; i < j; i, j = i+1, j-1 {
+			e.EntryList[i], e.EntryList[j] = e.EntryList[j], e.EntryList[i]
+		}
+	}
+	if offset >= len(e.EntryList) {
+		e.EntryList = make([]entryaddr, 0)
+	} else {
+		e.EntryList = e.EntryList[offset:]
+	}
+	if limit > 0 && limit < len(e.EntryList) {
+		e.EntryList = e.EntryList[:limit]
+	}
+
+	if p, err := json.Marshal(e); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleRateLimits(ctx *web.Context) {
+	if p, err := json.Marshal(GetRateLimitStats()); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleLogLevels(ctx *web.Context) {
+	if p, err := json.Marshal(factomlog.SubsystemLevels()); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// handleLogLevel sets the log level of a subsystem, or of all of them if the
+// Subsystem is "all"
+func handleLogLevel(ctx *web.Context) {
+	type logLevel struct {
+		Subsystem string
+		Level     string
+	}
+
+	l := new(logLevel)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, l); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+	if err := factomlog.SetSubsystemLevel(l.Subsystem, l.Level); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	wsLog.Info("log level of ", l.Subsystem, " set to ", l.Level)
+	handleLogLevels(ctx)
+}
+
+// handleReloadConfig reloads the config file, like a SIGHUP, and returns
+// which settings were applied and which need a restart
+func handleReloadConfig(ctx *web.Context) {
+	r, err := util.ReloadConfig()
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	wsLog.Info("config reloaded, applied ", r.Applied, ", restart required for ", r.RestartRequired)
+	if p, err := json.Marshal(r); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// handleChainEntries returns a page of the entries of a chain. The page
+// starts at the eblock and offset query params, the cursor returned as
+// Next by the previous page.
+func handleChainEntries(ctx *web.Context, chainid string) {
+	type chainEntry struct {
+		EntryHash  string
+		EBSequence uint32
+		DBHeight   uint32
+	}
+	type chainEntries struct {
+		ChainID string
+		EBlocks uint32
+		Limit   int
+		Entries []chainEntry
+		Next    *factomapi.ChainCursor
+	}
+
+	offset, limit, descending, err := pageParams(ctx, defaultPageLimit)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	var cursor *factomapi.ChainCursor
+	if v, ok := ctx.Params["eblock"]; ok {
+		seq, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(fmt.Sprintf("Invalid eblock: %s", v)))
+			return
+		}
+		cursor = &factomapi.ChainCursor{EBSequence: uint32(seq), Offset: offset}
+	} else if offset != 0 {
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte("The offset needs an eblock"))
+		return
+	}
+
+	entries, next, count, err := factomapi.ChainEntries(chainid, cursor, limit, descending)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	c := new(chainEntries)
+	c.ChainID = chainid
+	c.EBlocks = count
+	c.Limit = limit
+	c.Next = next
+	c.Entries = make([]chainEntry, 0, len(entries))
+	for _, v := range entries {
+		c.Entries = append(c.Entries, chainEntry{
+			EntryHash:  v.EntryHash.String(),
+			EBSequence: v.EBSequence,
+			DBHeight:   v.DBHeight,
+		})
+	}
+
+	if p, err := json.Marshal(c); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+// pageParams reads the offset, limit and order (asc or desc) query
+// parameters. The limit defaults to defaultLimit and is capped at
+// maxPageLimit; 0 means no limit.
+func pageParams(ctx *web.Context, defaultLimit int) (offset, limit int, descending bool, err error) {
+	limit = defaultLimit
+	if v, ok := ctx.Params["offset"]; ok {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, false, fmt.Errorf("Invalid offset: %s", v)
+		}
+	}
+	if v, ok := ctx.Params["limit"]; ok {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return 0, 0, false, fmt.Errorf("Invalid limit: %s", v)
+		}
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	switch ctx.Params["order"] {
+	case "", "asc":
+	case "desc":
+		descending = true
+	default:
+		return 0, 0, false, fmt.Errorf("Invalid order: %s", ctx.Params["order"])
+	}
+	return
+}
+
+// handleEntriesByExtID finds the entries having all of the hex extid query
+// params, optionally in the chainid chain and between the from and to dir
+// block heights
+func handleEntriesByExtID(ctx *web.Context) {
+	type extIDEntry struct {
+		ChainID   string
+		EntryHash string
+		DBHeight  uint32
+	}
+	type extIDEntries struct {
+		Total   int
+		Offset  int
+		Limit   int
+		Entries []extIDEntry
+	}
+
+	query := ctx.Request.URL.Query()
+	extIDs := make([][]byte, 0, len(query["extid"]))
+	for _, v := range query["extid"] {
+		p, err := hex.DecodeString(v)
+		if err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(fmt.Sprintf("Invalid extid: %s", v)))
+			return
+		}
+		extIDs = append(extIDs, p)
+	}
+
+	from, to := uint64(0), uint64(math.MaxUint32)
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = strconv.ParseUint(v, 10, 32); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(fmt.Sprintf("Invalid from height: %s", v)))
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = strconv.ParseUint(v, 10, 32); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(fmt.Sprintf("Invalid to height: %s", v)))
+			return
+		}
+	}
+
+	offset, limit, descending, err := pageParams(ctx, defaultPageLimit)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	refs, total, err := factomapi.EntriesByExtIDs(extIDs, query.Get("chainid"), uint32(from), uint32(to),
+		offset, limit, descending)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	e := new(extIDEntries)
+	e.Total = total
+	e.Offset = offset
+	e.Limit = limit
+	e.Entries = make([]extIDEntry, 0, len(refs))
+	for _, v := range refs {
+		e.Entries = append(e.Entries, extIDEntry{
+			ChainID:   v.ChainID.String(),
+			EntryHash: v.EntryHash.String(),
+			DBHeight:  v.DBHeight,
+		})
+	}
+
 	if p, err := json.Marshal(e); err != nil {
 		wsLog.Error(err)
 		ctx.WriteHeader(httpBad)
@@ -483,6 +880,48 @@ func handleEntryCreditBalance(ctx *web.Context, eckey string) {
 
 }
 
+func handleEntryCreditHistory(ctx *web.Context, eckey string) {
+	type ecHistory struct {
+		Address      string
+		Total        int
+		Offset       int
+		Limit        int
+		Transactions []*factomapi.ECTransaction
+	}
+
+	offset, limit, descending, err := pageParams(ctx, defaultPageLimit)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	txs, total, err := factomapi.ECHistory(eckey, offset, limit, descending)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	h := &ecHistory{
+		Address:      eckey,
+		Total:        total,
+		Offset:       offset,
+		Limit:        limit,
+		Transactions: txs,
+	}
+	if p, err := json.Marshal(h); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
 func handleFactoidBalance(ctx *web.Context, eckey string) {
 	type fbal struct {
 		Response string
@@ -510,6 +949,52 @@ func handleFactoidBalance(ctx *web.Context, eckey string) {
 
 }
 
+// handleBalances returns the balances of the factoid and entry credit
+// addresses of the request at once, with the dir block height they are at
+func handleBalances(ctx *web.Context) {
+	type addresses struct {
+		FactoidAddresses []string
+		ECAddresses      []string
+	}
+
+	a := new(addresses)
+	if p, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		if err := json.Unmarshal(p, a); err != nil {
+			wsLog.Error(err)
+			ctx.WriteHeader(httpBad)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+	if len(a.FactoidAddresses)+len(a.ECAddresses) > maxPageLimit {
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(fmt.Sprintf("More than %d addresses", maxPageLimit)))
+		return
+	}
+
+	b, err := factomapi.Balances(a.FactoidAddresses, a.ECAddresses)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	if p, err := json.Marshal(b); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
 func returnMsg(ctx *web.Context, msg string, success bool) {
 	type rtn struct {
 		Response string
@@ -564,8 +1049,110 @@ func handleFactoidSubmit(ctx *web.Context) {
 
 	inMessageQ <- msg
 
-	returnMsg(ctx, "Successfully submitted the transaction", true)
+	// the txid lets the client follow the transaction status
+	type submitted struct {
+		Response string
+		Success  bool
+		TxID     string
+	}
+	r := submitted{
+		Response: "Successfully submitted the transaction",
+		Success:  true,
+		TxID:     hex.EncodeToString(msg.Transaction.GetHash().Bytes()),
+	}
+	if p, err := json.Marshal(r); err != nil {
+		wsLog.Error(err)
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleFactoidTransaction(ctx *web.Context, txid string) {
+	tx, err := factomapi.FactoidTxByID(txid)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	if p, err := json.Marshal(tx); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleFactoidTransactionStatus(ctx *web.Context, txid string) {
+	type txStatus struct {
+		TxID     string
+		Status   string
+		DBHeight uint32 `json:",omitempty"`
+	}
+
+	status, height, err := factomapi.FactoidTxStatus(txid)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
 
+	s := &txStatus{TxID: txid, Status: status, DBHeight: height}
+	if p, err := json.Marshal(s); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleFactoidTransactions(ctx *web.Context, address string) {
+	type addressTxs struct {
+		Address      string
+		Total        int
+		Offset       int
+		Limit        int
+		Transactions []*factomapi.FactoidTx
+	}
+
+	offset, limit, descending, err := pageParams(ctx, defaultPageLimit)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	txs, total, err := factomapi.FactoidTxsByAddress(address, offset, limit, descending)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	a := &addressTxs{
+		Address:      address,
+		Total:        total,
+		Offset:       offset,
+		Limit:        limit,
+		Transactions: txs,
+	}
+	if p, err := json.Marshal(a); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
 }
 
 func handleGetFee(ctx *web.Context) {
@@ -581,6 +1168,186 @@ func handleGetFee(ctx *web.Context) {
 	}
 }
 
+func handleExchangeRate(ctx *web.Context) {
+	type pendingRate struct {
+		Rate             uint64
+		ActivationHeight uint32
+		PubKey           string
+	}
+	type exchangeRate struct {
+		Rate    uint64
+		Pending []pendingRate
+	}
+
+	rate, pending := factomapi.ExchangeRate()
+	e := new(exchangeRate)
+	e.Rate = rate
+	e.Pending = make([]pendingRate, 0, len(pending))
+	for _, p := range pending {
+		e.Pending = append(e.Pending, pendingRate{
+			Rate:             p.Rate,
+			ActivationHeight: p.ActivationHeight,
+			PubKey:           hex.EncodeToString(p.PubKey[:]),
+		})
+	}
+
+	if p, err := json.Marshal(e); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleServerIdentities(ctx *web.Context) {
+	if p, err := json.Marshal(factomapi.ServerIdentities()); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handlePendingMatches(ctx *web.Context) {
+	type pendingMatch struct {
+		EntryHash string
+		Timestamp int64
+	}
+	type pendingMatches struct {
+		Commits []pendingMatch
+		Reveals []pendingMatch
+	}
+
+	pendings, err := factomapi.PendingMatches()
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	m := new(pendingMatches)
+	m.Commits = make([]pendingMatch, 0)
+	m.Reveals = make([]pendingMatch, 0)
+	for _, p := range pendings {
+		pm := pendingMatch{EntryHash: p.EntryHash.String(), Timestamp: p.Timestamp}
+		if p.IsReveal() {
+			m.Reveals = append(m.Reveals, pm)
+		} else {
+			m.Commits = append(m.Commits, pm)
+		}
+	}
+
+	if p, err := json.Marshal(m); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleReceipt(ctx *web.Context, hash string) {
+	r, err := factomapi.Receipt(hash)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	if p, err := json.Marshal(r); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handlePendingCommits(ctx *web.Context) {
+	writePending(ctx, factomapi.Pending().Commits)
+}
+
+func handlePendingReveals(ctx *web.Context) {
+	writePending(ctx, factomapi.Pending().Reveals)
+}
+
+func handlePendingFactoidTxs(ctx *web.Context) {
+	writePending(ctx, factomapi.Pending().FactoidTxs)
+}
+
+func writePending(ctx *web.Context, items []*process.PendingItem) {
+	if p, err := json.Marshal(items); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+type stateHash struct {
+	Height    uint32
+	StateHash string
+}
+
+func handleStateHash(ctx *web.Context) {
+	height, h := factomapi.LatestStateHash()
+	if h == nil {
+		err := fmt.Errorf("State hash not available yet")
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	s := &stateHash{Height: height, StateHash: h.String()}
+	if p, err := json.Marshal(s); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
+func handleStateHashByHeight(ctx *web.Context, height string) {
+	n, err := strconv.ParseUint(height, 10, 32)
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	h, err := factomapi.StateHash(uint32(n))
+	if err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+
+	s := &stateHash{Height: uint32(n), StateHash: h.String()}
+	if p, err := json.Marshal(s); err != nil {
+		wsLog.Error(err)
+		ctx.WriteHeader(httpBad)
+		ctx.Write([]byte(err.Error()))
+		return
+	} else {
+		ctx.Write(p)
+	}
+}
+
 func handleGetRaw(ctx *web.Context, hashkey string) {
 	type rawData struct {
 		Data string